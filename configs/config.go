@@ -1,41 +1,135 @@
 package configs
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 // AppConfig holds the application configuration values
 type AppConfig struct {
-	AppPort              string
-	CorsOrigin           string
-	GinMode              string
-	SwaggerHostConfig    string
-	ManagementPort       string
-	MongoURI             string
-	MongoMinPool         uint64
-	MongoMaxPool         uint64
-	JWTSecret            string
-	AddressAPI           string
-	PostgresDSN          string
-	PostgresPoolMax      int
-	MAILPIT_HOST         string
-	MAILPIT_PORT         int
-	S3_BUCKET_NAME       string
-	S3_REGION            string
-	S3_ACCESS_KEY_ID     string
-	S3_SECRET_ACCESS_KEY string
-	MAILGUN_API_KEY      string
-	MAILGUN_DOMAIN       string
-	ENVIRONMENT          string
-	FrontendURL          string
-	POSTGRES_DSN_TEST    string
-	WS_PORT              string
-	REDIS_ADDRESS        string
-	DOCUMENT_DB_DSN      string
+	AppPort               string
+	CorsOrigin            string
+	GinMode               string
+	SwaggerHostConfig     string
+	ManagementPort        string
+	MongoURI              string
+	MongoMinPool          uint64
+	MongoMaxPool          uint64
+	JWTSecret             string
+	AddressAPI            string
+	PostgresDSN           string
+	PostgresPoolMax       int
+	PostgresReplicaDSNs   []string
+	ReplicaHealthInterval time.Duration
+	ReadYourWrites        bool
+	MAILPIT_HOST          string
+	MAILPIT_PORT          int
+	S3_BUCKET_NAME        string
+	S3_REGION             string
+	S3_ACCESS_KEY_ID      string
+	S3_SECRET_ACCESS_KEY  string
+	S3Endpoint            string
+	S3UsePathStyle        bool
+	S3PublicURL           string
+	S3Encryption          string
+	S3KMSKeyID            string
+	MAILGUN_API_KEY       string
+	MAILGUN_DOMAIN        string
+	ENVIRONMENT           string
+	FrontendURL           string
+	POSTGRES_DSN_TEST     string
+	WS_PORT               string
+	REDIS_ADDRESS         string
+	DOCUMENT_DB_DSN       string
+	DBQueryTimeout        time.Duration
+	StorageProvider       string
+	LocalStoragePath      string
+	LocalStorageURL       string
+	LocalStorageSecret    string
+	LocalStoragePublic    []string
+	StorageKeySecret      string
+	StorageTimeout        time.Duration
+	PresignedURLTTL       time.Duration
+	ShareLinkURL          string
+	ShareLinkTTL          time.Duration
+	ShareLinkMaxTTL       time.Duration
+	FileUploadPolicy      UploadPolicyConfig
+	AvatarUploadPolicy    UploadPolicyConfig
+	AvatarSizes           []int
+	TusStagingPath        string
+	TusMaxSize            int64
+	TusUploadTTL          time.Duration
+	TusChunkTimeout       time.Duration
+	ScannerProvider       string
+	ClamAVAddress         string
+	ScannerTimeout        time.Duration
+	ProcessingWorkers     int
+	ProcessingInterval    time.Duration
+	ProcessingTimeout     time.Duration
+	ProcessingMaxAttempts int
+	FileVersioning        bool
+	LifecycleInterval     time.Duration
+	OrphanGracePeriod     time.Duration
+	OrphanAction          string
+	QuarantineTTL         time.Duration
+	RetentionPolicies     map[string]time.Duration
+	ReconcileExclude      []string
+	EmailTimeout          time.Duration
+	EmailProviders        []string
+	BreakerThreshold      int
+	BreakerCooldown       time.Duration
+	SMTPHost              string
+	SMTPPort              int
+	SMTPUsername          string
+	SMTPPassword          string
+	SMTPSecurity          string
+	SESRegion             string
+	SESEndpoint           string
+	SESAccessKeyID        string
+	SESSecretAccessKey    string
+	MailpitAPIURL         string
+	MailgunAPIBase        string
+	MailgunSigningKey     string
+	WebhookMaxAge         time.Duration
+	EmailSender           string
+	EmailTemplatesPath    string
+	EmailDefaultLocale    string
+	NotifyTimeout         time.Duration
+	SMSProvider           string
+	TwilioAccountSID      string
+	TwilioAuthToken       string
+	TwilioFrom            string
+	WhatsAppProvider      string
+	WhatsAppAPIBase       string
+	WhatsAppPhoneID       string
+	WhatsAppToken         string
+	WhatsAppTemplate      string
+	PushProvider          string
+	VAPIDPublicKey        string
+	VAPIDPrivateKey       string
+	VAPIDSubject          string
+	OutboxWorkers         int
+	OutboxInterval        time.Duration
+	OutboxMaxAttempts     int
+	OutboxRetention       time.Duration
+	QueueTimeout          time.Duration
+	WSBroker              string
+	WSRedisChannel        string
+	WSPingInterval        time.Duration
+	WSPongTimeout         time.Duration
+	WSWriteTimeout        time.Duration
+	WSSendQueue           int
+	MigrateOnStartup      bool
+	TenancyMode           string
+	DefaultTenantUUID     string
+	AdminAPIKey           string
 }
 
 // LoadConfig initializes the AppConfig struct with values from environment variables
@@ -53,7 +147,7 @@ func LoadConfig(envFilePath string) (*AppConfig, error) {
 	if err != nil {
 		return nil, err
 	}
-	mailpitPort, err := strconv.Atoi(os.Getenv("MAILPIT_PORT"))
+	mailpitPort, err := parseUint(os.Getenv("MAILPIT_PORT"), 1025)
 	if err != nil {
 		return nil, err
 	}
@@ -61,36 +155,397 @@ func LoadConfig(envFilePath string) (*AppConfig, error) {
 	if err != nil {
 		postgresPoolMax = 25
 	}
+	replicaHealthInterval, err := parseDuration(os.Getenv("REPLICA_HEALTH_INTERVAL"), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	dbQueryTimeout, err := parseDuration(os.Getenv("DB_QUERY_TIMEOUT"), 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	storageTimeout, err := parseDuration(os.Getenv("STORAGE_TIMEOUT"), 60*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	presignedURLTTL, err := parseDuration(os.Getenv("PRESIGNED_URL_TTL"), 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	shareLinkTTL, err := parseDuration(os.Getenv("FILE_SHARE_LINK_TTL"), 7*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	shareLinkMaxTTL, err := parseDuration(os.Getenv("FILE_SHARE_LINK_MAX_TTL"), 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	fileUploadPolicy, err := loadUploadPolicy("UPLOAD_FILES", UploadPolicyConfig{
+		MaxBytes:   1 << 30,
+		MIMETypes:  []string{"application/pdf", "image/*", "text/plain", "application/zip", "application/msword", "application/vnd.ms-excel", "application/vnd.ms-powerpoint", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "application/vnd.openxmlformats-officedocument.presentationml.presentation", "application/vnd.oasis.opendocument.text", "application/vnd.oasis.opendocument.spreadsheet"},
+		Extensions: []string{"pdf", "png", "jpg", "jpeg", "gif", "webp", "txt", "csv", "zip", "doc", "docx", "xls", "xlsx", "ppt", "pptx", "odt", "ods"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	avatarUploadPolicy, err := loadUploadPolicy("UPLOAD_AVATARS", UploadPolicyConfig{
+		MaxBytes:       5 << 20,
+		MIMETypes:      []string{"image/jpeg", "image/png", "image/webp"},
+		Extensions:     []string{"jpg", "jpeg", "png", "webp"},
+		MaxImageWidth:  4096,
+		MaxImageHeight: 4096,
+	})
+	if err != nil {
+		return nil, err
+	}
+	avatarSizes, err := parseSizes(os.Getenv("AVATAR_SIZES"), []int{64, 256, 512})
+	if err != nil {
+		return nil, err
+	}
+	tusMaxSize, err := parseUint(os.Getenv("TUS_MAX_SIZE"), 5<<30)
+	if err != nil {
+		return nil, err
+	}
+	tusUploadTTL, err := parseDuration(os.Getenv("TUS_UPLOAD_TTL"), 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	tusChunkTimeout, err := parseDuration(os.Getenv("TUS_CHUNK_TIMEOUT"), 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	scannerTimeout, err := parseDuration(os.Getenv("SCANNER_TIMEOUT"), time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	processingWorkers, err := parseUint(os.Getenv("FILE_PROCESSING_WORKERS"), 2)
+	if err != nil {
+		return nil, err
+	}
+	processingInterval, err := parseDuration(os.Getenv("FILE_PROCESSING_INTERVAL"), 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	processingTimeout, err := parseDuration(os.Getenv("FILE_PROCESSING_TIMEOUT"), 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	processingMaxAttempts, err := parseUint(os.Getenv("FILE_PROCESSING_MAX_ATTEMPTS"), 5)
+	if err != nil {
+		return nil, err
+	}
+	s3Encryption := os.Getenv("S3_SSE")
+	if s3Encryption == "" && os.Getenv("S3_SSE_KMS_KEY_ID") != "" {
+		s3Encryption = "aws:kms"
+	}
+	if s3Encryption != "" && s3Encryption != "AES256" && s3Encryption != "aws:kms" {
+		return nil, fmt.Errorf("invalid S3 server-side encryption %q", s3Encryption)
+	}
+	lifecycleInterval, err := parseDuration(os.Getenv("STORAGE_LIFECYCLE_INTERVAL"), 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	orphanGracePeriod, err := parseDuration(os.Getenv("STORAGE_ORPHAN_GRACE_PERIOD"), 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	orphanAction := getEnv("STORAGE_ORPHAN_ACTION", "quarantine")
+	if orphanAction != "quarantine" && orphanAction != "delete" {
+		return nil, fmt.Errorf("invalid storage orphan action %q", orphanAction)
+	}
+	quarantineTTL, err := parseDuration(os.Getenv("STORAGE_QUARANTINE_TTL"), 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	retentionPolicies, err := parseRetention(os.Getenv("STORAGE_RETENTION"))
+	if err != nil {
+		return nil, err
+	}
+	emailTimeout, err := parseDuration(os.Getenv("EMAIL_TIMEOUT"), 15*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	// development keeps sending to Mailpit and production to Mailgun unless told otherwise
+	defaultEmailProvider := "mailgun"
+	if os.Getenv("ENVIRONMENT") == "dev" {
+		defaultEmailProvider = "mailpit"
+	}
+	emailProviders := parseList(getEnv("EMAIL_PROVIDERS", defaultEmailProvider))
+	for _, provider := range emailProviders {
+		switch provider {
+		case "mailpit", "mailpit-api", "mailgun", "mailgun-api", "smtp", "ses":
+		default:
+			return nil, fmt.Errorf("invalid email provider %q", provider)
+		}
+	}
+	breakerThreshold, err := parseUint(os.Getenv("EMAIL_BREAKER_THRESHOLD"), 3)
+	if err != nil {
+		return nil, err
+	}
+	breakerCooldown, err := parseDuration(os.Getenv("EMAIL_BREAKER_COOLDOWN"), time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	smtpPort, err := parseUint(os.Getenv("SMTP_PORT"), 587)
+	if err != nil {
+		return nil, err
+	}
+	smtpSecurity := getEnv("SMTP_SECURITY", "starttls")
+	if smtpSecurity != "starttls" && smtpSecurity != "tls" && smtpSecurity != "none" {
+		return nil, fmt.Errorf("invalid smtp security %q", smtpSecurity)
+	}
+	webhookMaxAge, err := parseDuration(os.Getenv("EMAIL_WEBHOOK_MAX_AGE"), 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	notifyTimeout, err := parseDuration(os.Getenv("NOTIFICATION_TIMEOUT"), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	// the channels other than email are disabled unless a provider is set
+	smsProvider := os.Getenv("SMS_PROVIDER")
+	if err := checkChoice("sms provider", smsProvider, "", "fake", "twilio"); err != nil {
+		return nil, err
+	}
+	whatsAppProvider := os.Getenv("WHATSAPP_PROVIDER")
+	if err := checkChoice("whatsapp provider", whatsAppProvider, "", "fake", "cloud"); err != nil {
+		return nil, err
+	}
+	pushProvider := os.Getenv("PUSH_PROVIDER")
+	if err := checkChoice("push provider", pushProvider, "", "fake", "webpush"); err != nil {
+		return nil, err
+	}
+	outboxWorkers, err := parseUint(os.Getenv("EMAIL_OUTBOX_WORKERS"), 1)
+	if err != nil {
+		return nil, err
+	}
+	outboxInterval, err := parseDuration(os.Getenv("EMAIL_OUTBOX_INTERVAL"), 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	outboxMaxAttempts, err := parseUint(os.Getenv("EMAIL_OUTBOX_MAX_ATTEMPTS"), 8)
+	if err != nil {
+		return nil, err
+	}
+	outboxRetention, err := parseDuration(os.Getenv("EMAIL_OUTBOX_RETENTION"), 7*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	queueTimeout, err := parseDuration(os.Getenv("QUEUE_TIMEOUT"), 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	tenancyMode := getEnv("TENANCY_MODE", "row")
+	if err := checkChoice("tenancy mode", tenancyMode, "row", "schema"); err != nil {
+		return nil, err
+	}
+	// the pre-signed URLs of the local storage are the only access to its objects,
+	// so they are not signed with a secret shared with the tokens
+	storageProvider := getEnv("STORAGE_PROVIDER", "s3")
+	localStorageSecret := os.Getenv("LOCAL_STORAGE_SECRET")
+	if storageProvider == "local" && localStorageSecret == "" {
+		return nil, fmt.Errorf("LOCAL_STORAGE_SECRET is required by the local storage provider")
+	}
+	// the keys of the stored files are derived from their content with this secret,
+	// so it may neither be empty nor shared with the tokens
+	storageKeySecret := os.Getenv("STORAGE_KEY_SECRET")
+	if storageKeySecret == "" {
+		return nil, fmt.Errorf("STORAGE_KEY_SECRET is required")
+	}
+	wsBroker := getEnv("WS_BROKER", "local")
+	if err := checkChoice("websocket broker", wsBroker, "local", "redis"); err != nil {
+		return nil, err
+	}
+	wsPingInterval, err := parseDuration(os.Getenv("WS_PING_INTERVAL"), 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	wsPongTimeout, err := parseDuration(os.Getenv("WS_PONG_TIMEOUT"), 60*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	// a pong must be able to arrive before the connection is given up
+	if wsPongTimeout <= wsPingInterval {
+		return nil, fmt.Errorf("WS_PONG_TIMEOUT (%s) must be longer than WS_PING_INTERVAL (%s)", wsPongTimeout, wsPingInterval)
+	}
+	wsWriteTimeout, err := parseDuration(os.Getenv("WS_WRITE_TIMEOUT"), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	wsSendQueue, err := parseUint(os.Getenv("WS_SEND_QUEUE"), 64)
+	if err != nil {
+		return nil, err
+	}
 
 	return &AppConfig{
-		AppPort:              os.Getenv("APP_PORT"),
-		CorsOrigin:           os.Getenv("CORS_ORIGIN"),
-		GinMode:              os.Getenv("GIN_MODE"),
-		SwaggerHostConfig:    os.Getenv("SWAGGER_HOST_CFG"),
-		ManagementPort:       os.Getenv("MANAGEMENT_PORT"),
-		MongoURI:             os.Getenv("MONGO_URI"),
-		MongoMinPool:         minPool,
-		MongoMaxPool:         maxPool,
-		JWTSecret:            os.Getenv("JWT_SECRET"),
-		PostgresDSN:          os.Getenv("POSTGRES_DSN"),
-		PostgresPoolMax:      postgresPoolMax,
-		MAILPIT_HOST:         os.Getenv("MAILPIT_HOST"),
-		MAILPIT_PORT:         mailpitPort,
-		S3_BUCKET_NAME:       os.Getenv("S3_BUCKET_NAME"),
-		S3_REGION:            os.Getenv("S3_REGION"),
-		S3_ACCESS_KEY_ID:     os.Getenv("S3_ACCESS_KEY_ID"),
-		S3_SECRET_ACCESS_KEY: os.Getenv("S3_SECRET_ACCESS_KEY"),
-		MAILGUN_DOMAIN:       os.Getenv("MAILGUN_DOMAIN"),
-		MAILGUN_API_KEY:      os.Getenv("MAILGUN_API_KEY"),
-		ENVIRONMENT:          os.Getenv("ENVIRONMENT"),
-		FrontendURL:          os.Getenv("FRONTEND_URL"),
-		POSTGRES_DSN_TEST:    os.Getenv("POSTGRES_DSN_TEST"),
-		WS_PORT:              os.Getenv("WS_PORT"),
-		REDIS_ADDRESS:        os.Getenv("REDIS_ADDRESS"),
-		DOCUMENT_DB_DSN:      os.Getenv("DOCUMENT_DB_DSN"),
+		AppPort:               os.Getenv("APP_PORT"),
+		CorsOrigin:            os.Getenv("CORS_ORIGIN"),
+		GinMode:               os.Getenv("GIN_MODE"),
+		SwaggerHostConfig:     os.Getenv("SWAGGER_HOST_CFG"),
+		ManagementPort:        os.Getenv("MANAGEMENT_PORT"),
+		MongoURI:              os.Getenv("MONGO_URI"),
+		MongoMinPool:          minPool,
+		MongoMaxPool:          maxPool,
+		JWTSecret:             os.Getenv("JWT_SECRET"),
+		PostgresDSN:           os.Getenv("POSTGRES_DSN"),
+		PostgresPoolMax:       postgresPoolMax,
+		PostgresReplicaDSNs:   parseList(os.Getenv("POSTGRES_REPLICA_DSNS")),
+		ReplicaHealthInterval: replicaHealthInterval,
+		ReadYourWrites:        os.Getenv("DB_READ_YOUR_WRITES") != "false",
+		MAILPIT_HOST:          os.Getenv("MAILPIT_HOST"),
+		MAILPIT_PORT:          int(mailpitPort),
+		S3_BUCKET_NAME:        os.Getenv("S3_BUCKET_NAME"),
+		S3_REGION:             os.Getenv("S3_REGION"),
+		S3_ACCESS_KEY_ID:      os.Getenv("S3_ACCESS_KEY_ID"),
+		S3_SECRET_ACCESS_KEY:  os.Getenv("S3_SECRET_ACCESS_KEY"),
+		S3Endpoint:            os.Getenv("S3_ENDPOINT"),
+		S3UsePathStyle:        os.Getenv("S3_USE_PATH_STYLE") == "true",
+		S3PublicURL:           os.Getenv("S3_PUBLIC_URL"),
+		S3Encryption:          s3Encryption,
+		S3KMSKeyID:            os.Getenv("S3_SSE_KMS_KEY_ID"),
+		MAILGUN_DOMAIN:        os.Getenv("MAILGUN_DOMAIN"),
+		MAILGUN_API_KEY:       os.Getenv("MAILGUN_API_KEY"),
+		ENVIRONMENT:           os.Getenv("ENVIRONMENT"),
+		FrontendURL:           os.Getenv("FRONTEND_URL"),
+		POSTGRES_DSN_TEST:     os.Getenv("POSTGRES_DSN_TEST"),
+		WS_PORT:               os.Getenv("WS_PORT"),
+		REDIS_ADDRESS:         os.Getenv("REDIS_ADDRESS"),
+		DOCUMENT_DB_DSN:       os.Getenv("DOCUMENT_DB_DSN"),
+		DBQueryTimeout:        dbQueryTimeout,
+		StorageProvider:       storageProvider,
+		LocalStoragePath:      getEnv("LOCAL_STORAGE_PATH", "./storage"),
+		LocalStorageURL:       getEnv("LOCAL_STORAGE_URL", "http://localhost:8080/api/v1/storage/local"),
+		LocalStorageSecret:    localStorageSecret,
+		LocalStoragePublic:    parseList(getEnv("LOCAL_STORAGE_PUBLIC_PREFIXES", "avatars/")),
+		StorageKeySecret:      storageKeySecret,
+		StorageTimeout:        storageTimeout,
+		PresignedURLTTL:       presignedURLTTL,
+		ShareLinkURL:          getEnv("FILE_SHARE_LINK_URL", "http://localhost:8080/api/v1/shared"),
+		ShareLinkTTL:          shareLinkTTL,
+		ShareLinkMaxTTL:       shareLinkMaxTTL,
+		FileUploadPolicy:      fileUploadPolicy,
+		AvatarUploadPolicy:    avatarUploadPolicy,
+		AvatarSizes:           avatarSizes,
+		TusStagingPath:        getEnv("TUS_STAGING_PATH", "./storage/tus"),
+		TusMaxSize:            int64(tusMaxSize),
+		TusUploadTTL:          tusUploadTTL,
+		TusChunkTimeout:       tusChunkTimeout,
+		ScannerProvider:       getEnv("SCANNER_PROVIDER", "clamav"),
+		ClamAVAddress:         getEnv("CLAMAV_ADDRESS", "tcp://localhost:3310"),
+		ScannerTimeout:        scannerTimeout,
+		ProcessingWorkers:     int(processingWorkers),
+		ProcessingInterval:    processingInterval,
+		ProcessingTimeout:     processingTimeout,
+		ProcessingMaxAttempts: int(processingMaxAttempts),
+		FileVersioning:        os.Getenv("FILE_VERSIONING") == "true",
+		LifecycleInterval:     lifecycleInterval,
+		OrphanGracePeriod:     orphanGracePeriod,
+		OrphanAction:          orphanAction,
+		QuarantineTTL:         quarantineTTL,
+		RetentionPolicies:     retentionPolicies,
+		ReconcileExclude:      parseList(getEnv("STORAGE_RECONCILE_EXCLUDE", "avatars/,tus/")),
+		EmailTimeout:          emailTimeout,
+		EmailProviders:        emailProviders,
+		BreakerThreshold:      int(breakerThreshold),
+		BreakerCooldown:       breakerCooldown,
+		SMTPHost:              os.Getenv("SMTP_HOST"),
+		SMTPPort:              int(smtpPort),
+		SMTPUsername:          os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:          os.Getenv("SMTP_PASSWORD"),
+		SMTPSecurity:          smtpSecurity,
+		SESRegion:             getEnv("SES_REGION", "us-east-1"),
+		SESEndpoint:           os.Getenv("SES_ENDPOINT"),
+		SESAccessKeyID:        os.Getenv("SES_ACCESS_KEY_ID"),
+		SESSecretAccessKey:    os.Getenv("SES_SECRET_ACCESS_KEY"),
+		MailpitAPIURL:         getEnv("MAILPIT_API_URL", "http://localhost:8025/api/v1"),
+		MailgunAPIBase:        os.Getenv("MAILGUN_API_BASE"),
+		MailgunSigningKey:     os.Getenv("MAILGUN_WEBHOOK_SIGNING_KEY"),
+		WebhookMaxAge:         webhookMaxAge,
+		EmailSender:           getEnv("EMAIL_SENDER", "no-reply@company.com"),
+		EmailTemplatesPath:    os.Getenv("EMAIL_TEMPLATES_PATH"),
+		EmailDefaultLocale:    getEnv("EMAIL_DEFAULT_LOCALE", "pt-BR"),
+		NotifyTimeout:         notifyTimeout,
+		SMSProvider:           smsProvider,
+		TwilioAccountSID:      os.Getenv("TWILIO_ACCOUNT_SID"),
+		TwilioAuthToken:       os.Getenv("TWILIO_AUTH_TOKEN"),
+		TwilioFrom:            os.Getenv("TWILIO_FROM"),
+		WhatsAppProvider:      whatsAppProvider,
+		WhatsAppAPIBase:       getEnv("WHATSAPP_API_BASE", "https://graph.facebook.com/v21.0"),
+		WhatsAppPhoneID:       os.Getenv("WHATSAPP_PHONE_NUMBER_ID"),
+		WhatsAppToken:         os.Getenv("WHATSAPP_ACCESS_TOKEN"),
+		WhatsAppTemplate:      os.Getenv("WHATSAPP_TEMPLATE"),
+		PushProvider:          pushProvider,
+		VAPIDPublicKey:        os.Getenv("VAPID_PUBLIC_KEY"),
+		VAPIDPrivateKey:       os.Getenv("VAPID_PRIVATE_KEY"),
+		VAPIDSubject:          os.Getenv("VAPID_SUBJECT"),
+		OutboxWorkers:         int(outboxWorkers),
+		OutboxInterval:        outboxInterval,
+		OutboxMaxAttempts:     int(outboxMaxAttempts),
+		OutboxRetention:       outboxRetention,
+		QueueTimeout:          queueTimeout,
+		WSBroker:              wsBroker,
+		WSRedisChannel:        getEnv("WS_REDIS_CHANNEL", "ws:notifications"),
+		WSPingInterval:        wsPingInterval,
+		WSPongTimeout:         wsPongTimeout,
+		WSWriteTimeout:        wsWriteTimeout,
+		WSSendQueue:           max(int(wsSendQueue), 1),
+		MigrateOnStartup:      os.Getenv("MIGRATE_ON_STARTUP") == "true",
+		TenancyMode:           tenancyMode,
+		DefaultTenantUUID:     os.Getenv("DEFAULT_TENANT_UUID"),
+		AdminAPIKey:           os.Getenv("ADMIN_API_KEY"),
 	}, nil
 }
 
+// UploadPolicyConfig limits the files accepted for one purpose. Empty lists and
+// zero limits are not enforced
+type UploadPolicyConfig struct {
+	MaxBytes       int64
+	MIMETypes      []string
+	Extensions     []string
+	MaxImageWidth  int
+	MaxImageHeight int
+}
+
+// loadUploadPolicy reads the <prefix>_MAX_BYTES, <prefix>_MIME_TYPES, <prefix>_EXTENSIONS,
+// <prefix>_MAX_IMAGE_WIDTH and <prefix>_MAX_IMAGE_HEIGHT variables, keeping the
+// fallback values of the unset ones
+func loadUploadPolicy(prefix string, fallback UploadPolicyConfig) (UploadPolicyConfig, error) {
+	policy := fallback
+
+	maxBytes, err := parseUint(os.Getenv(prefix+"_MAX_BYTES"), uint64(fallback.MaxBytes))
+	if err != nil {
+		return policy, err
+	}
+	policy.MaxBytes = int64(maxBytes)
+
+	if value, ok := os.LookupEnv(prefix + "_MIME_TYPES"); ok {
+		policy.MIMETypes = parseList(value)
+	}
+	if value, ok := os.LookupEnv(prefix + "_EXTENSIONS"); ok {
+		policy.Extensions = parseList(value)
+	}
+
+	maxWidth, err := parseUint(os.Getenv(prefix+"_MAX_IMAGE_WIDTH"), uint64(fallback.MaxImageWidth))
+	if err != nil {
+		return policy, err
+	}
+	maxHeight, err := parseUint(os.Getenv(prefix+"_MAX_IMAGE_HEIGHT"), uint64(fallback.MaxImageHeight))
+	if err != nil {
+		return policy, err
+	}
+	policy.MaxImageWidth = int(maxWidth)
+	policy.MaxImageHeight = int(maxHeight)
+
+	return policy, nil
+}
+
+// getEnv is a helper function to read an environment variable with a fallback value
+func getEnv(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
 // parseUint is a helper function to convert a string to uint64 with a fallback value
 func parseUint(value string, fallback uint64) (uint64, error) {
 	if value == "" {
@@ -98,3 +553,66 @@ func parseUint(value string, fallback uint64) (uint64, error) {
 	}
 	return strconv.ParseUint(value, 10, 64)
 }
+
+// parseDuration is a helper function to convert a string such as "5s" to time.Duration with a fallback value
+func parseDuration(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// checkChoice returns an error naming the setting when value is not one of choices
+func checkChoice(name string, value string, choices ...string) error {
+	if !slices.Contains(choices, value) {
+		return fmt.Errorf("invalid %s %q", name, value)
+	}
+	return nil
+}
+
+// parseList is a helper function to split a comma separated string, ignoring empty items
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseSizes is a helper function to convert a comma separated string of positive integers with a fallback value
+func parseSizes(value string, fallback []int) ([]int, error) {
+	items := parseList(value)
+	if len(items) == 0 {
+		return fallback, nil
+	}
+
+	sizes := make([]int, 0, len(items))
+	for _, item := range items {
+		size, err := strconv.ParseUint(item, 10, 16)
+		if err != nil || size == 0 {
+			return nil, fmt.Errorf("invalid size %q", item)
+		}
+		sizes = append(sizes, int(size))
+	}
+	return sizes, nil
+}
+
+// parseRetention is a helper function to convert a comma separated list of folder=duration pairs, such as "pdf=720h"
+func parseRetention(value string) (map[string]time.Duration, error) {
+	policies := map[string]time.Duration{}
+	for _, item := range parseList(value) {
+		folder, duration, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(folder) == "" {
+			return nil, fmt.Errorf("invalid retention policy %q", item)
+		}
+
+		retention, err := time.ParseDuration(strings.TrimSpace(duration))
+		if err != nil || retention <= 0 {
+			return nil, fmt.Errorf("invalid retention policy %q", item)
+		}
+		policies[strings.TrimSpace(folder)] = retention
+	}
+	return policies, nil
+}
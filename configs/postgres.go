@@ -13,16 +13,11 @@ import (
 // ConnectDB initializes and returns a new database connection.
 func ConnectDB(config *AppConfig) (*sql.DB, error) {
 	// Open the connection to PostgreSQL database
-	db, err := sql.Open("postgres", config.PostgresDSN)
+	db, err := openPostgres(config.PostgresDSN, config.PostgresPoolMax)
 	if err != nil {
 		return nil, fmt.Errorf("could not connect to the PostgreSQL database: %w", err)
 	}
 
-	// Configure connection pooling
-	db.SetMaxOpenConns(config.PostgresPoolMax) // Maximum open connections
-	db.SetMaxIdleConns(5)                      // Minimum idle connections in the pool
-	db.SetConnMaxLifetime(5 * time.Minute)     // Max time a connection can be reused
-
 	// Use context to manage connection timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
@@ -36,3 +31,38 @@ func ConnectDB(config *AppConfig) (*sql.DB, error) {
 	log.Println("Connected to PostgreSQL database successfully")
 	return db, nil
 }
+
+// ConnectReplicas opens a connection pool for every read replica. Replicas are not
+// pinged here: an unavailable replica is skipped by the health checks of the DB router
+func ConnectReplicas(config *AppConfig) ([]*sql.DB, error) {
+	var replicas []*sql.DB
+	for i, dsn := range config.PostgresReplicaDSNs {
+		db, err := openPostgres(dsn, config.PostgresPoolMax)
+		if err != nil {
+			for _, replica := range replicas {
+				replica.Close()
+			}
+			return nil, fmt.Errorf("could not open PostgreSQL replica %d: %w", i, err)
+		}
+		replicas = append(replicas, db)
+	}
+	if len(replicas) > 0 {
+		log.Printf("Configured %d PostgreSQL read replica(s)", len(replicas))
+	}
+	return replicas, nil
+}
+
+// openPostgres opens a connection pool without connecting to the database
+func openPostgres(dsn string, poolMax int) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure connection pooling
+	db.SetMaxOpenConns(poolMax)            // Maximum open connections
+	db.SetMaxIdleConns(5)                  // Minimum idle connections in the pool
+	db.SetConnMaxLifetime(5 * time.Minute) // Max time a connection can be reused
+
+	return db, nil
+}
@@ -0,0 +1,15 @@
+package attachments
+
+import "time"
+
+// Attachment links a file to a record of any entity
+type Attachment struct {
+	AttachmentUUID   string     `json:"attachment_uuid" db:"attachment_uuid"`               // UUID do anexo (chave primaria)
+	EntityType       string     `json:"entity_type" db:"entity_type"`                       // Tipo da entidade, ex: users
+	EntityID         string     `json:"entity_id" db:"entity_id"`                           // ID do registro da entidade
+	FileUUID         string     `json:"file_uuid" db:"file_uuid"`                           // Arquivo anexado
+	Role             string     `json:"role" db:"role"`                                     // Papel do arquivo no registro, ex: contract
+	CreatedBy        string     `json:"created_by,omitempty" db:"created_by"`               // Usuario que anexou
+	CreationDate     time.Time  `json:"creation_date,omitempty" db:"creation_date"`         // Data de criação
+	ModificationDate *time.Time `json:"modification_date,omitempty" db:"modification_date"` // Data de modificação
+}
@@ -0,0 +1,6 @@
+package attachments
+
+type AttachmentRequest struct {
+	FileUUID string `json:"file_uuid" binding:"required,uuid" example:"0192d1a4-0000-7000-8000-000000000001"`
+	Role     string `json:"role" binding:"omitempty,max=50" example:"contract"`
+}
@@ -0,0 +1,8 @@
+package attachments
+
+type AttachmentResponse struct {
+	Attachment
+	OriginalFileName string `json:"original_file_name"`
+	FileType         string `json:"file_type"`
+	FileSize         int64  `json:"file_size,omitempty"`
+}
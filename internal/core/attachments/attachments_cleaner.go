@@ -0,0 +1,26 @@
+package attachments
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"context"
+)
+
+type attachmentsCleaner struct {
+	repo AttachmentsRepository
+}
+
+// NewAttachmentsCleaner creates the cleaner the services of the entities accepting
+// attachments use to detach the files of the records they delete
+func NewAttachmentsCleaner(repo AttachmentsRepository) *attachmentsCleaner {
+	return &attachmentsCleaner{repo: repo}
+}
+
+// DeleteByEntity detaches every file from a record in the transaction of uow, or
+// on its own when uow is nil
+func (c *attachmentsCleaner) DeleteByEntity(ctx context.Context, uow *database.UnitOfWork, entityType string, entityID string) error {
+	repo := c.repo
+	if uow != nil {
+		repo = repo.WithTx(uow.Tx())
+	}
+	return repo.DeleteByEntity(ctx, entityType, entityID)
+}
@@ -0,0 +1,124 @@
+package attachments
+
+import (
+	"bernardtm/backend/internal/core/files"
+	"bernardtm/backend/internal/core/shareds"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AttachmentsController serves the attachments of every entity. Each method returns
+// the handler of one entity type, the name of the routes of the entity
+type AttachmentsController interface {
+	GetAll(entityType string) gin.HandlerFunc
+	Create(entityType string) gin.HandlerFunc
+	Delete(entityType string) gin.HandlerFunc
+}
+
+type attachmentsController struct {
+	service AttachmentsService
+}
+
+func NewAttachmentsController(service AttachmentsService) *attachmentsController {
+	return &attachmentsController{service: service}
+}
+
+// GetAll lists the files attached to a record
+// @Summary Get the attachments of a record
+// @Description Lists the attachments whose file the user can read
+// @Tags Attachments
+// @Produce json
+// @Security BearerAuth
+// @Param entity path string true "Entity of the record, e.g. users"
+// @Param id path string true "ID of the record"
+// @Success 200 {array} AttachmentResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /{entity}/{id}/attachments [get]
+func (c *attachmentsController) GetAll(entityType string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		attachments, err := c.service.GetByEntity(ctx.Request.Context(), ctx.GetString("ID"), entityType, ctx.Param("id"))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching attachments"})
+			return
+		}
+		ctx.JSON(http.StatusOK, attachments)
+	}
+}
+
+// Create attaches a file to a record
+// @Summary Attach a file to a record
+// @Description Attaches a file the user can read to an existing record with an optional role, "attachment" by default
+// @Tags Attachments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param entity path string true "Entity of the record, e.g. users"
+// @Param id path string true "ID of the record"
+// @Param input body AttachmentRequest true "Attachment Data"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 409 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /{entity}/{id}/attachments [post]
+func (c *attachmentsController) Create(entityType string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var input AttachmentRequest
+		if err := ctx.ShouldBindJSON(&input); err != nil {
+			ctx.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid input data"})
+			return
+		}
+
+		createdID, err := c.service.Create(ctx.Request.Context(), ctx.GetString("ID"), entityType, ctx.Param("id"), input)
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			ctx.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Record not found"})
+			return
+		case errors.Is(err, files.ErrFileNotFound):
+			ctx.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "File not found"})
+			return
+		case errors.Is(err, ErrAttachmentExists):
+			ctx.JSON(http.StatusConflict, shareds.ErrorResponse{Message: "File is already attached"})
+			return
+		case err != nil:
+			ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error creating attachment"})
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, gin.H{"id": createdID, "message": "File attached successfully"})
+	}
+}
+
+// Delete detaches a file from a record
+// @Summary Delete an attachment
+// @Description Detaches the file from the record, the file itself is kept. Only the user who attached the file or its owner may detach it
+// @Tags Attachments
+// @Security BearerAuth
+// @Param entity path string true "Entity of the record, e.g. users"
+// @Param id path string true "ID of the record"
+// @Param attachmentId path string true "ID of the attachment"
+// @Success 204
+// @Failure 403 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /{entity}/{id}/attachments/{attachmentId} [delete]
+func (c *attachmentsController) Delete(entityType string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		err := c.service.Delete(ctx.Request.Context(), ctx.GetString("ID"), entityType, ctx.Param("id"), ctx.Param("attachmentId"))
+		switch {
+		case errors.Is(err, ErrAttachmentNotFound):
+			ctx.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Attachment not found"})
+			return
+		case errors.Is(err, ErrAttachmentForbidden):
+			ctx.JSON(http.StatusForbidden, shareds.ErrorResponse{Message: "Only the user who attached the file or its owner can detach it"})
+			return
+		case err != nil:
+			ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error deleting attachment"})
+			return
+		}
+
+		ctx.JSON(http.StatusNoContent, nil)
+	}
+}
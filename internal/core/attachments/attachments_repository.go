@@ -0,0 +1,192 @@
+package attachments
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrAttachmentNotFound is returned when no attachment of the record matches the given ID
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// ErrAttachmentExists is returned when the file is already attached to the record with the same role
+var ErrAttachmentExists = errors.New("file is already attached")
+
+type AttachmentsRepository interface {
+	GetByEntity(ctx context.Context, entityType string, entityID string) ([]AttachmentResponse, error)
+	GetByID(ctx context.Context, entityType string, entityID string, id string) (Attachment, error)
+	Create(ctx context.Context, data Attachment) (string, error)
+	Delete(ctx context.Context, entityType string, entityID string, id string) error
+	DeleteByEntity(ctx context.Context, entityType string, entityID string) error
+	WithTx(tx database.DBTX) AttachmentsRepository
+}
+
+type attachmentsRepository struct {
+	db      database.DBTX
+	timeout time.Duration
+}
+
+func NewAttachmentsRepository(db database.DBTX, timeout time.Duration) *attachmentsRepository {
+	return &attachmentsRepository{db: db, timeout: timeout}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *attachmentsRepository) WithTx(tx database.DBTX) AttachmentsRepository {
+	return &attachmentsRepository{db: tx, timeout: r.timeout}
+}
+
+// GetByEntity returns the attachments of a record with the metadata of their files
+func (r *attachmentsRepository) GetByEntity(ctx context.Context, entityType string, entityID string) ([]AttachmentResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT a.attachment_uuid, a.entity_type, a.entity_id, a.file_uuid, a.role, a.created_by, a.creation_date, a.modification_date,
+		       COALESCE(f.original_file_name, f.file_name), f.file_type, f.file_size
+		FROM default_schema.attachments a
+		JOIN default_schema.files f ON f.file_uuid = a.file_uuid
+		WHERE a.entity_type = $1 AND a.entity_id = $2 AND a.tenant_uuid = $3
+		ORDER BY a.creation_date, a.attachment_uuid`, entityType, entityID, tenantID)
+
+	if err != nil {
+		return nil, errors.New("failed to retrive attachments")
+	}
+
+	defer rows.Close()
+
+	models := []AttachmentResponse{}
+	for rows.Next() {
+		var model AttachmentResponse
+		var createdBy sql.NullString
+		var fileSize sql.NullInt64
+
+		err := rows.Scan(&model.AttachmentUUID, &model.EntityType, &model.EntityID, &model.FileUUID, &model.Role, &createdBy, &model.CreationDate, &model.ModificationDate,
+			&model.OriginalFileName, &model.FileType, &fileSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		model.CreatedBy = createdBy.String
+		model.FileSize = fileSize.Int64
+
+		models = append(models, model)
+	}
+
+	return models, nil
+}
+
+// GetByID returns an attachment of a record
+func (r *attachmentsRepository) GetByID(ctx context.Context, entityType string, entityID string, id string) (Attachment, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	var model Attachment
+	var createdBy sql.NullString
+
+	err = r.db.QueryRowContext(ctx, `
+		SELECT attachment_uuid, entity_type, entity_id, file_uuid, role, created_by, creation_date, modification_date
+		FROM default_schema.attachments
+		WHERE attachment_uuid = $1 AND entity_type = $2 AND entity_id = $3 AND tenant_uuid = $4`, id, entityType, entityID, tenantID).
+		Scan(&model.AttachmentUUID, &model.EntityType, &model.EntityID, &model.FileUUID, &model.Role, &createdBy, &model.CreationDate, &model.ModificationDate)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Attachment{}, ErrAttachmentNotFound
+		}
+		return Attachment{}, errors.New("failed to retrive attachment")
+	}
+	model.CreatedBy = createdBy.String
+
+	return model, nil
+}
+
+// Create attaches a file to a record, failing with ErrAttachmentExists when it is
+// already attached with the same role
+func (r *attachmentsRepository) Create(ctx context.Context, data Attachment) (string, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var id string
+
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO default_schema.attachments (entity_type, entity_id, file_uuid, role, created_by, tenant_uuid)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, $6)
+		ON CONFLICT DO NOTHING
+		RETURNING attachment_uuid`, data.EntityType, data.EntityID, data.FileUUID, data.Role, data.CreatedBy, tenantID).Scan(&id)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrAttachmentExists
+		}
+
+		log.Print(err)
+		return "", errors.New("failed to create attachment")
+	}
+
+	return id, nil
+}
+
+// Delete detaches a file from a record. The file itself is kept
+func (r *attachmentsRepository) Delete(ctx context.Context, entityType string, entityID string, id string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM default_schema.attachments
+		WHERE attachment_uuid = $1 AND entity_type = $2 AND entity_id = $3 AND tenant_uuid = $4`, id, entityType, entityID, tenantID)
+
+	if err != nil {
+		return errors.New("failed to delete attachment")
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrAttachmentNotFound
+	}
+
+	return nil
+}
+
+// DeleteByEntity detaches every file from a record, once the record is deleted
+func (r *attachmentsRepository) DeleteByEntity(ctx context.Context, entityType string, entityID string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		DELETE FROM default_schema.attachments
+		WHERE entity_type = $1 AND entity_id = $2 AND tenant_uuid = $3`, entityType, entityID, tenantID)
+
+	if err != nil {
+		return errors.New("failed to delete attachments")
+	}
+
+	return nil
+}
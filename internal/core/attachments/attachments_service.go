@@ -0,0 +1,131 @@
+package attachments
+
+import (
+	"bernardtm/backend/internal/core/files"
+	"context"
+	"errors"
+)
+
+// defaultRole is the role of the files attached without one
+const defaultRole = "attachment"
+
+// ErrRecordNotFound is returned when the record to attach a file to does not exist
+var ErrRecordNotFound = errors.New("record not found")
+
+// ErrAttachmentForbidden is returned when the user may neither detach the attachment
+// nor manage its file
+var ErrAttachmentForbidden = errors.New("attachment cannot be deleted by the user")
+
+// RecordLookup checks that a record of an entity exists, failing with
+// ErrRecordNotFound when it does not
+type RecordLookup func(ctx context.Context, id string) error
+
+// LookupRecord adapts the GetByID of the service of an entity to a RecordLookup,
+// notFound being the error of the service for a missing record
+func LookupRecord[T any](getByID func(ctx context.Context, id string) (T, error), notFound error) RecordLookup {
+	return func(ctx context.Context, id string) error {
+		_, err := getByID(ctx, id)
+		if errors.Is(err, notFound) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+}
+
+type AttachmentsService interface {
+	GetByEntity(ctx context.Context, userID string, entityType string, entityID string) ([]AttachmentResponse, error)
+	Create(ctx context.Context, userID string, entityType string, entityID string, data AttachmentRequest) (string, error)
+	Delete(ctx context.Context, userID string, entityType string, entityID string, id string) error
+}
+
+type attachmentsService struct {
+	repo         AttachmentsRepository
+	filesService files.FilesService
+	records      map[string]RecordLookup
+}
+
+// NewAttachmentsService creates the attachments service. records holds the lookup
+// of the records of every entity accepting attachments, by entity type
+func NewAttachmentsService(repo AttachmentsRepository, filesService files.FilesService, records map[string]RecordLookup) *attachmentsService {
+	return &attachmentsService{repo: repo, filesService: filesService, records: records}
+}
+
+// GetByEntity lists the attachments of a record whose file the user can read
+func (s *attachmentsService) GetByEntity(ctx context.Context, userID string, entityType string, entityID string) ([]AttachmentResponse, error) {
+	attachments, err := s.repo.GetByEntity(ctx, entityType, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	readable := map[string]bool{}
+	visible := []AttachmentResponse{}
+	for _, attachment := range attachments {
+		allowed, checked := readable[attachment.FileUUID]
+		if !checked {
+			_, err := s.filesService.GetByID(ctx, userID, attachment.FileUUID)
+			if err != nil && !errors.Is(err, files.ErrFileNotFound) {
+				return nil, err
+			}
+			allowed = err == nil
+			readable[attachment.FileUUID] = allowed
+		}
+		if allowed {
+			visible = append(visible, attachment)
+		}
+	}
+
+	return visible, nil
+}
+
+// Create attaches a file to an existing record. The user must be able to read the
+// file, so files cannot be attached by someone they were not shared with
+func (s *attachmentsService) Create(ctx context.Context, userID string, entityType string, entityID string, data AttachmentRequest) (string, error) {
+	lookup, ok := s.records[entityType]
+	if !ok {
+		return "", ErrRecordNotFound
+	}
+	if err := lookup(ctx, entityID); err != nil {
+		return "", err
+	}
+
+	if _, err := s.filesService.GetByID(ctx, userID, data.FileUUID); err != nil {
+		return "", err
+	}
+
+	role := data.Role
+	if role == "" {
+		role = defaultRole
+	}
+
+	return s.repo.Create(ctx, Attachment{
+		EntityType: entityType,
+		EntityID:   entityID,
+		FileUUID:   data.FileUUID,
+		Role:       role,
+		CreatedBy:  userID,
+	})
+}
+
+// Delete detaches a file from a record. Only the user who attached it or the owner
+// of the file may do so
+func (s *attachmentsService) Delete(ctx context.Context, userID string, entityType string, entityID string, id string) error {
+	attachment, err := s.repo.GetByID(ctx, entityType, entityID, id)
+	if err != nil {
+		return err
+	}
+
+	if attachment.CreatedBy != userID {
+		file, err := s.filesService.GetByID(ctx, userID, attachment.FileUUID)
+		if errors.Is(err, files.ErrFileNotFound) {
+			return ErrAttachmentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if file.OwnerUUID != userID {
+			return ErrAttachmentForbidden
+		}
+	}
+
+	return s.repo.Delete(ctx, entityType, entityID, id)
+}
@@ -0,0 +1,252 @@
+package attachments
+
+import (
+	"bernardtm/backend/internal/core/files"
+	"bernardtm/backend/internal/infra/database"
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testTenant = "0192d1a4-0000-7000-8000-00000000000a"
+	testUser   = "0192d1a4-0000-7000-8000-0000000000aa"
+)
+
+// mockFilesService answers the access checks of the attached files, owners holding
+// the owner of every readable file
+type mockFilesService struct {
+	files.FilesService
+	owners map[string]string
+}
+
+func (m *mockFilesService) GetByID(ctx context.Context, userID string, id string) (files.FileResponse, error) {
+	owner, ok := m.owners[id]
+	if !ok {
+		return files.FileResponse{}, files.ErrFileNotFound
+	}
+	return files.FileResponse{FileUUID: id, OwnerUUID: owner}, nil
+}
+
+// fakeAttachmentsRepository keeps the attachments in memory, oldest first, naming
+// the attached files after their IDs
+type fakeAttachmentsRepository struct {
+	AttachmentsRepository
+	attachments []AttachmentResponse
+}
+
+func (r *fakeAttachmentsRepository) GetByEntity(ctx context.Context, entityType string, entityID string) ([]AttachmentResponse, error) {
+	if _, err := database.TenantID(ctx); err != nil {
+		return nil, err
+	}
+
+	models := []AttachmentResponse{}
+	for _, attachment := range r.attachments {
+		if attachment.EntityType == entityType && attachment.EntityID == entityID {
+			models = append(models, attachment)
+		}
+	}
+	return models, nil
+}
+
+func (r *fakeAttachmentsRepository) GetByID(ctx context.Context, entityType string, entityID string, id string) (Attachment, error) {
+	for _, attachment := range r.attachments {
+		if attachment.AttachmentUUID == id && attachment.EntityType == entityType && attachment.EntityID == entityID {
+			return attachment.Attachment, nil
+		}
+	}
+	return Attachment{}, ErrAttachmentNotFound
+}
+
+func (r *fakeAttachmentsRepository) Create(ctx context.Context, data Attachment) (string, error) {
+	if _, err := database.TenantID(ctx); err != nil {
+		return "", err
+	}
+
+	for _, attachment := range r.attachments {
+		if attachment.EntityType == data.EntityType && attachment.EntityID == data.EntityID && attachment.FileUUID == data.FileUUID && attachment.Role == data.Role {
+			return "", ErrAttachmentExists
+		}
+	}
+
+	data.AttachmentUUID = fmt.Sprintf("attachment-%d", len(r.attachments)+1)
+	data.CreationDate = time.Now()
+	r.attachments = append(r.attachments, AttachmentResponse{Attachment: data, OriginalFileName: data.FileUUID + ".pdf", FileType: "application/pdf"})
+	return data.AttachmentUUID, nil
+}
+
+func (r *fakeAttachmentsRepository) Delete(ctx context.Context, entityType string, entityID string, id string) error {
+	for i, attachment := range r.attachments {
+		if attachment.AttachmentUUID == id && attachment.EntityType == entityType && attachment.EntityID == entityID {
+			r.attachments = slices.Delete(r.attachments, i, i+1)
+			return nil
+		}
+	}
+	return ErrAttachmentNotFound
+}
+
+func (r *fakeAttachmentsRepository) DeleteByEntity(ctx context.Context, entityType string, entityID string) error {
+	if _, err := database.TenantID(ctx); err != nil {
+		return err
+	}
+
+	r.attachments = slices.DeleteFunc(r.attachments, func(attachment AttachmentResponse) bool {
+		return attachment.EntityType == entityType && attachment.EntityID == entityID
+	})
+	return nil
+}
+
+func (r *fakeAttachmentsRepository) WithTx(tx database.DBTX) AttachmentsRepository {
+	return r
+}
+
+// ids returns the IDs of the stored attachments
+func (r *fakeAttachmentsRepository) ids() []string {
+	ids := []string{}
+	for _, attachment := range r.attachments {
+		ids = append(ids, attachment.AttachmentUUID)
+	}
+	return ids
+}
+
+// attached returns an attachment of user-1 created by createdBy
+func attached(id string, fileUUID string, createdBy string) AttachmentResponse {
+	return AttachmentResponse{
+		Attachment:       Attachment{AttachmentUUID: id, EntityType: "users", EntityID: "user-1", FileUUID: fileUUID, Role: defaultRole, CreatedBy: createdBy},
+		OriginalFileName: fileUUID + ".pdf",
+		FileType:         "application/pdf",
+	}
+}
+
+// lookupUser finds user-1 only
+func lookupUser(ctx context.Context, id string) error {
+	if id != "user-1" {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// newTestAttachmentsService creates a service whose user can read file-1, owned
+// by the user, and file-3, shared by another user
+func newTestAttachmentsService(attachments ...AttachmentResponse) (*attachmentsService, *fakeAttachmentsRepository) {
+	repo := &fakeAttachmentsRepository{attachments: attachments}
+	filesService := &mockFilesService{owners: map[string]string{"file-1": testUser, "file-3": "another-user"}}
+	records := map[string]RecordLookup{"users": lookupUser, "menus": func(ctx context.Context, id string) error { return nil }}
+	return NewAttachmentsService(repo, filesService, records), repo
+}
+
+func TestAttachmentsService_Create(t *testing.T) {
+	tests := []struct {
+		name       string
+		entityType string
+		entityID   string
+		data       AttachmentRequest
+		err        error
+		role       string
+	}{
+		{name: "attaches a readable file", entityType: "users", entityID: "user-1", data: AttachmentRequest{FileUUID: "file-1"}, role: defaultRole},
+		{name: "keeps the given role", entityType: "users", entityID: "user-1", data: AttachmentRequest{FileUUID: "file-3", Role: "contract"}, role: "contract"},
+		{name: "requires a readable file", entityType: "users", entityID: "user-1", data: AttachmentRequest{FileUUID: "file-2", Role: "contract"}, err: files.ErrFileNotFound},
+		{name: "requires the record", entityType: "users", entityID: "user-2", data: AttachmentRequest{FileUUID: "file-1"}, err: ErrRecordNotFound},
+		{name: "requires an entity accepting attachments", entityType: "unknown", entityID: "user-1", data: AttachmentRequest{FileUUID: "file-1"}, err: ErrRecordNotFound},
+		{name: "refuses a file attached with the same role", entityType: "users", entityID: "user-1", data: AttachmentRequest{FileUUID: "file-1", Role: "contract"}, err: ErrAttachmentExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := attached("attachment-1", "file-1", testUser)
+			existing.Role = "contract"
+			service, repo := newTestAttachmentsService(existing)
+			ctx := database.WithTenant(context.Background(), testTenant)
+
+			id, err := service.Create(ctx, testUser, tt.entityType, tt.entityID, tt.data)
+
+			assert.ErrorIs(t, err, tt.err)
+			if tt.err != nil {
+				assert.Equal(t, []string{"attachment-1"}, repo.ids(), "nothing is attached")
+				return
+			}
+			assert.Equal(t, "attachment-2", id)
+			if assert.Len(t, repo.attachments, 2) {
+				assert.Equal(t, tt.role, repo.attachments[1].Role)
+				assert.Equal(t, testUser, repo.attachments[1].CreatedBy)
+			}
+		})
+	}
+}
+
+func TestAttachmentsService_GetByEntity(t *testing.T) {
+	service, _ := newTestAttachmentsService(
+		attached("attachment-1", "file-1", ""),
+		attached("attachment-2", "file-2", "another-user"),
+		attached("attachment-3", "file-3", "another-user"),
+		AttachmentResponse{Attachment: Attachment{AttachmentUUID: "attachment-4", EntityType: "menus", EntityID: "menu-1", FileUUID: "file-1"}},
+	)
+	ctx := database.WithTenant(context.Background(), testTenant)
+
+	attachments, err := service.GetByEntity(ctx, testUser, "users", "user-1")
+
+	assert.NoError(t, err)
+	// file-2 cannot be read by the user, so its attachment is hidden
+	if assert.Len(t, attachments, 2) {
+		assert.Equal(t, "file-1.pdf", attachments[0].OriginalFileName)
+		assert.Empty(t, attachments[0].CreatedBy)
+		assert.Equal(t, "attachment-3", attachments[1].AttachmentUUID)
+	}
+}
+
+func TestAttachmentsService_Delete(t *testing.T) {
+	tests := []struct {
+		name       string
+		attachment AttachmentResponse
+		err        error
+	}{
+		{name: "by the user who attached the file", attachment: attached("attachment-1", "file-3", testUser)},
+		{name: "by the owner of the file", attachment: attached("attachment-1", "file-1", "")},
+		// the file is shared with the user, who neither attached nor owns it
+		{name: "refused to a reader of the file", attachment: attached("attachment-1", "file-3", "another-user"), err: ErrAttachmentForbidden},
+		{name: "hidden when the file cannot be read", attachment: attached("attachment-1", "file-2", "another-user"), err: ErrAttachmentNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, repo := newTestAttachmentsService(tt.attachment)
+			ctx := database.WithTenant(context.Background(), testTenant)
+
+			err := service.Delete(ctx, testUser, "users", "user-1", "attachment-1")
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.err != nil, slices.Contains(repo.ids(), "attachment-1"))
+		})
+	}
+}
+
+func TestAttachmentsService_DeleteUnknownAttachment(t *testing.T) {
+	service, _ := newTestAttachmentsService(attached("attachment-1", "file-1", testUser))
+	ctx := database.WithTenant(context.Background(), testTenant)
+
+	err := service.Delete(ctx, testUser, "menus", "user-1", "attachment-1")
+
+	assert.ErrorIs(t, err, ErrAttachmentNotFound)
+}
+
+func TestAttachmentsCleaner_DeleteByEntity(t *testing.T) {
+	repo := &fakeAttachmentsRepository{attachments: []AttachmentResponse{
+		attached("attachment-1", "file-1", testUser),
+		attached("attachment-2", "file-3", testUser),
+		{Attachment: Attachment{AttachmentUUID: "attachment-3", EntityType: "menus", EntityID: "user-1", FileUUID: "file-1"}},
+	}}
+	cleaner := NewAttachmentsCleaner(repo)
+	ctx := database.WithTenant(context.Background(), testTenant)
+
+	err := database.NewFakeTxManager().WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		return cleaner.DeleteByEntity(ctx, uow, "users", "user-1")
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"attachment-3"}, repo.ids(), "only the attachments of the record are deleted")
+}
@@ -41,7 +41,7 @@ func (uc *authController) Login(c *gin.Context) {
 	}
 
 	// auth logic
-	token, err := uc.service.Login(loginRequest.Email, loginRequest.Password)
+	token, err := uc.service.Login(c.Request.Context(), loginRequest.Email, loginRequest.Password)
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: err.Error()})
@@ -77,7 +77,7 @@ func (uc *authController) Login2Step(c *gin.Context) {
 	}
 
 	// validate 2fa code and generate jwt token
-	loginResponse, err := uc.service.Login2Step(twoFactorCodeID.(string), loginRequest.OTP)
+	loginResponse, err := uc.service.Login2Step(c.Request.Context(), twoFactorCodeID.(string), loginRequest.OTP)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: err.Error()})
 		return
@@ -104,7 +104,7 @@ func (uc *authController) RequestPasswordReset(c *gin.Context) {
 		return
 	}
 	// send email with recovery link and verification code
-	err := uc.service.RequestPasswordReset(requestData)
+	err := uc.service.RequestPasswordReset(c.Request.Context(), requestData)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: err.Error()})
 		return
@@ -141,7 +141,7 @@ func (uc *authController) ResetPassword(c *gin.Context) {
 
 	userUUID, ok := id.(string)
 	if ok {
-		errorsList := uc.service.ResetPassword(userUUID, requestData)
+		errorsList := uc.service.ResetPassword(c.Request.Context(), userUUID, requestData)
 		if errorsList != nil {
 			var errorResponses []shareds.ErrorResponse
 			for _, err := range errorsList {
@@ -4,29 +4,33 @@ import (
 	"bernardtm/backend/configs"
 	"bernardtm/backend/internal/core/auth/token"
 	"bernardtm/backend/internal/core/email"
+	"bernardtm/backend/internal/core/notifications"
 	"bernardtm/backend/internal/core/users"
+	"bernardtm/backend/internal/infra/database"
 	"bernardtm/backend/internal/utils"
-	"bernardtm/backend/pkg/providers/emails"
+	"context"
 	"errors"
 	"fmt"
+	"net/url"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService interface {
-	Login(email string, password string) (string, error)
-	Send2FACode(to string, otp string) error
-	Login2Step(twoFactorCodeID string, otp string) (LoginResponse, error)
-	RequestPasswordReset(requestData RecoverPasswordRequest) error
-	ResetPassword(userUUID string, requestData PasswordResetRequest) []error
+	Login(ctx context.Context, email string, password string) (string, error)
+	Send2FACode(ctx context.Context, uow *database.UnitOfWork, recipient notifications.Recipient, otp string) error
+	Login2Step(ctx context.Context, twoFactorCodeID string, otp string) (LoginResponse, error)
+	RequestPasswordReset(ctx context.Context, requestData RecoverPasswordRequest) error
+	ResetPassword(ctx context.Context, userUUID string, requestData PasswordResetRequest) []error
 }
 
 type authService struct {
 	userRepo              users.UserRepository
-	emailService          email.EmailService
+	notificationService   notifications.NotificationService
 	twoFactorCodesService TwoFactorCodesService
 	tokenService          token.TokenService
+	txManager             database.TxManager
 	frontendURL           string
 }
 
@@ -34,22 +38,24 @@ type authService struct {
 func NewAuthService(
 	userRepo users.UserRepository,
 	config *configs.AppConfig,
-	emailService email.EmailService,
+	notificationService notifications.NotificationService,
 	twoFactorCodesService TwoFactorCodesService,
 	tokenService token.TokenService,
+	txManager database.TxManager,
 ) *authService {
 	return &authService{
 		userRepo:              userRepo,
-		emailService:          emailService,
+		notificationService:   notificationService,
 		twoFactorCodesService: twoFactorCodesService,
 		tokenService:          tokenService,
+		txManager:             txManager,
 		frontendURL:           config.FrontendURL,
 	}
 }
 
 // Login authenticate a user and returns a JWT token
-func (s *authService) Login(email string, password string) (string, error) {
-	user, err := s.userRepo.GetByEmail(email)
+func (s *authService) Login(ctx context.Context, email string, password string) (string, error) {
+	user, err := s.userRepo.GetByEmail(ctx, email)
 	if err != nil {
 		return "", errors.New("invalid email or password")
 	}
@@ -65,19 +71,25 @@ func (s *authService) Login(email string, password string) (string, error) {
 		IsAlphanumeric:  false,
 		MinutesToExpiry: 15,
 	}
-	twoFactor, err := s.twoFactorCodesService.GenerateTwoFactorCode(twoFactorRequest)
-	if err != nil {
-		return "", err
-	}
-	// send 2fa code by email
-	err = s.Send2FACode(email, twoFactor.Code)
+	// the code is only persisted along with the notification carrying it
+	var twoFactor TwoFactorCodesResponse
+	err = s.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		twoFactor, err = s.twoFactorCodesService.WithTx(uow.Tx()).GenerateTwoFactorCode(ctx, twoFactorRequest)
+		if err != nil {
+			return err
+		}
+		// the 2fa code is sent once the code is committed
+		return s.Send2FACode(ctx, uow, userRecipient(user), twoFactor.Code)
+	})
 	if err != nil {
 		return "", err
 	}
 
 	// generate token using twoFactorCodeID
+	tenantID, _ := database.TenantFromContext(ctx)
 	claims := &token.Claims{
-		ID: twoFactor.TwoFactorCodeUUID,
+		ID:       twoFactor.TwoFactorCodeUUID,
+		TenantID: tenantID,
 	}
 	token, err := s.tokenService.GenerateToken(claims, "2step_verification", 15*time.Minute)
 	if err != nil {
@@ -87,50 +99,43 @@ func (s *authService) Login(email string, password string) (string, error) {
 	return token, nil
 }
 
-func (s *authService) Send2FACode(to string, otp string) error {
-	var mail emails.EmailDto
-	mail.To = []string{to}
-	mail.Sender = "no-reply@company.com"
-	mail.Subject = "Código de Verificação"
-	mail.Body = fmt.Sprintf(`
-	Prezado(a),
-
-	Este é o seu código de verificação:
-
-	%s
-	O código de verificação só é válido por 15 minutos. Por favor, não compartilhe este código com ninguém.
-
-	Nota: Caso não tenha iniciado esta solicitação, por favor, altere a sua senha imediatamente. Vá para Alterar Senha.`, otp)
-	err := s.emailService.SendEmail(mail)
-	if err != nil {
-		return err
-	}
-	return nil
+// Send2FACode sends a two factor code on the preferred channel of the user, once
+// the transaction of uow is committed when not nil
+func (s *authService) Send2FACode(ctx context.Context, uow *database.UnitOfWork, recipient notifications.Recipient, otp string) error {
+	return s.notificationService.Notify(ctx, uow, recipient, notifications.Notification{
+		Template: email.TemplateTwoFactorCode,
+		Data: email.TwoFactorCodeData{
+			Code:             otp,
+			ExpiresInMinutes: 15,
+		},
+	})
 }
 
-func (s *authService) Login2Step(twoFactorCodeID string, otp string) (LoginResponse, error) {
-	twoFactorCodeResponse, err := s.twoFactorCodesService.ValidateTwoFactorCode(twoFactorCodeID, otp)
+func (s *authService) Login2Step(ctx context.Context, twoFactorCodeID string, otp string) (LoginResponse, error) {
+	twoFactorCodeResponse, err := s.twoFactorCodesService.ValidateTwoFactorCode(ctx, twoFactorCodeID, otp)
 	if err != nil {
 		return LoginResponse{}, err
 	}
-	user, err := s.userRepo.GetByID(twoFactorCodeResponse.Id)
+	user, err := s.userRepo.GetByID(ctx, twoFactorCodeResponse.Id)
 	if err != nil {
 		return LoginResponse{}, errors.New("invalid code")
 	}
 
+	tenantID, _ := database.TenantFromContext(ctx)
 	claims := &token.APIClaims{
 		ID:          user.Id,
 		Email:       user.Email,
 		Name:        user.Username,
 		Role:        "api",
 		Permissions: []string{"read", "write"},
+		TenantID:    tenantID,
 	}
 	token, err := s.tokenService.GenerateToken(claims, "api", 24*time.Hour)
 	if err != nil {
 		return LoginResponse{}, err
 	}
 	// invalidate two factor only at the end
-	if err = s.twoFactorCodesService.InvalidateTwoFactorCode(twoFactorCodeID); err != nil {
+	if err = s.twoFactorCodesService.InvalidateTwoFactorCode(ctx, twoFactorCodeID); err != nil {
 		return LoginResponse{}, err
 	}
 	loginResponse := LoginResponse{
@@ -143,56 +148,41 @@ func (s *authService) Login2Step(twoFactorCodeID string, otp string) (LoginRespo
 }
 
 // RequestPasswordReset identify user by email, generate and send a verification code
-func (s *authService) RequestPasswordReset(requestData RecoverPasswordRequest) error {
-	user, err := s.userRepo.GetByEmail(requestData.Email)
+func (s *authService) RequestPasswordReset(ctx context.Context, requestData RecoverPasswordRequest) error {
+	user, err := s.userRepo.GetByEmail(ctx, requestData.Email)
 	if err != nil {
 		return errors.New("invalid email")
 	}
+	tenantID, _ := database.TenantFromContext(ctx)
 	claims := &token.Claims{
-		ID: user.Id,
+		ID:       user.Id,
+		TenantID: tenantID,
 	}
 	token, err := s.tokenService.GenerateToken(claims, "password_reset_verification", 15*time.Minute)
 	if err != nil {
 		return err
 	}
-	if err = s.SendRecoveryPasswordLinkEmail(requestData.Email, token); err != nil {
+	if err = s.SendRecoveryPasswordLink(ctx, userRecipient(user), token); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *authService) SendRecoveryPasswordLinkEmail(to string, token string) error {
-
-	var mail emails.EmailDto
-	mail.To = []string{to}
-	mail.Sender = "no-reply@company.com"
-	mail.Subject = "Solicitação de Redefinição de Senha"
-	mail.IsHTML = true
-	mail.Body = fmt.Sprintf(`
-	<p>Prezado(a),</p>
-
-	<p>Você solicitou a redefinição de sua senha de acesso.</p>
-
-	<p>Caso você não tenha feito essa solicitação, por favor ignore este e-mail.</p>
-
-	<p>Acesse o link abaixo para redefinir sua senha: <br />
-	<a href="%s/recovery-password?token=%s">Clique aqui para redefinir a senha</a>
-	</p>
-
-	<p>Este link só é válido por 15 minutos. Por favor, não compartilhe este link com ninguém.</p>`,
-		s.frontendURL,
-		token,
-	)
-	err := s.emailService.SendEmail(mail)
-	if err != nil {
-		return err
-	}
-	return nil
+func (s *authService) SendRecoveryPasswordLink(ctx context.Context, recipient notifications.Recipient, token string) error {
+	link := fmt.Sprintf("%s/recovery-password?token=%s", s.frontendURL, url.QueryEscape(token))
+	return s.notificationService.Notify(ctx, nil, recipient, notifications.Notification{
+		Template: email.TemplatePasswordResetLink,
+		Data: email.PasswordResetLinkData{
+			Link:             link,
+			ExpiresInMinutes: 15,
+		},
+		Link: link,
+	})
 }
 
-func (s *authService) ResetPassword(userid string, requestData PasswordResetRequest) []error {
+func (s *authService) ResetPassword(ctx context.Context, userid string, requestData PasswordResetRequest) []error {
 	var errorsList []error
-	user, err := s.userRepo.GetByID(userid)
+	user, err := s.userRepo.GetByID(ctx, userid)
 	if err != nil {
 		return append(errorsList, errors.New("invalid code"))
 	}
@@ -213,30 +203,37 @@ func (s *authService) ResetPassword(userid string, requestData PasswordResetRequ
 		Email:      user.Email,
 		TaxNumber:  user.TaxNumber,
 		StatusUUID: user.StatusUUID,
+		Locale:     user.Locale,
 	}
-	s.userRepo.Update(user.Id, userRequest)
+	s.userRepo.Update(ctx, user.Id, userRequest)
 
-	// enviar email informando que a senha foi alterada
-	if err = s.SendPasswordResetEmail(user.Email, user.Username); err != nil {
+	// notificar o usuário de que a senha foi alterada
+	if err = s.SendPasswordChanged(ctx, userRecipient(user), user.Username); err != nil {
 		return append(errorsList, err)
 	}
 	return nil
 }
 
-func (s *authService) SendPasswordResetEmail(to string, username string) error {
-	var mail emails.EmailDto
-	mail.To = []string{to}
-	mail.Sender = "no-reply@company.com"
-	mail.Subject = "Senha Redefinida"
-	mail.Body = fmt.Sprintf(`
-	Prezado(a) %s,
-
-	Sua Senha foi redefinida com sucesso.`,
-		username,
-	)
-	err := s.emailService.SendEmail(mail)
-	if err != nil {
-		return err
+func (s *authService) SendPasswordChanged(ctx context.Context, recipient notifications.Recipient, username string) error {
+	return s.notificationService.Notify(ctx, nil, recipient, notifications.Notification{
+		Template: email.TemplatePasswordChanged,
+		Data: email.PasswordChangedData{
+			Name: username,
+		},
+	})
+}
+
+// userRecipient returns the addresses and preferences a user is notified with
+func userRecipient(user users.UserResponse) notifications.Recipient {
+	recipient := notifications.Recipient{UserUUID: user.Id, Email: user.Email}
+	if user.Phone != nil {
+		recipient.Phone = *user.Phone
 	}
-	return nil
+	if user.Locale != nil {
+		recipient.Locale = *user.Locale
+	}
+	if user.NotificationChannel != nil {
+		recipient.Channel = *user.NotificationChannel
+	}
+	return recipient
 }
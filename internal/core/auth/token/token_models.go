@@ -6,7 +6,8 @@ import (
 
 // Struct representing the claims of the JWT token
 type Claims struct {
-	ID string `json:"id"`
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
 	jwt.StandardClaims
 }
 
@@ -17,5 +18,6 @@ type APIClaims struct {
 	Name        string   `json:"name"`
 	Role        string   `json:"role"`
 	Permissions []string `json:"permissions"`
+	TenantID    string   `json:"tenant_id"`
 	jwt.StandardClaims
 }
@@ -1,30 +1,49 @@
 package auth
 
 import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/utils"
+	"context"
 	"database/sql"
 	"fmt"
+	"time"
 )
 
 // TwoFactorCodesRepository defines the interface for two-factor codes operations
 type TwoFactorCodesRepository interface {
-	GetByID(id string) (TwoFactorCodesResponse, error)
-	Create(userid string, code string, expiryTime int) (string, error)
-	Update(id string) error
+	GetByID(ctx context.Context, id string) (TwoFactorCodesResponse, error)
+	Create(ctx context.Context, userid string, code string, expiryTime int) (string, error)
+	Update(ctx context.Context, id string) error
+	WithTx(tx database.DBTX) TwoFactorCodesRepository
 }
 
 type twoFactorCodesRepository struct {
-	db *sql.DB
+	db      database.DBTX
+	timeout time.Duration
 }
 
 // NewTwoFactorCodesRepository creates a new instance of TwoFactorCodesRepository
-func NewTwoFactorCodesRepository(db *sql.DB) *twoFactorCodesRepository {
-	return &twoFactorCodesRepository{db: db}
+func NewTwoFactorCodesRepository(db database.DBTX, timeout time.Duration) *twoFactorCodesRepository {
+	return &twoFactorCodesRepository{db: db, timeout: timeout}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *twoFactorCodesRepository) WithTx(tx database.DBTX) TwoFactorCodesRepository {
+	return &twoFactorCodesRepository{db: tx, timeout: r.timeout}
 }
 
 // GetByID retrieves a two-factor code by its ID
-func (r *twoFactorCodesRepository) GetByID(id string) (TwoFactorCodesResponse, error) {
+func (r *twoFactorCodesRepository) GetByID(ctx context.Context, id string) (TwoFactorCodesResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return TwoFactorCodesResponse{}, err
+	}
+
 	var entity TwoFactorCodesResponse
-	err := r.db.QueryRow(`
+	err = r.db.QueryRowContext(ctx, `
 		SELECT
 			two_factor_code_uuid,
 			user_uuid,
@@ -32,7 +51,7 @@ func (r *twoFactorCodesRepository) GetByID(id string) (TwoFactorCodesResponse, e
 			expiration_date,
 			status_uuid
 		FROM default_schema.two_factor_codes
-		WHERE two_factor_code_uuid = $1`, id).
+		WHERE two_factor_code_uuid = $1 AND tenant_uuid = $2`, id, tenantID).
 		Scan(&entity.TwoFactorCodeUUID, &entity.Id, &entity.Code, &entity.ExpirationDate, &entity.StatusUUID)
 
 	if err != nil {
@@ -45,22 +64,32 @@ func (r *twoFactorCodesRepository) GetByID(id string) (TwoFactorCodesResponse, e
 }
 
 // Create inserts a new two-factor code and returns its ID
-func (r *twoFactorCodesRepository) Create(userid string, code string, minutesToExpiry int) (string, error) {
+func (r *twoFactorCodesRepository) Create(ctx context.Context, userid string, code string, minutesToExpiry int) (string, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return "", err
+	}
+
 	var twoFactorCodeID string
 
-	err := r.db.QueryRow(`
+	err = r.db.QueryRowContext(ctx, `
 		INSERT INTO default_schema.two_factor_codes (
 			user_uuid,
 			code,
 			expiration_date,
-			status_uuid
+			status_uuid,
+			tenant_uuid
 		)
 		VALUES ($1, $2, CURRENT_TIMESTAMP + $3::interval,
-			(SELECT status_uuid FROM default_schema.status WHERE name = 'Actived'))
+			(SELECT status_uuid FROM default_schema.status WHERE name = 'Actived'), $4)
 		RETURNING two_factor_code_uuid`,
 		userid,
 		code,
-		fmt.Sprintf("%d minutes", minutesToExpiry)).
+		fmt.Sprintf("%d minutes", minutesToExpiry),
+		tenantID).
 		Scan(&twoFactorCodeID)
 
 	if err != nil {
@@ -70,14 +99,23 @@ func (r *twoFactorCodesRepository) Create(userid string, code string, minutesToE
 }
 
 // Update sets the status of a two-factor code to 'Inactived'
-func (r *twoFactorCodesRepository) Update(id string) error {
-	_, err := r.db.Exec(`
+func (r *twoFactorCodesRepository) Update(ctx context.Context, id string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
 		UPDATE default_schema.two_factor_codes
 		SET
 			status_uuid = (SELECT status_uuid FROM default_schema.status WHERE name = 'Inactived'),
 			modification_date = CURRENT_TIMESTAMP
-		WHERE two_factor_code_uuid = $1`,
+		WHERE two_factor_code_uuid = $1 AND tenant_uuid = $2`,
 		id,
+		tenantID,
 	)
 	if err != nil {
 		return err
@@ -2,14 +2,17 @@ package auth
 
 import (
 	"bernardtm/backend/internal/core/status"
+	"bernardtm/backend/internal/infra/database"
 	"bernardtm/backend/internal/utils"
+	"context"
 	"errors"
 )
 
 type TwoFactorCodesService interface {
-	GenerateTwoFactorCode(entity TwoFactorCodesRequest) (TwoFactorCodesResponse, error)
-	ValidateTwoFactorCode(twoFactorCodeID string, otp string) (TwoFactorCodesResponse, error)
-	InvalidateTwoFactorCode(twoFactorCodeID string) error
+	GenerateTwoFactorCode(ctx context.Context, entity TwoFactorCodesRequest) (TwoFactorCodesResponse, error)
+	ValidateTwoFactorCode(ctx context.Context, twoFactorCodeID string, otp string) (TwoFactorCodesResponse, error)
+	InvalidateTwoFactorCode(ctx context.Context, twoFactorCodeID string) error
+	WithTx(tx database.DBTX) TwoFactorCodesService
 }
 
 type twoFactorCodesService struct {
@@ -24,8 +27,16 @@ func NewTwoFactorCodesService(repo TwoFactorCodesRepository, repoStatus status.S
 	}
 }
 
+// WithTx returns a copy of the service whose repositories are bound to the given transaction
+func (s *twoFactorCodesService) WithTx(tx database.DBTX) TwoFactorCodesService {
+	return &twoFactorCodesService{
+		repo:       s.repo.WithTx(tx),
+		repoStatus: s.repoStatus.WithTx(tx),
+	}
+}
+
 // GenerateTwoFactorCode generates a 2FA code and persists it in the database
-func (s *twoFactorCodesService) GenerateTwoFactorCode(entity TwoFactorCodesRequest) (TwoFactorCodesResponse, error) {
+func (s *twoFactorCodesService) GenerateTwoFactorCode(ctx context.Context, entity TwoFactorCodesRequest) (TwoFactorCodesResponse, error) {
 	twoFactorCodeResponse := TwoFactorCodesResponse{}
 	// generate otp
 	otp, err := utils.GenerateOTP(entity.Size, entity.IsAlphanumeric)
@@ -33,7 +44,7 @@ func (s *twoFactorCodesService) GenerateTwoFactorCode(entity TwoFactorCodesReque
 		return twoFactorCodeResponse, err
 	}
 	// persist otp, 15 minutes expiration
-	twoFactorCodeID, err := s.repo.Create(entity.Id, otp, entity.MinutesToExpiry)
+	twoFactorCodeID, err := s.repo.Create(ctx, entity.Id, otp, entity.MinutesToExpiry)
 	if err != nil {
 		return twoFactorCodeResponse, err
 	}
@@ -43,13 +54,13 @@ func (s *twoFactorCodesService) GenerateTwoFactorCode(entity TwoFactorCodesReque
 }
 
 // ValidateTwoFactorCode validates a 2FA code
-func (s *twoFactorCodesService) ValidateTwoFactorCode(twoFactorCodeID string, otp string) (TwoFactorCodesResponse, error) {
-	twoFactor, err := s.repo.GetByID(twoFactorCodeID)
+func (s *twoFactorCodesService) ValidateTwoFactorCode(ctx context.Context, twoFactorCodeID string, otp string) (TwoFactorCodesResponse, error) {
+	twoFactor, err := s.repo.GetByID(ctx, twoFactorCodeID)
 	if err != nil {
 		return TwoFactorCodesResponse{}, errors.New("invalid 2fa code")
 	}
 
-	status, err := s.repoStatus.GetByName("Actived")
+	status, err := s.repoStatus.GetByName(ctx, "Actived")
 	if err != nil {
 		return TwoFactorCodesResponse{}, errors.New("invalid 2fa code, inactived")
 	}
@@ -65,6 +76,6 @@ func (s *twoFactorCodesService) ValidateTwoFactorCode(twoFactorCodeID string, ot
 	return twoFactor, nil
 }
 
-func (s *twoFactorCodesService) InvalidateTwoFactorCode(twoFactorCodeID string) error {
-	return s.repo.Update(twoFactorCodeID)
+func (s *twoFactorCodesService) InvalidateTwoFactorCode(ctx context.Context, twoFactorCodeID string) error {
+	return s.repo.Update(ctx, twoFactorCodeID)
 }
@@ -0,0 +1,273 @@
+package email
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/providers/emails"
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRetryDelay bounds the exponential delay between attempts
+	maxRetryDelay = time.Hour
+	// purgeInterval is how often the sent messages past their retention are deleted
+	purgeInterval = time.Hour
+)
+
+// EmailDispatcher sends the emails queued in the outbox in background workers.
+// Failed attempts are retried with an exponential delay, and a message is
+// dead-lettered once its attempts are exhausted, until an administrator retries it
+type EmailDispatcher interface {
+	Enqueue(ctx context.Context, uow *database.UnitOfWork, email emails.EmailDto, idempotencyKey string) (string, error)
+	Notify()
+	Start(ctx context.Context)
+	Close()
+	GetAll(ctx context.Context, status string, page, size int) ([]OutboxMessage, error)
+	GetByID(ctx context.Context, id string) (OutboxMessage, error)
+	Retry(ctx context.Context, id string) (OutboxMessage, error)
+}
+
+type emailDispatcher struct {
+	outboxRepo  EmailOutboxRepository
+	provider    emails.EmailProvider
+	suppressed  SuppressionList
+	workers     int
+	interval    time.Duration
+	timeout     time.Duration
+	maxAttempts int
+	retention   time.Duration
+	wake        chan struct{}
+	stop        context.CancelFunc
+	done        sync.WaitGroup
+}
+
+func NewEmailDispatcher(outboxRepo EmailOutboxRepository, provider emails.EmailProvider, suppressed SuppressionList, config *configs.AppConfig) *emailDispatcher {
+	return &emailDispatcher{
+		outboxRepo:  outboxRepo,
+		provider:    provider,
+		suppressed:  suppressed,
+		workers:     max(config.OutboxWorkers, 1),
+		interval:    cmp.Or(config.OutboxInterval, 5*time.Second),
+		timeout:     cmp.Or(config.EmailTimeout, 15*time.Second),
+		maxAttempts: max(config.OutboxMaxAttempts, 1),
+		retention:   config.OutboxRetention,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Enqueue queues an email in the transaction of uow, or on its own when uow is
+// nil. The workers are woken once it is committed. A message already queued with
+// the same idempotency key is not queued again, its ID being returned instead
+func (d *emailDispatcher) Enqueue(ctx context.Context, uow *database.UnitOfWork, email emails.EmailDto, idempotencyKey string) (string, error) {
+	if uow == nil {
+		id, created, err := d.outboxRepo.Enqueue(ctx, email, idempotencyKey)
+		if created {
+			d.Notify()
+		}
+		return id, err
+	}
+
+	id, created, err := d.outboxRepo.WithTx(uow.Tx()).Enqueue(ctx, email, idempotencyKey)
+	if created {
+		uow.AfterCommit(d.Notify)
+	}
+	return id, err
+}
+
+// Notify wakes an idle worker
+func (d *emailDispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the workers until Close. Messages left by a previous run, including
+// the ones interrupted by a crash, are picked up once their lease expires
+func (d *emailDispatcher) Start(ctx context.Context) {
+	ctx, d.stop = context.WithCancel(ctx)
+
+	for i := 0; i < d.workers; i++ {
+		d.done.Add(1)
+		go func() {
+			defer d.done.Done()
+			d.work(ctx)
+		}()
+	}
+
+	if d.retention > 0 {
+		d.done.Add(1)
+		go func() {
+			defer d.done.Done()
+			d.purge(ctx)
+		}()
+	}
+}
+
+// Close stops the workers and waits for the running sends to return
+func (d *emailDispatcher) Close() {
+	if d.stop != nil {
+		d.stop()
+		d.done.Wait()
+	}
+}
+
+func (d *emailDispatcher) work(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		for d.runNext(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge deletes the sent messages past their retention until ctx is done. They are
+// kept until then so a message queued again with the same idempotency key is not
+// sent twice
+func (d *emailDispatcher) purge(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if purged, err := d.outboxRepo.PurgeSent(ctx, time.Now().Add(-d.retention)); err != nil && ctx.Err() == nil {
+			log.Printf("failed to purge sent emails: %v", err)
+		} else if purged > 0 {
+			log.Printf("purged %d sent emails", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runNext claims and sends one message, reporting whether there was one
+func (d *emailDispatcher) runNext(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// the lease outlives the send timeout, leaving time to record the outcome
+	message, ok, err := d.outboxRepo.Claim(ctx, d.timeout+time.Minute)
+	if err != nil {
+		log.Printf("failed to claim outbox message: %v", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	d.handle(ctx, message)
+	return true
+}
+
+// handle sends a message and records the outcome. A message whose main recipients
+// were all suppressed since it was queued is dead-lettered right away
+func (d *emailDispatcher) handle(ctx context.Context, message OutboxMessage) {
+	// the outcome must be recorded even when the workers stop
+	record := context.WithoutCancel(ctx)
+
+	email, ok := withoutSuppressed(ctx, d.suppressed, withMessageID(message))
+	if !ok {
+		if err := d.outboxRepo.Fail(record, message.MessageUUID, ErrRecipientSuppressed.Error()); err != nil {
+			log.Printf("failed to dead-letter outbox message %s: %v", message.MessageUUID, err)
+		}
+		return
+	}
+
+	if err := d.provider.Send(ctx, email); err != nil {
+		d.retry(record, message, err)
+		return
+	}
+
+	if err := d.outboxRepo.MarkSent(record, message.MessageUUID); err != nil {
+		log.Printf("failed to mark outbox message %s as sent: %v", message.MessageUUID, err)
+	}
+}
+
+// retry schedules another attempt of a message with an exponential delay, or
+// dead-letters it once the attempts are exhausted
+func (d *emailDispatcher) retry(ctx context.Context, message OutboxMessage, cause error) {
+	log.Printf("failed to send outbox message %s (attempt %d): %v", message.MessageUUID, message.Attempts, cause)
+
+	if message.Attempts < d.maxAttempts {
+		delay := min(time.Duration(1<<message.Attempts)*d.interval, maxRetryDelay)
+		if err := d.outboxRepo.Retry(ctx, message.MessageUUID, time.Now().Add(delay), cause.Error()); err != nil {
+			log.Printf("failed to reschedule outbox message %s: %v", message.MessageUUID, err)
+		}
+		return
+	}
+
+	log.Printf("outbox message %s of tenant %s dead-lettered after %d attempts", message.MessageUUID, message.TenantUUID, message.Attempts)
+	if err := d.outboxRepo.Fail(ctx, message.MessageUUID, cause.Error()); err != nil {
+		log.Printf("failed to dead-letter outbox message %s: %v", message.MessageUUID, err)
+	}
+}
+
+// GetAll returns a page of the messages in a state, or of every message when the
+// state is empty
+func (d *emailDispatcher) GetAll(ctx context.Context, status string, page, size int) ([]OutboxMessage, error) {
+	return d.outboxRepo.GetAll(ctx, status, page, size)
+}
+
+func (d *emailDispatcher) GetByID(ctx context.Context, id string) (OutboxMessage, error) {
+	return d.outboxRepo.GetByID(ctx, id)
+}
+
+// Retry sends a dead or pending message again right away, with a fresh set of
+// attempts
+func (d *emailDispatcher) Retry(ctx context.Context, id string) (OutboxMessage, error) {
+	if _, err := d.outboxRepo.GetByID(ctx, id); err != nil {
+		return OutboxMessage{}, err
+	}
+	if err := d.outboxRepo.Requeue(ctx, id); err != nil {
+		return OutboxMessage{}, err
+	}
+	d.Notify()
+
+	return d.outboxRepo.GetByID(ctx, id)
+}
+
+// withMessageID returns the email of a message with a Message-ID derived from the
+// message, so a message sent again after its outcome failed to be recorded keeps
+// its ID and can be deduplicated by the receiving servers
+func withMessageID(message OutboxMessage) emails.EmailDto {
+	email := message.Email
+	for key := range email.Headers {
+		if strings.EqualFold(key, "Message-ID") {
+			return email
+		}
+	}
+
+	domain := "localhost"
+	if address, err := mail.ParseAddress(email.Sender); err == nil {
+		if _, host, ok := strings.Cut(address.Address, "@"); ok {
+			domain = host
+		}
+	}
+
+	email.Headers = maps.Clone(email.Headers)
+	if email.Headers == nil {
+		email.Headers = map[string]string{}
+	}
+	email.Headers["Message-ID"] = fmt.Sprintf("<%s@%s>", message.MessageUUID, domain)
+	return email
+}
@@ -0,0 +1,277 @@
+package email
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/providers/emails"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testTenant = "3f1c7a52-8a3e-4f1b-9c55-0d2b9c4e7a10"
+
+var testMessage = OutboxMessage{
+	MessageUUID: "message-1",
+	TenantUUID:  testTenant,
+	Email:       emails.EmailDto{Sender: "no-reply@company.com", To: []string{"user@example.com"}, Subject: "Hi", Body: "Hello"},
+	Status:      OutboxPending,
+	Attempts:    1,
+}
+
+// fakeOutboxRepository keeps the outbox messages in memory, numbering them in the
+// order they are queued
+type fakeOutboxRepository struct {
+	EmailOutboxRepository
+	messages    map[string]OutboxMessage
+	lockedUntil map[string]time.Time
+}
+
+func newFakeOutboxRepository(messages ...OutboxMessage) *fakeOutboxRepository {
+	repo := &fakeOutboxRepository{messages: map[string]OutboxMessage{}, lockedUntil: map[string]time.Time{}}
+	for _, message := range messages {
+		repo.messages[message.MessageUUID] = message
+	}
+	return repo
+}
+
+func (r *fakeOutboxRepository) Enqueue(ctx context.Context, email emails.EmailDto, idempotencyKey string) (string, bool, error) {
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, message := range r.messages {
+		if idempotencyKey != "" && message.TenantUUID == tenantID && message.IdempotencyKey == idempotencyKey {
+			return message.MessageUUID, false, nil
+		}
+	}
+
+	id := fmt.Sprintf("message-%d", len(r.messages)+1)
+	r.messages[id] = OutboxMessage{MessageUUID: id, TenantUUID: tenantID, IdempotencyKey: idempotencyKey, Email: email, Status: OutboxPending, RunAfter: time.Now(), CreationDate: time.Now()}
+	return id, true, nil
+}
+
+func (r *fakeOutboxRepository) MarkSent(ctx context.Context, id string) error {
+	message := r.messages[id]
+	now := time.Now()
+	message.SentDate = &now
+	message.Status = OutboxSent
+	r.messages[id] = message
+	delete(r.lockedUntil, id)
+	return nil
+}
+
+func (r *fakeOutboxRepository) Retry(ctx context.Context, id string, runAfter time.Time, reason string) error {
+	message := r.messages[id]
+	message.RunAfter = runAfter
+	message.LastError = reason
+	r.messages[id] = message
+	delete(r.lockedUntil, id)
+	return nil
+}
+
+func (r *fakeOutboxRepository) Fail(ctx context.Context, id string, reason string) error {
+	message := r.messages[id]
+	now := time.Now()
+	message.FailedDate = &now
+	message.LastError = reason
+	message.Status = OutboxDead
+	r.messages[id] = message
+	delete(r.lockedUntil, id)
+	return nil
+}
+
+func (r *fakeOutboxRepository) Requeue(ctx context.Context, id string) error {
+	message, ok := r.messages[id]
+	if !ok || message.SentDate != nil || r.lockedUntil[id].After(time.Now()) {
+		return ErrMessageNotRetryable
+	}
+
+	message.Attempts = 0
+	message.FailedDate = nil
+	message.RunAfter = time.Now()
+	message.Status = OutboxPending
+	r.messages[id] = message
+	return nil
+}
+
+func (r *fakeOutboxRepository) GetByID(ctx context.Context, id string) (OutboxMessage, error) {
+	message, ok := r.messages[id]
+	if !ok {
+		return OutboxMessage{}, ErrMessageNotFound
+	}
+	return message, nil
+}
+
+func (r *fakeOutboxRepository) WithTx(tx database.DBTX) EmailOutboxRepository {
+	return r
+}
+
+func newTestDispatcher(provider emails.EmailProvider, messages ...OutboxMessage) (*emailDispatcher, *fakeOutboxRepository) {
+	repo := newFakeOutboxRepository(messages...)
+	dispatcher := NewEmailDispatcher(repo, provider, nil, &configs.AppConfig{OutboxInterval: time.Second, OutboxMaxAttempts: 3})
+	return dispatcher, repo
+}
+
+// woken reports whether a worker was woken, consuming the wake up
+func woken(dispatcher *emailDispatcher) bool {
+	select {
+	case <-dispatcher.wake:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestEmailDispatcher_EnqueueWakesWorkersOnCommit(t *testing.T) {
+	dispatcher, repo := newTestDispatcher(&MockEmailProvider{})
+	ctx := database.WithTenant(context.Background(), testTenant)
+
+	err := database.NewFakeTxManager().WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		id, err := dispatcher.Enqueue(ctx, uow, testMessage.Email, "")
+		assert.Equal(t, "message-1", id)
+		assert.False(t, woken(dispatcher), "the message is not visible before the commit")
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, woken(dispatcher))
+	if assert.Contains(t, repo.messages, "message-1") {
+		assert.Equal(t, testTenant, repo.messages["message-1"].TenantUUID)
+		assert.Equal(t, OutboxPending, repo.messages["message-1"].Status)
+	}
+}
+
+func TestEmailDispatcher_EnqueueDeduplicatesByIdempotencyKey(t *testing.T) {
+	dispatcher, repo := newTestDispatcher(&MockEmailProvider{})
+	ctx := database.WithTenant(context.Background(), testTenant)
+
+	first, err := dispatcher.Enqueue(ctx, nil, testMessage.Email, "welcome:user-1")
+	assert.NoError(t, err)
+	assert.True(t, woken(dispatcher))
+
+	second, err := dispatcher.Enqueue(ctx, nil, testMessage.Email, "welcome:user-1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Len(t, repo.messages, 1)
+	assert.False(t, woken(dispatcher), "the message was already queued")
+}
+
+func TestEmailDispatcher_SendsMessage(t *testing.T) {
+	var sent emails.EmailDto
+	dispatcher, repo := newTestDispatcher(&MockEmailProvider{SendFunc: func(email emails.EmailDto) error {
+		sent = email
+		return nil
+	}}, testMessage)
+
+	dispatcher.handle(context.Background(), testMessage)
+
+	assert.Equal(t, "Hello", sent.Body)
+	assert.Equal(t, "<message-1@company.com>", sent.Headers["Message-ID"])
+	assert.Nil(t, testMessage.Email.Headers, "the queued email is left as is")
+	assert.Equal(t, OutboxSent, repo.messages["message-1"].Status)
+	assert.NotNil(t, repo.messages["message-1"].SentDate)
+}
+
+func TestEmailDispatcher_HandlesFailedSend(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		status   string
+		retried  bool
+	}{
+		{name: "retries the message while attempts are left", attempts: 1, status: OutboxPending, retried: true},
+		{name: "dead-letters the message on the last attempt", attempts: 3, status: OutboxDead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := testMessage
+			message.Attempts = tt.attempts
+			dispatcher, repo := newTestDispatcher(&MockEmailProvider{SendFunc: func(email emails.EmailDto) error {
+				return errors.New("connection refused")
+			}}, message)
+
+			dispatcher.handle(context.Background(), message)
+
+			stored := repo.messages["message-1"]
+			assert.Equal(t, tt.status, stored.Status)
+			assert.Equal(t, "connection refused", stored.LastError)
+			assert.Nil(t, stored.SentDate)
+			assert.Equal(t, tt.retried, stored.RunAfter.After(time.Now()), "a retried message waits before the next attempt")
+		})
+	}
+}
+
+func TestEmailDispatcher_Retry(t *testing.T) {
+	failed := time.Now()
+	dead := testMessage
+	dead.Attempts = 3
+	dead.LastError = "connection refused"
+	dead.FailedDate = &failed
+	dead.Status = OutboxDead
+
+	sent := testMessage
+	sent.SentDate = &failed
+	sent.Status = OutboxSent
+
+	tests := []struct {
+		name     string
+		message  OutboxMessage
+		err      error
+		status   string
+		attempts int
+	}{
+		{name: "requeues a dead message", message: dead, status: OutboxPending},
+		{name: "leaves a sent message", message: sent, err: ErrMessageNotRetryable, status: OutboxSent, attempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dispatcher, repo := newTestDispatcher(&MockEmailProvider{}, tt.message)
+
+			message, err := dispatcher.Retry(context.Background(), "message-1")
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.err == nil, woken(dispatcher))
+			if tt.err == nil {
+				assert.Equal(t, tt.status, message.Status)
+				assert.Equal(t, []string{"user@example.com"}, message.Email.To)
+			}
+			assert.Equal(t, tt.status, repo.messages["message-1"].Status)
+			assert.Equal(t, tt.attempts, repo.messages["message-1"].Attempts)
+		})
+	}
+}
+
+func TestEmailDispatcher_RetryUnknownMessage(t *testing.T) {
+	dispatcher, _ := newTestDispatcher(&MockEmailProvider{})
+
+	_, err := dispatcher.Retry(context.Background(), "message-1")
+
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+	assert.False(t, woken(dispatcher))
+}
+
+func TestEmailService_QueueTemplate(t *testing.T) {
+	dispatcher, repo := newTestDispatcher(&MockEmailProvider{})
+	emailService := NewEmailService(&MockEmailProvider{}, NewTemplateRenderer(&configs.AppConfig{}), dispatcher, nil, "no-reply@company.com")
+	ctx := database.WithTenant(context.Background(), testTenant)
+
+	err := emailService.QueueTemplate(ctx, nil, "user@example.com", TemplatePasswordChanged, "en", PasswordChangedData{Name: "Alice"}, "")
+
+	assert.NoError(t, err)
+	assert.True(t, woken(dispatcher))
+	if assert.Contains(t, repo.messages, "message-1") {
+		email := repo.messages["message-1"].Email
+		assert.Equal(t, []string{"user@example.com"}, email.To)
+		assert.Equal(t, "no-reply@company.com", email.Sender)
+		assert.Contains(t, email.Body, "Alice")
+	}
+}
@@ -0,0 +1,122 @@
+package email
+
+import (
+	"bernardtm/backend/internal/core/shareds"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailOutboxController interface {
+	GetAll(ctx *gin.Context)
+	GetByID(ctx *gin.Context)
+	Retry(ctx *gin.Context)
+}
+
+type emailOutboxController struct {
+	dispatcher EmailDispatcher
+}
+
+func NewEmailOutboxController(dispatcher EmailDispatcher) *emailOutboxController {
+	return &emailOutboxController{dispatcher: dispatcher}
+}
+
+// GetAll lists the outbox messages
+// @Summary List the outbox messages
+// @Description Lists the queued, sent and dead-lettered emails of every tenant, most recent first
+// @Tags Emails
+// @Produce json
+// @Security AdminKey
+// @Param status query string false "pending, sent or dead, every message when empty"
+// @Param page query int false "Page Number"
+// @Param size query int false "Page Size"
+// @Success 200 {array} OutboxMessage
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 401 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /emails/outbox [get]
+func (oc *emailOutboxController) GetAll(c *gin.Context) {
+	status := c.Query("status")
+	if _, ok := outboxStatusFilters[status]; !ok {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid status, expected pending, sent or dead"})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid page parameter"})
+		return
+	}
+
+	size, err := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if err != nil || size <= 0 || size > 100 {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid size parameter"})
+		return
+	}
+
+	messages, err := oc.dispatcher.GetAll(c.Request.Context(), status, page, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching outbox messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, messages)
+}
+
+// GetByID returns an outbox message
+// @Summary Get an outbox message
+// @Tags Emails
+// @Produce json
+// @Security AdminKey
+// @Param id path string true "Message ID"
+// @Success 200 {object} OutboxMessage
+// @Failure 401 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /emails/outbox/{id} [get]
+func (oc *emailOutboxController) GetByID(c *gin.Context) {
+	message, err := oc.dispatcher.GetByID(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, ErrMessageNotFound) {
+		c.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Outbox message not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching outbox message"})
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
+
+// Retry sends an outbox message again
+// @Summary Retry an outbox message
+// @Description Sends a dead-lettered or pending email again right away, with a fresh set of attempts
+// @Tags Emails
+// @Produce json
+// @Security AdminKey
+// @Param id path string true "Message ID"
+// @Success 200 {object} OutboxMessage
+// @Failure 401 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 409 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /emails/outbox/{id}/retry [post]
+func (oc *emailOutboxController) Retry(c *gin.Context) {
+	message, err := oc.dispatcher.Retry(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, ErrMessageNotFound) {
+		c.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Outbox message not found"})
+		return
+	}
+	if errors.Is(err, ErrMessageNotRetryable) {
+		c.JSON(http.StatusConflict, shareds.ErrorResponse{Message: "Outbox message already sent or being sent"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error retrying outbox message"})
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
@@ -0,0 +1,32 @@
+package email
+
+import (
+	"bernardtm/backend/pkg/providers/emails"
+	"time"
+)
+
+// States of the outbox messages
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead"
+)
+
+// OutboxMessage is an email queued in the outbox. It is pending until sent, or
+// dead once its attempts are exhausted
+type OutboxMessage struct {
+	MessageUUID      string          `json:"message_uuid" db:"message_uuid"`                     // UUID da mensagem (chave primaria)
+	TenantUUID       string          `json:"tenant_uuid" db:"tenant_uuid"`                       // Tenant que enviou a mensagem
+	IdempotencyKey   string          `json:"idempotency_key,omitempty" db:"idempotency_key"`     // Chave que evita enfileirar a mesma mensagem duas vezes
+	Email            emails.EmailDto `json:"email" db:"payload"`                                 // Email enviado
+	Status           string          `json:"status"`                                             // pending, sent ou dead
+	Attempts         int             `json:"attempts" db:"attempts"`                             // Tentativas iniciadas
+	LastError        string          `json:"last_error,omitempty" db:"last_error"`               // Erro da ultima tentativa
+	RunAfter         time.Time       `json:"run_after" db:"run_after"`                           // Proxima tentativa
+	SentDate         *time.Time      `json:"sent_date,omitempty" db:"sent_date"`                 // Data de envio
+	FailedDate       *time.Time      `json:"failed_date,omitempty" db:"failed_date"`             // Data em que as tentativas se esgotaram
+	DeliveryStatus   string          `json:"delivery_status,omitempty" db:"delivery_status"`     // Ultimo evento reportado pelo provedor
+	DeliveryDate     *time.Time      `json:"delivery_date,omitempty" db:"delivery_date"`         // Data do ultimo evento reportado pelo provedor
+	CreationDate     time.Time       `json:"creation_date" db:"creation_date"`                   // Data de criação
+	ModificationDate *time.Time      `json:"modification_date,omitempty" db:"modification_date"` // Data de modificação
+}
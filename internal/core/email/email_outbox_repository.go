@@ -0,0 +1,339 @@
+package email
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/utils"
+	"bernardtm/backend/pkg/providers/emails"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	// ErrMessageNotFound is returned when no outbox message matches the given ID
+	ErrMessageNotFound = errors.New("outbox message not found")
+	// ErrMessageNotRetryable is returned when retrying a message already sent or
+	// being sent
+	ErrMessageNotRetryable = errors.New("outbox message cannot be retried")
+)
+
+// outboxColumns is the column list scanned by scanOutboxMessage
+const outboxColumns = `message_uuid, tenant_uuid, idempotency_key, payload, attempts, last_error, run_after, sent_date, failed_date, delivery_status, delivery_date, creation_date, modification_date`
+
+// outboxStatusFilters are the conditions selecting the messages of each state
+var outboxStatusFilters = map[string]string{
+	"":            "TRUE",
+	OutboxPending: "sent_date IS NULL AND failed_date IS NULL",
+	OutboxSent:    "sent_date IS NOT NULL",
+	OutboxDead:    "failed_date IS NOT NULL",
+}
+
+// EmailOutboxRepository stores the outbox messages. Enqueue is scoped to the tenant
+// of the context, the other methods serve the dispatcher and the administrators
+// across every tenant
+type EmailOutboxRepository interface {
+	Enqueue(ctx context.Context, email emails.EmailDto, idempotencyKey string) (string, bool, error)
+	Claim(ctx context.Context, lease time.Duration) (OutboxMessage, bool, error)
+	MarkSent(ctx context.Context, id string) error
+	Retry(ctx context.Context, id string, runAfter time.Time, reason string) error
+	Fail(ctx context.Context, id string, reason string) error
+	Requeue(ctx context.Context, id string) error
+	GetByID(ctx context.Context, id string) (OutboxMessage, error)
+	GetAll(ctx context.Context, status string, page, size int) ([]OutboxMessage, error)
+	PurgeSent(ctx context.Context, before time.Time) (int64, error)
+	WithTx(tx database.DBTX) EmailOutboxRepository
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+type emailOutboxRepository struct {
+	db      database.DBTX
+	timeout time.Duration
+}
+
+func NewEmailOutboxRepository(db database.DBTX, timeout time.Duration) *emailOutboxRepository {
+	return &emailOutboxRepository{db: db, timeout: timeout}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *emailOutboxRepository) WithTx(tx database.DBTX) EmailOutboxRepository {
+	return &emailOutboxRepository{db: tx, timeout: r.timeout}
+}
+
+func scanOutboxMessage(row rowScanner) (OutboxMessage, error) {
+	var model OutboxMessage
+	var idempotencyKey, lastError, deliveryStatus sql.NullString
+	var payload []byte
+
+	err := row.Scan(&model.MessageUUID, &model.TenantUUID, &idempotencyKey, &payload, &model.Attempts, &lastError, &model.RunAfter, &model.SentDate, &model.FailedDate, &deliveryStatus, &model.DeliveryDate, &model.CreationDate, &model.ModificationDate)
+	if err != nil {
+		return OutboxMessage{}, err
+	}
+	if err := json.Unmarshal(payload, &model.Email); err != nil {
+		return OutboxMessage{}, fmt.Errorf("failed to decode outbox message %s: %w", model.MessageUUID, err)
+	}
+
+	model.IdempotencyKey = idempotencyKey.String
+	model.LastError = lastError.String
+	model.DeliveryStatus = deliveryStatus.String
+	switch {
+	case model.SentDate != nil:
+		model.Status = OutboxSent
+	case model.FailedDate != nil:
+		model.Status = OutboxDead
+	default:
+		model.Status = OutboxPending
+	}
+	return model, nil
+}
+
+// Enqueue queues an email. A message already queued by the tenant with the same
+// idempotency key is kept instead, its ID being returned with created false. Run it
+// in the transaction of the operation sending the email, so the email is sent if
+// and only if the operation is committed
+func (r *emailOutboxRepository) Enqueue(ctx context.Context, email emails.EmailDto, idempotencyKey string) (string, bool, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return "", false, err
+	}
+
+	payload, err := json.Marshal(email)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to encode email: %w", err)
+	}
+
+	var id string
+
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO default_schema.email_outbox (idempotency_key, payload, tenant_uuid)
+		VALUES (NULLIF($1, ''), $2, $3)
+		ON CONFLICT (tenant_uuid, idempotency_key) DO NOTHING
+		RETURNING message_uuid`, idempotencyKey, payload, tenantID).Scan(&id)
+
+	if err == nil {
+		return id, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Print(err)
+		return "", false, errors.New("failed to enqueue email")
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+		SELECT message_uuid
+		FROM default_schema.email_outbox
+		WHERE idempotency_key = $1 AND tenant_uuid = $2`, idempotencyKey, tenantID).Scan(&id)
+
+	if err != nil {
+		log.Print(err)
+		return "", false, errors.New("failed to enqueue email")
+	}
+
+	return id, false, nil
+}
+
+// Claim takes the next due message of any tenant and leases it to the caller. A
+// message whose lease expires, e.g. because its worker died, is claimed again
+func (r *emailOutboxRepository) Claim(ctx context.Context, lease time.Duration) (OutboxMessage, bool, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	model, err := scanOutboxMessage(r.db.QueryRowContext(ctx, `
+		UPDATE default_schema.email_outbox
+		SET attempts = attempts + 1,
+		    locked_until = CURRENT_TIMESTAMP + $1 * INTERVAL '1 millisecond',
+		    modification_date = CURRENT_TIMESTAMP
+		WHERE message_uuid = (
+			SELECT message_uuid
+			FROM default_schema.email_outbox
+			WHERE sent_date IS NULL
+			  AND failed_date IS NULL
+			  AND run_after <= CURRENT_TIMESTAMP
+			  AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)
+			ORDER BY run_after
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING `+outboxColumns, lease.Milliseconds()))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return OutboxMessage{}, false, nil
+		}
+
+		log.Print(err)
+		return OutboxMessage{}, false, errors.New("failed to claim outbox message")
+	}
+
+	return model, true, nil
+}
+
+// MarkSent records the delivery of a message to the provider
+func (r *emailOutboxRepository) MarkSent(ctx context.Context, id string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE default_schema.email_outbox
+		SET sent_date = CURRENT_TIMESTAMP,
+		    locked_until = NULL,
+		    modification_date = CURRENT_TIMESTAMP
+		WHERE message_uuid = $1`, id)
+
+	if err != nil {
+		return errors.New("failed to mark outbox message as sent")
+	}
+
+	return nil
+}
+
+// Retry releases a message until runAfter, recording why the attempt failed
+func (r *emailOutboxRepository) Retry(ctx context.Context, id string, runAfter time.Time, reason string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE default_schema.email_outbox
+		SET run_after = $2,
+		    last_error = $3,
+		    locked_until = NULL,
+		    modification_date = CURRENT_TIMESTAMP
+		WHERE message_uuid = $1`, id, runAfter, reason)
+
+	if err != nil {
+		return errors.New("failed to retry outbox message")
+	}
+
+	return nil
+}
+
+// Fail dead-letters a message. It is kept with its last error until retried
+func (r *emailOutboxRepository) Fail(ctx context.Context, id string, reason string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE default_schema.email_outbox
+		SET failed_date = CURRENT_TIMESTAMP,
+		    last_error = $2,
+		    locked_until = NULL,
+		    modification_date = CURRENT_TIMESTAMP
+		WHERE message_uuid = $1`, id, reason)
+
+	if err != nil {
+		return errors.New("failed to fail outbox message")
+	}
+
+	return nil
+}
+
+// Requeue schedules a dead or pending message right away with a fresh set of
+// attempts. Messages already sent or being sent are left as they are
+func (r *emailOutboxRepository) Requeue(ctx context.Context, id string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE default_schema.email_outbox
+		SET attempts = 0,
+		    failed_date = NULL,
+		    run_after = CURRENT_TIMESTAMP,
+		    modification_date = CURRENT_TIMESTAMP
+		WHERE message_uuid = $1
+		  AND sent_date IS NULL
+		  AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)`, id)
+
+	if err != nil {
+		return errors.New("failed to requeue outbox message")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.New("failed to requeue outbox message")
+	}
+	if affected == 0 {
+		return ErrMessageNotRetryable
+	}
+
+	return nil
+}
+
+func (r *emailOutboxRepository) GetByID(ctx context.Context, id string) (OutboxMessage, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	model, err := scanOutboxMessage(r.db.QueryRowContext(ctx, `
+		SELECT `+outboxColumns+`
+		FROM default_schema.email_outbox
+		WHERE message_uuid = $1`, id))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return OutboxMessage{}, ErrMessageNotFound
+		}
+
+		return OutboxMessage{}, errors.New("failed to retrive outbox message")
+	}
+
+	return model, nil
+}
+
+// GetAll returns a page of the messages in a state, or of every message when the
+// state is empty, most recent first
+func (r *emailOutboxRepository) GetAll(ctx context.Context, status string, page, size int) ([]OutboxMessage, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter, ok := outboxStatusFilters[status]
+	if !ok {
+		return nil, fmt.Errorf("invalid outbox status %q", status)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+outboxColumns+`
+		FROM default_schema.email_outbox
+		WHERE `+filter+`
+		ORDER BY creation_date DESC
+		LIMIT $1 OFFSET $2`, size, (page-1)*size)
+
+	if err != nil {
+		return nil, errors.New("failed to retrive outbox messages")
+	}
+
+	defer rows.Close()
+
+	models := []OutboxMessage{}
+	for rows.Next() {
+		model, err := scanOutboxMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		models = append(models, model)
+	}
+
+	return models, nil
+}
+
+// PurgeSent deletes the messages sent before the given time, returning how many
+func (r *emailOutboxRepository) PurgeSent(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM default_schema.email_outbox
+		WHERE sent_date < $1`, before)
+
+	if err != nil {
+		return 0, errors.New("failed to purge sent outbox messages")
+	}
+
+	return result.RowsAffected()
+}
@@ -1,22 +1,94 @@
 package email
 
 import (
+	"bernardtm/backend/internal/infra/database"
 	"bernardtm/backend/pkg/providers/emails"
+	"context"
 	"errors"
 )
 
+// ErrRecipientSuppressed is returned when every main recipient of an email is in
+// the suppression list
+var ErrRecipientSuppressed = errors.New("email recipients are suppressed")
+
 // EmailService provides methods to send emails using any provider
 type EmailService struct {
-	provider emails.EmailProvider
+	provider     emails.EmailProvider
+	templates    TemplateRenderer
+	dispatcher   EmailDispatcher
+	suppressions SuppressionList
+	sender       string
+}
+
+// NewEmailService creates a new EmailService instance. The templated emails are
+// rendered by templates and sent from sender, the queued emails are sent by
+// dispatcher, and no email is sent to the addresses in suppressions
+func NewEmailService(provider emails.EmailProvider, templates TemplateRenderer, dispatcher EmailDispatcher, suppressions SuppressionList, sender string) *EmailService {
+	return &EmailService{provider: provider, templates: templates, dispatcher: dispatcher, suppressions: suppressions, sender: sender}
+}
+
+// SendEmail sends an email using the configured provider, leaving out the
+// suppressed recipients
+func (s *EmailService) SendEmail(ctx context.Context, email emails.EmailDto) error {
+	if err := validate(email); err != nil {
+		return err
+	}
+
+	email, ok := withoutSuppressed(ctx, s.suppressions, email)
+	if !ok {
+		return ErrRecipientSuppressed
+	}
+
+	// Delegate email sending to the configured provider
+	return s.provider.Send(ctx, email)
+}
+
+// QueueEmail queues an email in the outbox, in the transaction of uow when not
+// nil, to be sent in background with retries. Emails queued with the same
+// non-empty idempotency key are sent once
+func (s *EmailService) QueueEmail(ctx context.Context, uow *database.UnitOfWork, email emails.EmailDto, idempotencyKey string) error {
+	if err := validate(email); err != nil {
+		return err
+	}
+
+	_, err := s.dispatcher.Enqueue(ctx, uow, email, idempotencyKey)
+	return err
+}
+
+// SendTemplate renders a template in the locale closest to the given one and sends
+// it to a recipient, with the text body and its HTML alternative
+func (s *EmailService) SendTemplate(ctx context.Context, to string, name string, locale string, data any) error {
+	rendered, err := s.templates.Render(name, locale, data)
+	if err != nil {
+		return err
+	}
+
+	return s.SendEmail(ctx, s.templated(to, rendered))
 }
 
-// NewEmailService creates a new EmailService instance
-func NewEmailService(provider emails.EmailProvider) *EmailService {
-	return &EmailService{provider: provider}
+// QueueTemplate renders a template like SendTemplate and queues it like QueueEmail
+func (s *EmailService) QueueTemplate(ctx context.Context, uow *database.UnitOfWork, to string, name string, locale string, data any, idempotencyKey string) error {
+	rendered, err := s.templates.Render(name, locale, data)
+	if err != nil {
+		return err
+	}
+
+	return s.QueueEmail(ctx, uow, s.templated(to, rendered), idempotencyKey)
+}
+
+// templated returns the email of a rendered template, with the text body and its
+// HTML alternative
+func (s *EmailService) templated(to string, rendered RenderedEmail) emails.EmailDto {
+	return emails.EmailDto{
+		Sender:  s.sender,
+		To:      []string{to},
+		Subject: rendered.Subject,
+		Body:    rendered.Text,
+		HTML:    rendered.HTML,
+	}
 }
 
-// SendEmail sends an email using the configured provider
-func (s *EmailService) SendEmail(email emails.EmailDto) error {
+func validate(email emails.EmailDto) error {
 	if len(email.To) == 0 {
 		return errors.New("email must have at least one recipient")
 	}
@@ -25,6 +97,5 @@ func (s *EmailService) SendEmail(email emails.EmailDto) error {
 		return errors.New("email must have a sender address")
 	}
 
-	// Delegate email sending to the configured provider
-	return s.provider.Send(email)
+	return nil
 }
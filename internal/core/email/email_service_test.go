@@ -2,17 +2,21 @@ package email
 
 import (
 	"bernardtm/backend/pkg/providers/emails"
+	"context"
 	"errors"
 	"testing"
+	"time"
 )
 
 // MockEmailProvider is a mock implementation of the EmailProvider interface
 type MockEmailProvider struct {
 	SendFunc func(email emails.EmailDto) error // Function to simulate email sending
+	Ctx      context.Context                   // Context received by the last Send
 }
 
-// Send calls the mock's SendFunc
-func (m *MockEmailProvider) Send(email emails.EmailDto) error {
+// Send records ctx and calls the mock's SendFunc
+func (m *MockEmailProvider) Send(ctx context.Context, email emails.EmailDto) error {
+	m.Ctx = ctx
 	return m.SendFunc(email)
 }
 
@@ -73,10 +77,10 @@ func TestEmailService_SendEmail(t *testing.T) {
 			}
 
 			// Create the email service using the mock provider
-			emailService := NewEmailService(mockProvider)
+			emailService := NewEmailService(mockProvider, nil, nil, nil, "")
 
 			// Attempt to send the email
-			err := emailService.SendEmail(tt.email)
+			err := emailService.SendEmail(context.Background(), tt.email)
 
 			// Validate the result
 			if (err != nil) != tt.expectError {
@@ -85,3 +89,39 @@ func TestEmailService_SendEmail(t *testing.T) {
 		})
 	}
 }
+
+// TestEmailService_SendEmailCancelled checks that the request context reaches the
+// provider, which stops waiting once it is cancelled
+func TestEmailService_SendEmailCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	mockProvider := &MockEmailProvider{}
+	mockProvider.SendFunc = func(email emails.EmailDto) error {
+		// a provider blocked on the network until the request goes away
+		<-mockProvider.Ctx.Done()
+		return mockProvider.Ctx.Err()
+	}
+	emailService := NewEmailService(mockProvider, nil, nil, nil, "")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- emailService.SendEmail(ctx, emails.EmailDto{
+			To:      []string{"recipient@example.com"},
+			Sender:  "sender@example.com",
+			Subject: "Test Subject",
+			Body:    "Test Body",
+		})
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("provider did not receive the cancellation of the request context")
+	}
+	if mockProvider.Ctx.Done() != ctx.Done() {
+		t.Error("provider must receive the request context")
+	}
+}
@@ -0,0 +1,241 @@
+package email
+
+import (
+	"bernardtm/backend/configs"
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+// ErrTemplateNotFound is returned when an email template does not exist
+var ErrTemplateNotFound = errors.New("email template not found")
+
+// Locales lists the locales the email templates are written in
+var Locales = []string{"pt-BR", "en", "es"}
+
+// Names of the email templates
+const (
+	TemplateTwoFactorCode     = "two_factor_code"
+	TemplatePasswordResetLink = "password_reset_link"
+	TemplatePasswordChanged   = "password_changed"
+)
+
+// TwoFactorCodeData fills the two_factor_code template
+type TwoFactorCodeData struct {
+	Code             string
+	ExpiresInMinutes int
+}
+
+// PasswordResetLinkData fills the password_reset_link template
+type PasswordResetLinkData struct {
+	Link             string
+	ExpiresInMinutes int
+}
+
+// PasswordChangedData fills the password_changed template
+type PasswordChangedData struct {
+	Name string
+}
+
+// templateSamples fills each template when previewed
+var templateSamples = map[string]any{
+	TemplateTwoFactorCode:     TwoFactorCodeData{Code: "123456", ExpiresInMinutes: 15},
+	TemplatePasswordResetLink: PasswordResetLinkData{Link: "https://example.com/recovery-password?token=sample", ExpiresInMinutes: 15},
+	TemplatePasswordChanged:   PasswordChangedData{Name: "Maria Silva"},
+}
+
+// RenderedEmail is an email template rendered in a locale
+type RenderedEmail struct {
+	Name    string `json:"name"`
+	Locale  string `json:"locale"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html"`
+}
+
+// TemplateInfo describes an email template and the locales it is written in
+type TemplateInfo struct {
+	Name    string   `json:"name"`
+	Locales []string `json:"locales"`
+}
+
+// TemplateRenderer renders the email templates. Each template has a text and an
+// HTML version per locale, at <locale>/<name>.txt.tmpl and <locale>/<name>.html.tmpl,
+// defining the "content" block, the text version also defining the "subject". They
+// are wrapped by the layouts/base.*.tmpl layouts, and may use the blocks defined in
+// <locale>/partials.*.tmpl
+type TemplateRenderer interface {
+	Render(name string, locale string, data any) (RenderedEmail, error)
+	Preview(name string, locale string) (RenderedEmail, error)
+	Templates() []TemplateInfo
+	ResolveLocale(preferred string) string
+}
+
+type templateRenderer struct {
+	files         fs.FS
+	defaultLocale string
+}
+
+// NewTemplateRenderer creates a renderer of the embedded templates. The templates
+// found in the directory of EMAIL_TEMPLATES_PATH override the embedded ones
+func NewTemplateRenderer(config *configs.AppConfig) *templateRenderer {
+	files, _ := fs.Sub(embeddedTemplates, "templates")
+	if config.EmailTemplatesPath != "" {
+		files = overlayFS{override: os.DirFS(config.EmailTemplatesPath), base: files}
+	}
+
+	renderer := &templateRenderer{files: files, defaultLocale: Locales[0]}
+	renderer.defaultLocale = renderer.ResolveLocale(config.EmailDefaultLocale)
+	return renderer
+}
+
+// ResolveLocale returns the supported locale closest to the preferred one: the same
+// locale, else one of the same language, else the default locale
+func (r *templateRenderer) ResolveLocale(preferred string) string {
+	preferred = strings.ReplaceAll(strings.TrimSpace(preferred), "_", "-")
+	if preferred == "" {
+		return r.defaultLocale
+	}
+
+	for _, locale := range Locales {
+		if strings.EqualFold(locale, preferred) {
+			return locale
+		}
+	}
+
+	language, _, _ := strings.Cut(preferred, "-")
+	for _, locale := range Locales {
+		localeLanguage, _, _ := strings.Cut(locale, "-")
+		if strings.EqualFold(localeLanguage, language) {
+			return locale
+		}
+	}
+	return r.defaultLocale
+}
+
+// Templates lists the email templates
+func (r *templateRenderer) Templates() []TemplateInfo {
+	templates := make([]TemplateInfo, 0, len(templateSamples))
+	for name := range templateSamples {
+		templates = append(templates, TemplateInfo{Name: name, Locales: Locales})
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates
+}
+
+// Preview renders a template with sample data
+func (r *templateRenderer) Preview(name string, locale string) (RenderedEmail, error) {
+	sample, ok := templateSamples[name]
+	if !ok {
+		return RenderedEmail{}, ErrTemplateNotFound
+	}
+	return r.Render(name, locale, sample)
+}
+
+// Render renders the subject, text and HTML of a template in the supported locale
+// closest to the given one. The templates are parsed on each render, so edits of
+// the overrides apply without a restart
+func (r *templateRenderer) Render(name string, locale string, data any) (RenderedEmail, error) {
+	if _, ok := templateSamples[name]; !ok {
+		return RenderedEmail{}, ErrTemplateNotFound
+	}
+	locale = r.ResolveLocale(locale)
+	rendered := RenderedEmail{Name: name, Locale: locale}
+
+	funcs := map[string]any{
+		"locale": func() string { return locale },
+		"dict":   dict,
+	}
+
+	text := texttemplate.New(name).Funcs(funcs)
+	for _, file := range r.templateFiles(name, locale, "txt") {
+		content, err := fs.ReadFile(r.files, file)
+		if err != nil {
+			return RenderedEmail{}, fmt.Errorf("failed to read email template %s: %w", file, err)
+		}
+		if _, err := text.New(file).Parse(string(content)); err != nil {
+			return RenderedEmail{}, fmt.Errorf("failed to parse email template %s: %w", file, err)
+		}
+	}
+
+	var subject, body bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return RenderedEmail{}, fmt.Errorf("failed to render subject of email template %s: %w", name, err)
+	}
+	if err := text.ExecuteTemplate(&body, "layout", data); err != nil {
+		return RenderedEmail{}, fmt.Errorf("failed to render text of email template %s: %w", name, err)
+	}
+	rendered.Subject = strings.TrimSpace(subject.String())
+	rendered.Text = strings.TrimSpace(body.String()) + "\n"
+
+	funcs["subject"] = func() string { return rendered.Subject }
+	html := htmltemplate.New(name).Funcs(funcs)
+	for _, file := range r.templateFiles(name, locale, "html") {
+		content, err := fs.ReadFile(r.files, file)
+		if err != nil {
+			return RenderedEmail{}, fmt.Errorf("failed to read email template %s: %w", file, err)
+		}
+		if _, err := html.New(file).Parse(string(content)); err != nil {
+			return RenderedEmail{}, fmt.Errorf("failed to parse email template %s: %w", file, err)
+		}
+	}
+
+	body.Reset()
+	if err := html.ExecuteTemplate(&body, "layout", data); err != nil {
+		return RenderedEmail{}, fmt.Errorf("failed to render HTML of email template %s: %w", name, err)
+	}
+	rendered.HTML = body.String()
+
+	return rendered, nil
+}
+
+// templateFiles returns the files a template is parsed from, the layout first
+func (r *templateRenderer) templateFiles(name string, locale string, format string) []string {
+	return []string{
+		"layouts/base." + format + ".tmpl",
+		locale + "/partials." + format + ".tmpl",
+		locale + "/" + name + "." + format + ".tmpl",
+	}
+}
+
+// dict builds a map from key and value pairs, to pass several values to a partial
+func dict(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict expects key and value pairs")
+	}
+
+	values := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict key %v is not a string", pairs[i])
+		}
+		values[key] = pairs[i+1]
+	}
+	return values, nil
+}
+
+// overlayFS reads the files from the override directory, falling back to the base
+// files for those it does not have
+type overlayFS struct {
+	override fs.FS
+	base     fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	file, err := o.override.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.base.Open(name)
+	}
+	return file, err
+}
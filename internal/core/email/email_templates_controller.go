@@ -0,0 +1,77 @@
+package email
+
+import (
+	"bernardtm/backend/internal/core/shareds"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailTemplatesController interface {
+	GetAll(ctx *gin.Context)
+	Preview(ctx *gin.Context)
+}
+
+type emailTemplatesController struct {
+	templates TemplateRenderer
+}
+
+func NewEmailTemplatesController(templates TemplateRenderer) *emailTemplatesController {
+	return &emailTemplatesController{templates: templates}
+}
+
+// GetAll lists the email templates
+// @Summary List the email templates
+// @Description Lists the email templates and the locales they are written in
+// @Tags Emails
+// @Produce json
+// @Security AdminKey
+// @Success 200 {array} TemplateInfo
+// @Failure 401 {object} shareds.ErrorResponse
+// @Router /emails/templates [get]
+func (ec *emailTemplatesController) GetAll(c *gin.Context) {
+	c.JSON(http.StatusOK, ec.templates.Templates())
+}
+
+// Preview renders an email template with sample data
+// @Summary Preview an email template
+// @Description Renders an email template with sample data, as the HTML or text body, or as JSON with the subject and both bodies
+// @Tags Emails
+// @Produce html,plain,json
+// @Security AdminKey
+// @Param name path string true "Template name"
+// @Param locale query string false "Locale, the default locale when not supported"
+// @Param format query string false "html (default), text or json"
+// @Success 200 {object} RenderedEmail
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 401 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /emails/templates/{name}/preview [get]
+func (ec *emailTemplatesController) Preview(c *gin.Context) {
+	format := c.DefaultQuery("format", "html")
+	if format != "html" && format != "text" && format != "json" {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid format, expected html, text or json"})
+		return
+	}
+
+	rendered, err := ec.templates.Preview(c.Param("name"), c.Query("locale"))
+	if errors.Is(err, ErrTemplateNotFound) {
+		c.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Email template not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	switch format {
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(rendered.Text))
+	case "json":
+		c.JSON(http.StatusOK, rendered)
+	default:
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(rendered.HTML))
+	}
+}
@@ -0,0 +1,128 @@
+package email
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/pkg/providers/emails"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateRenderer_RendersEveryTemplateInEveryLocale(t *testing.T) {
+	renderer := NewTemplateRenderer(&configs.AppConfig{})
+
+	for _, info := range renderer.Templates() {
+		for _, locale := range info.Locales {
+			rendered, err := renderer.Preview(info.Name, locale)
+
+			assert.NoError(t, err, "%s in %s", info.Name, locale)
+			assert.Equal(t, locale, rendered.Locale)
+			assert.NotEmpty(t, rendered.Subject, "%s in %s", info.Name, locale)
+			assert.NotEmpty(t, rendered.Text, "%s in %s", info.Name, locale)
+			assert.Contains(t, rendered.HTML, `<html lang="`+locale+`">`)
+			assert.Contains(t, rendered.HTML, "<title>"+rendered.Subject+"</title>")
+		}
+	}
+}
+
+func TestTemplateRenderer_Render(t *testing.T) {
+	renderer := NewTemplateRenderer(&configs.AppConfig{})
+
+	rendered, err := renderer.Render(TemplatePasswordResetLink, "en", PasswordResetLinkData{
+		Link:             "https://app.example.com/recovery-password?token=abc",
+		ExpiresInMinutes: 15,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Password Reset Request", rendered.Subject)
+	assert.Contains(t, rendered.Text, "https://app.example.com/recovery-password?token=abc")
+	assert.Contains(t, rendered.Text, "This is an automated email")
+	assert.Contains(t, rendered.HTML, `href="https://app.example.com/recovery-password?token=abc"`)
+	assert.Contains(t, rendered.HTML, "Reset password")
+}
+
+func TestTemplateRenderer_EscapesHTML(t *testing.T) {
+	renderer := NewTemplateRenderer(&configs.AppConfig{})
+
+	rendered, err := renderer.Render(TemplatePasswordChanged, "en", PasswordChangedData{Name: "<script>alert(1)</script>"})
+
+	assert.NoError(t, err)
+	assert.NotContains(t, rendered.HTML, "<script>")
+	assert.Contains(t, rendered.HTML, "&lt;script&gt;")
+	assert.Contains(t, rendered.Text, "<script>alert(1)</script>", "the text body is not escaped")
+}
+
+func TestTemplateRenderer_NotFound(t *testing.T) {
+	renderer := NewTemplateRenderer(&configs.AppConfig{})
+
+	_, err := renderer.Preview("missing", "en")
+
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+}
+
+func TestTemplateRenderer_ResolveLocale(t *testing.T) {
+	renderer := NewTemplateRenderer(&configs.AppConfig{EmailDefaultLocale: "en"})
+
+	tests := map[string]string{
+		"pt-BR": "pt-BR",
+		"pt_br": "pt-BR",
+		"pt-PT": "pt-BR",
+		"es-AR": "es",
+		"EN":    "en",
+		"fr-FR": "en",
+		"":      "en",
+	}
+	for preferred, expected := range tests {
+		assert.Equal(t, expected, renderer.ResolveLocale(preferred), preferred)
+	}
+}
+
+func TestTemplateRenderer_UnsupportedDefaultLocale(t *testing.T) {
+	renderer := NewTemplateRenderer(&configs.AppConfig{EmailDefaultLocale: "fr"})
+
+	assert.Equal(t, "pt-BR", renderer.ResolveLocale(""))
+}
+
+func TestTemplateRenderer_OverridesFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "en"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	override := `{{define "subject"}}Your code{{end}}{{define "content"}}Code: {{.Code}}{{end}}`
+	if err := os.WriteFile(filepath.Join(dir, "en", "two_factor_code.txt.tmpl"), []byte(override), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	renderer := NewTemplateRenderer(&configs.AppConfig{EmailTemplatesPath: dir})
+
+	rendered, err := renderer.Render(TemplateTwoFactorCode, "en", TwoFactorCodeData{Code: "654321", ExpiresInMinutes: 15})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Your code", rendered.Subject)
+	assert.Contains(t, rendered.Text, "Code: 654321")
+	assert.Contains(t, rendered.Text, "This is an automated email", "the layout and partials are still embedded")
+	assert.Contains(t, rendered.HTML, "This is your verification code", "the HTML version is not overridden")
+}
+
+func TestEmailService_SendTemplate(t *testing.T) {
+	var sent emails.EmailDto
+	mockProvider := &MockEmailProvider{
+		SendFunc: func(email emails.EmailDto) error {
+			sent = email
+			return nil
+		},
+	}
+	emailService := NewEmailService(mockProvider, NewTemplateRenderer(&configs.AppConfig{}), nil, nil, "no-reply@company.com")
+
+	err := emailService.SendTemplate(context.Background(), "user@example.com", TemplateTwoFactorCode, "es", TwoFactorCodeData{Code: "123456", ExpiresInMinutes: 15})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "no-reply@company.com", sent.Sender)
+	assert.Equal(t, []string{"user@example.com"}, sent.To)
+	assert.Equal(t, "Código de Verificación", sent.Subject)
+	assert.Contains(t, sent.Body, "123456")
+	assert.Contains(t, sent.HTML, "123456")
+	assert.False(t, sent.IsHTML, "the body is the text version, with the HTML one as alternative")
+}
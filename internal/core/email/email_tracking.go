@@ -0,0 +1,191 @@
+package email
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/providers/emails"
+	"context"
+	"log"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SuppressionList tells which recipients must not be sent emails
+type SuppressionList interface {
+	Suppressed(ctx context.Context, addresses []string) ([]string, error)
+}
+
+// EmailTracker records the delivery events reported by the provider webhooks and
+// keeps the suppression list, adding the addresses that hard bounced or complained
+type EmailTracker interface {
+	SuppressionList
+	HandleMailgunWebhook(ctx context.Context, body []byte) error
+	GetEvents(ctx context.Context, messageUUID string) ([]DeliveryEvent, error)
+	GetSuppressions(ctx context.Context, page, size int) ([]Suppression, error)
+	DeleteSuppression(ctx context.Context, address string) error
+}
+
+type emailTracker struct {
+	trackingRepo EmailTrackingRepository
+	txManager    database.TxManager
+	signingKey   string
+	maxAge       time.Duration
+	now          func() time.Time
+}
+
+func NewEmailTracker(trackingRepo EmailTrackingRepository, txManager database.TxManager, config *configs.AppConfig) *emailTracker {
+	return &emailTracker{
+		trackingRepo: trackingRepo,
+		txManager:    txManager,
+		signingKey:   config.MailgunSigningKey,
+		maxAge:       config.WebhookMaxAge,
+		now:          time.Now,
+	}
+}
+
+// HandleMailgunWebhook verifies and records a Mailgun event. Events already
+// recorded and events that are not tracked are ignored, and a webhook whose token
+// was already used is rejected with ErrWebhookReplayed
+func (t *emailTracker) HandleMailgunWebhook(ctx context.Context, body []byte) error {
+	now := t.now()
+	event, token, ok, err := parseMailgunWebhook(body, t.signingKey, t.maxAge, now)
+	if err != nil || !ok {
+		return err
+	}
+
+	if err := t.record(ctx, token, event); err != nil {
+		return err
+	}
+
+	// the tokens signed before the maximum age are rejected by their signature
+	if t.maxAge > 0 {
+		if _, err := t.trackingRepo.PurgeWebhookTokens(ctx, now.Add(-t.maxAge)); err != nil {
+			log.Printf("failed to purge webhook tokens: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// record uses the token of the webhook, stores its event, updates the delivery
+// status of its outbox message and suppresses its recipient after a hard bounce or
+// a complaint
+func (t *emailTracker) record(ctx context.Context, token webhookToken, event DeliveryEvent) error {
+	return t.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		trackingRepo := t.trackingRepo.WithTx(uow.Tx())
+
+		unused, err := trackingRepo.UseWebhookToken(ctx, token.provider, token.token, token.signedDate)
+		if err != nil {
+			return err
+		}
+		if !unused {
+			return ErrWebhookReplayed
+		}
+
+		created, err := trackingRepo.CreateEvent(ctx, event)
+		if err != nil || !created {
+			return err
+		}
+
+		if event.MessageUUID != "" {
+			if err := trackingRepo.UpdateDeliveryStatus(ctx, event.MessageUUID, event.Event, event.OccurredDate); err != nil {
+				return err
+			}
+		}
+
+		reason := map[string]string{EventBounced: SuppressedBounce, EventComplained: SuppressedComplaint}[event.Event]
+		if reason == "" {
+			return nil
+		}
+
+		log.Printf("suppressing %s after a %s: %s", event.Recipient, reason, event.Reason)
+		return trackingRepo.Suppress(ctx, Suppression{Email: event.Recipient, Reason: reason, Detail: event.Reason})
+	})
+}
+
+// Suppressed returns which of the given addresses are suppressed, as given
+func (t *emailTracker) Suppressed(ctx context.Context, addresses []string) ([]string, error) {
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+
+	normalized := make([]string, len(addresses))
+	for i, address := range addresses {
+		normalized[i] = normalizeAddress(address)
+	}
+
+	found, err := t.trackingRepo.GetSuppressed(ctx, normalized)
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+
+	suppressed := []string{}
+	for i, address := range addresses {
+		for _, match := range found {
+			if normalized[i] == match {
+				suppressed = append(suppressed, address)
+				break
+			}
+		}
+	}
+
+	return suppressed, nil
+}
+
+func (t *emailTracker) GetEvents(ctx context.Context, messageUUID string) ([]DeliveryEvent, error) {
+	return t.trackingRepo.GetEvents(ctx, messageUUID)
+}
+
+func (t *emailTracker) GetSuppressions(ctx context.Context, page, size int) ([]Suppression, error) {
+	return t.trackingRepo.GetSuppressions(ctx, page, size)
+}
+
+// DeleteSuppression lets the emails be sent to an address again
+func (t *emailTracker) DeleteSuppression(ctx context.Context, address string) error {
+	return t.trackingRepo.DeleteSuppression(ctx, normalizeAddress(address))
+}
+
+// normalizeAddress returns the lower-case address of a recipient, which may
+// include a display name
+func normalizeAddress(recipient string) string {
+	if address, err := mail.ParseAddress(recipient); err == nil {
+		recipient = address.Address
+	}
+	return strings.ToLower(strings.TrimSpace(recipient))
+}
+
+// withoutSuppressed removes the suppressed recipients of an email, reporting false
+// when none of its main recipients is left. The email is sent as is when the
+// suppression list cannot be read, a missed suppression being better than a
+// missed verification code
+func withoutSuppressed(ctx context.Context, suppressions SuppressionList, email emails.EmailDto) (emails.EmailDto, bool) {
+	if suppressions == nil {
+		return email, true
+	}
+
+	recipients := append(append(append([]string{}, email.To...), email.CC...), email.BCC...)
+	suppressed, err := suppressions.Suppressed(ctx, recipients)
+	if err != nil {
+		log.Printf("failed to check suppressed recipients: %v", err)
+		return email, true
+	}
+	if len(suppressed) == 0 {
+		return email, true
+	}
+
+	log.Printf("not sending email %q to suppressed recipients %v", email.Subject, suppressed)
+	keep := func(addresses []string) []string {
+		var kept []string
+		for _, address := range addresses {
+			if !slices.Contains(suppressed, address) {
+				kept = append(kept, address)
+			}
+		}
+		return kept
+	}
+	email.To, email.CC, email.BCC = keep(email.To), keep(email.CC), keep(email.BCC)
+
+	return email, len(email.To) > 0
+}
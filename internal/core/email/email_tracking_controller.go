@@ -0,0 +1,147 @@
+package email
+
+import (
+	"bernardtm/backend/internal/core/shareds"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookBytes bounds the body of the provider webhooks
+const maxWebhookBytes = 1 << 20
+
+type EmailTrackingController interface {
+	MailgunWebhook(ctx *gin.Context)
+	GetEvents(ctx *gin.Context)
+	GetSuppressions(ctx *gin.Context)
+	DeleteSuppression(ctx *gin.Context)
+}
+
+type emailTrackingController struct {
+	tracker EmailTracker
+}
+
+func NewEmailTrackingController(tracker EmailTracker) *emailTrackingController {
+	return &emailTrackingController{tracker: tracker}
+}
+
+// MailgunWebhook receives the Mailgun delivery events
+// @Summary Receive a Mailgun event
+// @Description Records the delivered, failed, complained and opened events of the emails, signed with the webhook signing key. Each signature token is accepted once. Hard bounces and complaints suppress the recipient
+// @Tags Emails
+// @Accept json
+// @Produce json
+// @Success 200
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 401 {object} shareds.ErrorResponse
+// @Failure 406 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /emails/webhooks/mailgun [post]
+func (tc *emailTrackingController) MailgunWebhook(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid request body"})
+		return
+	}
+
+	err = tc.tracker.HandleMailgunWebhook(c.Request.Context(), body)
+	if errors.Is(err, ErrInvalidSignature) {
+		c.JSON(http.StatusUnauthorized, shareds.ErrorResponse{Message: "Invalid signature"})
+		return
+	}
+	if errors.Is(err, ErrWebhookReplayed) {
+		// Mailgun does not retry the events answered with 406
+		c.JSON(http.StatusNotAcceptable, shareds.ErrorResponse{Message: "Webhook already received"})
+		return
+	}
+	if err != nil {
+		// Mailgun retries the events not acknowledged
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error recording email event"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// GetEvents lists the delivery events of an outbox message
+// @Summary List the delivery events of an outbox message
+// @Tags Emails
+// @Produce json
+// @Security AdminKey
+// @Param id path string true "Message ID"
+// @Success 200 {array} DeliveryEvent
+// @Failure 401 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /emails/outbox/{id}/events [get]
+func (tc *emailTrackingController) GetEvents(c *gin.Context) {
+	events, err := tc.tracker.GetEvents(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching email events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// GetSuppressions lists the suppressed addresses
+// @Summary List the suppressed addresses
+// @Description Lists the addresses no email is sent to, most recent first
+// @Tags Emails
+// @Produce json
+// @Security AdminKey
+// @Param page query int false "Page Number"
+// @Param size query int false "Page Size"
+// @Success 200 {array} Suppression
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 401 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /emails/suppressions [get]
+func (tc *emailTrackingController) GetSuppressions(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid page parameter"})
+		return
+	}
+
+	size, err := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if err != nil || size <= 0 || size > 100 {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid size parameter"})
+		return
+	}
+
+	suppressions, err := tc.tracker.GetSuppressions(c.Request.Context(), page, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching suppressions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, suppressions)
+}
+
+// DeleteSuppression removes an address from the suppression list
+// @Summary Clear a suppressed address
+// @Description Lets the emails be sent to an address again, e.g. once its mailbox is fixed
+// @Tags Emails
+// @Security AdminKey
+// @Param email path string true "Email address"
+// @Success 204
+// @Failure 401 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /emails/suppressions/{email} [delete]
+func (tc *emailTrackingController) DeleteSuppression(c *gin.Context) {
+	err := tc.tracker.DeleteSuppression(c.Request.Context(), c.Param("email"))
+	if errors.Is(err, ErrSuppressionNotFound) {
+		c.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Suppression not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error deleting suppression"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
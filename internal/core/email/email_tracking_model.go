@@ -0,0 +1,40 @@
+package email
+
+import "time"
+
+// Delivery events reported by the providers
+const (
+	EventDelivered  = "delivered"
+	EventDeferred   = "deferred"
+	EventBounced    = "bounced"
+	EventComplained = "complained"
+	EventOpened     = "opened"
+)
+
+// Reasons an address is suppressed
+const (
+	SuppressedBounce    = "bounce"
+	SuppressedComplaint = "complaint"
+)
+
+// DeliveryEvent is a delivery event of an email reported by a provider
+type DeliveryEvent struct {
+	EventUUID       string    `json:"event_uuid" db:"event_uuid"`                 // UUID do evento (chave primaria)
+	Provider        string    `json:"provider" db:"provider"`                     // Provedor que reportou o evento
+	ProviderEventID string    `json:"provider_event_id" db:"provider_event_id"`   // ID do evento no provedor
+	MessageID       string    `json:"message_id,omitempty" db:"message_id"`       // Message-ID do email
+	MessageUUID     string    `json:"message_uuid,omitempty" db:"message_uuid"`   // Mensagem do outbox, quando enviada por ele
+	Recipient       string    `json:"recipient" db:"recipient"`                   // Destinatario
+	Event           string    `json:"event" db:"event"`                           // delivered, deferred, bounced, complained ou opened
+	Reason          string    `json:"reason,omitempty" db:"reason"`               // Motivo reportado pelo provedor
+	OccurredDate    time.Time `json:"occurred_date" db:"occurred_date"`           // Data do evento no provedor
+	CreationDate    time.Time `json:"creation_date,omitempty" db:"creation_date"` // Data de recebimento
+}
+
+// Suppression is an address no email is sent to
+type Suppression struct {
+	Email        string    `json:"email" db:"email"`                 // Endereço suprimido (chave primaria)
+	Reason       string    `json:"reason" db:"reason"`               // bounce ou complaint
+	Detail       string    `json:"detail,omitempty" db:"detail"`     // Motivo reportado pelo provedor
+	CreationDate time.Time `json:"creation_date" db:"creation_date"` // Data de criação
+}
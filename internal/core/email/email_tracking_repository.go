@@ -0,0 +1,274 @@
+package email
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrSuppressionNotFound is returned when the given address is not suppressed
+var ErrSuppressionNotFound = errors.New("suppression not found")
+
+// EmailTrackingRepository stores the delivery events, the suppressed addresses
+// and the tokens of the webhooks received. They are shared by every tenant, like
+// the outbox
+type EmailTrackingRepository interface {
+	UseWebhookToken(ctx context.Context, provider string, token string, signedDate time.Time) (bool, error)
+	PurgeWebhookTokens(ctx context.Context, before time.Time) (int64, error)
+	CreateEvent(ctx context.Context, event DeliveryEvent) (bool, error)
+	UpdateDeliveryStatus(ctx context.Context, messageUUID string, status string, date time.Time) error
+	GetEvents(ctx context.Context, messageUUID string) ([]DeliveryEvent, error)
+	Suppress(ctx context.Context, suppression Suppression) error
+	GetSuppressed(ctx context.Context, addresses []string) ([]string, error)
+	GetSuppressions(ctx context.Context, page, size int) ([]Suppression, error)
+	DeleteSuppression(ctx context.Context, address string) error
+	WithTx(tx database.DBTX) EmailTrackingRepository
+}
+
+type emailTrackingRepository struct {
+	db      database.DBTX
+	timeout time.Duration
+}
+
+func NewEmailTrackingRepository(db database.DBTX, timeout time.Duration) *emailTrackingRepository {
+	return &emailTrackingRepository{db: db, timeout: timeout}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *emailTrackingRepository) WithTx(tx database.DBTX) EmailTrackingRepository {
+	return &emailTrackingRepository{db: tx, timeout: r.timeout}
+}
+
+// UseWebhookToken stores the token of a webhook, reporting false when it was
+// already used
+func (r *emailTrackingRepository) UseWebhookToken(ctx context.Context, provider string, token string, signedDate time.Time) (bool, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO default_schema.email_webhook_tokens (provider, token, signed_date)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (provider, token) DO NOTHING`, provider, token, signedDate)
+
+	if err != nil {
+		log.Print(err)
+		return false, errors.New("failed to store webhook token")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.New("failed to store webhook token")
+	}
+
+	return affected == 1, nil
+}
+
+// PurgeWebhookTokens deletes the tokens signed before the given time, returning
+// how many
+func (r *emailTrackingRepository) PurgeWebhookTokens(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM default_schema.email_webhook_tokens
+		WHERE signed_date < $1`, before)
+
+	if err != nil {
+		return 0, errors.New("failed to purge webhook tokens")
+	}
+
+	return result.RowsAffected()
+}
+
+// CreateEvent stores a delivery event, reporting whether it is new. An event the
+// provider already reported, e.g. a webhook delivered twice, is ignored
+func (r *emailTrackingRepository) CreateEvent(ctx context.Context, event DeliveryEvent) (bool, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var id string
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO default_schema.email_events (provider, provider_event_id, message_id, message_uuid, recipient, event, reason, occurred_date)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, '')::UUID, $5, $6, NULLIF($7, ''), $8)
+		ON CONFLICT (provider, provider_event_id) DO NOTHING
+		RETURNING event_uuid`,
+		event.Provider, event.ProviderEventID, event.MessageID, event.MessageUUID, event.Recipient, event.Event, event.Reason, event.OccurredDate).Scan(&id)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		log.Print(err)
+		return false, errors.New("failed to create email event")
+	}
+
+	return true, nil
+}
+
+// UpdateDeliveryStatus sets the delivery status of an outbox message, unless a
+// later event already set it
+func (r *emailTrackingRepository) UpdateDeliveryStatus(ctx context.Context, messageUUID string, status string, date time.Time) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE default_schema.email_outbox
+		SET delivery_status = $2,
+		    delivery_date = $3,
+		    modification_date = CURRENT_TIMESTAMP
+		WHERE message_uuid = $1
+		  AND (delivery_date IS NULL OR delivery_date <= $3)`, messageUUID, status, date)
+
+	if err != nil {
+		return errors.New("failed to update delivery status")
+	}
+
+	return nil
+}
+
+// GetEvents returns the delivery events of an outbox message, oldest first
+func (r *emailTrackingRepository) GetEvents(ctx context.Context, messageUUID string) ([]DeliveryEvent, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT event_uuid, provider, provider_event_id, message_id, message_uuid, recipient, event, reason, occurred_date, creation_date
+		FROM default_schema.email_events
+		WHERE message_uuid = $1
+		ORDER BY occurred_date`, messageUUID)
+
+	if err != nil {
+		return nil, errors.New("failed to retrive email events")
+	}
+
+	defer rows.Close()
+
+	models := []DeliveryEvent{}
+	for rows.Next() {
+		var model DeliveryEvent
+		var messageID, messageUUID, reason sql.NullString
+
+		err := rows.Scan(&model.EventUUID, &model.Provider, &model.ProviderEventID, &messageID, &messageUUID, &model.Recipient, &model.Event, &reason, &model.OccurredDate, &model.CreationDate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		model.MessageID = messageID.String
+		model.MessageUUID = messageUUID.String
+		model.Reason = reason.String
+		models = append(models, model)
+	}
+
+	return models, nil
+}
+
+// Suppress adds an address to the suppression list. An address already in it
+// keeps its first reason
+func (r *emailTrackingRepository) Suppress(ctx context.Context, suppression Suppression) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO default_schema.email_suppressions (email, reason, detail)
+		VALUES ($1, $2, NULLIF($3, ''))
+		ON CONFLICT (email) DO NOTHING`, suppression.Email, suppression.Reason, suppression.Detail)
+
+	if err != nil {
+		log.Print(err)
+		return errors.New("failed to suppress email address")
+	}
+
+	return nil
+}
+
+// GetSuppressed returns which of the given normalized addresses are suppressed
+func (r *emailTrackingRepository) GetSuppressed(ctx context.Context, addresses []string) ([]string, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT email FROM default_schema.email_suppressions WHERE email = ANY($1)`, pq.Array(addresses))
+
+	if err != nil {
+		return nil, errors.New("failed to retrive suppressed addresses")
+	}
+
+	defer rows.Close()
+
+	suppressed := []string{}
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		suppressed = append(suppressed, address)
+	}
+
+	return suppressed, nil
+}
+
+// GetSuppressions returns a page of the suppressed addresses, most recent first
+func (r *emailTrackingRepository) GetSuppressions(ctx context.Context, page, size int) ([]Suppression, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT email, reason, detail, creation_date
+		FROM default_schema.email_suppressions
+		ORDER BY creation_date DESC
+		LIMIT $1 OFFSET $2`, size, (page-1)*size)
+
+	if err != nil {
+		return nil, errors.New("failed to retrive suppressions")
+	}
+
+	defer rows.Close()
+
+	models := []Suppression{}
+	for rows.Next() {
+		var model Suppression
+		var detail sql.NullString
+
+		if err := rows.Scan(&model.Email, &model.Reason, &detail, &model.CreationDate); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		model.Detail = detail.String
+		models = append(models, model)
+	}
+
+	return models, nil
+}
+
+// DeleteSuppression removes an address from the suppression list
+func (r *emailTrackingRepository) DeleteSuppression(ctx context.Context, address string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM default_schema.email_suppressions
+		WHERE email = $1`, address)
+
+	if err != nil {
+		return errors.New("failed to delete suppression")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.New("failed to delete suppression")
+	}
+	if affected == 0 {
+		return ErrSuppressionNotFound
+	}
+
+	return nil
+}
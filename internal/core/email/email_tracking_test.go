@@ -0,0 +1,364 @@
+package email
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/providers/emails"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSigningKey = "key-test"
+
+// mailgunBody returns a Mailgun webhook body signed at the given time
+func mailgunBody(signedAt time.Time, key string, event string, severity string) []byte {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(timestamp + "token-1"))
+
+	return []byte(fmt.Sprintf(`{
+		"signature": {"timestamp": %q, "token": "token-1", "signature": %q},
+		"event-data": {
+			"id": "event-1",
+			"event": %q,
+			"severity": %q,
+			"recipient": "User@Example.com",
+			"timestamp": 1700000000.5,
+			"delivery-status": {"message": "550 5.1.1 mailbox unavailable"},
+			"message": {"headers": {"message-id": "0190a3f4-5b6c-7d8e-9f00-112233445566@company.com"}}
+		}
+	}`, timestamp, hex.EncodeToString(mac.Sum(nil)), event, severity))
+}
+
+// fakeSuppressions suppresses a fixed set of addresses
+type fakeSuppressions []string
+
+func (f fakeSuppressions) Suppressed(ctx context.Context, addresses []string) ([]string, error) {
+	var suppressed []string
+	for _, address := range addresses {
+		for _, match := range f {
+			if address == match {
+				suppressed = append(suppressed, address)
+			}
+		}
+	}
+	return suppressed, nil
+}
+
+// fakeTrackingRepository keeps the delivery events, the delivery status of the
+// outbox messages and the suppressions in memory
+type fakeTrackingRepository struct {
+	tokens       map[string]time.Time
+	events       []DeliveryEvent
+	statuses     map[string]string
+	suppressions map[string]Suppression
+}
+
+func newFakeTrackingRepository() *fakeTrackingRepository {
+	return &fakeTrackingRepository{tokens: map[string]time.Time{}, statuses: map[string]string{}, suppressions: map[string]Suppression{}}
+}
+
+func (r *fakeTrackingRepository) UseWebhookToken(ctx context.Context, provider string, token string, signedDate time.Time) (bool, error) {
+	if _, ok := r.tokens[provider+":"+token]; ok {
+		return false, nil
+	}
+	r.tokens[provider+":"+token] = signedDate
+	return true, nil
+}
+
+func (r *fakeTrackingRepository) PurgeWebhookTokens(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	for token, signedDate := range r.tokens {
+		if signedDate.Before(before) {
+			delete(r.tokens, token)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *fakeTrackingRepository) CreateEvent(ctx context.Context, event DeliveryEvent) (bool, error) {
+	for _, recorded := range r.events {
+		if recorded.Provider == event.Provider && recorded.ProviderEventID == event.ProviderEventID {
+			return false, nil
+		}
+	}
+	r.events = append(r.events, event)
+	return true, nil
+}
+
+func (r *fakeTrackingRepository) UpdateDeliveryStatus(ctx context.Context, messageUUID string, status string, date time.Time) error {
+	r.statuses[messageUUID] = status
+	return nil
+}
+
+func (r *fakeTrackingRepository) GetEvents(ctx context.Context, messageUUID string) ([]DeliveryEvent, error) {
+	events := []DeliveryEvent{}
+	for _, event := range r.events {
+		if event.MessageUUID == messageUUID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (r *fakeTrackingRepository) Suppress(ctx context.Context, suppression Suppression) error {
+	if _, ok := r.suppressions[suppression.Email]; !ok {
+		r.suppressions[suppression.Email] = suppression
+	}
+	return nil
+}
+
+func (r *fakeTrackingRepository) GetSuppressed(ctx context.Context, addresses []string) ([]string, error) {
+	suppressed := []string{}
+	for _, address := range addresses {
+		if _, ok := r.suppressions[address]; ok {
+			suppressed = append(suppressed, address)
+		}
+	}
+	return suppressed, nil
+}
+
+func (r *fakeTrackingRepository) GetSuppressions(ctx context.Context, page, size int) ([]Suppression, error) {
+	suppressions := []Suppression{}
+	for _, suppression := range r.suppressions {
+		suppressions = append(suppressions, suppression)
+	}
+	return suppressions, nil
+}
+
+func (r *fakeTrackingRepository) DeleteSuppression(ctx context.Context, address string) error {
+	if _, ok := r.suppressions[address]; !ok {
+		return ErrSuppressionNotFound
+	}
+	delete(r.suppressions, address)
+	return nil
+}
+
+func (r *fakeTrackingRepository) WithTx(tx database.DBTX) EmailTrackingRepository {
+	return r
+}
+
+func newTestTracker() (*emailTracker, *fakeTrackingRepository) {
+	repo := newFakeTrackingRepository()
+	tracker := NewEmailTracker(repo, database.NewFakeTxManager(), &configs.AppConfig{
+		MailgunSigningKey: testSigningKey,
+		WebhookMaxAge:     15 * time.Minute,
+	})
+	return tracker, repo
+}
+
+func TestParseMailgunWebhook(t *testing.T) {
+	now := time.Now()
+
+	event, token, ok, err := parseMailgunWebhook(mailgunBody(now, testSigningKey, "failed", "permanent"), testSigningKey, 15*time.Minute, now)
+
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, webhookToken{provider: "mailgun", token: "token-1", signedDate: time.Unix(now.Unix(), 0).UTC()}, token)
+	assert.Equal(t, EventBounced, event.Event)
+	assert.Equal(t, "event-1", event.ProviderEventID)
+	assert.Equal(t, "user@example.com", event.Recipient)
+	assert.Equal(t, "0190a3f4-5b6c-7d8e-9f00-112233445566", event.MessageUUID)
+	assert.Equal(t, "550 5.1.1 mailbox unavailable", event.Reason)
+	assert.Equal(t, time.Unix(1700000000, 5e8).UTC(), event.OccurredDate)
+}
+
+func TestParseMailgunWebhook_TemporaryFailure(t *testing.T) {
+	now := time.Now()
+
+	event, _, ok, err := parseMailgunWebhook(mailgunBody(now, testSigningKey, "failed", "temporary"), testSigningKey, 15*time.Minute, now)
+
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, EventDeferred, event.Event)
+}
+
+func TestParseMailgunWebhook_UntrackedEvent(t *testing.T) {
+	now := time.Now()
+
+	_, _, ok, err := parseMailgunWebhook(mailgunBody(now, testSigningKey, "clicked", ""), testSigningKey, 15*time.Minute, now)
+
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestParseMailgunWebhook_InvalidSignature(t *testing.T) {
+	now := time.Now()
+
+	tests := map[string]struct {
+		body []byte
+		key  string
+	}{
+		"wrong key":      {mailgunBody(now, "another-key", "delivered", ""), testSigningKey},
+		"stale":          {mailgunBody(now.Add(-time.Hour), testSigningKey, "delivered", ""), testSigningKey},
+		"no signing key": {mailgunBody(now, "", "delivered", ""), ""},
+	}
+	for name, test := range tests {
+		_, _, _, err := parseMailgunWebhook(test.body, test.key, 15*time.Minute, now)
+
+		assert.ErrorIs(t, err, ErrInvalidSignature, name)
+	}
+}
+
+func TestEmailTracker_HandleMailgunWebhook(t *testing.T) {
+	tests := []struct {
+		name         string
+		event        string
+		severity     string
+		status       string
+		suppressions map[string]Suppression
+	}{
+		{
+			name:     "hard bounce suppresses the recipient",
+			event:    "failed",
+			severity: "permanent",
+			status:   EventBounced,
+			suppressions: map[string]Suppression{
+				"user@example.com": {Email: "user@example.com", Reason: SuppressedBounce, Detail: "550 5.1.1 mailbox unavailable"},
+			},
+		},
+		{
+			name:         "soft bounce only defers the message",
+			event:        "failed",
+			severity:     "temporary",
+			status:       EventDeferred,
+			suppressions: map[string]Suppression{},
+		},
+		{
+			name:   "complaint suppresses the recipient",
+			event:  "complained",
+			status: EventComplained,
+			suppressions: map[string]Suppression{
+				"user@example.com": {Email: "user@example.com", Reason: SuppressedComplaint, Detail: "550 5.1.1 mailbox unavailable"},
+			},
+		},
+		{
+			name:         "untracked event is ignored",
+			event:        "clicked",
+			suppressions: map[string]Suppression{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, repo := newTestTracker()
+
+			err := tracker.HandleMailgunWebhook(context.Background(), mailgunBody(time.Now(), testSigningKey, tt.event, tt.severity))
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.status, repo.statuses["0190a3f4-5b6c-7d8e-9f00-112233445566"])
+			assert.Equal(t, tt.suppressions, repo.suppressions)
+		})
+	}
+}
+
+func TestEmailTracker_IgnoresRecordedEvent(t *testing.T) {
+	tracker, repo := newTestTracker()
+	repo.events = []DeliveryEvent{{Provider: "mailgun", ProviderEventID: "event-1", Event: EventDelivered}}
+
+	err := tracker.HandleMailgunWebhook(context.Background(), mailgunBody(time.Now(), testSigningKey, "failed", "permanent"))
+
+	assert.NoError(t, err)
+	assert.Len(t, repo.events, 1)
+	assert.Empty(t, repo.statuses)
+	assert.Empty(t, repo.suppressions)
+}
+
+func TestEmailTracker_RejectsReplayedToken(t *testing.T) {
+	tracker, repo := newTestTracker()
+	signedAt := time.Now()
+
+	err := tracker.HandleMailgunWebhook(context.Background(), mailgunBody(signedAt, testSigningKey, "delivered", ""))
+	assert.NoError(t, err)
+
+	// the captured signature is sent again with a forged event
+	forged := bytes.Replace(mailgunBody(signedAt, testSigningKey, "failed", "permanent"), []byte(`"event-1"`), []byte(`"event-2"`), 1)
+	err = tracker.HandleMailgunWebhook(context.Background(), forged)
+
+	assert.ErrorIs(t, err, ErrWebhookReplayed)
+	assert.Len(t, repo.events, 1)
+	assert.Equal(t, EventDelivered, repo.statuses["0190a3f4-5b6c-7d8e-9f00-112233445566"])
+	assert.Empty(t, repo.suppressions)
+}
+
+func TestEmailTracker_PurgesExpiredTokens(t *testing.T) {
+	tracker, repo := newTestTracker()
+	repo.tokens["mailgun:expired"] = time.Now().Add(-time.Hour)
+
+	err := tracker.HandleMailgunWebhook(context.Background(), mailgunBody(time.Now(), testSigningKey, "delivered", ""))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mailgun:token-1"}, slices.Collect(maps.Keys(repo.tokens)))
+}
+
+func TestEmailTracker_Suppressed(t *testing.T) {
+	tracker, repo := newTestTracker()
+	repo.suppressions["user@example.com"] = Suppression{Email: "user@example.com", Reason: SuppressedBounce}
+
+	suppressed, err := tracker.Suppressed(context.Background(), []string{"User <User@Example.com>", "other@example.com"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"User <User@Example.com>"}, suppressed, "the addresses are returned as given")
+}
+
+func TestEmailTracker_DeleteSuppression(t *testing.T) {
+	tracker, repo := newTestTracker()
+	repo.suppressions["user@example.com"] = Suppression{Email: "user@example.com", Reason: SuppressedBounce}
+
+	assert.NoError(t, tracker.DeleteSuppression(context.Background(), "User@Example.com"))
+	assert.Empty(t, repo.suppressions)
+	assert.ErrorIs(t, tracker.DeleteSuppression(context.Background(), "user@example.com"), ErrSuppressionNotFound)
+}
+
+func TestEmailService_SendEmailSkipsSuppressedRecipients(t *testing.T) {
+	var sent emails.EmailDto
+	mockProvider := &MockEmailProvider{SendFunc: func(email emails.EmailDto) error {
+		sent = email
+		return nil
+	}}
+	emailService := NewEmailService(mockProvider, nil, nil, fakeSuppressions{"bounced@example.com"}, "")
+	email := emails.EmailDto{Sender: "sender@example.com", To: []string{"user@example.com", "bounced@example.com"}, CC: []string{"bounced@example.com"}}
+
+	err := emailService.SendEmail(context.Background(), email)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user@example.com"}, sent.To)
+	assert.Empty(t, sent.CC)
+}
+
+func TestEmailService_SendEmailToSuppressedRecipient(t *testing.T) {
+	mockProvider := &MockEmailProvider{SendFunc: func(email emails.EmailDto) error {
+		t.Fatal("the email must not be sent")
+		return nil
+	}}
+	emailService := NewEmailService(mockProvider, nil, nil, fakeSuppressions{"bounced@example.com"}, "")
+
+	err := emailService.SendEmail(context.Background(), emails.EmailDto{Sender: "sender@example.com", To: []string{"bounced@example.com"}})
+
+	assert.ErrorIs(t, err, ErrRecipientSuppressed)
+}
+
+func TestEmailDispatcher_DeadLettersSuppressedMessage(t *testing.T) {
+	dispatcher, repo := newTestDispatcher(&MockEmailProvider{SendFunc: func(email emails.EmailDto) error {
+		t.Fatal("the email must not be sent")
+		return nil
+	}}, testMessage)
+	dispatcher.suppressed = fakeSuppressions{"user@example.com"}
+
+	dispatcher.handle(context.Background(), testMessage)
+
+	assert.Equal(t, OutboxDead, repo.messages["message-1"].Status)
+	assert.Equal(t, ErrRecipientSuppressed.Error(), repo.messages["message-1"].LastError)
+}
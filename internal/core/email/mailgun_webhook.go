@@ -0,0 +1,143 @@
+package email
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSignature is returned when a webhook is not signed by the provider
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// ErrWebhookReplayed is returned when the token of a signed webhook was already used
+var ErrWebhookReplayed = errors.New("webhook token already used")
+
+// webhookToken is the token signed with a webhook, accepted once
+type webhookToken struct {
+	provider   string
+	token      string
+	signedDate time.Time
+}
+
+// messageUUIDRegex matches the outbox message UUIDs, the local part of the
+// Message-ID of the emails sent by the dispatcher
+var messageUUIDRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// mailgunWebhook is the body of the Mailgun event webhooks
+type mailgunWebhook struct {
+	Signature struct {
+		Timestamp string `json:"timestamp"`
+		Token     string `json:"token"`
+		Signature string `json:"signature"`
+	} `json:"signature"`
+	EventData struct {
+		ID             string  `json:"id"`
+		Event          string  `json:"event"`
+		Severity       string  `json:"severity"`
+		Reason         string  `json:"reason"`
+		Recipient      string  `json:"recipient"`
+		Timestamp      float64 `json:"timestamp"`
+		DeliveryStatus struct {
+			Message     string `json:"message"`
+			Description string `json:"description"`
+		} `json:"delivery-status"`
+		Message struct {
+			Headers struct {
+				MessageID string `json:"message-id"`
+			} `json:"headers"`
+		} `json:"message"`
+	} `json:"event-data"`
+}
+
+// parseMailgunWebhook verifies the signature of a Mailgun webhook and returns its
+// event and signed token, reporting false for the events that are not tracked. The
+// signature only covers its timestamp and token, so webhooks signed more than
+// maxAge ago are rejected, and the token must not have been used to prevent
+// replays within that age
+func parseMailgunWebhook(body []byte, signingKey string, maxAge time.Duration, now time.Time) (DeliveryEvent, webhookToken, bool, error) {
+	var webhook mailgunWebhook
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return DeliveryEvent{}, webhookToken{}, false, fmt.Errorf("invalid webhook body: %w", err)
+	}
+
+	signedDate, err := verifyMailgunSignature(webhook, signingKey, maxAge, now)
+	if err != nil {
+		return DeliveryEvent{}, webhookToken{}, false, err
+	}
+	token := webhookToken{provider: "mailgun", token: webhook.Signature.Token, signedDate: signedDate}
+
+	data := webhook.EventData
+	event := DeliveryEvent{
+		Provider:        "mailgun",
+		ProviderEventID: data.ID,
+		MessageID:       strings.Trim(data.Message.Headers.MessageID, "<>"),
+		Recipient:       normalizeAddress(data.Recipient),
+		Reason:          strings.TrimSpace(data.DeliveryStatus.Message + " " + data.DeliveryStatus.Description),
+	}
+	if event.Reason == "" {
+		event.Reason = data.Reason
+	}
+
+	seconds, fraction := math.Modf(data.Timestamp)
+	event.OccurredDate = time.Unix(int64(seconds), int64(fraction*1e9)).UTC()
+
+	if local, _, ok := strings.Cut(event.MessageID, "@"); ok && messageUUIDRegex.MatchString(local) {
+		event.MessageUUID = local
+	}
+
+	switch data.Event {
+	case "delivered":
+		event.Event = EventDelivered
+	case "failed":
+		event.Event = EventDeferred
+		if data.Severity == "permanent" {
+			event.Event = EventBounced
+		}
+	case "complained":
+		event.Event = EventComplained
+	case "opened":
+		event.Event = EventOpened
+	default:
+		return DeliveryEvent{}, token, false, nil
+	}
+
+	if event.ProviderEventID == "" || event.Recipient == "" {
+		return DeliveryEvent{}, webhookToken{}, false, errors.New("invalid webhook body: missing event ID or recipient")
+	}
+
+	return event, token, true, nil
+}
+
+// verifyMailgunSignature checks the HMAC-SHA256 of the timestamp and token of a
+// webhook with the webhook signing key of the account, returning when it was signed
+func verifyMailgunSignature(webhook mailgunWebhook, signingKey string, maxAge time.Duration, now time.Time) (time.Time, error) {
+	if signingKey == "" || webhook.Signature.Token == "" {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(webhook.Signature.Timestamp + webhook.Signature.Token))
+	signature, err := hex.DecodeString(webhook.Signature.Signature)
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	timestamp, err := strconv.ParseInt(webhook.Signature.Timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidSignature
+	}
+	signedDate := time.Unix(timestamp, 0).UTC()
+	if age := now.Sub(signedDate); maxAge > 0 && (age > maxAge || age < -maxAge) {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	return signedDate, nil
+}
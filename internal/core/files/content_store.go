@@ -0,0 +1,208 @@
+package files
+
+import (
+	"bernardtm/backend/internal/core/storage"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/providers/storages"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"path"
+	"slices"
+)
+
+// ErrChecksumMismatch is returned when the content read from the storage does not
+// match the checksum recorded when it was uploaded
+var ErrChecksumMismatch = errors.New("file checksum mismatch")
+
+// contentStore stores files by content: identical files of a tenant share one
+// object, which is kept while any files or file_versions row references its key
+type contentStore struct {
+	filesRepo      FilesRepository
+	storageService storage.StorageService
+	secret         []byte
+}
+
+func newContentStore(filesRepo FilesRepository, storageService storage.StorageService, secret string) contentStore {
+	return contentStore{filesRepo: filesRepo, storageService: storageService, secret: []byte(secret)}
+}
+
+// Checksum returns the hex encoded SHA-256 and the size of content, hashing it as
+// it streams, and rewinds it
+func Checksum(content io.ReadSeeker) (string, int64, error) {
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", 0, fmt.Errorf("failed to rewind file: %w", err)
+	}
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, content)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", 0, fmt.Errorf("failed to rewind file: %w", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), size, nil
+}
+
+// blobKey derives the key of a blob from the tenant and the checksum. The key is
+// an HMAC, so it cannot be guessed from the content, and differs across tenants
+func (s contentStore) blobKey(tenantID string, checksum string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(tenantID + ":" + checksum))
+	sum := hex.EncodeToString(mac.Sum(nil))
+
+	return path.Join("blobs", sum[0:2], sum[2:4], sum)
+}
+
+// store saves content unless the tenant already stores an identical blob and
+// returns the file referencing it. It must run inside the transaction creating
+// the files row, which keeps the reference count consistent with the objects
+func (s contentStore) store(ctx context.Context, uow *database.UnitOfWork, originalName string, content io.ReadSeeker, contentType string) (File, error) {
+	checksum, size, err := Checksum(content)
+	if err != nil {
+		return File{}, err
+	}
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return File{}, err
+	}
+
+	key := s.blobKey(tenantID, checksum)
+	filesRepo := s.filesRepo.WithTx(uow.Tx())
+
+	if err := filesRepo.LockKey(ctx, key); err != nil {
+		return File{}, err
+	}
+	references, err := filesRepo.CountByKey(ctx, key)
+	if err != nil {
+		return File{}, err
+	}
+
+	stored := references > 0
+	if stored {
+		// the object of an existing reference may have been lost
+		if _, err := s.storageService.Stat(ctx, key); errors.Is(err, storages.ErrObjectNotFound) {
+			stored = false
+		} else if err != nil {
+			return File{}, err
+		}
+	}
+
+	if !stored {
+		if err := s.storageService.Put(ctx, key, content, contentType); err != nil {
+			return File{}, errors.New("could not upload file")
+		}
+		if references == 0 {
+			uow.AddCompensation(func() error {
+				// the request may already be cancelled, but the cleanup must still run
+				return s.storageService.Delete(context.WithoutCancel(ctx), key)
+			})
+		}
+	}
+
+	return File{
+		Name:         path.Base(key),
+		OriginalName: originalName,
+		Link:         s.storageService.ObjectURL(key),
+		Key:          key,
+		Folder:       fileExtension(originalName),
+		Type:         contentType,
+		Checksum:     checksum,
+		Size:         size,
+	}, nil
+}
+
+// release removes a files row, with its versions, and the objects only they
+// referenced along with their thumbnails
+func (s contentStore) release(ctx context.Context, uow *database.UnitOfWork, file FileResponse, versions []FileVersionResponse) error {
+	keys := []string{file.Key}
+	for _, version := range versions {
+		keys = append(keys, version.Key)
+	}
+	// locks are always taken in the same order, so concurrent releases cannot deadlock
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+
+	filesRepo := s.filesRepo.WithTx(uow.Tx())
+	for _, key := range keys {
+		if err := filesRepo.LockKey(ctx, key); err != nil {
+			return err
+		}
+	}
+	if err := filesRepo.Delete(ctx, file.FileUUID); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := s.drop(ctx, filesRepo, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// drop removes the object of key and its thumbnail when no file or file version
+// references it anymore. The key must be locked by the transaction of filesRepo
+func (s contentStore) drop(ctx context.Context, filesRepo FilesRepository, key string) error {
+	references, err := filesRepo.CountByKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	if references > 0 {
+		return nil
+	}
+
+	for _, key := range []string{key, thumbnailKey(key)} {
+		err = s.storageService.Delete(ctx, key)
+		if err != nil && !errors.Is(err, storages.ErrObjectNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyingReader hashes the content it reads and fails at the end of the content
+// when it does not match the expected checksum
+type verifyingReader struct {
+	io.ReadCloser
+	hasher   hash.Hash
+	expected string
+	key      string
+}
+
+func newVerifyingReader(body io.ReadCloser, checksum string, key string) *verifyingReader {
+	return &verifyingReader{ReadCloser: body, hasher: sha256.New(), expected: checksum, key: key}
+}
+
+func (r *verifyingReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.hasher.Write(b[:n])
+
+	if errors.Is(err, io.EOF) {
+		if actual := hex.EncodeToString(r.hasher.Sum(nil)); actual != r.expected {
+			log.Printf("checksum mismatch for object %s: expected %s, got %s", r.key, r.expected, actual)
+			return n, ErrChecksumMismatch
+		}
+	}
+	return n, err
+}
+
+// newStorageName returns a random name for objects whose content is not known
+// when their key is chosen, such as pre-signed uploads
+func newStorageName() string {
+	name := make([]byte, 32)
+	if _, err := rand.Read(name); err != nil {
+		panic(fmt.Sprintf("failed to generate storage name: %v", err))
+	}
+	return hex.EncodeToString(name)
+}
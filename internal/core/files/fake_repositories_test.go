@@ -0,0 +1,697 @@
+package files
+
+import (
+	"bernardtm/backend/internal/core/status"
+	"bernardtm/backend/internal/core/tenants"
+	"bernardtm/backend/internal/infra/database"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// fakeFilesRepository keeps the files in memory. The references of a key count
+// the versions kept by versions, like the database does
+type fakeFilesRepository struct {
+	FilesRepository
+	mu        sync.Mutex
+	files     map[string]FileResponse
+	texts     map[string]string
+	versions  *fakeVersionsRepository
+	shares    *fakeSharesRepository
+	createErr error
+	next      int
+}
+
+func newFakeFilesRepository(versions *fakeVersionsRepository, shares *fakeSharesRepository) *fakeFilesRepository {
+	return &fakeFilesRepository{files: map[string]FileResponse{}, texts: map[string]string{}, versions: versions, shares: shares}
+}
+
+// add stores a file as is, returning it
+func (r *fakeFilesRepository) add(file FileResponse) FileResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.files[file.FileUUID] = file
+	return file
+}
+
+// get returns a stored file, or the zero value
+func (r *fakeFilesRepository) get(id string) FileResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.files[id]
+}
+
+func (r *fakeFilesRepository) GetByID(ctx context.Context, id string) (FileResponse, error) {
+	if _, err := database.TenantID(ctx); err != nil {
+		return FileResponse{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, ok := r.files[id]
+	if !ok {
+		return FileResponse{}, fmt.Errorf("%w with ID: %s", ErrFileNotFound, id)
+	}
+	return file, nil
+}
+
+func (r *fakeFilesRepository) Create(ctx context.Context, data FileRequest) (string, error) {
+	if _, err := database.TenantID(ctx); err != nil {
+		return "", err
+	}
+	if r.createErr != nil {
+		return "", r.createErr
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.next++
+	file := toFileResponse(data.File)
+	file.FileUUID = fmt.Sprintf("file-%d", r.next)
+	file.CreationDate = time.Now()
+	r.files[file.FileUUID] = file
+	return file.FileUUID, nil
+}
+
+func (r *fakeFilesRepository) Update(ctx context.Context, data FileRequest) error {
+	return r.update(data.FileUUID, func(file *FileResponse) {
+		file.Name, file.Link, file.Folder, file.Type = data.Name, data.Link, data.Folder, data.Type
+		file.StatusUUID, file.Checksum, file.Size = data.StatusUUID, data.Checksum, data.Size
+	})
+}
+
+func (r *fakeFilesRepository) UpdateStatus(ctx context.Context, id string, statusUUID string) error {
+	return r.update(id, func(file *FileResponse) { file.StatusUUID = statusUUID })
+}
+
+func (r *fakeFilesRepository) UpdateOwner(ctx context.Context, id string, ownerUUID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, ok := r.files[id]
+	if !ok || file.OwnerUUID != "" {
+		return ErrOwnerNotFound
+	}
+	file.OwnerUUID = ownerUUID
+	r.files[id] = file
+	return nil
+}
+
+func (r *fakeFilesRepository) UpdateProcessed(ctx context.Context, id string, statusUUID string, text string, thumbnailLink string) error {
+	r.mu.Lock()
+	r.texts[id] = text
+	r.mu.Unlock()
+
+	return r.update(id, func(file *FileResponse) {
+		file.StatusUUID, file.ThumbnailLink = statusUUID, thumbnailLink
+	})
+}
+
+func (r *fakeFilesRepository) UpdateStatusByKey(ctx context.Context, key string, statusUUID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, file := range r.files {
+		if file.Key == key {
+			file.StatusUUID = statusUUID
+			r.files[id] = file
+		}
+	}
+	return nil
+}
+
+func (r *fakeFilesRepository) GetText(ctx context.Context, id string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.files[id]; !ok {
+		return "", ErrFileNotFound
+	}
+	return r.texts[id], nil
+}
+
+// Delete removes a file with its versions and shares, as the foreign keys cascade
+func (r *fakeFilesRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	_, ok := r.files[id]
+	delete(r.files, id)
+	r.mu.Unlock()
+
+	if !ok {
+		return ErrFileNotFound
+	}
+	r.versions.deleteByFile(id)
+	r.shares.deleteByFile(id)
+	return nil
+}
+
+func (r *fakeFilesRepository) LockKey(ctx context.Context, key string) error {
+	return nil
+}
+
+func (r *fakeFilesRepository) CountByKey(ctx context.Context, key string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := r.versions.countByKey(key)
+	for _, file := range r.files {
+		if file.Key == key {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *fakeFilesRepository) ReplaceContent(ctx context.Context, data File) error {
+	return r.update(data.FileUUID, func(file *FileResponse) {
+		file.Name, file.OriginalName, file.Link, file.Key = data.Name, data.OriginalName, data.Link, data.Key
+		file.Folder, file.Type, file.Checksum, file.Size = data.Folder, data.Type, data.Checksum, data.Size
+		file.StatusUUID, file.ThumbnailLink = data.StatusUUID, ""
+	})
+}
+
+func (r *fakeFilesRepository) ReferencedKeys(ctx context.Context, keys []string) ([]string, error) {
+	if _, err := database.TenantID(ctx); err != nil {
+		return nil, err
+	}
+
+	var referenced []string
+	for _, key := range keys {
+		if count, _ := r.CountByKey(ctx, key); count > 0 {
+			referenced = append(referenced, key)
+		}
+	}
+	return referenced, nil
+}
+
+func (r *fakeFilesRepository) GetExpired(ctx context.Context, folder string, before time.Time, limit int) ([]FileResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	expired := []FileResponse{}
+	for _, file := range r.files {
+		if file.Folder == folder && file.CreationDate.Before(before) && len(expired) < limit {
+			expired = append(expired, file)
+		}
+	}
+	return expired, nil
+}
+
+func (r *fakeFilesRepository) WithTx(tx database.DBTX) FilesRepository {
+	return r
+}
+
+func (r *fakeFilesRepository) update(id string, fn func(file *FileResponse)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, ok := r.files[id]
+	if !ok {
+		return ErrFileNotFound
+	}
+	fn(&file)
+	r.files[id] = file
+	return nil
+}
+
+func toFileResponse(file File) FileResponse {
+	return FileResponse{
+		FileUUID:     file.FileUUID,
+		Name:         file.Name,
+		OriginalName: file.OriginalName,
+		Link:         file.Link,
+		Key:          file.Key,
+		Folder:       file.Folder,
+		Type:         file.Type,
+		Checksum:     file.Checksum,
+		Size:         file.Size,
+		OwnerUUID:    file.OwnerUUID,
+		StatusUUID:   file.StatusUUID,
+	}
+}
+
+// fakeVersionsRepository keeps the file versions in memory
+type fakeVersionsRepository struct {
+	mu       sync.Mutex
+	versions []FileVersionResponse
+}
+
+func (r *fakeVersionsRepository) Create(ctx context.Context, file FileResponse, createdBy string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	number := 1
+	for _, version := range r.versions {
+		if version.FileUUID == file.FileUUID {
+			number = max(number, version.VersionNumber+1)
+		}
+	}
+
+	version := FileVersionResponse{FileVersion{
+		VersionUUID:   fmt.Sprintf("version-%d", len(r.versions)+1),
+		FileUUID:      file.FileUUID,
+		VersionNumber: number,
+		OriginalName:  file.OriginalName,
+		Key:           file.Key,
+		Type:          file.Type,
+		Checksum:      file.Checksum,
+		Size:          file.Size,
+		CreatedBy:     createdBy,
+		CreationDate:  time.Now(),
+	}}
+	r.versions = append(r.versions, version)
+	return version.VersionUUID, nil
+}
+
+func (r *fakeVersionsRepository) GetByFile(ctx context.Context, fileUUID string) ([]FileVersionResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	versions := []FileVersionResponse{}
+	for _, version := range slices.Backward(r.versions) {
+		if version.FileUUID == fileUUID {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+func (r *fakeVersionsRepository) GetByID(ctx context.Context, fileUUID string, id string) (FileVersionResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, version := range r.versions {
+		if version.FileUUID == fileUUID && version.VersionUUID == id {
+			return version, nil
+		}
+	}
+	return FileVersionResponse{}, ErrVersionNotFound
+}
+
+func (r *fakeVersionsRepository) DeleteByKey(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.versions = slices.DeleteFunc(r.versions, func(version FileVersionResponse) bool { return version.Key == key })
+	return nil
+}
+
+func (r *fakeVersionsRepository) WithTx(tx database.DBTX) FileVersionsRepository {
+	return r
+}
+
+func (r *fakeVersionsRepository) countByKey(key string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	for _, version := range r.versions {
+		if version.Key == key {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *fakeVersionsRepository) deleteByFile(fileUUID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.versions = slices.DeleteFunc(r.versions, func(version FileVersionResponse) bool { return version.FileUUID == fileUUID })
+}
+
+// fakeShare is a share with the hash of its link token
+type fakeShare struct {
+	FileShareResponse
+	tokenHash string
+}
+
+// fakeSharesRepository keeps the file shares in memory
+type fakeSharesRepository struct {
+	mu     sync.Mutex
+	shares []fakeShare
+}
+
+func (r *fakeSharesRepository) ShareWithUser(ctx context.Context, fileUUID string, userUUID string, createdBy string, expiration *time.Time) (FileShareResponse, error) {
+	return r.add(fakeShare{FileShareResponse: FileShareResponse{FileShare{FileUUID: fileUUID, UserUUID: userUUID, CreatedBy: createdBy, ExpirationDate: expiration}}})
+}
+
+func (r *fakeSharesRepository) CreateLink(ctx context.Context, fileUUID string, tokenHash string, createdBy string, expiration time.Time) (FileShareResponse, error) {
+	return r.add(fakeShare{FileShareResponse: FileShareResponse{FileShare{FileUUID: fileUUID, CreatedBy: createdBy, ExpirationDate: &expiration}}, tokenHash: tokenHash})
+}
+
+func (r *fakeSharesRepository) GetByFile(ctx context.Context, fileUUID string) ([]FileShareResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	shares := []FileShareResponse{}
+	for _, share := range r.shares {
+		if share.FileUUID == fileUUID {
+			shares = append(shares, share.FileShareResponse)
+		}
+	}
+	return shares, nil
+}
+
+func (r *fakeSharesRepository) GetActiveLink(ctx context.Context, tokenHash string, now time.Time) (FileShareResponse, error) {
+	if _, err := database.TenantID(ctx); err != nil {
+		return FileShareResponse{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, share := range r.shares {
+		if share.tokenHash == tokenHash && share.ExpirationDate.After(now) {
+			return share.FileShareResponse, nil
+		}
+	}
+	return FileShareResponse{}, ErrShareNotFound
+}
+
+func (r *fakeSharesRepository) HasActiveShare(ctx context.Context, fileUUID string, userUUID string, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, share := range r.shares {
+		if share.FileUUID == fileUUID && share.UserUUID == userUUID && (share.ExpirationDate == nil || share.ExpirationDate.After(now)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeSharesRepository) Delete(ctx context.Context, fileUUID string, shareUUID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := len(r.shares)
+	r.shares = slices.DeleteFunc(r.shares, func(share fakeShare) bool {
+		return share.FileUUID == fileUUID && share.ShareUUID == shareUUID
+	})
+	if len(r.shares) == count {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+func (r *fakeSharesRepository) WithTx(tx database.DBTX) FileSharesRepository {
+	return r
+}
+
+func (r *fakeSharesRepository) add(share fakeShare) (FileShareResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	share.ShareUUID = fmt.Sprintf("share-%d", len(r.shares)+1)
+	share.CreationDate = time.Now()
+	r.shares = append(r.shares, share)
+	return share.FileShareResponse, nil
+}
+
+func (r *fakeSharesRepository) deleteByFile(fileUUID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.shares = slices.DeleteFunc(r.shares, func(share fakeShare) bool { return share.FileUUID == fileUUID })
+}
+
+// fakeHistoryRepository keeps the access history in memory
+type fakeHistoryRepository struct {
+	mu      sync.Mutex
+	entries []FileAccessResponse
+}
+
+func (r *fakeHistoryRepository) Record(ctx context.Context, fileUUID string, userUUID string, shareUUID string, statusUUID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, FileAccessResponse{FileAccess{
+		AccessUUID: fmt.Sprintf("access-%d", len(r.entries)+1),
+		FileUUID:   fileUUID,
+		UserUUID:   userUUID,
+		ShareUUID:  shareUUID,
+		StatusUUID: statusUUID,
+	}})
+	return nil
+}
+
+func (r *fakeHistoryRepository) GetByFile(ctx context.Context, fileUUID string) ([]FileAccessResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := []FileAccessResponse{}
+	for _, entry := range r.entries {
+		if entry.FileUUID == fileUUID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (r *fakeHistoryRepository) WithTx(tx database.DBTX) FileAccessHistoryRepository {
+	return r
+}
+
+// fakeJobsRepository keeps the processing jobs in memory, the failed ones apart
+type fakeJobsRepository struct {
+	mu     sync.Mutex
+	jobs   []FileJob
+	failed []FileJob
+}
+
+func (r *fakeJobsRepository) Enqueue(ctx context.Context, fileUUID string, userUUID string) error {
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.jobs = append(r.jobs, FileJob{
+		JobUUID:    fmt.Sprintf("job-%d", len(r.jobs)+len(r.failed)+1),
+		TenantUUID: tenantID,
+		FileUUID:   fileUUID,
+		UserUUID:   userUUID,
+		RunAfter:   time.Now(),
+	})
+	return nil
+}
+
+func (r *fakeJobsRepository) Claim(ctx context.Context, lease time.Duration) (FileJob, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, job := range r.jobs {
+		if !job.RunAfter.After(time.Now()) {
+			r.jobs[i].Attempts++
+			r.jobs[i].RunAfter = time.Now().Add(lease)
+			return r.jobs[i], true, nil
+		}
+	}
+	return FileJob{}, false, nil
+}
+
+func (r *fakeJobsRepository) Complete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.jobs = slices.DeleteFunc(r.jobs, func(job FileJob) bool { return job.JobUUID == id })
+	return nil
+}
+
+func (r *fakeJobsRepository) Retry(ctx context.Context, id string, runAfter time.Time, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, job := range r.jobs {
+		if job.JobUUID == id {
+			r.jobs[i].RunAfter, r.jobs[i].LastError = runAfter, reason
+		}
+	}
+	return nil
+}
+
+func (r *fakeJobsRepository) Fail(ctx context.Context, id string, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, job := range r.jobs {
+		if job.JobUUID == id {
+			job.LastError = reason
+			r.failed = append(r.failed, job)
+			r.jobs = slices.Delete(r.jobs, i, i+1)
+			break
+		}
+	}
+	return nil
+}
+
+func (r *fakeJobsRepository) WithTx(tx database.DBTX) FileJobsRepository {
+	return r
+}
+
+// fakeUploadsRepository keeps the resumable uploads in memory
+type fakeUploadsRepository struct {
+	mu      sync.Mutex
+	uploads map[string]ResumableUpload
+	next    int
+}
+
+func newFakeUploadsRepository() *fakeUploadsRepository {
+	return &fakeUploadsRepository{uploads: map[string]ResumableUpload{}}
+}
+
+// add stores an upload as is, returning it
+func (r *fakeUploadsRepository) add(upload ResumableUpload) ResumableUpload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.uploads[upload.UploadUUID] = upload
+	return upload
+}
+
+func (r *fakeUploadsRepository) GetByID(ctx context.Context, id string) (ResumableUpload, error) {
+	if _, err := database.TenantID(ctx); err != nil {
+		return ResumableUpload{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	upload, ok := r.uploads[id]
+	if !ok {
+		return ResumableUpload{}, ErrResumableUploadNotFound
+	}
+	return upload, nil
+}
+
+func (r *fakeUploadsRepository) GetByIDForUpdate(ctx context.Context, id string) (ResumableUpload, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *fakeUploadsRepository) Create(ctx context.Context, data ResumableUpload) (string, error) {
+	if _, err := database.TenantID(ctx); err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.next++
+	data.UploadUUID = fmt.Sprintf("upload-%d", r.next)
+	data.CreationDate = time.Now()
+	r.uploads[data.UploadUUID] = data
+	return data.UploadUUID, nil
+}
+
+func (r *fakeUploadsRepository) UpdateOffset(ctx context.Context, id string, from, to int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	upload, ok := r.uploads[id]
+	if !ok || upload.Offset != from {
+		return ErrUploadOffsetMismatch
+	}
+	upload.Offset = to
+	r.uploads[id] = upload
+	return nil
+}
+
+func (r *fakeUploadsRepository) Complete(ctx context.Context, id string, fileUUID string, statusUUID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	upload, ok := r.uploads[id]
+	if !ok || upload.Completed() {
+		return ErrResumableUploadNotFound
+	}
+	upload.FileUUID, upload.StatusUUID = &fileUUID, statusUUID
+	r.uploads[id] = upload
+	return nil
+}
+
+func (r *fakeUploadsRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.uploads[id]; !ok {
+		return ErrResumableUploadNotFound
+	}
+	delete(r.uploads, id)
+	return nil
+}
+
+func (r *fakeUploadsRepository) GetExpiredIDs(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := []string{}
+	for id, upload := range r.uploads {
+		if upload.ExpirationDate.Before(before) && len(ids) < limit {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *fakeUploadsRepository) WithTx(tx database.DBTX) ResumableUploadsRepository {
+	return r
+}
+
+// fakeStatusRepository knows every status, whose UUID is its name followed by -uuid
+type fakeStatusRepository struct {
+	status.StatusRepository
+}
+
+func (r fakeStatusRepository) GetByName(ctx context.Context, name string) (status.StatusResponse, error) {
+	return status.StatusResponse{Status: status.Status{StatusUUID: name + "-uuid", Name: name}}, nil
+}
+
+func (r fakeStatusRepository) WithTx(tx database.DBTX) status.StatusRepository {
+	return r
+}
+
+// fakeTenantsRepository lists a fixed set of tenants
+type fakeTenantsRepository struct {
+	tenants.TenantsRepository
+	ids []string
+}
+
+func (r fakeTenantsRepository) GetAll(ctx context.Context) ([]tenants.TenantResponse, error) {
+	all := []tenants.TenantResponse{}
+	for _, id := range r.ids {
+		all = append(all, tenants.TenantResponse{Tenant: tenants.Tenant{TenantUUID: id}})
+	}
+	return all, nil
+}
+
+// testRepositories are the in-memory repositories behind the services under test
+type testRepositories struct {
+	files    *fakeFilesRepository
+	versions *fakeVersionsRepository
+	shares   *fakeSharesRepository
+	history  *fakeHistoryRepository
+	jobs     *fakeJobsRepository
+	uploads  *fakeUploadsRepository
+}
+
+func newTestRepositories() *testRepositories {
+	versions := &fakeVersionsRepository{}
+	shares := &fakeSharesRepository{}
+	return &testRepositories{
+		files:    newFakeFilesRepository(versions, shares),
+		versions: versions,
+		shares:   shares,
+		history:  &fakeHistoryRepository{},
+		jobs:     &fakeJobsRepository{},
+		uploads:  newFakeUploadsRepository(),
+	}
+}
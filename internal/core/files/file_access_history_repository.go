@@ -0,0 +1,95 @@
+package files
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+type FileAccessHistoryRepository interface {
+	Record(ctx context.Context, fileUUID string, userUUID string, shareUUID string, statusUUID string) error
+	GetByFile(ctx context.Context, fileUUID string) ([]FileAccessResponse, error)
+	WithTx(tx database.DBTX) FileAccessHistoryRepository
+}
+
+type fileAccessHistoryRepository struct {
+	db      database.DBTX
+	timeout time.Duration
+}
+
+func NewFileAccessHistoryRepository(db database.DBTX, timeout time.Duration) *fileAccessHistoryRepository {
+	return &fileAccessHistoryRepository{db: db, timeout: timeout}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *fileAccessHistoryRepository) WithTx(tx database.DBTX) FileAccessHistoryRepository {
+	return &fileAccessHistoryRepository{db: tx, timeout: r.timeout}
+}
+
+// Record adds an entry to the access history of a file. userUUID is empty for
+// accesses through a share link and shareUUID for accesses not involving a share
+func (r *fileAccessHistoryRepository) Record(ctx context.Context, fileUUID string, userUUID string, shareUUID string, statusUUID string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO default_schema.file_access_history (file_uuid, user_uuid, share_uuid, status_uuid, tenant_uuid)
+		VALUES ($1, NULLIF($2, '')::uuid, NULLIF($3, '')::uuid, $4, $5)`, fileUUID, userUUID, shareUUID, statusUUID, tenantID)
+
+	if err != nil {
+		log.Print(err)
+		return errors.New("failed to record file access")
+	}
+
+	return nil
+}
+
+// GetByFile returns the access history of a file, most recent first
+func (r *fileAccessHistoryRepository) GetByFile(ctx context.Context, fileUUID string) ([]FileAccessResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT h.access_uuid, h.file_uuid, h.user_uuid, h.share_uuid, h.status_uuid, s.name, h.creation_date
+		FROM default_schema.file_access_history h
+		JOIN default_schema.status s ON s.status_uuid = h.status_uuid
+		WHERE h.file_uuid = $1 AND h.tenant_uuid = $2
+		ORDER BY h.creation_date DESC, h.access_uuid DESC`, fileUUID, tenantID)
+
+	if err != nil {
+		return nil, errors.New("failed to retrive file access history")
+	}
+
+	defer rows.Close()
+
+	models := []FileAccessResponse{}
+	for rows.Next() {
+		var model FileAccessResponse
+		var userUUID, shareUUID sql.NullString
+
+		if err := rows.Scan(&model.AccessUUID, &model.FileUUID, &userUUID, &shareUUID, &model.StatusUUID, &model.Status, &model.CreationDate); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		model.UserUUID = userUUID.String
+		model.ShareUUID = shareUUID.String
+
+		models = append(models, model)
+	}
+
+	return models, nil
+}
@@ -0,0 +1,16 @@
+package files
+
+import "time"
+
+// FileJob is a pending processing of a file: scanning, text extraction and thumbnailing
+type FileJob struct {
+	JobUUID          string     `json:"job_uuid" db:"job_uuid"`                             // UUID do job (chave primaria)
+	TenantUUID       string     `json:"tenant_uuid" db:"tenant_uuid"`                       // Tenant do arquivo
+	FileUUID         string     `json:"file_uuid" db:"file_uuid"`                           // Arquivo processado
+	UserUUID         string     `json:"user_uuid" db:"user_uuid"`                           // Usuario notificado ao final
+	Attempts         int        `json:"attempts" db:"attempts"`                             // Tentativas iniciadas
+	LastError        string     `json:"last_error,omitempty" db:"last_error"`               // Erro da ultima tentativa
+	RunAfter         time.Time  `json:"run_after" db:"run_after"`                           // Proxima tentativa
+	CreationDate     time.Time  `json:"creation_date,omitempty" db:"creation_date"`         // Data de criação
+	ModificationDate *time.Time `json:"modification_date,omitempty" db:"modification_date"` // Data de modificação
+}
@@ -0,0 +1,152 @@
+package files
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// FileJobsRepository stores the processing jobs. Enqueue is scoped to the tenant
+// of the context, the other methods serve the workers of every tenant
+type FileJobsRepository interface {
+	Enqueue(ctx context.Context, fileUUID string, userUUID string) error
+	Claim(ctx context.Context, lease time.Duration) (FileJob, bool, error)
+	Complete(ctx context.Context, id string) error
+	Retry(ctx context.Context, id string, runAfter time.Time, reason string) error
+	Fail(ctx context.Context, id string, reason string) error
+	WithTx(tx database.DBTX) FileJobsRepository
+}
+
+type fileJobsRepository struct {
+	db      database.DBTX
+	timeout time.Duration
+}
+
+func NewFileJobsRepository(db database.DBTX, timeout time.Duration) *fileJobsRepository {
+	return &fileJobsRepository{db: db, timeout: timeout}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *fileJobsRepository) WithTx(tx database.DBTX) FileJobsRepository {
+	return &fileJobsRepository{db: tx, timeout: r.timeout}
+}
+
+// Enqueue schedules the processing of a file. Run it in the transaction creating
+// the file, so a file is never left without its job
+func (r *fileJobsRepository) Enqueue(ctx context.Context, fileUUID string, userUUID string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO default_schema.file_jobs (file_uuid, user_uuid, tenant_uuid)
+		VALUES ($1, NULLIF($2, '')::uuid, $3)`, fileUUID, userUUID, tenantID)
+
+	if err != nil {
+		log.Print(err)
+		return errors.New("failed to enqueue file job")
+	}
+
+	return nil
+}
+
+// Claim takes the next due job of any tenant and leases it to the caller. A job
+// whose lease expires, e.g. because its worker died, is claimed again
+func (r *fileJobsRepository) Claim(ctx context.Context, lease time.Duration) (FileJob, bool, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var job FileJob
+	var userUUID, lastError sql.NullString
+
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE default_schema.file_jobs
+		SET attempts = attempts + 1,
+		    locked_until = CURRENT_TIMESTAMP + $1 * INTERVAL '1 millisecond',
+		    modification_date = CURRENT_TIMESTAMP
+		WHERE job_uuid = (
+			SELECT job_uuid
+			FROM default_schema.file_jobs
+			WHERE failed_date IS NULL
+			  AND run_after <= CURRENT_TIMESTAMP
+			  AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP)
+			ORDER BY run_after
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING job_uuid, tenant_uuid, file_uuid, user_uuid, attempts, last_error, run_after, creation_date, modification_date`,
+		lease.Milliseconds()).
+		Scan(&job.JobUUID, &job.TenantUUID, &job.FileUUID, &userUUID, &job.Attempts, &lastError, &job.RunAfter, &job.CreationDate, &job.ModificationDate)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return FileJob{}, false, nil
+		}
+
+		log.Print(err)
+		return FileJob{}, false, errors.New("failed to claim file job")
+	}
+
+	job.UserUUID = userUUID.String
+	job.LastError = lastError.String
+	return job, true, nil
+}
+
+// Complete removes a finished job
+func (r *fileJobsRepository) Complete(ctx context.Context, id string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM default_schema.file_jobs WHERE job_uuid = $1`, id); err != nil {
+		return errors.New("failed to complete file job")
+	}
+
+	return nil
+}
+
+// Retry releases a job until runAfter, recording why the attempt failed
+func (r *fileJobsRepository) Retry(ctx context.Context, id string, runAfter time.Time, reason string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE default_schema.file_jobs
+		SET run_after = $2,
+		    last_error = $3,
+		    locked_until = NULL,
+		    modification_date = CURRENT_TIMESTAMP
+		WHERE job_uuid = $1`, id, runAfter, reason)
+
+	if err != nil {
+		return errors.New("failed to retry file job")
+	}
+
+	return nil
+}
+
+// Fail stops retrying a job. It is kept with its last error for inspection
+func (r *fileJobsRepository) Fail(ctx context.Context, id string, reason string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE default_schema.file_jobs
+		SET failed_date = CURRENT_TIMESTAMP,
+		    last_error = $2,
+		    locked_until = NULL,
+		    modification_date = CURRENT_TIMESTAMP
+		WHERE job_uuid = $1`, id, reason)
+
+	if err != nil {
+		return errors.New("failed to fail file job")
+	}
+
+	return nil
+}
@@ -5,9 +5,14 @@ import "time"
 type File struct {
 	FileUUID         string     `json:"file_uuid" db:"file_uuid"`                           // UUID do arquivo  (chave primaria)
 	Name             string     `json:"file_name" db:"file_name"`                           // Nome do arquivo
-	Link             string     `json:"file_link" db:"file_link"`                           // LInk do arquivo
+	OriginalName     string     `json:"original_file_name" db:"original_file_name"`         // Nome original do arquivo enviado
+	Link             string     `json:"-" db:"file_link"`                                   // LInk do arquivo
+	Key              string     `json:"-" db:"file_key"`                                    // Chave do arquivo no storage
 	Folder           string     `json:"file_folder" db:"file_folder"`                       // Pasta do arquivo
 	Type             string     `json:"file_type" db:"file_type"`                           // typo do arquivo
+	Checksum         string     `json:"checksum" db:"checksum"`                             // SHA-256 do conteudo
+	Size             int64      `json:"file_size" db:"file_size"`                           // Tamanho em bytes
+	OwnerUUID        string     `json:"owner_uuid" db:"owner_uuid"`                         // Usuario que enviou o arquivo
 	StatusUUID       string     `json:"status_uuid" db:"status_uuid"`                       // Pasta do arquivo
 	CreationDate     time.Time  `json:"creation_date,omitempty" db:"creation_date"`         // Data de criação (valor padrão: data atual)
 	ModificationDate *time.Time `json:"modification_date,omitempty" db:"modification_date"` // Data de modificação (opcional)
@@ -0,0 +1,444 @@
+package files
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/core/status"
+	"bernardtm/backend/internal/core/storage"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/infra/socket"
+	"bernardtm/backend/pkg/imaging"
+	"bernardtm/backend/pkg/providers/scanners"
+	"bernardtm/backend/pkg/providers/storages"
+	"bernardtm/backend/pkg/textextract"
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// errUnprocessable marks the failures retrying cannot fix, such as a corrupt image
+var errUnprocessable = errors.New("file cannot be processed")
+
+const (
+	// thumbnailSize is the side in pixels of the square thumbnails
+	thumbnailSize = 256
+	// maxThumbnailPixels bounds the images decoded for a thumbnail, which are
+	// fully loaded in memory
+	maxThumbnailPixels = 64 << 20
+	// maxRetryDelay bounds the exponential delay between attempts
+	maxRetryDelay = 30 * time.Minute
+)
+
+// thumbnailTypes are the image types thumbnails are generated for
+var thumbnailTypes = map[string]bool{"image/jpeg": true, "image/png": true, "image/gif": true, "image/webp": true}
+
+// FileProcessor scans the uploaded files for malware, extracts their text and
+// generates their thumbnails in background workers. Each file ends up Processed,
+// Rejected when infected or Failed, and its uploader is notified over the websocket
+type FileProcessor interface {
+	Enqueue(ctx context.Context, uow *database.UnitOfWork, fileUUID string, userUUID string) error
+	Notify()
+	Start(ctx context.Context)
+	Close()
+}
+
+type fileProcessor struct {
+	jobsRepo       FileJobsRepository
+	filesRepo      FilesRepository
+	versionsRepo   FileVersionsRepository
+	statusRepo     status.StatusRepository
+	storageService storage.StorageService
+	scanner        scanners.ScannerProvider
+	notifier       socket.Notifier
+	publisher      socket.Publisher
+	txManager      database.TxManager
+	workers        int
+	interval       time.Duration
+	timeout        time.Duration
+	maxAttempts    int
+	wake           chan struct{}
+	stop           context.CancelFunc
+	done           sync.WaitGroup
+}
+
+// processingResult is what the processing learned about a file
+type processingResult struct {
+	Signature     string // Threat found by the scanner, empty when the file is clean
+	Text          string
+	ThumbnailLink string
+}
+
+func NewFileProcessor(jobsRepo FileJobsRepository, filesRepo FilesRepository, versionsRepo FileVersionsRepository, statusRepo status.StatusRepository, storageService storage.StorageService, scanner scanners.ScannerProvider, notifier socket.Notifier, publisher socket.Publisher, txManager database.TxManager, config *configs.AppConfig) *fileProcessor {
+	return &fileProcessor{
+		jobsRepo:       jobsRepo,
+		filesRepo:      filesRepo,
+		versionsRepo:   versionsRepo,
+		statusRepo:     statusRepo,
+		storageService: storageService,
+		scanner:        scanner,
+		notifier:       notifier,
+		publisher:      publisher,
+		txManager:      txManager,
+		workers:        max(config.ProcessingWorkers, 1),
+		interval:       cmp.Or(config.ProcessingInterval, 5*time.Second),
+		timeout:        cmp.Or(config.ProcessingTimeout, 10*time.Minute),
+		maxAttempts:    max(config.ProcessingMaxAttempts, 1),
+		wake:           make(chan struct{}, 1),
+	}
+}
+
+// Enqueue schedules the processing of a file in the transaction creating it.
+// Call Notify once the transaction is committed to start it right away
+func (p *fileProcessor) Enqueue(ctx context.Context, uow *database.UnitOfWork, fileUUID string, userUUID string) error {
+	return p.jobsRepo.WithTx(uow.Tx()).Enqueue(ctx, fileUUID, userUUID)
+}
+
+// Notify wakes an idle worker
+func (p *fileProcessor) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the workers until Close. Jobs left by a previous run, including
+// the ones interrupted by a crash, are picked up once their lease expires
+func (p *fileProcessor) Start(ctx context.Context) {
+	ctx, p.stop = context.WithCancel(ctx)
+
+	for i := 0; i < p.workers; i++ {
+		p.done.Add(1)
+		go func() {
+			defer p.done.Done()
+			p.work(ctx)
+		}()
+	}
+}
+
+// Close stops the workers and waits for the running jobs to return
+func (p *fileProcessor) Close() {
+	if p.stop != nil {
+		p.stop()
+		p.done.Wait()
+	}
+}
+
+func (p *fileProcessor) work(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		for p.runNext(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runNext claims and handles one job, reporting whether there was one
+func (p *fileProcessor) runNext(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// the lease outlives the processing timeout, leaving time to record the outcome
+	job, ok, err := p.jobsRepo.Claim(ctx, p.timeout+time.Minute)
+	if err != nil {
+		log.Printf("failed to claim file job: %v", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	p.handle(ctx, job)
+	return true
+}
+
+// handle processes the file of a job and records the outcome
+func (p *fileProcessor) handle(ctx context.Context, job FileJob) {
+	ctx = database.WithTenant(ctx, job.TenantUUID)
+	// the outcome must be recorded even when the processing times out or the
+	// workers stop
+	record := context.WithoutCancel(ctx)
+
+	file, err := p.filesRepo.GetByID(ctx, job.FileUUID)
+	if errors.Is(err, ErrFileNotFound) {
+		p.complete(record, job)
+		return
+	}
+	if err != nil {
+		p.retry(record, job, file, err)
+		return
+	}
+
+	rejected, err := p.statusRepo.GetByName(ctx, "Rejected")
+	if err != nil {
+		p.retry(record, job, file, err)
+		return
+	}
+	if file.StatusUUID == rejected.StatusUUID {
+		// rejected along with another file sharing its content
+		p.complete(record, job)
+		return
+	}
+
+	processCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	result, err := p.process(processCtx, file)
+	cancel()
+	if err != nil {
+		p.retry(record, job, file, err)
+		return
+	}
+
+	if result.Signature != "" {
+		err = p.reject(record, job, file, rejected.StatusUUID)
+	} else {
+		err = p.finish(record, job, file, result)
+	}
+	if err != nil {
+		p.retry(record, job, file, err)
+	}
+}
+
+// process scans a file, then extracts its text and generates its thumbnail when clean
+func (p *fileProcessor) process(ctx context.Context, file FileResponse) (processingResult, error) {
+	content, size, err := p.download(ctx, file)
+	if err != nil {
+		return processingResult{}, err
+	}
+	defer os.Remove(content.Name())
+	defer content.Close()
+
+	scan, err := p.scanner.Scan(ctx, io.NewSectionReader(content, 0, size))
+	if err != nil {
+		return processingResult{}, fmt.Errorf("failed to scan file: %w", err)
+	}
+	if scan.Infected {
+		return processingResult{Signature: scan.Signature}, nil
+	}
+
+	var result processingResult
+
+	if textextract.Supported(file.Type) {
+		result.Text, err = textextract.Extract(file.Type, content, size)
+		if err != nil {
+			return processingResult{}, fmt.Errorf("%w: %v", errUnprocessable, err)
+		}
+	}
+
+	if thumbnailTypes[file.Type] {
+		result.ThumbnailLink, err = p.thumbnail(ctx, file, io.NewSectionReader(content, 0, size))
+		if err != nil {
+			return processingResult{}, err
+		}
+	}
+
+	return result, nil
+}
+
+// download copies the object of a file to a temporary file, verifying its checksum
+func (p *fileProcessor) download(ctx context.Context, file FileResponse) (*os.File, int64, error) {
+	object, err := p.storageService.Download(ctx, file.Key)
+	if errors.Is(err, storages.ErrObjectNotFound) {
+		return nil, 0, fmt.Errorf("%w: %v", errUnprocessable, err)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer object.Body.Close()
+
+	body := io.Reader(object.Body)
+	if file.Checksum != "" {
+		body = newVerifyingReader(object.Body, file.Checksum, file.Key)
+	}
+
+	content, err := os.CreateTemp("", "file-processing-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	size, err := io.Copy(content, body)
+	if err != nil {
+		content.Close()
+		os.Remove(content.Name())
+		if errors.Is(err, ErrChecksumMismatch) {
+			return nil, 0, fmt.Errorf("%w: %v", errUnprocessable, err)
+		}
+		return nil, 0, fmt.Errorf("failed to download file: %w", err)
+	}
+
+	return content, size, nil
+}
+
+// thumbnail stores a square WebP thumbnail of an image next to its object
+func (p *fileProcessor) thumbnail(ctx context.Context, file FileResponse, content io.ReadSeeker) (string, error) {
+	config, _, err := image.DecodeConfig(content)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errUnprocessable, err)
+	}
+	if config.Width*config.Height > maxThumbnailPixels {
+		// too large to be decoded safely, the file is processed without thumbnail
+		return "", nil
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	img, err := imaging.Decode(content)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errUnprocessable, err)
+	}
+
+	var buffer bytes.Buffer
+	if err := imaging.Encode(&buffer, imaging.Thumbnail(img, thumbnailSize), imaging.WebP); err != nil {
+		return "", fmt.Errorf("%w: %v", errUnprocessable, err)
+	}
+
+	key := thumbnailKey(file.Key)
+	if err := p.storageService.Put(ctx, key, bytes.NewReader(buffer.Bytes()), imaging.WebP.ContentType()); err != nil {
+		return "", fmt.Errorf("failed to store thumbnail: %w", err)
+	}
+	return p.storageService.ObjectURL(key), nil
+}
+
+// finish marks a clean file as Processed with the results of its processing
+func (p *fileProcessor) finish(ctx context.Context, job FileJob, file FileResponse, result processingResult) error {
+	processed, err := p.statusRepo.GetByName(ctx, "Processed")
+	if err != nil {
+		return err
+	}
+
+	err = p.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		if err := p.filesRepo.WithTx(uow.Tx()).UpdateProcessed(ctx, file.FileUUID, processed.StatusUUID, result.Text, result.ThumbnailLink); err != nil {
+			return err
+		}
+		return p.jobsRepo.WithTx(uow.Tx()).Complete(ctx, job.JobUUID)
+	})
+	if err != nil {
+		return err
+	}
+
+	p.publish(ctx, job, "Processed")
+	p.notify(ctx, job, file, "File processed", fmt.Sprintf("%s is ready", displayName(file)))
+	return nil
+}
+
+// reject removes the infected object, marks every file sharing it as Rejected and
+// removes the versions keeping it
+func (p *fileProcessor) reject(ctx context.Context, job FileJob, file FileResponse, rejectedUUID string) error {
+	for _, key := range []string{file.Key, thumbnailKey(file.Key)} {
+		if err := p.storageService.Delete(ctx, key); err != nil && !errors.Is(err, storages.ErrObjectNotFound) {
+			return err
+		}
+	}
+
+	err := p.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		if err := p.filesRepo.WithTx(uow.Tx()).UpdateStatusByKey(ctx, file.Key, rejectedUUID); err != nil {
+			return err
+		}
+		if err := p.versionsRepo.WithTx(uow.Tx()).DeleteByKey(ctx, file.Key); err != nil {
+			return err
+		}
+		return p.jobsRepo.WithTx(uow.Tx()).Complete(ctx, job.JobUUID)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("file %s of tenant %s rejected by the malware scanner", file.FileUUID, job.TenantUUID)
+	p.publish(ctx, job, "Rejected")
+	p.notify(ctx, job, file, "File rejected", fmt.Sprintf("%s was removed because it contains malware", displayName(file)))
+	return nil
+}
+
+// retry schedules another attempt of a job with an exponential delay, or marks
+// its file as Failed once the attempts are exhausted or retrying cannot help
+func (p *fileProcessor) retry(ctx context.Context, job FileJob, file FileResponse, cause error) {
+	log.Printf("failed to process file %s (attempt %d): %v", job.FileUUID, job.Attempts, cause)
+
+	if !errors.Is(cause, errUnprocessable) && job.Attempts < p.maxAttempts {
+		delay := min(time.Duration(1<<job.Attempts)*p.interval, maxRetryDelay)
+		if err := p.jobsRepo.Retry(ctx, job.JobUUID, time.Now().Add(delay), cause.Error()); err != nil {
+			log.Printf("failed to reschedule file job %s: %v", job.JobUUID, err)
+		}
+		return
+	}
+
+	failed, err := p.statusRepo.GetByName(ctx, "Failed")
+	if err == nil {
+		err = p.filesRepo.UpdateStatus(ctx, job.FileUUID, failed.StatusUUID)
+	}
+	if err != nil && !errors.Is(err, ErrFileNotFound) {
+		log.Printf("failed to mark file %s as failed: %v", job.FileUUID, err)
+	}
+	if err := p.jobsRepo.Fail(ctx, job.JobUUID, cause.Error()); err != nil {
+		log.Printf("failed to fail file job %s: %v", job.JobUUID, err)
+	}
+
+	p.publish(ctx, job, "Failed")
+	p.notify(ctx, job, file, "File processing failed", fmt.Sprintf("%s could not be processed", displayName(file)))
+}
+
+// complete removes a job with nothing left to do
+func (p *fileProcessor) complete(ctx context.Context, job FileJob) {
+	if err := p.jobsRepo.Complete(ctx, job.JobUUID); err != nil {
+		log.Printf("failed to complete file job %s: %v", job.JobUUID, err)
+	}
+}
+
+// notify tells the uploader of a file how its processing ended
+func (p *fileProcessor) notify(ctx context.Context, job FileJob, file FileResponse, title string, description string) {
+	if job.UserUUID == "" {
+		return
+	}
+
+	p.notifier.Send(ctx, job.UserUUID, socket.NotificationType{
+		ReceivedUUID: job.UserUUID,
+		UUID:         &job.FileUUID,
+		Title:        title,
+		Description:  description,
+		CreationDate: time.Now().Format(time.RFC3339),
+		OptionType:   "file_processing",
+	})
+}
+
+// fileStatusChange is the data of the change events of the processed files
+type fileStatusChange struct {
+	Status string `json:"status"`
+}
+
+// publish tells the clients following a file the status its processing ended in
+func (p *fileProcessor) publish(ctx context.Context, job FileJob, statusName string) {
+	p.publisher.Publish(ctx, socket.FileTopic(job.FileUUID), socket.ChangeEvent{
+		Action: socket.ActionUpdated,
+		ID:     job.FileUUID,
+		Data:   fileStatusChange{Status: statusName},
+	})
+}
+
+// displayName returns the name of a file shown in the notifications
+func displayName(file FileResponse) string {
+	if file.OriginalName == "" {
+		return "The file"
+	}
+	return file.OriginalName
+}
+
+// thumbnailKey returns the key of the thumbnail of the object of key
+func thumbnailKey(key string) string {
+	return path.Join("thumbnails", key) + ".webp"
+}
@@ -0,0 +1,150 @@
+package files
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/core/storage"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/infra/socket"
+	"bernardtm/backend/pkg/imaging"
+	"bernardtm/backend/pkg/providers/scanners"
+	"bernardtm/backend/pkg/providers/storages"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingNotifier keeps the notifications and the published events instead of
+// sending them
+type recordingNotifier struct {
+	sent      []socket.NotificationType
+	published []socket.ChangeEvent
+}
+
+func (n *recordingNotifier) Send(ctx context.Context, userUUID string, message socket.NotificationType) {
+	n.sent = append(n.sent, message)
+}
+
+func (n *recordingNotifier) Publish(ctx context.Context, topic string, payload any) {
+	if event, ok := payload.(socket.ChangeEvent); ok {
+		n.published = append(n.published, event)
+	}
+}
+
+// failingScanner is a scanner whose daemon cannot be reached
+type failingScanner struct{}
+
+func (failingScanner) Scan(ctx context.Context, content io.Reader) (scanners.ScanResult, error) {
+	return scanners.ScanResult{}, errors.New("connection refused")
+}
+
+func newTestFileProcessor(repos *testRepositories, provider storages.StorageProvider, scanner scanners.ScannerProvider) (*fileProcessor, *recordingNotifier) {
+	notifier := &recordingNotifier{}
+	processor := NewFileProcessor(
+		repos.jobs,
+		repos.files,
+		repos.versions,
+		fakeStatusRepository{},
+		storage.NewStorageService(provider),
+		scanner,
+		notifier,
+		notifier,
+		database.NewFakeTxManager(),
+		&configs.AppConfig{ProcessingInterval: time.Second, ProcessingTimeout: time.Second, ProcessingMaxAttempts: 2},
+	)
+	return processor, notifier
+}
+
+// enqueueFile stores an uploaded file of fileType under key and its processing job
+func enqueueFile(t *testing.T, ctx context.Context, repos *testRepositories, key string, fileType string) {
+	repos.files.add(FileResponse{FileUUID: "file-1", Name: "blob", OriginalName: "upload", Link: "memory://" + key, Key: key, Type: fileType, OwnerUUID: testUser, StatusUUID: "Uploaded-uuid"})
+	if err := repos.jobs.Enqueue(ctx, "file-1", testUser); err != nil {
+		t.Fatalf("failed to enqueue job: %v", err)
+	}
+}
+
+func TestFileProcessor_ProcessesCleanFile(t *testing.T) {
+	repos := newTestRepositories()
+	provider := storages.NewMemoryStorageProvider()
+	processor, notifier := newTestFileProcessor(repos, provider, scanners.NewFakeScannerProvider())
+	ctx := database.WithTenant(context.Background(), testTenant)
+	provider.Put(ctx, "blobs/image", bytes.NewReader(pngImage(t, 400, 300)), "image/png")
+	enqueueFile(t, ctx, repos, "blobs/image", "image/png")
+
+	assert.True(t, processor.runNext(context.Background()))
+
+	file := repos.files.get("file-1")
+	assert.Equal(t, "Processed-uuid", file.StatusUUID)
+	assert.Equal(t, "memory://thumbnails/blobs/image.webp", file.ThumbnailLink)
+	assert.Empty(t, repos.jobs.jobs, "the job is completed")
+
+	object, err := provider.Download(ctx, "thumbnails/blobs/image.webp")
+	if assert.NoError(t, err) {
+		thumbnail, err := imaging.Decode(object.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "webp", thumbnail.Format)
+		assert.Equal(t, thumbnailSize, thumbnail.Bounds().Dx())
+	}
+	if assert.Len(t, notifier.sent, 1) {
+		assert.Equal(t, "File processed", notifier.sent[0].Title)
+		assert.Equal(t, "file-1", *notifier.sent[0].UUID)
+	}
+	assert.Equal(t, []socket.ChangeEvent{{Action: socket.ActionUpdated, ID: "file-1", Data: fileStatusChange{Status: "Processed"}}}, notifier.published)
+}
+
+func TestFileProcessor_RejectsInfectedFile(t *testing.T) {
+	repos := newTestRepositories()
+	provider := storages.NewMemoryStorageProvider()
+	processor, notifier := newTestFileProcessor(repos, provider, scanners.NewFakeScannerProvider())
+	ctx := database.WithTenant(context.Background(), testTenant)
+	provider.Put(ctx, "blobs/eicar", strings.NewReader(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`), "text/plain")
+	enqueueFile(t, ctx, repos, "blobs/eicar", "text/plain")
+	repos.versions.Create(ctx, FileResponse{FileUUID: "file-2", Key: "blobs/eicar"}, testUser)
+
+	assert.True(t, processor.runNext(context.Background()))
+
+	assert.Equal(t, "Rejected-uuid", repos.files.get("file-1").StatusUUID)
+	assert.Empty(t, repos.versions.versions, "the versions of the infected content are removed")
+	assert.Empty(t, repos.jobs.jobs, "the job is completed")
+	_, err := provider.Stat(ctx, "blobs/eicar")
+	assert.ErrorIs(t, err, storages.ErrObjectNotFound)
+	if assert.Len(t, notifier.sent, 1) {
+		assert.Equal(t, "File rejected", notifier.sent[0].Title)
+	}
+}
+
+func TestFileProcessor_RetriesThenFails(t *testing.T) {
+	repos := newTestRepositories()
+	provider := storages.NewMemoryStorageProvider()
+	processor, notifier := newTestFileProcessor(repos, provider, failingScanner{})
+	ctx := database.WithTenant(context.Background(), testTenant)
+	provider.Put(ctx, "blobs/notes", strings.NewReader("notes"), "text/plain")
+	enqueueFile(t, ctx, repos, "blobs/notes", "text/plain")
+
+	// the first attempt is retried later
+	assert.True(t, processor.runNext(context.Background()))
+	if assert.Len(t, repos.jobs.jobs, 1) {
+		assert.Equal(t, "failed to scan file: connection refused", repos.jobs.jobs[0].LastError)
+		assert.True(t, repos.jobs.jobs[0].RunAfter.After(time.Now()))
+	}
+	assert.Equal(t, "Uploaded-uuid", repos.files.get("file-1").StatusUUID)
+	assert.Empty(t, notifier.sent)
+
+	// the last one marks the file as Failed
+	repos.jobs.jobs[0].RunAfter = time.Now()
+	assert.True(t, processor.runNext(context.Background()))
+
+	assert.Empty(t, repos.jobs.jobs)
+	if assert.Len(t, repos.jobs.failed, 1) {
+		assert.Equal(t, "failed to scan file: connection refused", repos.jobs.failed[0].LastError)
+	}
+	assert.Equal(t, "Failed-uuid", repos.files.get("file-1").StatusUUID)
+	if assert.Len(t, notifier.sent, 1) {
+		assert.Equal(t, "File processing failed", notifier.sent[0].Title)
+	}
+}
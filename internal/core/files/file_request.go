@@ -1,5 +1,25 @@
 package files
 
+import "time"
+
 type FileRequest struct {
 	File
 }
+
+type UploadURLRequest struct {
+	FileName    string `json:"file_name" binding:"required" example:"report.pdf"`
+	ContentType string `json:"content_type" example:"application/pdf"`
+}
+
+type FileShareRequest struct {
+	UserUUID       string     `json:"user_uuid" binding:"required,uuid" example:"0192d1a4-0000-7000-8000-000000000001"`
+	ExpirationDate *time.Time `json:"expiration_date" example:"2030-01-01T00:00:00Z"`
+}
+
+type ShareLinkRequest struct {
+	ExpirationDate *time.Time `json:"expiration_date" example:"2030-01-01T00:00:00Z"`
+}
+
+type FileOwnerRequest struct {
+	OwnerUUID string `json:"owner_uuid" binding:"required,uuid" example:"0192d1a4-0000-7000-8000-000000000001"`
+}
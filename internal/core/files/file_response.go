@@ -1,16 +1,47 @@
 package files
 
 import (
+	"bernardtm/backend/pkg/providers/storages"
 	"time"
 )
 
 type FileResponse struct {
 	FileUUID         string     `json:"fileUUID"`
 	Name             string     `json:"file_name"`
-	Link             string     `json:"file_link"`
+	OriginalName     string     `json:"original_file_name"`
+	Link             string     `json:"-"` // direct URL of the object, only pre-signed URLs are handed out
+	Key              string     `json:"-"`
 	Folder           string     `json:"file_folder"`
 	Type             string     `json:"file_type"`
+	Checksum         string     `json:"checksum,omitempty"`
+	Size             int64      `json:"file_size,omitempty"`
+	ThumbnailLink    string     `json:"thumbnail_link,omitempty"` // pre-signed URL of the thumbnail, like the file itself
+	OwnerUUID        string     `json:"owner_uuid,omitempty"`
 	StatusUUID       string     `json:"status_uuid" db:"status_uuid"`
 	CreationDate     time.Time  `json:"creation_date,omitempty"`
 	ModificationDate *time.Time `json:"modification_date,omitempty"`
 }
+
+type UploadURLResponse struct {
+	FileUUID string                `json:"file_uuid"`
+	Upload   storages.PresignedURL `json:"upload"`
+}
+
+type FileShareResponse struct {
+	FileShare
+}
+
+// ShareLinkResponse is a new share link. Its token is only returned once
+type ShareLinkResponse struct {
+	FileShareResponse
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+type FileAccessResponse struct {
+	FileAccess
+}
+
+type FileVersionResponse struct {
+	FileVersion
+}
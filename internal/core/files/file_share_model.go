@@ -0,0 +1,26 @@
+package files
+
+import "time"
+
+// FileShare grants read access to a file, either to one user or to anyone holding
+// the token of a share link
+type FileShare struct {
+	ShareUUID        string     `json:"share_uuid" db:"share_uuid"`                         // UUID do compartilhamento (chave primaria)
+	FileUUID         string     `json:"file_uuid" db:"file_uuid"`                           // Arquivo compartilhado
+	UserUUID         string     `json:"user_uuid,omitempty" db:"user_uuid"`                 // Usuario com acesso, vazio nos links
+	CreatedBy        string     `json:"created_by,omitempty" db:"created_by"`               // Usuario que compartilhou
+	ExpirationDate   *time.Time `json:"expiration_date,omitempty" db:"expiration_date"`     // Fim do acesso (opcional para usuarios)
+	CreationDate     time.Time  `json:"creation_date,omitempty" db:"creation_date"`         // Data de criação
+	ModificationDate *time.Time `json:"modification_date,omitempty" db:"modification_date"` // Data de modificação
+}
+
+// FileAccess is an entry of the access history of a file
+type FileAccess struct {
+	AccessUUID   string    `json:"access_uuid" db:"access_uuid"`         // UUID do acesso (chave primaria)
+	FileUUID     string    `json:"file_uuid" db:"file_uuid"`             // Arquivo acessado
+	UserUUID     string    `json:"user_uuid,omitempty" db:"user_uuid"`   // Usuario, vazio nos acessos por link
+	ShareUUID    string    `json:"share_uuid,omitempty" db:"share_uuid"` // Compartilhamento usado ou criado
+	StatusUUID   string    `json:"status_uuid" db:"status_uuid"`         // Shared ou Downloaded
+	Status       string    `json:"status" db:"name"`                     // Nome do status
+	CreationDate time.Time `json:"creation_date" db:"creation_date"`     // Data do acesso
+}
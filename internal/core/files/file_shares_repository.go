@@ -0,0 +1,229 @@
+package files
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrShareNotFound is returned when no active share matches the given ID or token
+var ErrShareNotFound = errors.New("file share not found")
+
+// ErrShareUserNotFound is returned when sharing a file with a user outside the tenant
+var ErrShareUserNotFound = errors.New("user to share with not found")
+
+// shareColumns is the column list scanned by scanShare
+const shareColumns = `share_uuid, file_uuid, user_uuid, created_by, expiration_date, creation_date, modification_date`
+
+type FileSharesRepository interface {
+	ShareWithUser(ctx context.Context, fileUUID string, userUUID string, createdBy string, expiration *time.Time) (FileShareResponse, error)
+	CreateLink(ctx context.Context, fileUUID string, tokenHash string, createdBy string, expiration time.Time) (FileShareResponse, error)
+	GetByFile(ctx context.Context, fileUUID string) ([]FileShareResponse, error)
+	GetActiveLink(ctx context.Context, tokenHash string, now time.Time) (FileShareResponse, error)
+	HasActiveShare(ctx context.Context, fileUUID string, userUUID string, now time.Time) (bool, error)
+	Delete(ctx context.Context, fileUUID string, shareUUID string) error
+	WithTx(tx database.DBTX) FileSharesRepository
+}
+
+type fileSharesRepository struct {
+	db      database.DBTX
+	timeout time.Duration
+}
+
+func NewFileSharesRepository(db database.DBTX, timeout time.Duration) *fileSharesRepository {
+	return &fileSharesRepository{db: db, timeout: timeout}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *fileSharesRepository) WithTx(tx database.DBTX) FileSharesRepository {
+	return &fileSharesRepository{db: tx, timeout: r.timeout}
+}
+
+func scanShare(row rowScanner) (FileShareResponse, error) {
+	var model FileShareResponse
+	var userUUID, createdBy sql.NullString
+	var expiration sql.NullTime
+
+	err := row.Scan(&model.ShareUUID, &model.FileUUID, &userUUID, &createdBy, &expiration, &model.CreationDate, &model.ModificationDate)
+	model.UserUUID = userUUID.String
+	model.CreatedBy = createdBy.String
+	if expiration.Valid {
+		model.ExpirationDate = &expiration.Time
+	}
+
+	return model, err
+}
+
+// ShareWithUser grants a user of the tenant read access to a file. Sharing a file
+// again with the same user replaces the expiration of the existing share
+func (r *fileSharesRepository) ShareWithUser(ctx context.Context, fileUUID string, userUUID string, createdBy string, expiration *time.Time) (FileShareResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return FileShareResponse{}, err
+	}
+
+	model, err := scanShare(r.db.QueryRowContext(ctx, `
+		INSERT INTO default_schema.file_shares (file_uuid, user_uuid, created_by, expiration_date, tenant_uuid)
+		SELECT $1, user_uuid, NULLIF($3, '')::uuid, $4, $5
+		FROM default_schema.users
+		WHERE user_uuid = $2 AND tenant_uuid = $5
+		ON CONFLICT (file_uuid, user_uuid) DO UPDATE
+		SET expiration_date = EXCLUDED.expiration_date,
+		    modification_date = CURRENT_TIMESTAMP
+		RETURNING `+shareColumns, fileUUID, userUUID, createdBy, expiration, tenantID))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return FileShareResponse{}, ErrShareUserNotFound
+		}
+
+		log.Print(err)
+		return FileShareResponse{}, errors.New("failed to share file")
+	}
+
+	return model, nil
+}
+
+// CreateLink stores a share link. Only the hash of its token is kept
+func (r *fileSharesRepository) CreateLink(ctx context.Context, fileUUID string, tokenHash string, createdBy string, expiration time.Time) (FileShareResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return FileShareResponse{}, err
+	}
+
+	model, err := scanShare(r.db.QueryRowContext(ctx, `
+		INSERT INTO default_schema.file_shares (file_uuid, token_hash, created_by, expiration_date, tenant_uuid)
+		VALUES ($1, $2, NULLIF($3, '')::uuid, $4, $5)
+		RETURNING `+shareColumns, fileUUID, tokenHash, createdBy, expiration, tenantID))
+
+	if err != nil {
+		log.Print(err)
+		return FileShareResponse{}, errors.New("failed to create share link")
+	}
+
+	return model, nil
+}
+
+// GetByFile returns every share of a file, expired ones included
+func (r *fileSharesRepository) GetByFile(ctx context.Context, fileUUID string) ([]FileShareResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+shareColumns+`
+		FROM default_schema.file_shares
+		WHERE file_uuid = $1 AND tenant_uuid = $2
+		ORDER BY creation_date DESC, share_uuid DESC`, fileUUID, tenantID)
+
+	if err != nil {
+		return nil, errors.New("failed to retrive file shares")
+	}
+
+	defer rows.Close()
+
+	models := []FileShareResponse{}
+	for rows.Next() {
+		model, err := scanShare(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		models = append(models, model)
+	}
+
+	return models, nil
+}
+
+// GetActiveLink returns the share link of a token hash, unless it expired
+func (r *fileSharesRepository) GetActiveLink(ctx context.Context, tokenHash string, now time.Time) (FileShareResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return FileShareResponse{}, err
+	}
+
+	model, err := scanShare(r.db.QueryRowContext(ctx, `
+		SELECT `+shareColumns+`
+		FROM default_schema.file_shares
+		WHERE token_hash = $1 AND tenant_uuid = $2 AND expiration_date > $3`, tokenHash, tenantID, now))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return FileShareResponse{}, ErrShareNotFound
+		}
+
+		return FileShareResponse{}, errors.New("failed to retrive share link")
+	}
+
+	return model, nil
+}
+
+// HasActiveShare reports whether a file is shared with a user and the share did not expire
+func (r *fileSharesRepository) HasActiveShare(ctx context.Context, fileUUID string, userUUID string, now time.Time) (bool, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var shared bool
+
+	err = r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1
+			FROM default_schema.file_shares
+			WHERE file_uuid = $1 AND user_uuid = $2 AND tenant_uuid = $3
+			  AND (expiration_date IS NULL OR expiration_date > $4)
+		)`, fileUUID, userUUID, tenantID, now).Scan(&shared)
+
+	if err != nil {
+		return false, errors.New("failed to check file share")
+	}
+
+	return shared, nil
+}
+
+// Delete revokes a share of a file
+func (r *fileSharesRepository) Delete(ctx context.Context, fileUUID string, shareUUID string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM default_schema.file_shares
+		WHERE share_uuid = $1 AND file_uuid = $2 AND tenant_uuid = $3`, shareUUID, fileUUID, tenantID)
+
+	if err != nil {
+		return errors.New("failed to delete file share")
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrShareNotFound
+	}
+
+	return nil
+}
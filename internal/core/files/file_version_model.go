@@ -0,0 +1,17 @@
+package files
+
+import "time"
+
+// FileVersion is a previous content of a file, kept when the file is replaced
+type FileVersion struct {
+	VersionUUID   string    `json:"version_uuid" db:"version_uuid"`             // UUID da versao (chave primaria)
+	FileUUID      string    `json:"file_uuid" db:"file_uuid"`                   // Arquivo versionado
+	VersionNumber int       `json:"version_number" db:"version_number"`         // Numero sequencial da versao
+	OriginalName  string    `json:"original_file_name" db:"original_file_name"` // Nome original do arquivo nesta versao
+	Key           string    `json:"-" db:"file_key"`                            // Chave do conteudo no storage
+	Type          string    `json:"file_type" db:"file_type"`                   // Tipo do arquivo nesta versao
+	Checksum      string    `json:"checksum,omitempty" db:"checksum"`           // SHA-256 do conteudo
+	Size          int64     `json:"file_size,omitempty" db:"file_size"`         // Tamanho em bytes
+	CreatedBy     string    `json:"created_by,omitempty" db:"created_by"`       // Usuario que substituiu o conteudo
+	CreationDate  time.Time `json:"creation_date" db:"creation_date"`           // Data em que a versao foi substituida
+}
@@ -0,0 +1,165 @@
+package files
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrVersionNotFound is returned when no version of the file matches the given ID
+var ErrVersionNotFound = errors.New("file version not found")
+
+// versionColumns is the column list scanned by scanVersion
+const versionColumns = `version_uuid, file_uuid, version_number, original_file_name, file_key, file_type, checksum, file_size, created_by, creation_date`
+
+type FileVersionsRepository interface {
+	Create(ctx context.Context, file FileResponse, createdBy string) (string, error)
+	GetByFile(ctx context.Context, fileUUID string) ([]FileVersionResponse, error)
+	GetByID(ctx context.Context, fileUUID string, id string) (FileVersionResponse, error)
+	DeleteByKey(ctx context.Context, key string) error
+	WithTx(tx database.DBTX) FileVersionsRepository
+}
+
+type fileVersionsRepository struct {
+	db      database.DBTX
+	timeout time.Duration
+}
+
+func NewFileVersionsRepository(db database.DBTX, timeout time.Duration) *fileVersionsRepository {
+	return &fileVersionsRepository{db: db, timeout: timeout}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *fileVersionsRepository) WithTx(tx database.DBTX) FileVersionsRepository {
+	return &fileVersionsRepository{db: tx, timeout: r.timeout}
+}
+
+func scanVersion(row rowScanner) (FileVersionResponse, error) {
+	var model FileVersionResponse
+	var checksum, createdBy sql.NullString
+	var size sql.NullInt64
+
+	err := row.Scan(&model.VersionUUID, &model.FileUUID, &model.VersionNumber, &model.OriginalName, &model.Key, &model.Type, &checksum, &size, &createdBy, &model.CreationDate)
+	model.Checksum = checksum.String
+	model.Size = size.Int64
+	model.CreatedBy = createdBy.String
+
+	return model, err
+}
+
+// Create keeps the current content of a file as its next version. Run it in the
+// transaction replacing the content, after locking the file key
+func (r *fileVersionsRepository) Create(ctx context.Context, file FileResponse, createdBy string) (string, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var id string
+
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO default_schema.file_versions (
+		file_uuid, version_number, original_file_name, file_key, file_type, checksum, file_size, created_by, tenant_uuid
+		)
+		SELECT $1, COALESCE(MAX(version_number), 0) + 1, $2, $3, $4, NULLIF($5, ''), NULLIF($6::bigint, 0), NULLIF($7, '')::uuid, $8
+		FROM default_schema.file_versions
+		WHERE file_uuid = $1 AND tenant_uuid = $8
+		RETURNING version_uuid`, file.FileUUID, file.OriginalName, file.Key, file.Type, file.Checksum, file.Size, createdBy, tenantID).Scan(&id)
+
+	if err != nil {
+		log.Print(err)
+		return "", errors.New("failed to create file version")
+	}
+
+	return id, nil
+}
+
+// GetByFile returns the versions of a file, most recent first
+func (r *fileVersionsRepository) GetByFile(ctx context.Context, fileUUID string) ([]FileVersionResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+versionColumns+`
+		FROM default_schema.file_versions
+		WHERE file_uuid = $1 AND tenant_uuid = $2
+		ORDER BY version_number DESC`, fileUUID, tenantID)
+
+	if err != nil {
+		return nil, errors.New("failed to retrive file versions")
+	}
+
+	defer rows.Close()
+
+	models := []FileVersionResponse{}
+	for rows.Next() {
+		model, err := scanVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		models = append(models, model)
+	}
+
+	return models, nil
+}
+
+func (r *fileVersionsRepository) GetByID(ctx context.Context, fileUUID string, id string) (FileVersionResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return FileVersionResponse{}, err
+	}
+
+	model, err := scanVersion(r.db.QueryRowContext(ctx, `
+		SELECT `+versionColumns+`
+		FROM default_schema.file_versions
+		WHERE version_uuid = $1 AND file_uuid = $2 AND tenant_uuid = $3`, id, fileUUID, tenantID))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return FileVersionResponse{}, ErrVersionNotFound
+		}
+
+		return FileVersionResponse{}, errors.New("failed to retrive file version")
+	}
+
+	return model, nil
+}
+
+// DeleteByKey removes every version referencing the stored object of key
+func (r *fileVersionsRepository) DeleteByKey(ctx context.Context, key string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		DELETE FROM default_schema.file_versions
+		WHERE file_key = $1 AND tenant_uuid = $2`, key, tenantID)
+
+	if err != nil {
+		log.Print(err)
+		return errors.New("failed to delete file versions")
+	}
+
+	return nil
+}
@@ -2,13 +2,37 @@ package files
 
 import (
 	"bernardtm/backend/internal/core/shareds"
+	"bernardtm/backend/pkg/providers/storages"
+	"errors"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 type FilesController interface {
+	GetAll(ctx *gin.Context)
+	GetByID(ctx *gin.Context)
+	Paginate(ctx *gin.Context)
 	Create(ctx *gin.Context)
+	Download(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+	CreateUploadURL(ctx *gin.Context)
+	CompleteUpload(ctx *gin.Context)
+	GetDownloadURL(ctx *gin.Context)
+	GetText(ctx *gin.Context)
+	Share(ctx *gin.Context)
+	CreateShareLink(ctx *gin.Context)
+	GetShares(ctx *gin.Context)
+	RevokeShare(ctx *gin.Context)
+	GetAccessHistory(ctx *gin.Context)
+	DownloadShared(ctx *gin.Context)
+	UpdateContent(ctx *gin.Context)
+	GetVersions(ctx *gin.Context)
+	DownloadVersion(ctx *gin.Context)
+	AssignOwner(ctx *gin.Context)
 }
 
 type filesController struct {
@@ -21,6 +45,112 @@ func NewFilesController(service FilesService) *filesController {
 	}
 }
 
+// WriteUploadPolicyError answers the structured 4xx error of a file refused by an
+// upload policy. It reports false when err is not a policy violation
+func WriteUploadPolicyError(c *gin.Context, err error) bool {
+	var policyErr *UploadPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	details := map[string]interface{}{"reason": policyErr.Reason}
+	for key, value := range policyErr.Details {
+		details[key] = value
+	}
+
+	c.JSON(policyErr.Status, shareds.ApiError{
+		Status:  "error",
+		Message: policyErr.Message,
+		Errors:  details,
+		Code:    policyErr.Status,
+	})
+	return true
+}
+
+// writeFileAccessError answers the 404 of a file the user cannot see and the 403 of
+// an operation reserved to the owner. It reports false for any other error
+func writeFileAccessError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, ErrFileNotFound):
+		c.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "File not found"})
+	case errors.Is(err, ErrFileAccessDenied):
+		c.JSON(http.StatusForbidden, shareds.ErrorResponse{Message: "Only the owner of the file can do this"})
+	default:
+		return false
+	}
+	return true
+}
+
+// GetAll Get all files
+// @Summary Get all files
+// @Description Returns the files of the user and the files shared with it
+// @Tags Files
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} FileResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files [get]
+func (uc *filesController) GetAll(c *gin.Context) {
+	files, err := uc.service.GetAll(c.Request.Context(), c.GetString("ID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching files"})
+		return
+	}
+	c.JSON(http.StatusOK, files)
+}
+
+// GetByID gets the metadata of a file by ID
+// @Summary Get file by ID
+// @Tags Files
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID of the file"
+// @Success 200 {object} FileResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/{id} [get]
+func (uc *filesController) GetByID(c *gin.Context) {
+	file, err := uc.service.GetByID(c.Request.Context(), c.GetString("ID"), c.Param("id"))
+	if writeFileAccessError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching file"})
+		return
+	}
+	c.JSON(http.StatusOK, file)
+}
+
+// Paginate paginate files
+// @Summary Paginate files
+// @Description Paginates the files of the user and the files shared with it
+// @Tags Files
+// @Produce json
+// @Security BearerAuth
+// @Param page query int true "Page Number"
+// @Param size query int true "Page Size"
+// @Success 200 {array} FileResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/paginate [get]
+func (uc *filesController) Paginate(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("size", "5"))
+	if err != nil || limit <= 0 || limit > 50 {
+		limit = 10
+	}
+
+	files, err := uc.service.Paginate(c.Request.Context(), c.GetString("ID"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching paginated files"})
+		return
+	}
+	c.JSON(http.StatusOK, files)
+}
+
 // Create creates a new file
 // @Summary Create a new file
 // @Description A new file will be created and uploaded to the server
@@ -31,6 +161,9 @@ func NewFilesController(service FilesService) *filesController {
 // @Param file formData file true "The file to upload"
 // @Success 201 {object} map[string]interface{}
 // @Failure 400 {object} shareds.ErrorResponse
+// @Failure 413 {object} shareds.ApiError
+// @Failure 415 {object} shareds.ApiError
+// @Failure 422 {object} shareds.ApiError
 // @Failure 500 {object} shareds.ErrorResponse
 // @Router /files [post]
 func (uc *filesController) Create(c *gin.Context) {
@@ -54,13 +187,545 @@ func (uc *filesController) Create(c *gin.Context) {
 		},
 	}
 
-	createdId, link, err := uc.service.Create(input, fileStream)
+	createdId, err := uc.service.Create(c.Request.Context(), c.GetString("ID"), input, fileStream)
 
+	if WriteUploadPolicyError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error uploading file"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"id": createdId, "link": link, "message": "File uploaded successfully"})
+	c.JSON(http.StatusCreated, gin.H{"id": createdId, "message": "File uploaded successfully"})
+
+}
+
+// Download streams the content of a file
+// @Summary Download file by ID
+// @Tags Files
+// @Produce octet-stream
+// @Security BearerAuth
+// @Param id path string true "ID of the file"
+// @Success 200 {file} file
+// @Header 200 {string} X-Checksum-SHA256 "SHA-256 of the content"
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 409 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/{id}/download [get]
+func (uc *filesController) Download(c *gin.Context) {
+	file, object, err := uc.service.Download(c.Request.Context(), c.GetString("ID"), c.Param("id"))
+	serveFile(c, file, object, err)
+}
+
+// writeFileNotReady answers the 409 of a file whose content is requested before it
+// is processed
+func writeFileNotReady(c *gin.Context) {
+	c.JSON(http.StatusConflict, shareds.ErrorResponse{Message: "File is not available until its processing, malware scan included, succeeds"})
+}
+
+// serveFile streams the object of a file opened by the service, or answers the
+// error opening it
+func serveFile(c *gin.Context, file FileResponse, object *storages.Object, err error) {
+	if errors.Is(err, ErrFileNotFound) || errors.Is(err, storages.ErrObjectNotFound) {
+		c.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "File not found"})
+		return
+	}
+	if errors.Is(err, ErrFileNotReady) {
+		writeFileNotReady(c)
+		return
+	}
+	if errors.Is(err, ErrChecksumMismatch) {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "File failed the integrity check"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error downloading file"})
+		return
+	}
+	defer object.Body.Close()
+
+	contentType := object.ContentType
+	if contentType == "" && strings.Contains(file.Type, "/") {
+		contentType = file.Type
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension("." + file.Type)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	contentLength := object.Size
+	if contentLength <= 0 {
+		contentLength = -1
+	}
+
+	headers := map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": file.OriginalName}),
+	}
+	if file.Checksum != "" {
+		// a body failing the checksum is cut short, so the client sees a truncated download
+		headers["X-Checksum-SHA256"] = file.Checksum
+	}
+
+	c.DataFromReader(http.StatusOK, contentLength, contentType, object.Body, headers)
+}
+
+// Delete deletes a file and its stored content
+// @Summary Delete file by ID
+// @Tags Files
+// @Security BearerAuth
+// @Param id path string true "ID of the file"
+// @Success 204
+// @Failure 403 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/{id} [delete]
+func (uc *filesController) Delete(c *gin.Context) {
+	err := uc.service.Delete(c.Request.Context(), c.GetString("ID"), c.Param("id"))
+	if writeFileAccessError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error deleting file"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// CreateUploadURL registers a pending file and returns a pre-signed upload URL
+// @Summary Create a pre-signed upload URL
+// @Description Registers a pending file and returns a URL the file must be uploaded to with the returned method and headers. Call the completion endpoint once the upload finishes
+// @Tags Files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param input body UploadURLRequest true "File Data"
+// @Success 201 {object} UploadURLResponse
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 415 {object} shareds.ApiError
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/upload-url [post]
+func (uc *filesController) CreateUploadURL(c *gin.Context) {
+	var input UploadURLRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid input data"})
+		return
+	}
+
+	response, err := uc.service.CreateUploadURL(c.Request.Context(), c.GetString("ID"), input)
+	if WriteUploadPolicyError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error creating upload URL"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// CompleteUpload marks a pre-signed upload as finished
+// @Summary Complete a pre-signed upload
+// @Description Checks that the object was uploaded and follows the upload policy, then marks the file as Uploaded. A refused object is removed and the file marked as Rejected
+// @Tags Files
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID of the file"
+// @Success 200 {object} FileResponse
+// @Failure 403 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 409 {object} shareds.ErrorResponse
+// @Failure 413 {object} shareds.ApiError
+// @Failure 415 {object} shareds.ApiError
+// @Failure 422 {object} shareds.ApiError
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/{id}/complete [post]
+func (uc *filesController) CompleteUpload(c *gin.Context) {
+	file, err := uc.service.CompleteUpload(c.Request.Context(), c.GetString("ID"), c.Param("id"))
+	if WriteUploadPolicyError(c, err) || writeFileAccessError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, ErrUploadNotFound):
+		c.JSON(http.StatusConflict, shareds.ErrorResponse{Message: "File was not uploaded yet"})
+		return
+	case errors.Is(err, ErrUploadNotPending):
+		c.JSON(http.StatusConflict, shareds.ErrorResponse{Message: "File upload is already completed"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error completing upload"})
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+// GetDownloadURL returns a short-lived pre-signed download URL
+// @Summary Get a pre-signed download URL
+// @Tags Files
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID of the file"
+// @Success 200 {object} storages.PresignedURL
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 409 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/{id}/url [get]
+func (uc *filesController) GetDownloadURL(c *gin.Context) {
+	url, err := uc.service.GetDownloadURL(c.Request.Context(), c.GetString("ID"), c.Param("id"))
+	if writeFileAccessError(c, err) {
+		return
+	}
+	if errors.Is(err, ErrFileNotReady) {
+		writeFileNotReady(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error creating download URL"})
+		return
+	}
+
+	c.JSON(http.StatusOK, url)
+}
+
+// GetText returns the text extracted from a file
+// @Summary Get the text of a file
+// @Description Returns the text extracted while processing the file, empty when the file is not processed yet or its type has no text
+// @Tags Files
+// @Produce plain
+// @Security BearerAuth
+// @Param id path string true "ID of the file"
+// @Success 200 {string} string
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/{id}/text [get]
+func (uc *filesController) GetText(c *gin.Context) {
+	text, err := uc.service.GetText(c.Request.Context(), c.GetString("ID"), c.Param("id"))
+	if writeFileAccessError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching file text"})
+		return
+	}
+
+	c.String(http.StatusOK, text)
+}
+
+// Share shares a file with another user
+// @Summary Share a file with a user
+// @Description Grants another user of the tenant read access to the file until the optional expiration. Sharing again with the same user replaces the expiration
+// @Tags Files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID of the file"
+// @Param input body FileShareRequest true "Share Data"
+// @Success 201 {object} FileShareResponse
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 403 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/{id}/shares [post]
+func (uc *filesController) Share(c *gin.Context) {
+	var input FileShareRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid input data"})
+		return
+	}
+
+	share, err := uc.service.ShareWithUser(c.Request.Context(), c.GetString("ID"), c.Param("id"), input)
+	if writeFileAccessError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, ErrShareUserNotFound):
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "User not found"})
+		return
+	case errors.Is(err, ErrShareWithOwner):
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "File cannot be shared with its owner"})
+		return
+	case errors.Is(err, ErrInvalidShareExpiration):
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Expiration date must be in the future"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error sharing file"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, share)
+}
+
+// CreateShareLink creates an expiring share link
+// @Summary Create a share link
+// @Description Creates a link anyone can download the file with until it expires. The token is only returned once
+// @Tags Files
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID of the file"
+// @Param input body ShareLinkRequest false "Share Link Data"
+// @Success 201 {object} ShareLinkResponse
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 403 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/{id}/share-links [post]
+func (uc *filesController) CreateShareLink(c *gin.Context) {
+	var input ShareLinkRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid input data"})
+			return
+		}
+	}
+
+	link, err := uc.service.CreateShareLink(c.Request.Context(), c.GetString("ID"), c.Param("id"), input)
+	if writeFileAccessError(c, err) {
+		return
+	}
+	if errors.Is(err, ErrInvalidShareExpiration) {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Expiration date must be in the future and within the maximum share link lifetime"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error creating share link"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, link)
+}
+
+// GetShares lists the shares of a file
+// @Summary Get the shares of a file
+// @Tags Files
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID of the file"
+// @Success 200 {array} FileShareResponse
+// @Failure 403 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/{id}/shares [get]
+func (uc *filesController) GetShares(c *gin.Context) {
+	shares, err := uc.service.GetShares(c.Request.Context(), c.GetString("ID"), c.Param("id"))
+	if writeFileAccessError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching file shares"})
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+// RevokeShare revokes a share of a file
+// @Summary Revoke a share
+// @Description Removes a user share or a share link of the file
+// @Tags Files
+// @Security BearerAuth
+// @Param id path string true "ID of the file"
+// @Param shareId path string true "ID of the share"
+// @Success 204
+// @Failure 403 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/{id}/shares/{shareId} [delete]
+func (uc *filesController) RevokeShare(c *gin.Context) {
+	err := uc.service.RevokeShare(c.Request.Context(), c.GetString("ID"), c.Param("id"), c.Param("shareId"))
+	if writeFileAccessError(c, err) {
+		return
+	}
+	if errors.Is(err, ErrShareNotFound) {
+		c.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Share not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error revoking share"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// GetAccessHistory lists the accesses to a file
+// @Summary Get the access history of a file
+// @Description Returns when the file was shared and downloaded, and by whom, most recent first
+// @Tags Files
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID of the file"
+// @Success 200 {array} FileAccessResponse
+// @Failure 403 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/{id}/history [get]
+func (uc *filesController) GetAccessHistory(c *gin.Context) {
+	history, err := uc.service.GetAccessHistory(c.Request.Context(), c.GetString("ID"), c.Param("id"))
+	if writeFileAccessError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching file access history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// DownloadShared streams the file of a share link
+// @Summary Download a shared file
+// @Description Downloads the file of a share link without authentication
+// @Tags Files
+// @Produce octet-stream
+// @Param token path string true "Token of the share link"
+// @Success 200 {file} file
+// @Header 200 {string} X-Checksum-SHA256 "SHA-256 of the content"
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 409 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /shared/{token} [get]
+func (uc *filesController) DownloadShared(c *gin.Context) {
+	file, object, err := uc.service.DownloadShared(c.Request.Context(), c.Param("token"))
+	if errors.Is(err, ErrShareNotFound) {
+		c.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Share link not found or expired"})
+		return
+	}
+	serveFile(c, file, object, err)
+}
+
+// UpdateContent replaces the content of a file
+// @Summary Replace the content of a file
+// @Description Uploads a new content for the file, keeping its ID and shares. With versioning enabled the previous content stays available as a version
+// @Tags Files
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID of the file"
+// @Param file formData file true "The new content"
+// @Success 200 {object} FileResponse
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 403 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 413 {object} shareds.ApiError
+// @Failure 415 {object} shareds.ApiError
+// @Failure 422 {object} shareds.ApiError
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/{id}/content [put]
+func (uc *filesController) UpdateContent(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "File is required"})
+		return
+	}
+
+	fileStream, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Failed to open file"})
+		return
+	}
+	defer fileStream.Close()
+
+	updated, err := uc.service.UpdateContent(c.Request.Context(), c.GetString("ID"), c.Param("id"), file.Filename, fileStream)
+	if writeFileAccessError(c, err) || WriteUploadPolicyError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error updating file content"})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// GetVersions lists the previous contents of a file
+// @Summary Get the versions of a file
+// @Description Returns the previous contents of the file, most recent first
+// @Tags Files
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID of the file"
+// @Success 200 {array} FileVersionResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/{id}/versions [get]
+func (uc *filesController) GetVersions(c *gin.Context) {
+	versions, err := uc.service.GetVersions(c.Request.Context(), c.GetString("ID"), c.Param("id"))
+	if writeFileAccessError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching file versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+// DownloadVersion streams a previous content of a file
+// @Summary Download a version of a file
+// @Tags Files
+// @Produce octet-stream
+// @Security BearerAuth
+// @Param id path string true "ID of the file"
+// @Param versionId path string true "ID of the version"
+// @Success 200 {file} file
+// @Header 200 {string} X-Checksum-SHA256 "SHA-256 of the content"
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 409 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/{id}/versions/{versionId}/download [get]
+func (uc *filesController) DownloadVersion(c *gin.Context) {
+	file, object, err := uc.service.DownloadVersion(c.Request.Context(), c.GetString("ID"), c.Param("id"), c.Param("versionId"))
+	if errors.Is(err, ErrVersionNotFound) {
+		c.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "File version not found"})
+		return
+	}
+	serveFile(c, file, object, err)
+}
+
+// AssignOwner gives an owner to a file uploaded before owners were recorded
+// @Summary Assign the owner of a file
+// @Description Files without owner are hidden from the users until an admin assigns them to a user of their tenant
+// @Tags Files
+// @Accept json
+// @Produce json
+// @Param X-Admin-Key header string true "Admin key"
+// @Param X-Tenant-ID header string true "Tenant of the file"
+// @Param id path string true "ID of the file"
+// @Param input body FileOwnerRequest true "Owner"
+// @Success 200 {object} FileResponse
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 409 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/{id}/owner [put]
+func (uc *filesController) AssignOwner(c *gin.Context) {
+	var input FileOwnerRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid input data"})
+		return
+	}
+
+	file, err := uc.service.AssignOwner(c.Request.Context(), c.Param("id"), input.OwnerUUID)
+	switch {
+	case errors.Is(err, ErrFileNotFound):
+		c.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "File not found"})
+		return
+	case errors.Is(err, ErrOwnerNotFound):
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "User not found"})
+		return
+	case errors.Is(err, ErrFileHasOwner):
+		c.JSON(http.StatusConflict, shareds.ErrorResponse{Message: "File already has an owner"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error assigning file owner"})
+		return
+	}
 
+	c.JSON(http.StatusOK, file)
 }
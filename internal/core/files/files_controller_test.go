@@ -0,0 +1,102 @@
+package files
+
+import (
+	"bernardtm/backend/pkg/providers/storages"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockFilesService is a mock implementation of the FilesService interface
+type MockFilesService struct {
+	FilesService
+	DownloadFunc func(id string) (FileResponse, *storages.Object, error)
+	DeleteFunc   func(id string) error
+}
+
+func (m *MockFilesService) Download(ctx context.Context, userID string, id string) (FileResponse, *storages.Object, error) {
+	return m.DownloadFunc(id)
+}
+
+func (m *MockFilesService) Delete(ctx context.Context, userID string, id string) error {
+	return m.DeleteFunc(id)
+}
+
+func newFilesRouter(service FilesService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	controller := NewFilesController(service)
+	router := gin.New()
+	router.GET("/files/:id/download", controller.Download)
+	router.DELETE("/files/:id", controller.Delete)
+	return router
+}
+
+type trackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestFilesController_Download(t *testing.T) {
+	body := &trackingBody{Reader: strings.NewReader("%PDF-1.7")}
+	service := &MockFilesService{
+		DownloadFunc: func(id string) (FileResponse, *storages.Object, error) {
+			assert.Equal(t, "file-1", id)
+			return FileResponse{FileUUID: id, OriginalName: "relatório final.pdf", Type: "pdf"},
+				&storages.Object{ObjectInfo: storages.ObjectInfo{Size: 8}, Body: body}, nil
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	newFilesRouter(service).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/files/file-1/download", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "%PDF-1.7", rec.Body.String())
+	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "8", rec.Header().Get("Content-Length"))
+	assert.Equal(t, "attachment; filename*=utf-8''relat%C3%B3rio%20final.pdf", rec.Header().Get("Content-Disposition"))
+	assert.True(t, body.closed, "the object body must be closed")
+}
+
+func TestFilesController_NotFound(t *testing.T) {
+	service := &MockFilesService{
+		DownloadFunc: func(id string) (FileResponse, *storages.Object, error) {
+			return FileResponse{}, nil, ErrFileNotFound
+		},
+		DeleteFunc: func(id string) error {
+			return ErrFileNotFound
+		},
+	}
+	router := newFilesRouter(service)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/files/missing/download", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/files/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestFilesController_OwnerOnly(t *testing.T) {
+	service := &MockFilesService{
+		DeleteFunc: func(id string) error {
+			return ErrFileAccessDenied
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	newFilesRouter(service).ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/files/file-1", nil))
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
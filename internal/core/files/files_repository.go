@@ -1,35 +1,109 @@
 package files
 
 import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/utils"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+// ErrFileNotFound is returned when no file matches the given ID or link
+var ErrFileNotFound = errors.New("file not found")
+
+// ErrOwnerNotFound is returned when assigning a file to a user outside the tenant
+var ErrOwnerNotFound = errors.New("file owner not found")
+
+// fileColumns is the column list scanned by scanFile
+const fileColumns = `file_uuid, file_name, original_file_name, file_link, file_key, file_type, file_folder, checksum, file_size, thumbnail_link, owner_uuid, status_uuid, creation_date, modification_date`
+
+// readableBy restricts a query on files to the ones the user of the $2 parameter may
+// read: its own files and the files shared with it and, compared to the $3 time, not
+// expired. Files without owner are left to the admins
+const readableBy = `(owner_uuid = $2 OR file_uuid IN (
+		SELECT file_uuid
+		FROM default_schema.file_shares
+		WHERE user_uuid = $2 AND (expiration_date IS NULL OR expiration_date > $3)))`
+
 type FilesRepository interface {
-	GetAll() ([]FileResponse, error)
-	GetByLink(link string) (FileResponse, error)
-	Create(data FileRequest) (string, error)
-	Update(data FileRequest) error
-	Delete(link string) error
-	Paginate(page, size int) ([]FileResponse, error)
+	GetAll(ctx context.Context, userID string) ([]FileResponse, error)
+	GetByID(ctx context.Context, id string) (FileResponse, error)
+	GetByLink(ctx context.Context, link string) (FileResponse, error)
+	Create(ctx context.Context, data FileRequest) (string, error)
+	Update(ctx context.Context, data FileRequest) error
+	UpdateStatus(ctx context.Context, id string, statusUUID string) error
+	UpdateOwner(ctx context.Context, id string, ownerUUID string) error
+	UpdateProcessed(ctx context.Context, id string, statusUUID string, text string, thumbnailLink string) error
+	UpdateStatusByKey(ctx context.Context, key string, statusUUID string) error
+	GetText(ctx context.Context, id string) (string, error)
+	Delete(ctx context.Context, id string) error
+	LockKey(ctx context.Context, key string) error
+	CountByKey(ctx context.Context, key string) (int, error)
+	ReplaceContent(ctx context.Context, data File) error
+	ReferencedKeys(ctx context.Context, keys []string) ([]string, error)
+	GetExpired(ctx context.Context, folder string, before time.Time, limit int) ([]FileResponse, error)
+	Paginate(ctx context.Context, userID string, page, size int) ([]FileResponse, error)
+	WithTx(tx database.DBTX) FilesRepository
 }
 
 type filesRepository struct {
-	db *sql.DB
+	db      database.DBTX
+	timeout time.Duration
+}
+
+func NewFilesRepository(db database.DBTX, timeout time.Duration) *filesRepository {
+	return &filesRepository{db: db, timeout: timeout}
 }
 
-func NewFilesRepository(db *sql.DB) *filesRepository {
-	return &filesRepository{
-		db: db,
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *filesRepository) WithTx(tx database.DBTX) FilesRepository {
+	return &filesRepository{db: tx, timeout: r.timeout}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanFile(row rowScanner) (FileResponse, error) {
+	var model FileResponse
+	var originalName, checksum, thumbnailLink, ownerUUID sql.NullString
+	var size sql.NullInt64
+
+	err := row.Scan(&model.FileUUID, &model.Name, &originalName, &model.Link, &model.Key, &model.Type, &model.Folder, &checksum, &size, &thumbnailLink, &ownerUUID, &model.StatusUUID, &model.CreationDate, &model.ModificationDate)
+	model.Checksum = checksum.String
+	model.ThumbnailLink = thumbnailLink.String
+	model.OwnerUUID = ownerUUID.String
+	model.Size = size.Int64
+	model.OriginalName = originalName.String
+	if model.OriginalName == "" {
+		model.OriginalName = model.Name
 	}
+
+	return model, err
 }
 
-func (r *filesRepository) GetAll() ([]FileResponse, error) {
+// GetAll returns the files the user may read
+func (r *filesRepository) GetAll(ctx context.Context, userID string) ([]FileResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var models []FileResponse
 
-	rows, err := r.db.Query("SELECT * FROM default_schema.files")
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+fileColumns+`
+		FROM default_schema.files
+		WHERE tenant_uuid = $1 AND `+readableBy+`
+		ORDER BY creation_date DESC, file_uuid DESC`, tenantID, userID, time.Now())
 
 	if err != nil {
 		return nil, errors.New("failed to retrive all files")
@@ -38,9 +112,8 @@ func (r *filesRepository) GetAll() ([]FileResponse, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var model FileResponse
-
-		if err := rows.Scan(&model.FileUUID, &model.Name, &model.Link, &model.Type, &model.Folder, &model.StatusUUID, &model.CreationDate, &model.ModificationDate); err != nil {
+		model, err := scanFile(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
@@ -50,14 +123,48 @@ func (r *filesRepository) GetAll() ([]FileResponse, error) {
 	return models, nil
 }
 
-func (r *filesRepository) GetByLink(link string) (FileResponse, error) {
-	var model FileResponse
+func (r *filesRepository) GetByID(ctx context.Context, id string) (FileResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return FileResponse{}, err
+	}
+
+	model, err := scanFile(r.db.QueryRowContext(ctx, `
+		SELECT `+fileColumns+`
+		FROM default_schema.files
+		WHERE file_uuid = $1 AND tenant_uuid = $2`, id, tenantID))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return FileResponse{}, ErrFileNotFound
+		}
 
-	err := r.db.QueryRow("SELECT * FROM default_schema.files", link).Scan(&model.FileUUID, &model.Name, &model.Type, &model.Link, &model.Folder, &model.StatusUUID, &model.CreationDate, &model.ModificationDate)
+		return FileResponse{}, errors.New("failed to retrive file")
+	}
+
+	return model, nil
+}
+
+func (r *filesRepository) GetByLink(ctx context.Context, link string) (FileResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return FileResponse{}, err
+	}
+
+	model, err := scanFile(r.db.QueryRowContext(ctx, `
+		SELECT `+fileColumns+`
+		FROM default_schema.files
+		WHERE file_link = $1 AND tenant_uuid = $2`, link, tenantID))
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return FileResponse{}, errors.New("file not found")
+			return FileResponse{}, ErrFileNotFound
 		}
 
 		return FileResponse{}, errors.New("failed to retrive file")
@@ -66,15 +173,23 @@ func (r *filesRepository) GetByLink(link string) (FileResponse, error) {
 	return model, nil
 }
 
-func (r *filesRepository) Create(data FileRequest) (string, error) {
+func (r *filesRepository) Create(ctx context.Context, data FileRequest) (string, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return "", err
+	}
+
 	var id string
 
 	query := `INSERT INTO default_schema.files (
-		file_name, file_link, file_folder, file_type, status_uuid
-		) VALUES ($1, $2, $3, $4, $5)
+		file_name, original_file_name, file_link, file_key, file_folder, file_type, checksum, file_size, owner_uuid, status_uuid, tenant_uuid
+		) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8::bigint, 0), NULLIF($11, '')::uuid, $9, $10)
 		RETURNING file_uuid`
 
-	err := r.db.QueryRow(query, data.Name, data.Link, data.Folder, data.Type, data.StatusUUID).Scan(&id)
+	err = r.db.QueryRowContext(ctx, query, data.Name, data.OriginalName, data.Link, data.Key, data.Folder, data.Type, data.Checksum, data.Size, data.StatusUUID, tenantID, data.OwnerUUID).Scan(&id)
 
 	if err != nil {
 		log.Print(err)
@@ -84,16 +199,26 @@ func (r *filesRepository) Create(data FileRequest) (string, error) {
 	return id, nil
 }
 
-func (r *filesRepository) Update(data FileRequest) error {
-	_, err := r.db.Exec(`
+func (r *filesRepository) Update(ctx context.Context, data FileRequest) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
 		UPDATE default_schema.files
-		SET file_name = $1,
-		    file_link = $2,
-		    file_folder = $3,
-		    file_type = $4,
-		    status_uuid = $5
+		SET file_name = $2,
+		    file_link = $3,
+		    file_folder = $4,
+		    file_type = $5,
+		    status_uuid = $6,
+		    checksum = COALESCE(NULLIF($8, ''), checksum),
+		    file_size = COALESCE(NULLIF($9::bigint, 0), file_size),
 		    modification_date = CURRENT_DATE
-		    WHERE file_uuid = $1`, data.FileUUID, data.Name, data.Link, data.Folder, data.Type, data.StatusUUID)
+		    WHERE file_uuid = $1 AND tenant_uuid = $7`, data.FileUUID, data.Name, data.Link, data.Folder, data.Type, data.StatusUUID, tenantID, data.Checksum, data.Size)
 
 	if err != nil {
 		return errors.New("failed to update file")
@@ -102,22 +227,332 @@ func (r *filesRepository) Update(data FileRequest) error {
 	return nil
 }
 
-func (r *filesRepository) Delete(link string) error {
-	_, err := r.db.Exec(`DELETE FROM default_schema.files WHERE file_link = $1`, link)
+func (r *filesRepository) UpdateStatus(ctx context.Context, id string, statusUUID string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE default_schema.files
+		SET status_uuid = $2,
+		    modification_date = CURRENT_DATE
+		WHERE file_uuid = $1 AND tenant_uuid = $3`, id, statusUUID, tenantID)
+
+	if err != nil {
+		return errors.New("failed to update file status")
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrFileNotFound
+	}
+
+	return nil
+}
+
+// UpdateOwner gives an owner of the tenant to a file without one
+func (r *filesRepository) UpdateOwner(ctx context.Context, id string, ownerUUID string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE default_schema.files
+		SET owner_uuid = $2,
+		    modification_date = CURRENT_DATE
+		WHERE file_uuid = $1 AND owner_uuid IS NULL AND tenant_uuid = $3
+		  AND EXISTS (SELECT 1 FROM default_schema.users WHERE user_uuid = $2 AND tenant_uuid = $3)`, id, ownerUUID, tenantID)
+
+	if err != nil {
+		return errors.New("failed to update file owner")
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrOwnerNotFound
+	}
+
+	return nil
+}
+
+// UpdateProcessed stores the outcome of the processing of a file
+func (r *filesRepository) UpdateProcessed(ctx context.Context, id string, statusUUID string, text string, thumbnailLink string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE default_schema.files
+		SET status_uuid = $2,
+		    extracted_text = NULLIF($3, ''),
+		    thumbnail_link = NULLIF($4, ''),
+		    modification_date = CURRENT_DATE
+		WHERE file_uuid = $1 AND tenant_uuid = $5`, id, statusUUID, text, thumbnailLink, tenantID)
+
+	if err != nil {
+		return errors.New("failed to update processed file")
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrFileNotFound
+	}
+
+	return nil
+}
+
+// UpdateStatusByKey sets the status of every file referencing the stored object of key
+func (r *filesRepository) UpdateStatusByKey(ctx context.Context, key string, statusUUID string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		UPDATE default_schema.files
+		SET status_uuid = $2,
+		    thumbnail_link = NULL,
+		    modification_date = CURRENT_DATE
+		WHERE file_key = $1 AND tenant_uuid = $3`, key, statusUUID, tenantID)
+
+	if err != nil {
+		return errors.New("failed to update file status")
+	}
+
+	return nil
+}
+
+// GetText returns the text extracted from a file, empty when none was extracted
+func (r *filesRepository) GetText(ctx context.Context, id string) (string, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var text sql.NullString
+
+	err = r.db.QueryRowContext(ctx, `
+		SELECT extracted_text
+		FROM default_schema.files
+		WHERE file_uuid = $1 AND tenant_uuid = $2`, id, tenantID).Scan(&text)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrFileNotFound
+		}
+
+		return "", errors.New("failed to retrive file text")
+	}
+
+	return text.String, nil
+}
+
+func (r *filesRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM default_schema.files WHERE file_uuid = $1 AND tenant_uuid = $2`, id, tenantID)
 
 	if err != nil {
 		return errors.New("failed to delete file")
 	}
 
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrFileNotFound
+	}
+
+	return nil
+}
+
+// LockKey serializes, until the end of the transaction, the operations adding or
+// removing references to the stored object of key. It must run inside a transaction
+func (r *filesRepository) LockKey(ctx context.Context, key string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, key); err != nil {
+		return errors.New("failed to lock file key")
+	}
+
 	return nil
 }
 
-func (r *filesRepository) Paginate(page, size int) ([]FileResponse, error) {
+// CountByKey returns the number of files and file versions referencing the stored object of key
+func (r *filesRepository) CountByKey(ctx context.Context, key string) (int, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+
+	err = r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) + (
+			SELECT COUNT(*)
+			FROM default_schema.file_versions
+			WHERE file_key = $1 AND tenant_uuid = $2)
+		FROM default_schema.files
+		WHERE file_key = $1 AND tenant_uuid = $2`, key, tenantID).Scan(&count)
+
+	if err != nil {
+		return 0, errors.New("failed to count file references")
+	}
+
+	return count, nil
+}
+
+// ReplaceContent points a file to new content, resetting the results of its
+// processing. The file is renamed after the original name of the new content
+func (r *filesRepository) ReplaceContent(ctx context.Context, data File) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE default_schema.files
+		SET file_name = $2,
+		    original_file_name = $3,
+		    file_link = $4,
+		    file_key = $5,
+		    file_folder = $6,
+		    file_type = $7,
+		    checksum = NULLIF($8, ''),
+		    file_size = NULLIF($9::bigint, 0),
+		    status_uuid = $10,
+		    thumbnail_link = NULL,
+		    extracted_text = NULL,
+		    modification_date = CURRENT_DATE
+		WHERE file_uuid = $1 AND tenant_uuid = $11`, data.FileUUID, data.Name, data.OriginalName, data.Link, data.Key, data.Folder, data.Type, data.Checksum, data.Size, data.StatusUUID, tenantID)
+
+	if err != nil {
+		log.Print(err)
+		return errors.New("failed to replace file content")
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrFileNotFound
+	}
+
+	return nil
+}
+
+// ReferencedKeys returns which of the keys are referenced by a file or a file version of the tenant
+func (r *filesRepository) ReferencedKeys(ctx context.Context, keys []string) ([]string, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT file_key FROM default_schema.files WHERE file_key = ANY($1) AND tenant_uuid = $2
+		UNION
+		SELECT file_key FROM default_schema.file_versions WHERE file_key = ANY($1) AND tenant_uuid = $2`, pq.Array(keys), tenantID)
+
+	if err != nil {
+		return nil, errors.New("failed to retrive referenced keys")
+	}
+
+	defer rows.Close()
+
+	var referenced []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		referenced = append(referenced, key)
+	}
+
+	return referenced, rows.Err()
+}
+
+// GetExpired returns up to limit files of the folder created before the given time, oldest first
+func (r *filesRepository) GetExpired(ctx context.Context, folder string, before time.Time, limit int) ([]FileResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+fileColumns+`
+		FROM default_schema.files
+		WHERE file_folder = $1 AND creation_date < $2 AND tenant_uuid = $3
+		ORDER BY creation_date, file_uuid
+		LIMIT $4`, folder, before, tenantID, limit)
+
+	if err != nil {
+		return nil, errors.New("failed to retrive expired files")
+	}
+
+	defer rows.Close()
+
+	models := []FileResponse{}
+	for rows.Next() {
+		model, err := scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		models = append(models, model)
+	}
+
+	return models, nil
+}
+
+// Paginate returns a page of the files the user may read
+func (r *filesRepository) Paginate(ctx context.Context, userID string, page, size int) ([]FileResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var models []FileResponse
 
 	offset := (page - 1) * size
 
-	rows, err := r.db.Query(`SELECT * FROM default_schema.files LIMIT $1 OFFSET $2`, size, offset)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+fileColumns+`
+		FROM default_schema.files
+		WHERE tenant_uuid = $1 AND `+readableBy+`
+		ORDER BY creation_date DESC, file_uuid DESC
+		LIMIT $4 OFFSET $5`, tenantID, userID, time.Now(), size, offset)
 
 	if err != nil {
 		return nil, errors.New("failed to retrive all files")
@@ -126,9 +561,8 @@ func (r *filesRepository) Paginate(page, size int) ([]FileResponse, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var model FileResponse
-
-		if err := rows.Scan(&model.FileUUID, &model.Name, &model.Type, &model.Link, &model.StatusUUID, &model.CreationDate, &model.ModificationDate); err != nil {
+		model, err := scanFile(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
@@ -1,89 +1,511 @@
 package files
 
 import (
+	"bernardtm/backend/configs"
 	"bernardtm/backend/internal/core/status"
 	"bernardtm/backend/internal/core/storage"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/infra/socket"
 	"bernardtm/backend/pkg/providers/storages"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
-	"strconv"
+	"path"
 	"time"
 )
 
+// ErrUploadNotFound is returned when an upload is completed before its object reaches the storage
+var ErrUploadNotFound = errors.New("uploaded object not found")
+
+// ErrUploadNotPending is returned when completing the upload of a file that is not pending
+var ErrUploadNotPending = errors.New("file upload is not pending")
+
+// ErrFileNotReady is returned when the content of a file is requested before its
+// processing, the malware scan included, succeeded
+var ErrFileNotReady = errors.New("file is not processed")
+
+// ErrFileHasOwner is returned when assigning an owner to a file that already has one
+var ErrFileHasOwner = errors.New("file already has an owner")
+
+// ErrFileAccessDenied is returned when a user the file is shared with attempts an
+// operation reserved to its owner
+var ErrFileAccessDenied = errors.New("file access denied")
+
 type FilesService interface {
-	Create(data FileRequest, fileStream multipart.File) (string, string, error)
+	GetAll(ctx context.Context, userID string) ([]FileResponse, error)
+	GetByID(ctx context.Context, userID string, id string) (FileResponse, error)
+	Paginate(ctx context.Context, userID string, page, size int) ([]FileResponse, error)
+	Create(ctx context.Context, userID string, data FileRequest, fileStream multipart.File) (string, error)
+	Download(ctx context.Context, userID string, id string) (FileResponse, *storages.Object, error)
+	CreateUploadURL(ctx context.Context, userID string, data UploadURLRequest) (UploadURLResponse, error)
+	CompleteUpload(ctx context.Context, userID string, id string) (FileResponse, error)
+	GetDownloadURL(ctx context.Context, userID string, id string) (storages.PresignedURL, error)
+	GetText(ctx context.Context, userID string, id string) (string, error)
+	Delete(ctx context.Context, userID string, id string) error
+	UpdateContent(ctx context.Context, userID string, id string, name string, fileStream multipart.File) (FileResponse, error)
+	GetVersions(ctx context.Context, userID string, id string) ([]FileVersionResponse, error)
+	DownloadVersion(ctx context.Context, userID string, id string, versionID string) (FileResponse, *storages.Object, error)
+	ShareWithUser(ctx context.Context, userID string, id string, data FileShareRequest) (FileShareResponse, error)
+	CreateShareLink(ctx context.Context, userID string, id string, data ShareLinkRequest) (ShareLinkResponse, error)
+	GetShares(ctx context.Context, userID string, id string) ([]FileShareResponse, error)
+	RevokeShare(ctx context.Context, userID string, id string, shareID string) error
+	GetAccessHistory(ctx context.Context, userID string, id string) ([]FileAccessResponse, error)
+	DownloadShared(ctx context.Context, token string) (FileResponse, *storages.Object, error)
+	AssignOwner(ctx context.Context, id string, ownerUUID string) (FileResponse, error)
 }
 
 type fileService struct {
 	filesRepo      FilesRepository
+	versionsRepo   FileVersionsRepository
+	sharesRepo     FileSharesRepository
+	historyRepo    FileAccessHistoryRepository
 	statusRepo     status.StatusRepository
 	storageService storage.StorageService
+	txManager      database.TxManager
+	processor      FileProcessor
+	publisher      socket.Publisher
+	config         *configs.AppConfig
+	policy         UploadPolicy
+	contents       contentStore
 }
 
-func NewFilesService(filesRepo FilesRepository, statusRepo status.StatusRepository, storageService storage.StorageService) *fileService {
+func NewFilesService(filesRepo FilesRepository, versionsRepo FileVersionsRepository, sharesRepo FileSharesRepository, historyRepo FileAccessHistoryRepository, statusRepo status.StatusRepository, storageService storage.StorageService, txManager database.TxManager, processor FileProcessor, publisher socket.Publisher, config *configs.AppConfig) *fileService {
 	return &fileService{
 		filesRepo:      filesRepo,
+		versionsRepo:   versionsRepo,
+		sharesRepo:     sharesRepo,
+		historyRepo:    historyRepo,
 		statusRepo:     statusRepo,
 		storageService: storageService,
+		txManager:      txManager,
+		processor:      processor,
+		publisher:      publisher,
+		config:         config,
+		policy:         NewUploadPolicy("files", config.FileUploadPolicy),
+		contents:       newContentStore(filesRepo, storageService, config.StorageKeySecret),
+	}
+}
+
+// fileAccess is the level of access an operation needs on a file
+type fileAccess int
+
+const (
+	// readAccess is granted to the owner and to the users the file is shared with
+	readAccess fileAccess = iota
+	// manageAccess, e.g. to delete or share the file, is only granted to the owner
+	manageAccess
+)
+
+// authorize returns the file when the user has the access level on it. Files without
+// owner, uploaded before owners were recorded, are only managed by the admins through
+// AssignOwner. Files the user cannot read are reported as not found, so their
+// existence does not leak
+func (s *fileService) authorize(ctx context.Context, userID string, id string, access fileAccess) (FileResponse, error) {
+	file, err := s.filesRepo.GetByID(ctx, id)
+	if err != nil {
+		return FileResponse{}, err
+	}
+	if file.OwnerUUID != "" && file.OwnerUUID == userID {
+		return file, nil
+	}
+
+	shared, err := s.sharesRepo.HasActiveShare(ctx, file.FileUUID, userID, time.Now())
+	if err != nil {
+		return FileResponse{}, err
+	}
+	if !shared {
+		return FileResponse{}, ErrFileNotFound
+	}
+	if access == manageAccess {
+		return FileResponse{}, ErrFileAccessDenied
+	}
+	return file, nil
+}
+
+func (s *fileService) GetAll(ctx context.Context, userID string) ([]FileResponse, error) {
+	files, err := s.filesRepo.GetAll(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.presignThumbnails(ctx, files)
+}
+
+func (s *fileService) GetByID(ctx context.Context, userID string, id string) (FileResponse, error) {
+	file, err := s.authorize(ctx, userID, id, readAccess)
+	if err != nil {
+		return FileResponse{}, err
+	}
+	return s.presignThumbnail(ctx, file)
+}
+
+func (s *fileService) Paginate(ctx context.Context, userID string, page, size int) ([]FileResponse, error) {
+	files, err := s.filesRepo.Paginate(ctx, userID, page, size)
+	if err != nil {
+		return nil, err
+	}
+	return s.presignThumbnails(ctx, files)
+}
+
+// presignThumbnail replaces the stored link of the thumbnail of a file with a
+// short-lived pre-signed URL. Thumbnails are not served publicly: they show the
+// content of the file, so they are only reachable while the file is
+func (s *fileService) presignThumbnail(ctx context.Context, file FileResponse) (FileResponse, error) {
+	if file.ThumbnailLink == "" {
+		return file, nil
 	}
+
+	key := thumbnailKey(file.Key)
+	url, err := s.storageService.PresignDownload(ctx, key, path.Base(key), s.config.PresignedURLTTL)
+	if err != nil {
+		return FileResponse{}, err
+	}
+	file.ThumbnailLink = url.URL
+	return file, nil
 }
 
-func (s *fileService) Create(data FileRequest, fileStream multipart.File) (string, string, error) {
+func (s *fileService) presignThumbnails(ctx context.Context, files []FileResponse) ([]FileResponse, error) {
+	for i, file := range files {
+		var err error
+		if files[i], err = s.presignThumbnail(ctx, file); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Create stores the content of a new file and schedules its processing. Identical
+// content already stored by the tenant is not uploaded again, the new file
+// references the existing object
+func (s *fileService) Create(ctx context.Context, userID string, data FileRequest, fileStream multipart.File) (string, error) {
+	inspection, err := s.policy.InspectFile(data.File.Name, fileStream)
+	if err != nil {
+		return "", err
+	}
+
+	var fileUUID string
 
-	// Create a SHA-256 hash from the file name
-	hash := sha256.New()
-	timeString := strconv.Itoa(int(time.Now().Unix()))
-	hash.Write([]byte(timeString + data.File.Name)) // Hash the file name (or any part of the file)
-	hashString := hex.EncodeToString(hash.Sum(nil))
+	// the upload, the status lookup and the insert succeed or fail together:
+	// a failed insert removes the uploaded object instead of orphaning it
+	err = s.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		file, err := s.contents.store(ctx, uow, data.File.Name, fileStream, inspection.MIMEType)
+		if err != nil {
+			return err
+		}
 
-	// Use the hash as the file name
-	fmt.Printf("Generated hash for file name: %s\n", hashString)
+		status, err := s.getStatusByName(ctx, s.statusRepo.WithTx(uow.Tx()), "Uploaded")
+		if err != nil {
+			return err
+		}
+		file.StatusUUID = status.StatusUUID
+		file.OwnerUUID = userID
 
-	uploadDto := storages.UploadDto{
-		FileName:         hashString,
-		OriginalFileName: data.File.Name,
-		FileStream:       fileStream,
+		fileUUID, err = s.filesRepo.WithTx(uow.Tx()).Create(ctx, FileRequest{File: file})
+		if err != nil {
+			return err
+		}
+		return s.processor.Enqueue(ctx, uow, fileUUID, userID)
+	})
+	if err != nil {
+		return "", err
 	}
+	s.processor.Notify()
+	return fileUUID, nil
+}
 
-	var result storages.UploadFileOutput
-	resultInterface, err := s.storageService.Upload(uploadDto)
+// Download opens the stored object of a file and records the download in its access
+// history. The caller must close its body, whose last read fails with
+// ErrChecksumMismatch when the content does not match the checksum recorded at upload
+func (s *fileService) Download(ctx context.Context, userID string, id string) (FileResponse, *storages.Object, error) {
+	file, err := s.authorize(ctx, userID, id, readAccess)
 	if err != nil {
-		return "", "", errors.New("could not upload file")
+		return FileResponse{}, nil, err
 	}
-	result, ok := resultInterface.(storages.UploadFileOutput)
-	if !ok {
-		return "", "", errors.New("unexpected type from storage service upload")
+	if err := s.checkProcessed(ctx, file); err != nil {
+		return FileResponse{}, nil, err
 	}
 
-	status, err := s.getStatusByName("Pending")
+	object, err := s.open(ctx, file)
 	if err != nil {
-		return "", "", err
+		return FileResponse{}, nil, err
 	}
 
-	model := &FileRequest{
-		File: File{
-			Name:       result.Name,
-			Type:       result.Type,
-			Folder:     result.Folder,
-			Link:       result.Link,
-			StatusUUID: status.StatusUUID,
-		},
+	if err := s.recordAccess(ctx, s.historyRepo, file.FileUUID, userID, "", "Downloaded"); err != nil {
+		object.Body.Close()
+		return FileResponse{}, nil, err
 	}
 
-	fileUUID, err := s.filesRepo.Create(*model)
+	return file, object, nil
+}
+
+// checkProcessed refuses to serve a file until it is Processed: the content of a
+// Pending, Uploaded, Failed or Rejected file was not cleared by the malware scan
+func (s *fileService) checkProcessed(ctx context.Context, file FileResponse) error {
+	processed, err := s.getStatusByName(ctx, s.statusRepo, "Processed")
 	if err != nil {
-		return "", "", err
+		return err
 	}
-	return fileUUID, result.Link, nil
+	if file.StatusUUID != processed.StatusUUID {
+		return ErrFileNotReady
+	}
+	return nil
 }
 
-func (s *fileService) getStatusByName(name string) (status.StatusResponse, error) {
-	statusResponse, err := s.statusRepo.GetByName(name)
+// open downloads the stored object of a file, verifying it against its checksum
+func (s *fileService) open(ctx context.Context, file FileResponse) (*storages.Object, error) {
+	object, err := s.storageService.Download(ctx, file.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.Checksum != "" {
+		if file.Size > 0 && object.Size > 0 && object.Size != file.Size {
+			object.Body.Close()
+			return nil, ErrChecksumMismatch
+		}
+		object.Body = newVerifyingReader(object.Body, file.Checksum, file.Key)
+	}
+
+	return object, nil
+}
+
+// Delete removes the row of a file with its versions, and their stored objects
+// when no other file references them. The row is only removed once the objects
+// are gone, so a failed removal can be retried
+func (s *fileService) Delete(ctx context.Context, userID string, id string) error {
+	file, err := s.authorize(ctx, userID, id, manageAccess)
+	if err != nil {
+		return err
+	}
+
+	err = s.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		versions, err := s.versionsRepo.WithTx(uow.Tx()).GetByFile(ctx, file.FileUUID)
+		if err != nil {
+			return err
+		}
+		return s.contents.release(ctx, uow, file, versions)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publisher.Publish(ctx, socket.FileTopic(file.FileUUID), socket.ChangeEvent{Action: socket.ActionDeleted, ID: file.FileUUID})
+	return nil
+}
+
+// CreateUploadURL registers a pending file and returns a pre-signed URL the client
+// uploads it to. The file stays pending until CompleteUpload is called
+func (s *fileService) CreateUploadURL(ctx context.Context, userID string, data UploadURLRequest) (UploadURLResponse, error) {
+	if err := s.policy.CheckName(data.FileName); err != nil {
+		return UploadURLResponse{}, err
+	}
+
+	name := newStorageName()
+	key, folder, fileType := storages.BuildKey(name, data.FileName, time.Now())
+
+	var response UploadURLResponse
+
+	err := s.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		status, err := s.getStatusByName(ctx, s.statusRepo.WithTx(uow.Tx()), "Pending")
+		if err != nil {
+			return err
+		}
+
+		fileUUID, err := s.filesRepo.WithTx(uow.Tx()).Create(ctx, FileRequest{
+			File: File{
+				Name:         name,
+				OriginalName: data.FileName,
+				Type:         fileType,
+				Folder:       folder,
+				Link:         s.storageService.ObjectURL(key),
+				Key:          key,
+				OwnerUUID:    userID,
+				StatusUUID:   status.StatusUUID,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		upload, err := s.storageService.PresignUpload(ctx, key, data.ContentType, s.config.PresignedURLTTL)
+		if err != nil {
+			return err
+		}
+
+		response = UploadURLResponse{FileUUID: fileUUID, Upload: upload}
+		return nil
+	})
+	if err != nil {
+		return UploadURLResponse{}, err
+	}
+
+	return response, nil
+}
+
+// CompleteUpload checks that the object of a pending file was uploaded, marks the file as Uploaded
+// and schedules its processing. An object refused by the upload policy is removed and the file
+// marked as Rejected
+func (s *fileService) CompleteUpload(ctx context.Context, userID string, id string) (FileResponse, error) {
+	file, err := s.authorize(ctx, userID, id, manageAccess)
+	if err != nil {
+		return FileResponse{}, err
+	}
+
+	pending, err := s.getStatusByName(ctx, s.statusRepo, "Pending")
+	if err != nil {
+		return FileResponse{}, err
+	}
+	if file.StatusUUID != pending.StatusUUID {
+		return FileResponse{}, ErrUploadNotPending
+	}
+
+	inspection, checksum, err := s.inspectObject(ctx, file)
+	if err != nil {
+		var policyErr *UploadPolicyError
+		if errors.As(err, &policyErr) {
+			return FileResponse{}, errors.Join(err, s.reject(ctx, file))
+		}
+		return FileResponse{}, err
+	}
+
+	uploaded, err := s.getStatusByName(ctx, s.statusRepo, "Uploaded")
+	if err != nil {
+		return FileResponse{}, err
+	}
+
+	file.Type = inspection.MIMEType
+	file.Checksum = checksum
+	file.Size = inspection.Size
+	file.StatusUUID = uploaded.StatusUUID
+	err = s.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		if err := s.filesRepo.WithTx(uow.Tx()).Update(ctx, FileRequest{File: File{
+			FileUUID:   file.FileUUID,
+			Name:       file.Name,
+			Link:       file.Link,
+			Folder:     file.Folder,
+			Type:       file.Type,
+			Checksum:   file.Checksum,
+			Size:       file.Size,
+			StatusUUID: file.StatusUUID,
+		}}); err != nil {
+			return err
+		}
+		return s.processor.Enqueue(ctx, uow, file.FileUUID, userID)
+	})
+	if err != nil {
+		return FileResponse{}, err
+	}
+	s.processor.Notify()
+
+	return file, nil
+}
+
+// inspectObject checks the uploaded object of a file against the upload policy and
+// returns its checksum, reading the object once
+func (s *fileService) inspectObject(ctx context.Context, file FileResponse) (Inspection, string, error) {
+	object, err := s.storageService.Download(ctx, file.Key)
+	if err != nil {
+		if errors.Is(err, storages.ErrObjectNotFound) {
+			return Inspection{}, "", ErrUploadNotFound
+		}
+		return Inspection{}, "", err
+	}
+	defer object.Body.Close()
+
+	hasher := sha256.New()
+	content := io.TeeReader(object.Body, hasher)
+
+	inspection, err := s.policy.Inspect(file.OriginalName, object.Size, content)
+	if err != nil {
+		return Inspection{}, "", err
+	}
+	if _, err := io.Copy(io.Discard, content); err != nil {
+		return Inspection{}, "", fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	return inspection, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// reject removes the object of a file refused by the upload policy
+func (s *fileService) reject(ctx context.Context, file FileResponse) error {
+	if err := s.storageService.Delete(ctx, file.Key); err != nil && !errors.Is(err, storages.ErrObjectNotFound) {
+		return err
+	}
+
+	rejected, err := s.getStatusByName(ctx, s.statusRepo, "Rejected")
+	if err != nil {
+		return err
+	}
+	return s.filesRepo.UpdateStatus(ctx, file.FileUUID, rejected.StatusUUID)
+}
+
+// GetDownloadURL returns a short-lived pre-signed URL to download a file directly from
+// the storage and records the download in its access history
+func (s *fileService) GetDownloadURL(ctx context.Context, userID string, id string) (storages.PresignedURL, error) {
+	file, err := s.authorize(ctx, userID, id, readAccess)
+	if err != nil {
+		return storages.PresignedURL{}, err
+	}
+	if err := s.checkProcessed(ctx, file); err != nil {
+		return storages.PresignedURL{}, err
+	}
+
+	url, err := s.storageService.PresignDownload(ctx, file.Key, file.OriginalName, s.config.PresignedURLTTL)
+	if err != nil {
+		return storages.PresignedURL{}, err
+	}
+
+	if err := s.recordAccess(ctx, s.historyRepo, file.FileUUID, userID, "", "Downloaded"); err != nil {
+		return storages.PresignedURL{}, err
+	}
+	return url, nil
+}
+
+// GetText returns the text extracted from a file during its processing
+func (s *fileService) GetText(ctx context.Context, userID string, id string) (string, error) {
+	if _, err := s.authorize(ctx, userID, id, readAccess); err != nil {
+		return "", err
+	}
+	return s.filesRepo.GetText(ctx, id)
+}
+
+// AssignOwner gives an owner to a file uploaded before owners were recorded, which
+// makes it reachable by that user again
+func (s *fileService) AssignOwner(ctx context.Context, id string, ownerUUID string) (FileResponse, error) {
+	file, err := s.filesRepo.GetByID(ctx, id)
+	if err != nil {
+		return FileResponse{}, err
+	}
+	if file.OwnerUUID != "" {
+		return FileResponse{}, ErrFileHasOwner
+	}
+
+	if err := s.filesRepo.UpdateOwner(ctx, file.FileUUID, ownerUUID); err != nil {
+		return FileResponse{}, err
+	}
+	file.OwnerUUID = ownerUUID
+	return s.presignThumbnail(ctx, file)
+}
+
+func (s *fileService) getStatusByName(ctx context.Context, statusRepo status.StatusRepository, name string) (status.StatusResponse, error) {
+	statusResponse, err := statusRepo.GetByName(ctx, name)
 	if err != nil {
 		return status.StatusResponse{}, err
 	}
 	return statusResponse, nil
 }
+
+// TopicAuthorizer authorizes the users to follow the file:<uuid> topics of the
+// files they can read
+func TopicAuthorizer(filesService FilesService) socket.TopicAuthorizer {
+	return func(ctx context.Context, userUUID string, id string) error {
+		_, err := filesService.GetByID(ctx, userUUID, id)
+		if errors.Is(err, ErrFileNotFound) {
+			return socket.ErrTopicForbidden
+		}
+		return err
+	}
+}
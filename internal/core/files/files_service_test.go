@@ -0,0 +1,214 @@
+package files
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/core/storage"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/providers/scanners"
+	"bernardtm/backend/pkg/providers/storages"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testTenant = "0192d1a4-0000-7000-8000-00000000000a"
+
+// memoryFile is an in-memory multipart.File
+type memoryFile struct {
+	*strings.Reader
+}
+
+func (f memoryFile) Close() error { return nil }
+
+func newTestFilesService() (*fileService, *testRepositories, storages.StorageProvider) {
+	repos := newTestRepositories()
+	provider := storages.NewMemoryStorageProvider()
+	processor, _ := newTestFileProcessor(repos, provider, scanners.NewFakeScannerProvider())
+	service := NewFilesService(
+		repos.files,
+		repos.versions,
+		repos.shares,
+		repos.history,
+		fakeStatusRepository{},
+		storage.NewStorageService(provider),
+		database.NewFakeTxManager(),
+		processor,
+		&recordingNotifier{},
+		&configs.AppConfig{PresignedURLTTL: time.Minute, ShareLinkURL: "https://api.test/shared/", ShareLinkTTL: time.Hour, ShareLinkMaxTTL: 24 * time.Hour},
+	)
+	return service, repos, provider
+}
+
+// storedFile returns file-1, a processed PDF of owner stored under key
+func storedFile(key string, owner string) FileResponse {
+	return FileResponse{
+		FileUUID:     "file-1",
+		Name:         "blob",
+		OriginalName: "report.pdf",
+		Link:         "memory://" + key,
+		Key:          key,
+		Folder:       "pdf",
+		Type:         "application/pdf",
+		OwnerUUID:    owner,
+		StatusUUID:   "Processed-uuid",
+		CreationDate: time.Now(),
+	}
+}
+
+const pdfChecksum = "b0c3b4e5dd2ab4ff3a5e5d7d1c2f8a3a4e9c86f1a1c35e8fb94d0f3f2e5e1f9b"
+
+func TestFileService_Create(t *testing.T) {
+	service, repos, provider := newTestFilesService()
+	ctx := database.WithTenant(context.Background(), testTenant)
+
+	id, err := service.Create(ctx, testUser, FileRequest{File: File{Name: "report.pdf"}}, memoryFile{strings.NewReader("%PDF-1.7")})
+
+	assert.NoError(t, err)
+	file := repos.files.get(id)
+	assert.Equal(t, "report.pdf", file.OriginalName)
+	assert.Equal(t, "application/pdf", file.Type)
+	assert.Equal(t, int64(8), file.Size)
+	assert.Equal(t, testUser, file.OwnerUUID)
+	assert.Equal(t, "Uploaded-uuid", file.StatusUUID)
+	if assert.Len(t, repos.jobs.jobs, 1, "the processing of the file is scheduled") {
+		assert.Equal(t, id, repos.jobs.jobs[0].FileUUID)
+	}
+	objects, _ := provider.List(ctx, "")
+	if assert.Len(t, objects, 1) {
+		assert.Equal(t, file.Key, objects[0].Key)
+		assert.True(t, strings.HasPrefix(objects[0].Key, "blobs/"))
+	}
+}
+
+func TestFileService_CreateDeduplicatesContent(t *testing.T) {
+	service, repos, provider := newTestFilesService()
+	ctx := database.WithTenant(context.Background(), testTenant)
+
+	var ids []string
+	for _, name := range []string{"report.pdf", "copy.pdf"} {
+		id, err := service.Create(ctx, testUser, FileRequest{File: File{Name: name}}, memoryFile{strings.NewReader("%PDF-1.7")})
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	objects, _ := provider.List(ctx, "")
+	assert.Len(t, objects, 1, "identical content must share one object")
+	assert.Equal(t, repos.files.get(ids[0]).Key, repos.files.get(ids[1]).Key)
+
+	// the key depends on the tenant, so another tenant gets its own object
+	other := service.contents.blobKey("another-tenant", pdfChecksum)
+	assert.NotEqual(t, service.contents.blobKey(testTenant, pdfChecksum), other)
+	assert.NotContains(t, other, pdfChecksum)
+}
+
+func TestFileService_CreateRemovesObjectWhenInsertFails(t *testing.T) {
+	service, repos, provider := newTestFilesService()
+	ctx := database.WithTenant(context.Background(), testTenant)
+	repos.files.createErr = errors.New("insert failed")
+
+	_, err := service.Create(ctx, testUser, FileRequest{File: File{Name: "report.pdf"}}, memoryFile{strings.NewReader("%PDF-1.7")})
+
+	assert.Error(t, err)
+	objects, _ := provider.List(ctx, "")
+	assert.Empty(t, objects, "the uploaded object must be compensated")
+	assert.Empty(t, repos.jobs.jobs)
+}
+
+func TestFileService_RefusesUnprocessedFile(t *testing.T) {
+	service, repos, provider := newTestFilesService()
+	ctx := database.WithTenant(context.Background(), testTenant)
+	provider.Put(ctx, "blobs/unscanned", strings.NewReader("%PDF-1.7"), "application/pdf")
+
+	for _, statusName := range []string{"Pending", "Uploaded", "Failed", "Rejected"} {
+		file := storedFile("blobs/unscanned", testUser)
+		file.StatusUUID = statusName + "-uuid"
+		repos.files.add(file)
+
+		_, err := service.GetDownloadURL(ctx, testUser, "file-1")
+		assert.ErrorIs(t, err, ErrFileNotReady, statusName)
+	}
+	assert.Empty(t, repos.history.entries)
+}
+
+func TestFileService_Delete(t *testing.T) {
+	tests := []struct {
+		name       string
+		references int
+		removed    bool
+	}{
+		{name: "removes the object only the file references", references: 0, removed: true},
+		{name: "keeps the object other files reference", references: 1, removed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, repos, provider := newTestFilesService()
+			ctx := database.WithTenant(context.Background(), testTenant)
+			provider.Put(ctx, "blobs/shared", strings.NewReader("%PDF-1.7"), "application/pdf")
+			provider.Put(ctx, thumbnailKey("blobs/shared"), strings.NewReader("webp"), "image/webp")
+			repos.files.add(storedFile("blobs/shared", testUser))
+			for i := range tt.references {
+				other := storedFile("blobs/shared", testOwner)
+				other.FileUUID = fmt.Sprintf("copy-%d", i)
+				repos.files.add(other)
+			}
+
+			assert.NoError(t, service.Delete(ctx, testUser, "file-1"))
+
+			_, err := repos.files.GetByID(ctx, "file-1")
+			assert.ErrorIs(t, err, ErrFileNotFound)
+			objects, _ := provider.List(ctx, "")
+			if tt.removed {
+				assert.Empty(t, objects, "the object and its thumbnail are removed")
+			} else {
+				assert.Len(t, objects, 2)
+			}
+		})
+	}
+}
+
+func TestFileService_DownloadVerifiesChecksum(t *testing.T) {
+	service, repos, provider := newTestFilesService()
+	ctx := database.WithTenant(context.Background(), testTenant)
+	provider.Put(ctx, "blobs/tampered", strings.NewReader("%PDF-1.8"), "application/pdf")
+
+	file := storedFile("blobs/tampered", testUser)
+	file.Checksum, file.Size, _ = Checksum(strings.NewReader("%PDF-1.7"))
+	repos.files.add(file)
+
+	_, object, err := service.Download(ctx, testUser, "file-1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = io.ReadAll(object.Body)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	if assert.Len(t, repos.history.entries, 1, "the download is recorded") {
+		assert.Equal(t, "Downloaded-uuid", repos.history.entries[0].StatusUUID)
+	}
+}
+
+func TestFileService_PresignsThumbnails(t *testing.T) {
+	service, repos, _ := newTestFilesService()
+	ctx := database.WithTenant(context.Background(), testTenant)
+
+	file := storedFile("blobs/image", testUser)
+	file.ThumbnailLink = "http://localhost:8080/api/v1/storage/local/thumbnails/blobs/image.webp"
+	repos.files.add(file)
+
+	found, err := service.GetByID(ctx, testUser, "file-1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "memory://"+thumbnailKey("blobs/image"), found.ThumbnailLink, "the stored link is replaced by a pre-signed URL")
+
+	// files without thumbnail keep an empty link
+	repos.files.add(storedFile("blobs/report", testUser))
+	found, err = service.GetByID(ctx, testUser, "file-1")
+	assert.NoError(t, err)
+	assert.Empty(t, found.ThumbnailLink)
+}
@@ -0,0 +1,185 @@
+package files
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/providers/storages"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrShareWithOwner is returned when sharing a file with its own owner
+var ErrShareWithOwner = errors.New("file cannot be shared with its owner")
+
+// ErrInvalidShareExpiration is returned for an expiration in the past or, for share
+// links, beyond the maximum lifetime
+var ErrInvalidShareExpiration = errors.New("invalid share expiration")
+
+// ShareWithUser grants another user of the tenant read access to a file until the
+// optional expiration, and records the share in the access history of the file
+func (s *fileService) ShareWithUser(ctx context.Context, userID string, id string, data FileShareRequest) (FileShareResponse, error) {
+	file, err := s.authorize(ctx, userID, id, manageAccess)
+	if err != nil {
+		return FileShareResponse{}, err
+	}
+	if data.UserUUID == userID || data.UserUUID == file.OwnerUUID {
+		return FileShareResponse{}, ErrShareWithOwner
+	}
+	if data.ExpirationDate != nil && !data.ExpirationDate.After(time.Now()) {
+		return FileShareResponse{}, ErrInvalidShareExpiration
+	}
+
+	var share FileShareResponse
+
+	err = s.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		share, err = s.sharesRepo.WithTx(uow.Tx()).ShareWithUser(ctx, file.FileUUID, data.UserUUID, userID, data.ExpirationDate)
+		if err != nil {
+			return err
+		}
+		return s.recordAccess(ctx, s.historyRepo.WithTx(uow.Tx()), file.FileUUID, userID, share.ShareUUID, "Shared")
+	})
+	if err != nil {
+		return FileShareResponse{}, err
+	}
+
+	return share, nil
+}
+
+// CreateShareLink creates a link anyone can download a file with until it expires,
+// by default after the configured share link lifetime. The token of the link is
+// only returned here, the database keeps its hash
+func (s *fileService) CreateShareLink(ctx context.Context, userID string, id string, data ShareLinkRequest) (ShareLinkResponse, error) {
+	file, err := s.authorize(ctx, userID, id, manageAccess)
+	if err != nil {
+		return ShareLinkResponse{}, err
+	}
+
+	now := time.Now()
+	expiration := now.Add(s.config.ShareLinkTTL)
+	if data.ExpirationDate != nil {
+		expiration = *data.ExpirationDate
+	}
+	if !expiration.After(now) || (s.config.ShareLinkMaxTTL > 0 && expiration.After(now.Add(s.config.ShareLinkMaxTTL))) {
+		return ShareLinkResponse{}, ErrInvalidShareExpiration
+	}
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return ShareLinkResponse{}, err
+	}
+	token, err := newShareToken(tenantID)
+	if err != nil {
+		return ShareLinkResponse{}, err
+	}
+
+	var share FileShareResponse
+
+	err = s.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		share, err = s.sharesRepo.WithTx(uow.Tx()).CreateLink(ctx, file.FileUUID, hashShareToken(token), userID, expiration)
+		if err != nil {
+			return err
+		}
+		return s.recordAccess(ctx, s.historyRepo.WithTx(uow.Tx()), file.FileUUID, userID, share.ShareUUID, "Shared")
+	})
+	if err != nil {
+		return ShareLinkResponse{}, err
+	}
+
+	return ShareLinkResponse{
+		FileShareResponse: share,
+		Token:             token,
+		URL:               strings.TrimRight(s.config.ShareLinkURL, "/") + "/" + token,
+	}, nil
+}
+
+// GetShares returns the shares of a file, user shares and share links
+func (s *fileService) GetShares(ctx context.Context, userID string, id string) ([]FileShareResponse, error) {
+	file, err := s.authorize(ctx, userID, id, manageAccess)
+	if err != nil {
+		return nil, err
+	}
+	return s.sharesRepo.GetByFile(ctx, file.FileUUID)
+}
+
+// RevokeShare removes a share of a file, ending the access it granted
+func (s *fileService) RevokeShare(ctx context.Context, userID string, id string, shareID string) error {
+	file, err := s.authorize(ctx, userID, id, manageAccess)
+	if err != nil {
+		return err
+	}
+	return s.sharesRepo.Delete(ctx, file.FileUUID, shareID)
+}
+
+// GetAccessHistory returns who shared and downloaded a file, and when
+func (s *fileService) GetAccessHistory(ctx context.Context, userID string, id string) ([]FileAccessResponse, error) {
+	file, err := s.authorize(ctx, userID, id, manageAccess)
+	if err != nil {
+		return nil, err
+	}
+	return s.historyRepo.GetByFile(ctx, file.FileUUID)
+}
+
+// DownloadShared opens the file of a share link. The link carries its tenant, so it
+// works without authentication; unknown, revoked and expired links are reported as
+// ErrShareNotFound
+func (s *fileService) DownloadShared(ctx context.Context, token string) (FileResponse, *storages.Object, error) {
+	tenantID, _, ok := strings.Cut(token, ".")
+	if !ok || !database.IsValidTenantID(tenantID) {
+		return FileResponse{}, nil, ErrShareNotFound
+	}
+	ctx = database.WithTenant(ctx, tenantID)
+
+	share, err := s.sharesRepo.GetActiveLink(ctx, hashShareToken(token), time.Now())
+	if err != nil {
+		return FileResponse{}, nil, err
+	}
+
+	file, err := s.filesRepo.GetByID(ctx, share.FileUUID)
+	if err != nil {
+		return FileResponse{}, nil, err
+	}
+	if err := s.checkProcessed(ctx, file); err != nil {
+		return FileResponse{}, nil, err
+	}
+
+	object, err := s.open(ctx, file)
+	if err != nil {
+		return FileResponse{}, nil, err
+	}
+
+	if err := s.recordAccess(ctx, s.historyRepo, file.FileUUID, "", share.ShareUUID, "Downloaded"); err != nil {
+		object.Body.Close()
+		return FileResponse{}, nil, err
+	}
+
+	return file, object, nil
+}
+
+// recordAccess adds an entry with the named status to the access history of a file
+func (s *fileService) recordAccess(ctx context.Context, historyRepo FileAccessHistoryRepository, fileUUID string, userID string, shareUUID string, statusName string) error {
+	status, err := s.getStatusByName(ctx, s.statusRepo, statusName)
+	if err != nil {
+		return err
+	}
+	return historyRepo.Record(ctx, fileUUID, userID, shareUUID, status.StatusUUID)
+}
+
+// newShareToken returns a random share link token prefixed by the tenant it belongs to
+func newShareToken(tenantID string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return tenantID + "." + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// hashShareToken returns the hash a share link token is stored and looked up by
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,157 @@
+package files
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testOwner = "0192d1a4-0000-7000-8000-0000000000bb"
+
+func TestFileService_Authorize(t *testing.T) {
+	expired := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name       string
+		owner      string
+		share      bool
+		expiration *time.Time
+		readErr    error
+		manageErr  error
+	}{
+		{name: "owner", owner: testUser},
+		{name: "shared user can read but not manage", owner: testOwner, share: true, manageErr: ErrFileAccessDenied},
+		{name: "unshared file is not found", owner: testOwner, readErr: ErrFileNotFound, manageErr: ErrFileNotFound},
+		{name: "expired share is not found", owner: testOwner, share: true, expiration: &expired, readErr: ErrFileNotFound, manageErr: ErrFileNotFound},
+		{name: "ownerless file is not found", owner: "", readErr: ErrFileNotFound, manageErr: ErrFileNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, repos, _ := newTestFilesService()
+			ctx := database.WithTenant(context.Background(), testTenant)
+			repos.files.add(storedFile("blobs/report", tt.owner))
+			if tt.share {
+				repos.shares.ShareWithUser(ctx, "file-1", testUser, testOwner, tt.expiration)
+			}
+
+			_, err := service.GetByID(ctx, testUser, "file-1")
+			if tt.readErr != nil {
+				assert.ErrorIs(t, err, tt.readErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			_, err = service.GetShares(ctx, testUser, "file-1")
+			if tt.manageErr != nil {
+				assert.ErrorIs(t, err, tt.manageErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFileService_AssignOwner(t *testing.T) {
+	service, repos, _ := newTestFilesService()
+	ctx := database.WithTenant(context.Background(), testTenant)
+	repos.files.add(storedFile("blobs/legacy", ""))
+
+	file, err := service.AssignOwner(ctx, "file-1", testUser)
+	assert.NoError(t, err)
+	assert.Equal(t, testUser, file.OwnerUUID)
+	assert.Equal(t, testUser, repos.files.get("file-1").OwnerUUID)
+
+	// a file with an owner is not reassigned
+	_, err = service.AssignOwner(ctx, "file-1", testOwner)
+	assert.ErrorIs(t, err, ErrFileHasOwner)
+	assert.Equal(t, testUser, repos.files.get("file-1").OwnerUUID)
+}
+
+func TestFileService_ShareWithUser(t *testing.T) {
+	service, repos, _ := newTestFilesService()
+	ctx := database.WithTenant(context.Background(), testTenant)
+	repos.files.add(storedFile("blobs/report", testOwner))
+
+	share, err := service.ShareWithUser(ctx, testOwner, "file-1", FileShareRequest{UserUUID: testUser})
+
+	assert.NoError(t, err)
+	assert.Equal(t, testUser, share.UserUUID)
+	_, err = service.GetByID(ctx, testUser, "file-1")
+	assert.NoError(t, err, "the user the file is shared with can read it")
+	if assert.Len(t, repos.history.entries, 1) {
+		assert.Equal(t, share.ShareUUID, repos.history.entries[0].ShareUUID)
+		assert.Equal(t, "Shared-uuid", repos.history.entries[0].StatusUUID)
+	}
+
+	_, err = service.ShareWithUser(ctx, testOwner, "file-1", FileShareRequest{UserUUID: testOwner})
+	assert.ErrorIs(t, err, ErrShareWithOwner)
+}
+
+func TestFileService_RevokeShare(t *testing.T) {
+	service, repos, _ := newTestFilesService()
+	ctx := database.WithTenant(context.Background(), testTenant)
+	repos.files.add(storedFile("blobs/report", testOwner))
+
+	share, err := service.ShareWithUser(ctx, testOwner, "file-1", FileShareRequest{UserUUID: testUser})
+	assert.NoError(t, err)
+	assert.NoError(t, service.RevokeShare(ctx, testOwner, "file-1", share.ShareUUID))
+
+	_, err = service.GetByID(ctx, testUser, "file-1")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+	assert.ErrorIs(t, service.RevokeShare(ctx, testOwner, "file-1", share.ShareUUID), ErrShareNotFound)
+}
+
+func TestFileService_ShareLink(t *testing.T) {
+	service, repos, provider := newTestFilesService()
+	ctx := database.WithTenant(context.Background(), testTenant)
+	provider.Put(ctx, "blobs/report", strings.NewReader("%PDF-1.7"), "application/pdf")
+	repos.files.add(storedFile("blobs/report", testOwner))
+
+	link, err := service.CreateShareLink(ctx, testOwner, "file-1", ShareLinkRequest{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, strings.HasPrefix(link.Token, testTenant+"."))
+	assert.Equal(t, "https://api.test/shared/"+link.Token, link.URL)
+	assert.NotContains(t, repos.shares.shares[0].tokenHash, link.Token, "only the hash of the token is stored")
+
+	// the link works without a tenant in the context
+	_, object, err := service.DownloadShared(context.Background(), link.Token)
+	if assert.NoError(t, err) {
+		content, _ := io.ReadAll(object.Body)
+		object.Body.Close()
+		assert.Equal(t, "%PDF-1.7", string(content))
+	}
+	if assert.Len(t, repos.history.entries, 2) {
+		assert.Equal(t, link.ShareUUID, repos.history.entries[1].ShareUUID)
+		assert.Equal(t, "Downloaded-uuid", repos.history.entries[1].StatusUUID)
+	}
+
+	// and stops working once revoked
+	assert.NoError(t, service.RevokeShare(ctx, testOwner, "file-1", link.ShareUUID))
+	_, _, err = service.DownloadShared(context.Background(), link.Token)
+	assert.ErrorIs(t, err, ErrShareNotFound)
+}
+
+func TestFileService_ShareLinkExpiration(t *testing.T) {
+	service, repos, _ := newTestFilesService()
+	ctx := database.WithTenant(context.Background(), testTenant)
+	repos.files.add(storedFile("blobs/report", testOwner))
+
+	for _, expiration := range []time.Time{time.Now().Add(-time.Minute), time.Now().Add(48 * time.Hour)} {
+		_, err := service.CreateShareLink(ctx, testOwner, "file-1", ShareLinkRequest{ExpirationDate: &expiration})
+		assert.ErrorIs(t, err, ErrInvalidShareExpiration)
+	}
+	assert.Empty(t, repos.shares.shares)
+
+	_, _, err := service.DownloadShared(context.Background(), "not-a-tenant.secret")
+	assert.ErrorIs(t, err, ErrShareNotFound)
+	_, _, err = service.DownloadShared(context.Background(), testTenant+".unknown")
+	assert.ErrorIs(t, err, ErrShareNotFound)
+}
@@ -0,0 +1,122 @@
+package files
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/infra/socket"
+	"bernardtm/backend/pkg/providers/storages"
+	"context"
+	"mime/multipart"
+)
+
+// UpdateContent replaces the content of a file, keeping its ID, shares and
+// attachments, and schedules the processing of the new content. With versioning
+// enabled the previous content is kept as a version of the file, otherwise its
+// object is removed once no other file references it
+func (s *fileService) UpdateContent(ctx context.Context, userID string, id string, name string, fileStream multipart.File) (FileResponse, error) {
+	file, err := s.authorize(ctx, userID, id, manageAccess)
+	if err != nil {
+		return FileResponse{}, err
+	}
+
+	inspection, err := s.policy.InspectFile(name, fileStream)
+	if err != nil {
+		return FileResponse{}, err
+	}
+
+	err = s.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		filesRepo := s.filesRepo.WithTx(uow.Tx())
+
+		// concurrent updates of the file are serialized, so each one versions
+		// the content the previous one stored
+		if err := filesRepo.LockKey(ctx, "file:"+file.FileUUID); err != nil {
+			return err
+		}
+		current, err := filesRepo.GetByID(ctx, file.FileUUID)
+		if err != nil {
+			return err
+		}
+		if err := filesRepo.LockKey(ctx, current.Key); err != nil {
+			return err
+		}
+
+		content, err := s.contents.store(ctx, uow, name, fileStream, inspection.MIMEType)
+		if err != nil {
+			return err
+		}
+
+		if s.config.FileVersioning {
+			if _, err := s.versionsRepo.WithTx(uow.Tx()).Create(ctx, current, userID); err != nil {
+				return err
+			}
+		}
+
+		status, err := s.getStatusByName(ctx, s.statusRepo.WithTx(uow.Tx()), "Uploaded")
+		if err != nil {
+			return err
+		}
+		content.FileUUID = current.FileUUID
+		content.StatusUUID = status.StatusUUID
+
+		if err := filesRepo.ReplaceContent(ctx, content); err != nil {
+			return err
+		}
+		if err := s.processor.Enqueue(ctx, uow, current.FileUUID, userID); err != nil {
+			return err
+		}
+
+		if current.Key == content.Key {
+			return nil
+		}
+		return s.contents.drop(ctx, filesRepo, current.Key)
+	})
+	if err != nil {
+		return FileResponse{}, err
+	}
+	s.processor.Notify()
+	s.publisher.Publish(ctx, socket.FileTopic(file.FileUUID), socket.ChangeEvent{Action: socket.ActionUpdated, ID: file.FileUUID})
+
+	return s.filesRepo.GetByID(ctx, file.FileUUID)
+}
+
+// GetVersions returns the previous contents of a file, most recent first
+func (s *fileService) GetVersions(ctx context.Context, userID string, id string) ([]FileVersionResponse, error) {
+	file, err := s.authorize(ctx, userID, id, readAccess)
+	if err != nil {
+		return nil, err
+	}
+	return s.versionsRepo.GetByFile(ctx, file.FileUUID)
+}
+
+// DownloadVersion opens a previous content of a file, like Download opens the
+// current one, and records the download in the access history of the file
+func (s *fileService) DownloadVersion(ctx context.Context, userID string, id string, versionID string) (FileResponse, *storages.Object, error) {
+	file, err := s.authorize(ctx, userID, id, readAccess)
+	if err != nil {
+		return FileResponse{}, nil, err
+	}
+	if err := s.checkProcessed(ctx, file); err != nil {
+		return FileResponse{}, nil, err
+	}
+
+	version, err := s.versionsRepo.GetByID(ctx, file.FileUUID, versionID)
+	if err != nil {
+		return FileResponse{}, nil, err
+	}
+	file.OriginalName = version.OriginalName
+	file.Key = version.Key
+	file.Type = version.Type
+	file.Checksum = version.Checksum
+	file.Size = version.Size
+
+	object, err := s.open(ctx, file)
+	if err != nil {
+		return FileResponse{}, nil, err
+	}
+
+	if err := s.recordAccess(ctx, s.historyRepo, file.FileUUID, userID, "", "Downloaded"); err != nil {
+		object.Body.Close()
+		return FileResponse{}, nil, err
+	}
+
+	return file, object, nil
+}
@@ -0,0 +1,83 @@
+package files
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileService_UpdateContent(t *testing.T) {
+	tests := []struct {
+		name       string
+		versioning bool
+		objects    int
+		versions   int
+	}{
+		{name: "keeps the previous content as a version", versioning: true, objects: 3, versions: 1},
+		{name: "removes the previous content and its thumbnail", versioning: false, objects: 1, versions: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, repos, provider := newTestFilesService()
+			service.config.FileVersioning = tt.versioning
+			ctx := database.WithTenant(context.Background(), testTenant)
+			provider.Put(ctx, "blobs/old", strings.NewReader("%PDF-1.6"), "application/pdf")
+			provider.Put(ctx, thumbnailKey("blobs/old"), strings.NewReader("webp"), "image/webp")
+			repos.files.add(storedFile("blobs/old", testUser))
+
+			file, err := service.UpdateContent(ctx, testUser, "file-1", "report-v2.pdf", memoryFile{strings.NewReader("%PDF-1.7")})
+
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, "report-v2.pdf", file.OriginalName)
+			assert.NotEqual(t, "blobs/old", file.Key)
+			assert.Equal(t, "Uploaded-uuid", file.StatusUUID)
+			assert.Len(t, repos.jobs.jobs, 1, "the processing of the new content is scheduled")
+
+			objects, _ := provider.List(ctx, "")
+			assert.Len(t, objects, tt.objects)
+			versions, _ := repos.versions.GetByFile(ctx, "file-1")
+			if assert.Len(t, versions, tt.versions) && tt.versioning {
+				assert.Equal(t, "blobs/old", versions[0].Key)
+				assert.Equal(t, "report.pdf", versions[0].OriginalName)
+				assert.Equal(t, 1, versions[0].VersionNumber)
+			}
+		})
+	}
+}
+
+func TestFileService_DownloadVersion(t *testing.T) {
+	service, repos, provider := newTestFilesService()
+	ctx := database.WithTenant(context.Background(), testTenant)
+	provider.Put(ctx, "blobs/old", strings.NewReader("%PDF-1.6"), "application/pdf")
+	repos.files.add(storedFile("blobs/new", testUser))
+	versionID, _ := repos.versions.Create(ctx, FileResponse{FileUUID: "file-1", OriginalName: "draft.pdf", Key: "blobs/old", Type: "application/pdf"}, testUser)
+
+	file, object, err := service.DownloadVersion(ctx, testUser, "file-1", versionID)
+
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "draft.pdf", file.OriginalName)
+	content, _ := io.ReadAll(object.Body)
+	object.Body.Close()
+	assert.Equal(t, "%PDF-1.6", string(content))
+	assert.Len(t, repos.history.entries, 1, "the download is recorded")
+}
+
+func TestFileService_DownloadVersionNotFound(t *testing.T) {
+	service, repos, _ := newTestFilesService()
+	ctx := database.WithTenant(context.Background(), testTenant)
+	repos.files.add(storedFile("blobs/new", testUser))
+
+	_, _, err := service.DownloadVersion(ctx, testUser, "file-1", "missing")
+
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+	assert.Empty(t, repos.history.entries)
+}
@@ -0,0 +1,22 @@
+package files
+
+import "time"
+
+type ResumableUpload struct {
+	UploadUUID       string     `json:"upload_uuid" db:"upload_uuid"`                       // UUID do upload (chave primaria)
+	UserUUID         string     `json:"user_uuid" db:"user_uuid"`                           // Usuario que iniciou o upload
+	FileName         string     `json:"file_name" db:"file_name"`                           // Nome original do arquivo
+	FileType         string     `json:"file_type" db:"file_type"`                           // Tipo informado pelo cliente
+	Length           int64      `json:"upload_length" db:"upload_length"`                   // Tamanho total em bytes
+	Offset           int64      `json:"upload_offset" db:"upload_offset"`                   // Bytes ja recebidos
+	FileUUID         *string    `json:"file_uuid,omitempty" db:"file_uuid"`                 // Arquivo criado ao finalizar
+	StatusUUID       string     `json:"status_uuid" db:"status_uuid"`                       // Status do upload
+	ExpirationDate   time.Time  `json:"expiration_date" db:"expiration_date"`               // Data de expiracao
+	CreationDate     time.Time  `json:"creation_date,omitempty" db:"creation_date"`         // Data de criação (valor padrão: data atual)
+	ModificationDate *time.Time `json:"modification_date,omitempty" db:"modification_date"` // Data de modificação (opcional)
+}
+
+// Completed reports whether the upload was finalized into a file
+func (u ResumableUpload) Completed() bool {
+	return u.FileUUID != nil
+}
@@ -0,0 +1,226 @@
+package files
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrResumableUploadNotFound is returned when no resumable upload matches the given ID
+var ErrResumableUploadNotFound = errors.New("resumable upload not found")
+
+// ErrUploadOffsetMismatch is returned when a chunk does not start at the persisted offset
+var ErrUploadOffsetMismatch = errors.New("upload offset mismatch")
+
+type ResumableUploadsRepository interface {
+	GetByID(ctx context.Context, id string) (ResumableUpload, error)
+	GetByIDForUpdate(ctx context.Context, id string) (ResumableUpload, error)
+	Create(ctx context.Context, data ResumableUpload) (string, error)
+	UpdateOffset(ctx context.Context, id string, from, to int64) error
+	Complete(ctx context.Context, id string, fileUUID string, statusUUID string) error
+	Delete(ctx context.Context, id string) error
+	GetExpiredIDs(ctx context.Context, before time.Time, limit int) ([]string, error)
+	WithTx(tx database.DBTX) ResumableUploadsRepository
+}
+
+type resumableUploadsRepository struct {
+	db      database.DBTX
+	timeout time.Duration
+}
+
+func NewResumableUploadsRepository(db database.DBTX, timeout time.Duration) *resumableUploadsRepository {
+	return &resumableUploadsRepository{db: db, timeout: timeout}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *resumableUploadsRepository) WithTx(tx database.DBTX) ResumableUploadsRepository {
+	return &resumableUploadsRepository{db: tx, timeout: r.timeout}
+}
+
+func (r *resumableUploadsRepository) GetByID(ctx context.Context, id string) (ResumableUpload, error) {
+	return r.get(ctx, id, "")
+}
+
+// GetByIDForUpdate returns an upload and locks its row until the end of the
+// transaction, which serializes the chunks of the upload across instances. It must
+// run inside a transaction
+func (r *resumableUploadsRepository) GetByIDForUpdate(ctx context.Context, id string) (ResumableUpload, error) {
+	return r.get(ctx, id, "FOR UPDATE")
+}
+
+func (r *resumableUploadsRepository) get(ctx context.Context, id string, lock string) (ResumableUpload, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return ResumableUpload{}, err
+	}
+
+	var model ResumableUpload
+	var userUUID, fileType sql.NullString
+
+	err = r.db.QueryRowContext(ctx, `
+		SELECT upload_uuid, user_uuid, file_name, file_type, upload_length, upload_offset, file_uuid, status_uuid, expiration_date, creation_date, modification_date
+		FROM default_schema.uploads
+		WHERE upload_uuid = $1 AND tenant_uuid = $2
+		`+lock, id, tenantID).
+		Scan(&model.UploadUUID, &userUUID, &model.FileName, &fileType, &model.Length, &model.Offset, &model.FileUUID, &model.StatusUUID, &model.ExpirationDate, &model.CreationDate, &model.ModificationDate)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ResumableUpload{}, ErrResumableUploadNotFound
+		}
+
+		return ResumableUpload{}, errors.New("failed to retrive upload")
+	}
+
+	model.UserUUID = userUUID.String
+	model.FileType = fileType.String
+	return model, nil
+}
+
+func (r *resumableUploadsRepository) Create(ctx context.Context, data ResumableUpload) (string, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var id string
+
+	query := `INSERT INTO default_schema.uploads (
+		user_uuid, file_name, file_type, upload_length, status_uuid, expiration_date, tenant_uuid
+		) VALUES (NULLIF($1, '')::uuid, $2, NULLIF($3, ''), $4, $5, $6, $7)
+		RETURNING upload_uuid`
+
+	err = r.db.QueryRowContext(ctx, query, data.UserUUID, data.FileName, data.FileType, data.Length, data.StatusUUID, data.ExpirationDate, tenantID).Scan(&id)
+
+	if err != nil {
+		log.Print(err)
+		return "", errors.New("failed to create upload")
+	}
+
+	return id, nil
+}
+
+// UpdateOffset moves the offset of an upload from one value to another. The update
+// only applies while the offset is still at from, so concurrent chunks cannot both win
+func (r *resumableUploadsRepository) UpdateOffset(ctx context.Context, id string, from, to int64) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE default_schema.uploads
+		SET upload_offset = $3,
+		    modification_date = CURRENT_TIMESTAMP
+		WHERE upload_uuid = $1 AND upload_offset = $2 AND tenant_uuid = $4`, id, from, to, tenantID)
+
+	if err != nil {
+		return errors.New("failed to update upload offset")
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrUploadOffsetMismatch
+	}
+
+	return nil
+}
+
+// Complete links a finished upload to the file created from it
+func (r *resumableUploadsRepository) Complete(ctx context.Context, id string, fileUUID string, statusUUID string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE default_schema.uploads
+		SET file_uuid = $2,
+		    status_uuid = $3,
+		    modification_date = CURRENT_TIMESTAMP
+		WHERE upload_uuid = $1 AND file_uuid IS NULL AND tenant_uuid = $4`, id, fileUUID, statusUUID, tenantID)
+
+	if err != nil {
+		return errors.New("failed to complete upload")
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrResumableUploadNotFound
+	}
+
+	return nil
+}
+
+func (r *resumableUploadsRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM default_schema.uploads WHERE upload_uuid = $1 AND tenant_uuid = $2`, id, tenantID)
+
+	if err != nil {
+		return errors.New("failed to delete upload")
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrResumableUploadNotFound
+	}
+
+	return nil
+}
+
+// GetExpiredIDs returns the IDs of up to limit uploads expired before the given date
+func (r *resumableUploadsRepository) GetExpiredIDs(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT upload_uuid
+		FROM default_schema.uploads
+		WHERE expiration_date < $1 AND tenant_uuid = $2
+		ORDER BY expiration_date, upload_uuid
+		LIMIT $3`, before, tenantID, limit)
+
+	if err != nil {
+		return nil, errors.New("failed to retrive expired uploads")
+	}
+
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,410 @@
+package files
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/core/status"
+	"bernardtm/backend/internal/core/storage"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/providers/storages"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"time"
+)
+
+// ErrUploadExpired is returned when a resumable upload is resumed after its expiration date
+var ErrUploadExpired = errors.New("upload expired")
+
+// ErrUploadTooLarge is returned when an upload exceeds TUS_MAX_SIZE or its declared length
+var ErrUploadTooLarge = errors.New("upload too large")
+
+// ErrUploadIncomplete is returned when the parts stored for an upload do not add up
+// to its length when it is finalized
+var ErrUploadIncomplete = errors.New("upload incomplete")
+
+// uploadsPrefix is where the parts of the resumable uploads are stored
+const uploadsPrefix = "uploads/"
+
+// ResumableUploadsService stores uploads received in chunks. Every chunk is stored
+// as a part in the storage and the offset is persisted along with it, so an
+// interrupted upload resumes from the last persisted offset on any instance. Once
+// every byte is received the parts are joined into the stored file and a files row
+// is created
+type ResumableUploadsService interface {
+	Create(ctx context.Context, userID string, length int64, fileName string, fileType string) (ResumableUpload, error)
+	Get(ctx context.Context, userID string, id string) (ResumableUpload, error)
+	Write(ctx context.Context, userID string, id string, offset int64, chunk io.Reader) (ResumableUpload, error)
+	MaxSize() int64
+}
+
+type resumableUploadsService struct {
+	uploadsRepo    ResumableUploadsRepository
+	filesRepo      FilesRepository
+	statusRepo     status.StatusRepository
+	storageService storage.StorageService
+	txManager      database.TxManager
+	processor      FileProcessor
+	config         *configs.AppConfig
+	policy         UploadPolicy
+	contents       contentStore
+}
+
+func NewResumableUploadsService(uploadsRepo ResumableUploadsRepository, filesRepo FilesRepository, statusRepo status.StatusRepository, storageService storage.StorageService, txManager database.TxManager, processor FileProcessor, config *configs.AppConfig) *resumableUploadsService {
+	return &resumableUploadsService{
+		uploadsRepo:    uploadsRepo,
+		filesRepo:      filesRepo,
+		statusRepo:     statusRepo,
+		storageService: storageService,
+		txManager:      txManager,
+		processor:      processor,
+		config:         config,
+		policy:         NewUploadPolicy("files", config.FileUploadPolicy),
+		contents:       newContentStore(filesRepo, storageService, config.StorageKeySecret),
+	}
+}
+
+// MaxSize returns the largest upload accepted, the lowest of TUS_MAX_SIZE and the
+// size limit of the upload policy. Zero means unlimited
+func (s *resumableUploadsService) MaxSize() int64 {
+	maxSize := s.config.TusMaxSize
+	if s.policy.MaxBytes > 0 && (maxSize <= 0 || s.policy.MaxBytes < maxSize) {
+		maxSize = s.policy.MaxBytes
+	}
+	return maxSize
+}
+
+// Create registers a new upload of length bytes owned by userID
+func (s *resumableUploadsService) Create(ctx context.Context, userID string, length int64, fileName string, fileType string) (ResumableUpload, error) {
+	if length < 0 || (s.config.TusMaxSize > 0 && length > s.config.TusMaxSize) {
+		return ResumableUpload{}, ErrUploadTooLarge
+	}
+	if err := s.policy.CheckSize(length); err != nil {
+		return ResumableUpload{}, err
+	}
+	if err := s.policy.CheckName(fileName); err != nil {
+		return ResumableUpload{}, err
+	}
+
+	pending, err := s.statusRepo.GetByName(ctx, "Pending")
+	if err != nil {
+		return ResumableUpload{}, err
+	}
+
+	upload := ResumableUpload{
+		UserUUID:       userID,
+		FileName:       fileName,
+		FileType:       fileType,
+		Length:         length,
+		StatusUUID:     pending.StatusUUID,
+		ExpirationDate: time.Now().Add(s.config.TusUploadTTL),
+	}
+
+	upload.UploadUUID, err = s.uploadsRepo.Create(ctx, upload)
+	if err != nil {
+		return ResumableUpload{}, err
+	}
+
+	// an empty file is complete as soon as it is created
+	if length == 0 {
+		return s.finalize(ctx, upload.UploadUUID)
+	}
+	return upload, nil
+}
+
+// Get returns an upload of userID. Uploads of other users are reported as not found
+func (s *resumableUploadsService) Get(ctx context.Context, userID string, id string) (ResumableUpload, error) {
+	upload, err := s.uploadsRepo.GetByID(ctx, id)
+	if err != nil {
+		return ResumableUpload{}, err
+	}
+	if err := checkUpload(upload, userID); err != nil {
+		return ResumableUpload{}, err
+	}
+	return upload, nil
+}
+
+// checkUpload refuses the uploads of other users and the expired ones
+func checkUpload(upload ResumableUpload, userID string) error {
+	if upload.UserUUID != userID {
+		return ErrResumableUploadNotFound
+	}
+	if !upload.Completed() && time.Now().After(upload.ExpirationDate) {
+		return ErrUploadExpired
+	}
+	return nil
+}
+
+// Write appends chunk to the upload, which must be at the given offset. The chunk
+// is received in a temporary file first, then its part is stored and the offset
+// advanced while the row of the upload is locked, so concurrent chunks are
+// serialized across instances without holding a transaction while a client sends.
+// The received bytes are persisted even when the chunk is interrupted, and the
+// upload is finalized as soon as it is complete. An empty chunk retries a failed
+// finalization
+func (s *resumableUploadsService) Write(ctx context.Context, userID string, id string, offset int64, chunk io.Reader) (ResumableUpload, error) {
+	upload, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return upload, err
+	}
+	if upload.Offset != offset {
+		return upload, ErrUploadOffsetMismatch
+	}
+
+	var staged *os.File
+	var written int64
+	var receiveErr error
+	if !upload.Completed() {
+		staged, written, receiveErr = s.receive(upload, chunk)
+		if staged == nil {
+			return upload, receiveErr
+		}
+		defer os.Remove(staged.Name())
+		defer staged.Close()
+	}
+
+	// the client may be gone, but the received bytes must still be recorded
+	persistCtx := context.WithoutCancel(ctx)
+
+	err = s.txManager.WithinTransaction(persistCtx, func(uow *database.UnitOfWork) error {
+		uploadsRepo := s.uploadsRepo.WithTx(uow.Tx())
+
+		var err error
+		upload, err = uploadsRepo.GetByIDForUpdate(persistCtx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkUpload(upload, userID); err != nil {
+			return err
+		}
+		// another chunk may have been stored while this one was received
+		if upload.Offset != offset {
+			return ErrUploadOffsetMismatch
+		}
+		if upload.Completed() || written == 0 {
+			return nil
+		}
+
+		if err := s.storageService.Put(persistCtx, uploadPartKey(upload.UploadUUID, upload.Offset), staged, "application/octet-stream"); err != nil {
+			return fmt.Errorf("failed to store upload part: %w", err)
+		}
+		if err := uploadsRepo.UpdateOffset(persistCtx, id, upload.Offset, upload.Offset+written); err != nil {
+			return err
+		}
+		upload.Offset += written
+		return nil
+	})
+	if err != nil {
+		return upload, err
+	}
+	if receiveErr != nil {
+		return upload, receiveErr
+	}
+
+	if !upload.Completed() && upload.Offset == upload.Length {
+		return s.finalize(ctx, id)
+	}
+	return upload, nil
+}
+
+// receive copies chunk to a temporary file, rewound for the storage, and returns
+// the bytes copied. The bytes received before the chunk is interrupted or exceeds
+// the length of the upload are kept, along with the error. The caller must remove
+// the file, which is nil when it could not be created or written
+func (s *resumableUploadsService) receive(upload ResumableUpload, chunk io.Reader) (*os.File, int64, error) {
+	staged, err := s.tempFile("chunk-*")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	remaining := upload.Length - upload.Offset
+	written, copyErr := io.Copy(staged, io.LimitReader(chunk, remaining))
+	if copyErr == nil {
+		// a chunk longer than the declared length is refused past the last byte
+		var extra [1]byte
+		if n, _ := chunk.Read(extra[:]); n > 0 {
+			copyErr = ErrUploadTooLarge
+		}
+	}
+
+	if _, err := staged.Seek(0, io.SeekStart); err != nil {
+		staged.Close()
+		os.Remove(staged.Name())
+		return nil, 0, fmt.Errorf("failed to rewind upload chunk: %w", err)
+	}
+	return staged, written, copyErr
+}
+
+// finalize joins the parts of the upload into the stored file and creates its files
+// row. The row of the upload is locked meanwhile, and the stored file, the files row
+// and the completion of the upload succeed or fail together. A file refused by the
+// upload policy is discarded with its upload
+func (s *resumableUploadsService) finalize(ctx context.Context, id string) (ResumableUpload, error) {
+	var upload ResumableUpload
+	var rejected error
+
+	err := s.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		uploadsRepo := s.uploadsRepo.WithTx(uow.Tx())
+
+		var err error
+		upload, err = uploadsRepo.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		// another request may have finalized it while this one waited for the lock
+		if upload.Completed() {
+			return nil
+		}
+
+		staged, err := s.assemble(ctx, upload)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(staged.Name())
+		defer staged.Close()
+
+		inspection, err := s.policy.InspectFile(upload.FileName, staged)
+		if err != nil {
+			var policyErr *UploadPolicyError
+			if errors.As(err, &policyErr) {
+				rejected = err
+				return uploadsRepo.Delete(ctx, upload.UploadUUID)
+			}
+			return err
+		}
+
+		file, err := s.contents.store(ctx, uow, upload.FileName, staged, inspection.MIMEType)
+		if err != nil {
+			return err
+		}
+
+		uploaded, err := s.statusRepo.WithTx(uow.Tx()).GetByName(ctx, "Uploaded")
+		if err != nil {
+			return err
+		}
+		completed, err := s.statusRepo.WithTx(uow.Tx()).GetByName(ctx, "Completed")
+		if err != nil {
+			return err
+		}
+
+		file.StatusUUID = uploaded.StatusUUID
+		file.OwnerUUID = upload.UserUUID
+		fileUUID, err := s.filesRepo.WithTx(uow.Tx()).Create(ctx, FileRequest{File: file})
+		if err != nil {
+			return err
+		}
+
+		if err := uploadsRepo.Complete(ctx, upload.UploadUUID, fileUUID, completed.StatusUUID); err != nil {
+			return err
+		}
+		if err := s.processor.Enqueue(ctx, uow, fileUUID, upload.UserUUID); err != nil {
+			return err
+		}
+
+		upload.FileUUID = &fileUUID
+		upload.StatusUUID = completed.StatusUUID
+		return nil
+	})
+	if err != nil {
+		return upload, err
+	}
+	if rejected != nil {
+		return upload, errors.Join(rejected, removeUploadParts(ctx, s.storageService, upload.UploadUUID))
+	}
+	s.processor.Notify()
+
+	if err := removeUploadParts(ctx, s.storageService, upload.UploadUUID); err != nil {
+		log.Printf("failed to remove the parts of upload %s: %v", upload.UploadUUID, err)
+	}
+	return upload, nil
+}
+
+// assemble joins the parts of the upload into a temporary file. The parts are
+// chained from offset 0, each one starting where the previous one ends, and the
+// upload is refused unless they add up to its length
+func (s *resumableUploadsService) assemble(ctx context.Context, upload ResumableUpload) (*os.File, error) {
+	staged, err := s.tempFile("upload-*")
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := s.joinParts(ctx, staged, upload)
+	if err == nil && size != upload.Length {
+		err = fmt.Errorf("%w: %d of %d bytes stored", ErrUploadIncomplete, size, upload.Length)
+	}
+	if err == nil {
+		_, err = staged.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		staged.Close()
+		os.Remove(staged.Name())
+		return nil, err
+	}
+	return staged, nil
+}
+
+// joinParts copies the parts of the upload to dst and returns the bytes copied
+func (s *resumableUploadsService) joinParts(ctx context.Context, dst io.Writer, upload ResumableUpload) (int64, error) {
+	var size int64
+	for size < upload.Length {
+		part, err := s.storageService.Download(ctx, uploadPartKey(upload.UploadUUID, size))
+		if errors.Is(err, storages.ErrObjectNotFound) {
+			return size, nil
+		}
+		if err != nil {
+			return size, err
+		}
+
+		written, err := io.Copy(dst, part.Body)
+		part.Body.Close()
+		if err != nil {
+			return size, fmt.Errorf("failed to read upload part: %w", err)
+		}
+		if written == 0 {
+			return size, nil
+		}
+		size += written
+	}
+	return size, nil
+}
+
+// tempFile creates a temporary file under TUS_STAGING_PATH. The caller must remove it
+func (s *resumableUploadsService) tempFile(pattern string) (*os.File, error) {
+	if err := os.MkdirAll(s.config.TusStagingPath, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create staging folder: %w", err)
+	}
+
+	file, err := os.CreateTemp(s.config.TusStagingPath, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staged upload: %w", err)
+	}
+	return file, nil
+}
+
+// uploadPartKey returns the key of the part of an upload starting at offset. The
+// offset is zero padded so the parts are listed in order
+func uploadPartKey(id string, offset int64) string {
+	return fmt.Sprintf("%s%020d", uploadPartsPrefix(id), offset)
+}
+
+func uploadPartsPrefix(id string) string {
+	return uploadsPrefix + path.Base(id) + "/"
+}
+
+// removeUploadParts deletes every part stored for an upload
+func removeUploadParts(ctx context.Context, storageService storage.StorageService, id string) error {
+	parts, err := storageService.List(ctx, uploadPartsPrefix(id))
+	if err != nil {
+		return err
+	}
+
+	for _, part := range parts {
+		if err := storageService.Delete(ctx, part.Key); err != nil && !errors.Is(err, storages.ErrObjectNotFound) {
+			return err
+		}
+	}
+	return nil
+}
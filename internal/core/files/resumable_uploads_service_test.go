@@ -0,0 +1,192 @@
+package files
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/core/storage"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/providers/scanners"
+	"bernardtm/backend/pkg/providers/storages"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testUser = "0192d1a4-0000-7000-8000-0000000000aa"
+
+func newTestResumableUploadsService(t *testing.T) (*resumableUploadsService, *testRepositories, storages.StorageProvider, string) {
+	staging := t.TempDir()
+	repos := newTestRepositories()
+	provider := storages.NewMemoryStorageProvider()
+	processor, _ := newTestFileProcessor(repos, provider, scanners.NewFakeScannerProvider())
+	service := NewResumableUploadsService(
+		repos.uploads,
+		repos.files,
+		fakeStatusRepository{},
+		storage.NewStorageService(provider),
+		database.NewFakeTxManager(),
+		processor,
+		&configs.AppConfig{TusStagingPath: staging, TusMaxSize: 1024, TusUploadTTL: time.Hour},
+	)
+	return service, repos, provider, staging
+}
+
+// pendingUpload returns upload-1, an upload of 11 bytes of testUser received up to offset
+func pendingUpload(offset int64) ResumableUpload {
+	return ResumableUpload{
+		UploadUUID:     "upload-1",
+		UserUUID:       testUser,
+		FileName:       "report.pdf",
+		FileType:       "application/pdf",
+		Length:         11,
+		Offset:         offset,
+		StatusUUID:     "Pending-uuid",
+		ExpirationDate: time.Now().Add(time.Hour),
+	}
+}
+
+// interruptedReader returns its content and then fails, like a dropped connection
+type interruptedReader struct {
+	io.Reader
+}
+
+func (r interruptedReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func TestResumableUploadsService_ResumesAndFinalizes(t *testing.T) {
+	service, repos, provider, staging := newTestResumableUploadsService(t)
+	ctx := database.WithTenant(context.Background(), testTenant)
+
+	upload, err := service.Create(ctx, testUser, 11, "report.pdf", "application/pdf")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "Pending-uuid", upload.StatusUUID)
+	id := upload.UploadUUID
+
+	// the first chunk is interrupted after 6 bytes, which are still persisted
+	upload, err = service.Write(ctx, testUser, id, 0, interruptedReader{strings.NewReader("%PDF-1")})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, int64(6), upload.Offset)
+	stored, _ := repos.uploads.GetByID(ctx, id)
+	assert.Equal(t, int64(6), stored.Offset)
+
+	// a chunk resent from the stale offset is refused
+	_, err = service.Write(ctx, testUser, id, 0, strings.NewReader("%PDF-1.7 ok"))
+	assert.ErrorIs(t, err, ErrUploadOffsetMismatch)
+
+	// the last chunk completes the upload and creates the file
+	upload, err = service.Write(ctx, testUser, id, 6, strings.NewReader(".7 ok"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), upload.Offset)
+	assert.Equal(t, "Completed-uuid", upload.StatusUUID)
+	if assert.NotNil(t, upload.FileUUID) {
+		file := repos.files.get(*upload.FileUUID)
+		assert.Equal(t, "report.pdf", file.OriginalName)
+		assert.Equal(t, testUser, file.OwnerUUID)
+		assert.Equal(t, "Uploaded-uuid", file.StatusUUID)
+		assert.Len(t, repos.jobs.jobs, 1, "the processing of the file is scheduled")
+	}
+
+	objects, _ := provider.List(ctx, "blobs/")
+	if assert.Len(t, objects, 1) {
+		object, err := provider.Download(ctx, objects[0].Key)
+		assert.NoError(t, err)
+		content, _ := io.ReadAll(object.Body)
+		assert.Equal(t, "%PDF-1.7 ok", string(content))
+	}
+	parts, _ := provider.List(ctx, uploadsPrefix)
+	assert.Empty(t, parts)
+	staged, _ := os.ReadDir(staging)
+	assert.Empty(t, staged)
+}
+
+func TestResumableUploadsService_RefusesIncompleteUpload(t *testing.T) {
+	service, repos, provider, _ := newTestResumableUploadsService(t)
+	ctx := database.WithTenant(context.Background(), testTenant)
+
+	// every byte is recorded, but the last part never reached the storage
+	repos.uploads.add(pendingUpload(11))
+	provider.Put(ctx, uploadPartKey("upload-1", 0), strings.NewReader("%PDF-1"), "application/octet-stream")
+
+	_, err := service.Write(ctx, testUser, "upload-1", 11, strings.NewReader(""))
+
+	assert.ErrorIs(t, err, ErrUploadIncomplete)
+	objects, _ := provider.List(ctx, "blobs/")
+	assert.Empty(t, objects)
+	upload, _ := repos.uploads.GetByID(ctx, "upload-1")
+	assert.False(t, upload.Completed())
+	assert.Empty(t, repos.files.files)
+}
+
+func TestResumableUploadsService_HidesUploadsOfOtherUsers(t *testing.T) {
+	service, repos, _, _ := newTestResumableUploadsService(t)
+	ctx := database.WithTenant(context.Background(), testTenant)
+	repos.uploads.add(pendingUpload(0))
+
+	_, err := service.Get(ctx, "another-user", "upload-1")
+	assert.ErrorIs(t, err, ErrResumableUploadNotFound)
+
+	_, err = service.Write(ctx, "another-user", "upload-1", 0, strings.NewReader("%PDF-1.7 ok"))
+	assert.ErrorIs(t, err, ErrResumableUploadNotFound)
+	upload, _ := repos.uploads.GetByID(ctx, "upload-1")
+	assert.Equal(t, int64(0), upload.Offset)
+}
+
+// racingReader runs race before returning its content, like another request
+// storing a chunk at the same offset while this one is received
+type racingReader struct {
+	io.Reader
+	race func()
+}
+
+func (r *racingReader) Read(b []byte) (int, error) {
+	if r.race != nil {
+		r.race()
+		r.race = nil
+	}
+	return r.Reader.Read(b)
+}
+
+func TestResumableUploadsService_RefusesChunkRacedByAnother(t *testing.T) {
+	service, repos, provider, staging := newTestResumableUploadsService(t)
+	ctx := database.WithTenant(context.Background(), testTenant)
+	repos.uploads.add(pendingUpload(0))
+
+	chunk := &racingReader{Reader: strings.NewReader("%PDF-1.7 ok"), race: func() {
+		provider.Put(ctx, uploadPartKey("upload-1", 0), strings.NewReader("%PDF-1"), "application/octet-stream")
+		repos.uploads.UpdateOffset(ctx, "upload-1", 0, 6)
+	}}
+
+	_, err := service.Write(ctx, testUser, "upload-1", 0, chunk)
+
+	assert.ErrorIs(t, err, ErrUploadOffsetMismatch)
+	upload, _ := repos.uploads.GetByID(ctx, "upload-1")
+	assert.Equal(t, int64(6), upload.Offset)
+	part, err := provider.Download(ctx, uploadPartKey("upload-1", 0))
+	if assert.NoError(t, err) {
+		content, _ := io.ReadAll(part.Body)
+		assert.Equal(t, "%PDF-1", string(content), "the part of the other chunk is kept")
+	}
+	staged, _ := os.ReadDir(staging)
+	assert.Empty(t, staged)
+}
+
+func TestParseMetadata(t *testing.T) {
+	metadata, err := parseMetadata("filename cmVwb3J0LnBkZg==,filetype YXBwbGljYXRpb24vcGRm,is_confidential")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"filename": "report.pdf", "filetype": "application/pdf", "is_confidential": ""}, metadata)
+
+	_, err = parseMetadata("filename not-base64!")
+	assert.Error(t, err)
+}
@@ -0,0 +1,427 @@
+package files
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/core/storage"
+	"bernardtm/backend/internal/core/tenants"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/providers/storages"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrLifecycleRunning is returned when a run of the storage lifecycle is requested
+// while another one is in progress
+var ErrLifecycleRunning = errors.New("storage lifecycle already running")
+
+const (
+	// quarantinePrefix is where orphans are moved to until the quarantine expires
+	quarantinePrefix = "quarantine/"
+	// thumbnailPrefix is where the thumbnails of the objects are stored
+	thumbnailPrefix = "thumbnails/"
+	// reconcileBatchSize bounds the keys looked up and the files expired at once
+	reconcileBatchSize = 500
+)
+
+// LifecycleReport summarizes a run of the storage lifecycle
+type LifecycleReport struct {
+	Scanned     int `json:"scanned"`     // Objects listed in the storage
+	Orphans     int `json:"orphans"`     // Objects no file or file version references
+	Quarantined int `json:"quarantined"` // Orphans moved to the quarantine
+	Deleted     int `json:"deleted"`     // Orphans deleted
+	Purged      int `json:"purged"`      // Quarantined objects deleted once expired
+	Expired     int `json:"expired"`     // Files removed by the retention policies
+	Uploads     int `json:"uploads"`     // Expired resumable uploads removed with their parts
+}
+
+// StorageLifecycle reconciles the storage with the database: objects left behind by
+// failed uploads or deleted rows are quarantined or deleted, the files of the
+// folders with a retention policy are removed once they expire and so are the
+// resumable uploads
+type StorageLifecycle interface {
+	Run(ctx context.Context) (LifecycleReport, error)
+	Start(ctx context.Context)
+	Close()
+}
+
+type storageLifecycle struct {
+	tenantsRepo    tenants.TenantsRepository
+	filesRepo      FilesRepository
+	versionsRepo   FileVersionsRepository
+	uploadsRepo    ResumableUploadsRepository
+	storageService storage.StorageService
+	txManager      database.TxManager
+	contents       contentStore
+	interval       time.Duration
+	gracePeriod    time.Duration
+	action         string
+	quarantineTTL  time.Duration
+	retention      map[string]time.Duration
+	exclude        []string
+	running        sync.Mutex
+	stop           context.CancelFunc
+	done           sync.WaitGroup
+}
+
+func NewStorageLifecycle(tenantsRepo tenants.TenantsRepository, filesRepo FilesRepository, versionsRepo FileVersionsRepository, uploadsRepo ResumableUploadsRepository, storageService storage.StorageService, txManager database.TxManager, config *configs.AppConfig) *storageLifecycle {
+	return &storageLifecycle{
+		tenantsRepo:    tenantsRepo,
+		filesRepo:      filesRepo,
+		versionsRepo:   versionsRepo,
+		uploadsRepo:    uploadsRepo,
+		storageService: storageService,
+		txManager:      txManager,
+		contents:       newContentStore(filesRepo, storageService, config.StorageKeySecret),
+		interval:       config.LifecycleInterval,
+		gracePeriod:    config.OrphanGracePeriod,
+		action:         config.OrphanAction,
+		quarantineTTL:  config.QuarantineTTL,
+		retention:      config.RetentionPolicies,
+		exclude:        config.ReconcileExclude,
+	}
+}
+
+// Start runs the lifecycle right away and then at every interval until Close. A
+// zero interval disables the scheduled runs
+func (l *storageLifecycle) Start(ctx context.Context) {
+	if l.interval <= 0 {
+		return
+	}
+	ctx, l.stop = context.WithCancel(ctx)
+
+	l.done.Add(1)
+	go func() {
+		defer l.done.Done()
+
+		ticker := time.NewTicker(l.interval)
+		defer ticker.Stop()
+
+		for {
+			report, err := l.Run(ctx)
+			if err != nil && !errors.Is(err, ErrLifecycleRunning) && ctx.Err() == nil {
+				log.Printf("storage lifecycle failed: %v", err)
+			} else if err == nil {
+				log.Printf("storage lifecycle: %+v", report)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close stops the scheduled runs and waits for the running one to return
+func (l *storageLifecycle) Close() {
+	if l.stop != nil {
+		l.stop()
+		l.done.Wait()
+	}
+}
+
+// Run reconciles the storage once: it applies the retention policies, removes the
+// expired uploads, handles the orphans and purges the expired quarantine
+func (l *storageLifecycle) Run(ctx context.Context) (LifecycleReport, error) {
+	if !l.running.TryLock() {
+		return LifecycleReport{}, ErrLifecycleRunning
+	}
+	defer l.running.Unlock()
+
+	var report LifecycleReport
+
+	tenantIDs, err := l.tenantIDs(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	// expiring first lets the orphans left by a failed removal be collected below
+	if err := l.expire(ctx, tenantIDs, &report); err != nil {
+		return report, err
+	}
+	if err := l.purgeUploads(ctx, tenantIDs, &report); err != nil {
+		return report, err
+	}
+	if err := l.reconcile(ctx, tenantIDs, &report); err != nil {
+		return report, err
+	}
+	if err := l.purgeQuarantine(ctx, &report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+func (l *storageLifecycle) tenantIDs(ctx context.Context) ([]string, error) {
+	all, err := l.tenantsRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(all))
+	for _, tenant := range all {
+		ids = append(ids, tenant.TenantUUID)
+	}
+	return ids, nil
+}
+
+// expire removes the files of the folders with a retention policy created before
+// their retention, with their versions and the objects only they referenced
+func (l *storageLifecycle) expire(ctx context.Context, tenantIDs []string, report *LifecycleReport) error {
+	now := time.Now()
+
+	for _, tenantID := range tenantIDs {
+		tenantCtx := database.WithTenant(ctx, tenantID)
+
+		for folder, retention := range l.retention {
+			for {
+				expired, err := l.filesRepo.GetExpired(tenantCtx, folder, now.Add(-retention), reconcileBatchSize)
+				if err != nil {
+					return err
+				}
+
+				for _, file := range expired {
+					err := l.txManager.WithinTransaction(tenantCtx, func(uow *database.UnitOfWork) error {
+						versions, err := l.versionsRepo.WithTx(uow.Tx()).GetByFile(tenantCtx, file.FileUUID)
+						if err != nil {
+							return err
+						}
+						return l.contents.release(tenantCtx, uow, file, versions)
+					})
+					if err != nil {
+						return fmt.Errorf("failed to expire file %s of tenant %s: %w", file.FileUUID, tenantID, err)
+					}
+					report.Expired++
+				}
+
+				if len(expired) < reconcileBatchSize {
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// purgeUploads removes the resumable uploads past their expiration date. Their parts
+// are deleted before their row, so a failed removal is retried by the next run
+func (l *storageLifecycle) purgeUploads(ctx context.Context, tenantIDs []string, report *LifecycleReport) error {
+	now := time.Now()
+
+	for _, tenantID := range tenantIDs {
+		tenantCtx := database.WithTenant(ctx, tenantID)
+
+		for {
+			expired, err := l.uploadsRepo.GetExpiredIDs(tenantCtx, now, reconcileBatchSize)
+			if err != nil {
+				return err
+			}
+
+			for _, id := range expired {
+				if err := removeUploadParts(tenantCtx, l.storageService, id); err != nil {
+					return fmt.Errorf("failed to remove the parts of upload %s of tenant %s: %w", id, tenantID, err)
+				}
+				if err := l.uploadsRepo.Delete(tenantCtx, id); err != nil && !errors.Is(err, ErrResumableUploadNotFound) {
+					return err
+				}
+				report.Uploads++
+			}
+
+			if len(expired) < reconcileBatchSize {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// reconcile finds the objects older than the grace period no file or file version
+// of any tenant references, and quarantines or deletes them. Thumbnails follow the
+// object they were generated from
+func (l *storageLifecycle) reconcile(ctx context.Context, tenantIDs []string, report *LifecycleReport) error {
+	objects, err := l.storageService.List(ctx, "")
+	if err != nil {
+		return err
+	}
+	report.Scanned = len(objects)
+
+	cutoff := time.Now().Add(-l.gracePeriod)
+	var candidates []storages.ObjectInfo
+	for _, object := range objects {
+		if l.excluded(object.Key) || object.LastModified.After(cutoff) {
+			continue
+		}
+		candidates = append(candidates, object)
+	}
+
+	for start := 0; start < len(candidates); start += reconcileBatchSize {
+		batch := candidates[start:min(start+reconcileBatchSize, len(candidates))]
+
+		orphans, err := l.orphans(ctx, tenantIDs, batch)
+		if err != nil {
+			return err
+		}
+		for _, object := range orphans {
+			if err := l.collect(ctx, object, cutoff, report); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// excluded reports whether the object of key is not tracked by the files table. The
+// parts of the uploads are removed with their upload
+func (l *storageLifecycle) excluded(key string) bool {
+	if strings.HasPrefix(key, quarantinePrefix) || strings.HasPrefix(key, uploadsPrefix) {
+		return true
+	}
+	for _, prefix := range l.exclude {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// orphans returns the objects of the batch whose key, or source key for the
+// thumbnails, is referenced by no tenant
+func (l *storageLifecycle) orphans(ctx context.Context, tenantIDs []string, batch []storages.ObjectInfo) ([]storages.ObjectInfo, error) {
+	unreferenced := map[string]bool{}
+	for _, object := range batch {
+		unreferenced[sourceKey(object.Key)] = true
+	}
+
+	for _, tenantID := range tenantIDs {
+		keys := make([]string, 0, len(unreferenced))
+		for key := range unreferenced {
+			keys = append(keys, key)
+		}
+		if len(keys) == 0 {
+			break
+		}
+
+		referenced, err := l.filesRepo.ReferencedKeys(database.WithTenant(ctx, tenantID), keys)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range referenced {
+			delete(unreferenced, key)
+		}
+	}
+
+	var orphans []storages.ObjectInfo
+	for _, object := range batch {
+		if unreferenced[sourceKey(object.Key)] {
+			orphans = append(orphans, object)
+		}
+	}
+	return orphans, nil
+}
+
+// collect quarantines or deletes an orphan. The key is locked like the content store
+// does, and an orphan stored again since it was listed, as identical content being
+// uploaded, is kept: it is referenced by the file being created
+func (l *storageLifecycle) collect(ctx context.Context, object storages.ObjectInfo, cutoff time.Time, report *LifecycleReport) error {
+	return l.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		if err := l.filesRepo.WithTx(uow.Tx()).LockKey(ctx, sourceKey(object.Key)); err != nil {
+			return err
+		}
+
+		info, err := l.storageService.Stat(ctx, object.Key)
+		if errors.Is(err, storages.ErrObjectNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.LastModified.After(cutoff) {
+			return nil
+		}
+		report.Orphans++
+
+		if l.action == "delete" {
+			if err := l.storageService.Delete(ctx, object.Key); err != nil && !errors.Is(err, storages.ErrObjectNotFound) {
+				return err
+			}
+			report.Deleted++
+			return nil
+		}
+
+		if err := l.quarantine(ctx, info); err != nil {
+			return fmt.Errorf("failed to quarantine %s: %w", object.Key, err)
+		}
+		report.Quarantined++
+		return nil
+	})
+}
+
+// quarantine moves an object under the quarantine prefix, where it is kept until
+// the quarantine expires. The copy is staged in a temporary file, as the storage
+// needs a seekable body
+func (l *storageLifecycle) quarantine(ctx context.Context, info storages.ObjectInfo) error {
+	object, err := l.storageService.Download(ctx, info.Key)
+	if err != nil {
+		return err
+	}
+	defer object.Body.Close()
+
+	staged, err := os.CreateTemp("", "quarantine-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(staged.Name())
+	defer staged.Close()
+
+	if _, err := io.Copy(staged, object.Body); err != nil {
+		return err
+	}
+	if _, err := staged.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err := l.storageService.Put(ctx, quarantinePrefix+info.Key, staged, info.ContentType); err != nil {
+		return err
+	}
+	if err := l.storageService.Delete(ctx, info.Key); err != nil && !errors.Is(err, storages.ErrObjectNotFound) {
+		return err
+	}
+	return nil
+}
+
+// purgeQuarantine deletes the quarantined objects once the quarantine expires
+func (l *storageLifecycle) purgeQuarantine(ctx context.Context, report *LifecycleReport) error {
+	objects, err := l.storageService.List(ctx, quarantinePrefix)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-l.quarantineTTL)
+	for _, object := range objects {
+		if object.LastModified.After(cutoff) {
+			continue
+		}
+		if err := l.storageService.Delete(ctx, object.Key); err != nil && !errors.Is(err, storages.ErrObjectNotFound) {
+			return err
+		}
+		report.Purged++
+	}
+	return nil
+}
+
+// sourceKey returns the key of the object a thumbnail was generated from, and any
+// other key as is
+func sourceKey(key string) string {
+	if strings.HasPrefix(key, thumbnailPrefix) && path.Ext(key) == ".webp" {
+		return strings.TrimSuffix(strings.TrimPrefix(key, thumbnailPrefix), ".webp")
+	}
+	return key
+}
@@ -0,0 +1,46 @@
+package files
+
+import (
+	"bernardtm/backend/internal/core/shareds"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StorageLifecycleController interface {
+	Reconcile(ctx *gin.Context)
+}
+
+type storageLifecycleController struct {
+	lifecycle StorageLifecycle
+}
+
+func NewStorageLifecycleController(lifecycle StorageLifecycle) *storageLifecycleController {
+	return &storageLifecycleController{lifecycle: lifecycle}
+}
+
+// Reconcile runs the storage lifecycle right away
+// @Summary Reconcile the storage
+// @Description Applies the retention policies, quarantines or deletes the objects no file references and purges the expired quarantine
+// @Tags Storage
+// @Produce json
+// @Security AdminKey
+// @Success 200 {object} LifecycleReport
+// @Failure 401 {object} shareds.ErrorResponse
+// @Failure 409 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /storage/reconcile [post]
+func (lc *storageLifecycleController) Reconcile(c *gin.Context) {
+	report, err := lc.lifecycle.Run(c.Request.Context())
+	if errors.Is(err, ErrLifecycleRunning) {
+		c.JSON(http.StatusConflict, shareds.ErrorResponse{Message: "Storage reconciliation already running"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error reconciling storage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
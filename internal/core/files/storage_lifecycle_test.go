@@ -0,0 +1,159 @@
+package files
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/core/storage"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/providers/storages"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStorageLifecycle(action string) (*storageLifecycle, *testRepositories, storages.StorageProvider) {
+	repos := newTestRepositories()
+	provider := storages.NewMemoryStorageProvider()
+	lifecycle := NewStorageLifecycle(
+		fakeTenantsRepository{ids: []string{testTenant}},
+		repos.files,
+		repos.versions,
+		repos.uploads,
+		storage.NewStorageService(provider),
+		database.NewFakeTxManager(),
+		&configs.AppConfig{OrphanAction: action, QuarantineTTL: time.Hour, ReconcileExclude: []string{"avatars/"}},
+	)
+	return lifecycle, repos, provider
+}
+
+func putObjects(ctx context.Context, provider storages.StorageProvider, keys ...string) {
+	for _, key := range keys {
+		provider.Put(ctx, key, strings.NewReader(key), "application/octet-stream")
+	}
+}
+
+// objectKeys returns the keys of every stored object
+func objectKeys(ctx context.Context, provider storages.StorageProvider) []string {
+	objects, _ := provider.List(ctx, "")
+	keys := []string{}
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	return keys
+}
+
+func TestStorageLifecycle_HandlesOrphans(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		report LifecycleReport
+		kept   []string
+	}{
+		{
+			name:   "quarantine",
+			action: "quarantine",
+			report: LifecycleReport{Scanned: 5, Orphans: 2, Quarantined: 2},
+			kept:   []string{"quarantine/blobs/orphan", "quarantine/" + thumbnailKey("blobs/orphan")},
+		},
+		{
+			name:   "delete",
+			action: "delete",
+			report: LifecycleReport{Scanned: 5, Orphans: 2, Deleted: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lifecycle, repos, provider := newTestStorageLifecycle(tt.action)
+			ctx := database.WithTenant(context.Background(), testTenant)
+			putObjects(ctx, provider, "blobs/kept", thumbnailKey("blobs/kept"), "blobs/orphan", thumbnailKey("blobs/orphan"), "avatars/user/64.webp")
+			repos.files.add(storedFile("blobs/kept", testUser))
+
+			report, err := lifecycle.Run(context.Background())
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.report, report)
+			kept := append([]string{"avatars/user/64.webp", "blobs/kept", thumbnailKey("blobs/kept")}, tt.kept...)
+			assert.ElementsMatch(t, kept, objectKeys(ctx, provider))
+		})
+	}
+}
+
+func TestStorageLifecycle_KeepsObjectsOfVersions(t *testing.T) {
+	lifecycle, repos, provider := newTestStorageLifecycle("delete")
+	ctx := database.WithTenant(context.Background(), testTenant)
+	putObjects(ctx, provider, "blobs/current", "blobs/previous")
+	repos.files.add(storedFile("blobs/current", testUser))
+	repos.versions.Create(ctx, storedFile("blobs/previous", testUser), testUser)
+
+	report, err := lifecycle.Run(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, LifecycleReport{Scanned: 2}, report)
+	assert.ElementsMatch(t, []string{"blobs/current", "blobs/previous"}, objectKeys(ctx, provider))
+}
+
+func TestStorageLifecycle_KeepsRecentObjects(t *testing.T) {
+	lifecycle, _, provider := newTestStorageLifecycle("delete")
+	lifecycle.gracePeriod = time.Hour
+	ctx := context.Background()
+	putObjects(ctx, provider, "blobs/uploading")
+
+	report, err := lifecycle.Run(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, LifecycleReport{Scanned: 1}, report)
+	_, err = provider.Stat(ctx, "blobs/uploading")
+	assert.NoError(t, err, "objects younger than the grace period may be uploads in progress")
+}
+
+func TestStorageLifecycle_ExpiresFiles(t *testing.T) {
+	lifecycle, repos, provider := newTestStorageLifecycle("delete")
+	lifecycle.retention = map[string]time.Duration{"pdf": time.Hour}
+	ctx := database.WithTenant(context.Background(), testTenant)
+	putObjects(ctx, provider, "blobs/old", "blobs/recent")
+
+	old := storedFile("blobs/old", testUser)
+	old.CreationDate = time.Now().Add(-2 * time.Hour)
+	repos.files.add(old)
+	recent := storedFile("blobs/recent", testUser)
+	recent.FileUUID = "file-2"
+	repos.files.add(recent)
+
+	report, err := lifecycle.Run(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, LifecycleReport{Scanned: 1, Expired: 1}, report)
+	_, err = repos.files.GetByID(ctx, "file-1")
+	assert.ErrorIs(t, err, ErrFileNotFound)
+	assert.Equal(t, []string{"blobs/recent"}, objectKeys(ctx, provider))
+}
+
+func TestStorageLifecycle_RemovesExpiredUploads(t *testing.T) {
+	lifecycle, repos, provider := newTestStorageLifecycle("delete")
+	ctx := database.WithTenant(context.Background(), testTenant)
+	putObjects(ctx, provider, uploadPartKey("upload-1", 0), uploadPartKey("upload-1", 6), uploadPartKey("upload-2", 0))
+
+	expired := pendingUpload(6)
+	expired.ExpirationDate = time.Now().Add(-time.Minute)
+	repos.uploads.add(expired)
+	inProgress := pendingUpload(0)
+	inProgress.UploadUUID = "upload-2"
+	repos.uploads.add(inProgress)
+
+	report, err := lifecycle.Run(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, LifecycleReport{Scanned: 1, Uploads: 1}, report)
+	_, err = repos.uploads.GetByID(ctx, "upload-1")
+	assert.ErrorIs(t, err, ErrResumableUploadNotFound)
+	// the parts of the uploads in progress are not orphans
+	assert.Equal(t, []string{uploadPartKey("upload-2", 0)}, objectKeys(ctx, provider))
+}
+
+func TestSourceKey(t *testing.T) {
+	assert.Equal(t, "blobs/ab/cd/abcd", sourceKey(thumbnailKey("blobs/ab/cd/abcd")))
+	assert.Equal(t, "blobs/ab/cd/abcd", sourceKey("blobs/ab/cd/abcd"))
+}
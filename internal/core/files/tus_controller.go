@@ -0,0 +1,240 @@
+package files
+
+import (
+	"bernardtm/backend/internal/core/shareds"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TusVersion is the version of the tus protocol implemented by TusController
+const TusVersion = "1.0.0"
+
+// tusOffsetContentType is the content type of every PATCH request carrying a chunk
+const tusOffsetContentType = "application/offset+octet-stream"
+
+// TusController implements the core and creation extensions of the tus 1.0
+// resumable upload protocol (https://tus.io/protocols/resumable-upload)
+type TusController interface {
+	Options(c *gin.Context)
+	Create(c *gin.Context)
+	Head(c *gin.Context)
+	Patch(c *gin.Context)
+}
+
+type tusController struct {
+	service      ResumableUploadsService
+	chunkTimeout time.Duration
+}
+
+func NewTusController(service ResumableUploadsService, chunkTimeout time.Duration) *tusController {
+	return &tusController{
+		service:      service,
+		chunkTimeout: chunkTimeout,
+	}
+}
+
+// checkVersion answers 412 to clients speaking another version of the protocol
+func checkVersion(c *gin.Context) bool {
+	c.Header("Tus-Resumable", TusVersion)
+	if c.GetHeader("Tus-Resumable") != TusVersion {
+		c.Header("Tus-Version", TusVersion)
+		c.JSON(http.StatusPreconditionFailed, shareds.ErrorResponse{Message: "Unsupported tus version"})
+		return false
+	}
+	return true
+}
+
+// parseMetadata decodes an Upload-Metadata header: comma separated pairs of a
+// key and an optional base64 encoded value
+func parseMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty metadata key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+func (uc *tusController) writeError(c *gin.Context, err error) {
+	if WriteUploadPolicyError(c, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, ErrResumableUploadNotFound):
+		c.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Upload not found"})
+	case errors.Is(err, ErrUploadExpired):
+		c.JSON(http.StatusGone, shareds.ErrorResponse{Message: "Upload expired"})
+	case errors.Is(err, ErrUploadOffsetMismatch):
+		c.JSON(http.StatusConflict, shareds.ErrorResponse{Message: "Upload-Offset does not match the upload offset"})
+	case errors.Is(err, ErrUploadTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, shareds.ErrorResponse{Message: "Upload exceeds its length or the maximum size"})
+	default:
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error uploading file"})
+	}
+}
+
+// Options describes the tus capabilities of the server
+// @Summary Discover the tus upload capabilities
+// @Tags Files
+// @Success 204
+// @Router /files/tus [options]
+func (uc *tusController) Options(c *gin.Context) {
+	c.Header("Tus-Resumable", TusVersion)
+	c.Header("Tus-Version", TusVersion)
+	c.Header("Tus-Extension", "creation")
+	if maxSize := uc.service.MaxSize(); maxSize > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(maxSize, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Create starts a resumable upload
+// @Summary Create a resumable upload
+// @Description Creates a tus upload of Upload-Length bytes. Upload-Metadata carries the base64 encoded filename and filetype. The Location header is the URL the chunks are sent to
+// @Tags Files
+// @Security BearerAuth
+// @Param Tus-Resumable header string true "tus version" default(1.0.0)
+// @Param Upload-Length header int true "Size of the file in bytes"
+// @Param Upload-Metadata header string false "Metadata of the file"
+// @Success 201
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 412 {object} shareds.ErrorResponse
+// @Failure 413 {object} shareds.ApiError
+// @Failure 415 {object} shareds.ApiError
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/tus [post]
+func (uc *tusController) Create(c *gin.Context) {
+	if !checkVersion(c) {
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid Upload-Length"})
+		return
+	}
+
+	metadata, err := parseMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil || metadata["filename"] == "" {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Upload-Metadata must carry the filename"})
+		return
+	}
+
+	upload, err := uc.service.Create(c.Request.Context(), c.GetString("ID"), length, metadata["filename"], metadata["filetype"])
+	if err != nil {
+		uc.writeError(c, err)
+		return
+	}
+
+	c.Header("Location", strings.TrimRight(c.Request.URL.Path, "/")+"/"+upload.UploadUUID)
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if upload.FileUUID != nil {
+		c.Header("X-File-ID", *upload.FileUUID)
+	}
+	c.Status(http.StatusCreated)
+}
+
+// Head returns the offset of a resumable upload
+// @Summary Get the offset of a resumable upload
+// @Tags Files
+// @Security BearerAuth
+// @Param Tus-Resumable header string true "tus version" default(1.0.0)
+// @Param id path string true "ID of the upload"
+// @Success 200
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 410 {object} shareds.ErrorResponse
+// @Failure 412 {object} shareds.ErrorResponse
+// @Router /files/tus/{id} [head]
+func (uc *tusController) Head(c *gin.Context) {
+	if !checkVersion(c) {
+		return
+	}
+
+	upload, err := uc.service.Get(c.Request.Context(), c.GetString("ID"), c.Param("id"))
+	if err != nil {
+		uc.writeError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if upload.FileUUID != nil {
+		c.Header("X-File-ID", *upload.FileUUID)
+	}
+	c.Status(http.StatusOK)
+}
+
+// Patch appends a chunk to a resumable upload
+// @Summary Upload a chunk of a resumable upload
+// @Description Appends the body at Upload-Offset, which must match the current offset of the upload. The file is created once the last byte is received and its ID returned in X-File-ID
+// @Tags Files
+// @Accept application/offset+octet-stream
+// @Security BearerAuth
+// @Param Tus-Resumable header string true "tus version" default(1.0.0)
+// @Param Upload-Offset header int true "Offset of the chunk"
+// @Param id path string true "ID of the upload"
+// @Success 204
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 409 {object} shareds.ErrorResponse
+// @Failure 410 {object} shareds.ErrorResponse
+// @Failure 412 {object} shareds.ErrorResponse
+// @Failure 413 {object} shareds.ErrorResponse
+// @Failure 415 {object} shareds.ErrorResponse
+// @Failure 422 {object} shareds.ApiError
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /files/tus/{id} [patch]
+func (uc *tusController) Patch(c *gin.Context) {
+	if !checkVersion(c) {
+		return
+	}
+
+	if c.ContentType() != tusOffsetContentType {
+		c.JSON(http.StatusUnsupportedMediaType, shareds.ErrorResponse{Message: "Content-Type must be " + tusOffsetContentType})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid Upload-Offset"})
+		return
+	}
+
+	// a chunk may take longer than the server timeouts on slow connections
+	if uc.chunkTimeout > 0 {
+		deadline := time.Now().Add(uc.chunkTimeout)
+		controller := http.NewResponseController(c.Writer)
+		_ = controller.SetReadDeadline(deadline)
+		_ = controller.SetWriteDeadline(deadline)
+	}
+
+	upload, err := uc.service.Write(c.Request.Context(), c.GetString("ID"), c.Param("id"), offset, c.Request.Body)
+	if err != nil {
+		uc.writeError(c, err)
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if upload.FileUUID != nil {
+		c.Header("X-File-ID", *upload.FileUUID)
+	}
+	c.Status(http.StatusNoContent)
+}
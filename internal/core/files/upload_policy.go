@@ -0,0 +1,227 @@
+package files
+
+import (
+	"bernardtm/backend/configs"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // GIF dimensions
+	_ "image/jpeg" // JPEG dimensions
+	_ "image/png"  // PNG dimensions
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// sniffLength is the number of leading bytes inspected to detect the MIME type
+const sniffLength = 3072
+
+// UploadPolicyError describes why a file was refused by an upload policy
+type UploadPolicyError struct {
+	Status  int                    // HTTP status answered to the client
+	Reason  string                 // Machine readable reason, e.g. mime_type_not_allowed
+	Message string                 // Human readable message
+	Details map[string]interface{} // Limits and detected values
+}
+
+func (e *UploadPolicyError) Error() string {
+	return e.Message
+}
+
+// Inspection is what an upload policy learned about an accepted file
+type Inspection struct {
+	Size      int64
+	Extension string
+	MIMEType  string
+	Width     int
+	Height    int
+}
+
+// UploadPolicy limits the files accepted for one purpose, such as the files of
+// the files module or the avatars of the users
+type UploadPolicy struct {
+	Purpose string
+	configs.UploadPolicyConfig
+}
+
+func NewUploadPolicy(purpose string, config configs.UploadPolicyConfig) UploadPolicy {
+	return UploadPolicy{Purpose: purpose, UploadPolicyConfig: config}
+}
+
+// CheckSize refuses files larger than MaxBytes
+func (p UploadPolicy) CheckSize(size int64) error {
+	if p.MaxBytes > 0 && size > p.MaxBytes {
+		return &UploadPolicyError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Reason:  "file_too_large",
+			Message: fmt.Sprintf("File exceeds the maximum size of %d bytes", p.MaxBytes),
+			Details: map[string]interface{}{"max_bytes": p.MaxBytes, "size": size},
+		}
+	}
+	return nil
+}
+
+// CheckName refuses file names whose extension is not allowed
+func (p UploadPolicy) CheckName(fileName string) error {
+	extension := fileExtension(fileName)
+	if len(p.Extensions) > 0 && !slices.ContainsFunc(p.Extensions, func(allowed string) bool {
+		return strings.EqualFold(strings.TrimPrefix(allowed, "."), extension)
+	}) {
+		return &UploadPolicyError{
+			Status:  http.StatusUnsupportedMediaType,
+			Reason:  "extension_not_allowed",
+			Message: fmt.Sprintf("Files with the extension %q are not allowed", extension),
+			Details: map[string]interface{}{"extension": extension, "allowed_extensions": p.Extensions},
+		}
+	}
+	return nil
+}
+
+// Inspect checks a file of the given size against the policy. The MIME type is
+// detected from the content, never from the name or the client
+func (p UploadPolicy) Inspect(fileName string, size int64, content io.Reader) (Inspection, error) {
+	if err := p.CheckSize(size); err != nil {
+		return Inspection{}, err
+	}
+	if err := p.CheckName(fileName); err != nil {
+		return Inspection{}, err
+	}
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return Inspection{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	head = head[:n]
+
+	detected := mimetype.Detect(head)
+	inspection := Inspection{
+		Size:      size,
+		Extension: fileExtension(fileName),
+		MIMEType:  strings.SplitN(detected.String(), ";", 2)[0],
+	}
+
+	if len(p.MIMETypes) > 0 && !mimeTypeAllowed(detected, p.MIMETypes) {
+		return Inspection{}, &UploadPolicyError{
+			Status:  http.StatusUnsupportedMediaType,
+			Reason:  "mime_type_not_allowed",
+			Message: fmt.Sprintf("Files of type %q are not allowed", inspection.MIMEType),
+			Details: map[string]interface{}{"mime_type": inspection.MIMEType, "allowed_mime_types": p.MIMETypes},
+		}
+	}
+
+	if (p.MaxImageWidth > 0 || p.MaxImageHeight > 0) && strings.HasPrefix(inspection.MIMEType, "image/") {
+		inspection.Width, inspection.Height, err = imageDimensions(inspection.MIMEType, io.MultiReader(bytes.NewReader(head), content))
+		if err != nil {
+			return Inspection{}, &UploadPolicyError{
+				Status:  http.StatusUnprocessableEntity,
+				Reason:  "invalid_image",
+				Message: "Image could not be decoded",
+				Details: map[string]interface{}{"mime_type": inspection.MIMEType},
+			}
+		}
+		if (p.MaxImageWidth > 0 && inspection.Width > p.MaxImageWidth) || (p.MaxImageHeight > 0 && inspection.Height > p.MaxImageHeight) {
+			return Inspection{}, &UploadPolicyError{
+				Status:  http.StatusUnprocessableEntity,
+				Reason:  "image_too_large",
+				Message: fmt.Sprintf("Image exceeds the maximum dimensions of %dx%d", p.MaxImageWidth, p.MaxImageHeight),
+				Details: map[string]interface{}{
+					"width": inspection.Width, "height": inspection.Height,
+					"max_width": p.MaxImageWidth, "max_height": p.MaxImageHeight,
+				},
+			}
+		}
+	}
+
+	return inspection, nil
+}
+
+// InspectFile checks a seekable file against the policy and rewinds it
+func (p UploadPolicy) InspectFile(fileName string, file io.ReadSeeker) (Inspection, error) {
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Inspection{}, fmt.Errorf("failed to read file size: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return Inspection{}, fmt.Errorf("failed to rewind file: %w", err)
+	}
+
+	inspection, err := p.Inspect(fileName, size, file)
+	if _, seekErr := file.Seek(0, io.SeekStart); err == nil && seekErr != nil {
+		err = fmt.Errorf("failed to rewind file: %w", seekErr)
+	}
+	return inspection, err
+}
+
+func fileExtension(fileName string) string {
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(fileName)), ".")
+}
+
+// mimeTypeAllowed matches the detected type, or any type it derives from, against
+// the allowlist. Entries such as image/* match a whole family
+func mimeTypeAllowed(detected *mimetype.MIME, allowed []string) bool {
+	for current := detected; current != nil; current = current.Parent() {
+		for _, entry := range allowed {
+			if family, ok := strings.CutSuffix(entry, "/*"); ok {
+				if strings.HasPrefix(current.String(), family+"/") {
+					return true
+				}
+			} else if current.Is(entry) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// imageDimensions reads the width and height from the header of an image
+func imageDimensions(mimeType string, content io.Reader) (int, int, error) {
+	if mimeType == "image/webp" {
+		return webpDimensions(content)
+	}
+
+	config, _, err := image.DecodeConfig(content)
+	if err != nil {
+		return 0, 0, err
+	}
+	return config.Width, config.Height, nil
+}
+
+// webpDimensions reads the canvas size of the lossy (VP8), lossless (VP8L) and
+// extended (VP8X) WebP formats
+func webpDimensions(content io.Reader) (int, int, error) {
+	header := make([]byte, 30)
+	if _, err := io.ReadFull(content, header); err != nil {
+		return 0, 0, err
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return 0, 0, errors.New("not a WebP image")
+	}
+
+	switch string(header[12:16]) {
+	case "VP8X":
+		width := int(header[24]) | int(header[25])<<8 | int(header[26])<<16
+		height := int(header[27]) | int(header[28])<<8 | int(header[29])<<16
+		return width + 1, height + 1, nil
+	case "VP8L":
+		if header[20] != 0x2f {
+			return 0, 0, errors.New("invalid VP8L signature")
+		}
+		bits := binary.LittleEndian.Uint32(header[21:25])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, nil
+	case "VP8 ":
+		if !bytes.Equal(header[23:26], []byte{0x9d, 0x01, 0x2a}) {
+			return 0, 0, errors.New("invalid VP8 start code")
+		}
+		width := binary.LittleEndian.Uint16(header[26:28]) & 0x3fff
+		height := binary.LittleEndian.Uint16(header[28:30]) & 0x3fff
+		return int(width), int(height), nil
+	}
+	return 0, 0, errors.New("unknown WebP format")
+}
@@ -0,0 +1,85 @@
+package files
+
+import (
+	"bernardtm/backend/configs"
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pngImage(t *testing.T, width, height int) []byte {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func assertViolation(t *testing.T, err error, status int, reason string) {
+	var policyErr *UploadPolicyError
+	if assert.ErrorAs(t, err, &policyErr) {
+		assert.Equal(t, status, policyErr.Status)
+		assert.Equal(t, reason, policyErr.Reason)
+	}
+}
+
+func TestUploadPolicy_Inspect(t *testing.T) {
+	policy := NewUploadPolicy("files", configs.UploadPolicyConfig{
+		MaxBytes:       1024,
+		MIMETypes:      []string{"application/pdf", "image/*"},
+		Extensions:     []string{"pdf", "png"},
+		MaxImageWidth:  64,
+		MaxImageHeight: 64,
+	})
+
+	inspection, err := policy.InspectFile("report.PDF", strings.NewReader("%PDF-1.7 content"))
+	assert.NoError(t, err)
+	assert.Equal(t, "application/pdf", inspection.MIMEType)
+	assert.Equal(t, int64(16), inspection.Size)
+
+	inspection, err = policy.InspectFile("avatar.png", bytes.NewReader(pngImage(t, 32, 16)))
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", inspection.MIMEType)
+	assert.Equal(t, 32, inspection.Width)
+	assert.Equal(t, 16, inspection.Height)
+
+	_, err = policy.InspectFile("large.pdf", strings.NewReader("%PDF-1.7"+strings.Repeat(" ", 1024)))
+	assertViolation(t, err, http.StatusRequestEntityTooLarge, "file_too_large")
+
+	_, err = policy.InspectFile("script.sh", strings.NewReader("#!/bin/sh"))
+	assertViolation(t, err, http.StatusUnsupportedMediaType, "extension_not_allowed")
+
+	// the extension does not decide the type: an executable renamed to pdf is refused
+	_, err = policy.InspectFile("invoice.pdf", bytes.NewReader([]byte("MZ\x90\x00\x03\x00\x00\x00")))
+	assertViolation(t, err, http.StatusUnsupportedMediaType, "mime_type_not_allowed")
+
+	_, err = policy.InspectFile("avatar.png", bytes.NewReader(pngImage(t, 128, 16)))
+	assertViolation(t, err, http.StatusUnprocessableEntity, "image_too_large")
+}
+
+func TestUploadPolicy_InspectWithoutLimits(t *testing.T) {
+	policy := NewUploadPolicy("files", configs.UploadPolicyConfig{})
+
+	inspection, err := policy.InspectFile("notes", strings.NewReader("plain text"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain", inspection.MIMEType)
+}
+
+func TestWebpDimensions(t *testing.T) {
+	// lossless header of a 300x200 image: 14 bits of width-1 followed by 14 bits of height-1
+	bits := uint32(299) | uint32(199)<<14
+	header := []byte("RIFF\x00\x00\x00\x00WEBPVP8L\x00\x00\x00\x00\x2f")
+	header = append(header, byte(bits), byte(bits>>8), byte(bits>>16), byte(bits>>24), 0, 0, 0, 0, 0)
+
+	width, height, err := webpDimensions(bytes.NewReader(header))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 300, width)
+	assert.Equal(t, 200, height)
+}
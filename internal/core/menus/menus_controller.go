@@ -35,7 +35,7 @@ func NewMenusController(service MenusService) *menusController {
 // @Failure 500 {object} shareds.ErrorResponse
 // @Router /menus [get]
 func (c *menusController) GetAll(ctx *gin.Context) {
-	menus, err := c.service.GetAll()
+	menus, err := c.service.GetAll(ctx.Request.Context())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching menus"})
 		return
@@ -56,7 +56,7 @@ func (c *menusController) GetAll(ctx *gin.Context) {
 func (c *menusController) GetByID(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	menu, err := c.service.GetByID(id)
+	menu, err := c.service.GetByID(ctx.Request.Context(), id)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Menu not found"})
 		return
@@ -82,7 +82,7 @@ func (c *menusController) Create(ctx *gin.Context) {
 		return
 	}
 
-	createdID, err := c.service.Create(input)
+	createdID, err := c.service.Create(ctx.Request.Context(), input)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error creating menu"})
 		return
@@ -112,7 +112,7 @@ func (c *menusController) Update(ctx *gin.Context) {
 		return
 	}
 
-	err := c.service.Update(id, input)
+	err := c.service.Update(ctx.Request.Context(), id, input)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error updating menu"})
 		return
@@ -133,7 +133,7 @@ func (c *menusController) Update(ctx *gin.Context) {
 func (c *menusController) Delete(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	err := c.service.Delete(id)
+	err := c.service.Delete(ctx.Request.Context(), id)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Menu not found"})
 		return
@@ -167,7 +167,7 @@ func (c *menusController) Paginate(ctx *gin.Context) {
 		limit = 10
 	}
 
-	menus, err := c.service.Paginate(page, limit)
+	menus, err := c.service.Paginate(ctx.Request.Context(), page, limit)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching paginated menus"})
 		return
@@ -193,7 +193,7 @@ func (c *menusController) GetMenusByUserID(ctx *gin.Context) {
 		return
 	}
 
-	menus, err := c.service.GetMenusByUserID(userid.(string))
+	menus, err := c.service.GetMenusByUserID(ctx.Request.Context(), userid.(string))
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching menus by user"})
 		return
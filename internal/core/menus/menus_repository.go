@@ -1,33 +1,55 @@
 package menus
 
 import (
+	"bernardtm/backend/internal/infra/database"
 	"bernardtm/backend/internal/utils"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 )
 
+// ErrMenuNotFound is returned when no menu of the tenant matches the given ID
+var ErrMenuNotFound = errors.New("menu not found")
+
 // MenusRepository defines the interface for menu-related operations
 type MenusRepository interface {
-	GetAll() ([]MenusResponse, error)
-	GetByID(id string) (MenusResponse, error)
-	Create(entity MenusRequest) (string, error)
-	Update(id string, entity MenusRequest) error
-	Delete(id string) error
-	Paginate(page, size int) ([]MenusResponse, error)
-	GetMenusByUserID(userUUID string) ([]MenusResponse, error)
+	GetAll(ctx context.Context) ([]MenusResponse, error)
+	GetByID(ctx context.Context, id string) (MenusResponse, error)
+	Create(ctx context.Context, entity MenusRequest) (string, error)
+	Update(ctx context.Context, id string, entity MenusRequest) error
+	Delete(ctx context.Context, id string) error
+	Paginate(ctx context.Context, page, size int) ([]MenusResponse, error)
+	GetMenusByUserID(ctx context.Context, userUUID string) ([]MenusResponse, error)
+	WithTx(tx database.DBTX) MenusRepository
 }
 
 type menusRepository struct {
-	db *sql.DB
+	db      database.DBTX
+	timeout time.Duration
 }
 
 // NewMenusRepository creates a new instance of MenusRepository
-func NewMenusRepository(db *sql.DB) *menusRepository {
-	return &menusRepository{db: db}
+func NewMenusRepository(db database.DBTX, timeout time.Duration) *menusRepository {
+	return &menusRepository{db: db, timeout: timeout}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *menusRepository) WithTx(tx database.DBTX) MenusRepository {
+	return &menusRepository{db: tx, timeout: r.timeout}
 }
 
 // GetAll retrieves all menus
-func (r *menusRepository) GetAll() ([]MenusResponse, error) {
+func (r *menusRepository) GetAll(ctx context.Context) ([]MenusResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var entities []MenusResponse
 	query := `
 		SELECT
@@ -41,9 +63,11 @@ func (r *menusRepository) GetAll() ([]MenusResponse, error) {
 			, status_uuid
 		FROM
 			default_schema.menus
+		WHERE
+			tenant_uuid = $1
 		ORDER BY order_index asc
 	`
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query, tenantID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all menus: %w", err)
 	}
@@ -90,7 +114,15 @@ func (r *menusRepository) GetAll() ([]MenusResponse, error) {
 }
 
 // GetByID retrieves a menu by its ID
-func (r *menusRepository) GetByID(id string) (MenusResponse, error) {
+func (r *menusRepository) GetByID(ctx context.Context, id string) (MenusResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return MenusResponse{}, err
+	}
+
 	var entity MenusResponse
 	query := `
 		SELECT
@@ -106,8 +138,9 @@ func (r *menusRepository) GetByID(id string) (MenusResponse, error) {
 			default_schema.menus
 		WHERE
 			menu_uuid = $1
+			AND tenant_uuid = $2
 	`
-	err := r.db.QueryRow(query, id).Scan(
+	err = r.db.QueryRowContext(ctx, query, id, tenantID).Scan(
 		&entity.MenuUUID,
 		&entity.Name,
 		&entity.Icon,
@@ -119,7 +152,7 @@ func (r *menusRepository) GetByID(id string) (MenusResponse, error) {
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return MenusResponse{}, fmt.Errorf("menu not found with ID: %s", id)
+			return MenusResponse{}, fmt.Errorf("%w with ID: %s", ErrMenuNotFound, id)
 		}
 		return MenusResponse{}, fmt.Errorf("failed to get menu by ID: %w", err)
 	}
@@ -147,7 +180,15 @@ func (r *menusRepository) GetByID(id string) (MenusResponse, error) {
 }
 
 // Create inserts a new menu and returns its UUID
-func (r *menusRepository) Create(entity MenusRequest) (string, error) {
+func (r *menusRepository) Create(ctx context.Context, entity MenusRequest) (string, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return "", err
+	}
+
 	var id string
 	query := `
 		INSERT INTO default_schema.menus (
@@ -156,16 +197,18 @@ func (r *menusRepository) Create(entity MenusRequest) (string, error) {
 			, url
 			, order_index
 			, status_uuid
+			, tenant_uuid
 		)
-		VALUES ($1, $2, $3, $4, $5)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING menu_uuid
 	`
-	err := r.db.QueryRow(query,
+	err = r.db.QueryRowContext(ctx, query,
 		entity.Name,
 		entity.Icon,
 		entity.Url,
 		entity.OrderIndex,
 		entity.StatusUUID,
+		tenantID,
 	).Scan(&id)
 	if err != nil {
 		return "", fmt.Errorf("failed to create menu: %w", err)
@@ -174,7 +217,15 @@ func (r *menusRepository) Create(entity MenusRequest) (string, error) {
 }
 
 // Update modifies an existing menu by its ID
-func (r *menusRepository) Update(id string, entity MenusRequest) error {
+func (r *menusRepository) Update(ctx context.Context, id string, entity MenusRequest) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE default_schema.menus
 		SET
@@ -184,15 +235,16 @@ func (r *menusRepository) Update(id string, entity MenusRequest) error {
 			order_index = $5,
 			modification_date = CURRENT_DATE,
 			status_uuid = $6
-		WHERE menu_uuid = $1
+		WHERE menu_uuid = $1 AND tenant_uuid = $7
 	`
-	_, err := r.db.Exec(query,
+	_, err = r.db.ExecContext(ctx, query,
 		id,
 		entity.Name,
 		entity.Icon,
 		entity.Url,
 		entity.OrderIndex,
 		entity.StatusUUID,
+		tenantID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update menu: %w", err)
@@ -201,12 +253,20 @@ func (r *menusRepository) Update(id string, entity MenusRequest) error {
 }
 
 // Delete removes a menu by its ID
-func (r *menusRepository) Delete(id string) error {
+func (r *menusRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		DELETE FROM default_schema.menus
-		WHERE menu_uuid = $1
+		WHERE menu_uuid = $1 AND tenant_uuid = $2
 	`
-	_, err := r.db.Exec(query, id)
+	_, err = r.db.ExecContext(ctx, query, id, tenantID)
 	if err != nil {
 		return fmt.Errorf("failed to delete menu: %w", err)
 	}
@@ -214,7 +274,15 @@ func (r *menusRepository) Delete(id string) error {
 }
 
 // Paginate retrieves menus with pagination
-func (r *menusRepository) Paginate(page, size int) ([]MenusResponse, error) {
+func (r *menusRepository) Paginate(ctx context.Context, page, size int) ([]MenusResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var entities []MenusResponse
 	offset := (page - 1) * size
 	query := `
@@ -229,10 +297,12 @@ func (r *menusRepository) Paginate(page, size int) ([]MenusResponse, error) {
 			, status_uuid
 		FROM
 			default_schema.menus
+		WHERE
+			tenant_uuid = $3
 		ORDER BY order_index asc
 		LIMIT $1 OFFSET $2
 	`
-	rows, err := r.db.Query(query, size, offset)
+	rows, err := r.db.QueryContext(ctx, query, size, offset, tenantID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to paginate menus: %w", err)
 	}
@@ -278,7 +348,15 @@ func (r *menusRepository) Paginate(page, size int) ([]MenusResponse, error) {
 	return entities, nil
 }
 
-func (r *menusRepository) GetMenusByUserID(userUUID string) ([]MenusResponse, error) {
+func (r *menusRepository) GetMenusByUserID(ctx context.Context, userUUID string) ([]MenusResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var entities []MenusResponse
 	query := `
 		SELECT
@@ -296,11 +374,12 @@ func (r *menusRepository) GetMenusByUserID(userUUID string) ([]MenusResponse, er
 			m.menu_uuid IN (
 				SELECT um.menu_uuid
 				FROM default_schema.user_menus um
-				WHERE um.user_uuid = $1
+				WHERE um.user_uuid = $1 AND um.tenant_uuid = $2
 			)
+			AND m.tenant_uuid = $2
 		ORDER BY order_index asc
 	`
-	rows, err := r.db.Query(query, userUUID)
+	rows, err := r.db.QueryContext(ctx, query, userUUID, tenantID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user menus: %w", err)
 	}
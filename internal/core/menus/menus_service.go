@@ -1,53 +1,98 @@
 package menus
 
+import (
+	"bernardtm/backend/internal/core/shareds"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/infra/socket"
+	"context"
+)
+
 type MenusService interface {
-	GetAll() ([]MenusResponse, error)
-	GetByID(id string) (MenusResponse, error)
-	Create(entity MenusRequest) (string, error)
-	Update(id string, entity MenusRequest) error
-	Delete(id string) error
-	Paginate(page int, size int) ([]MenusResponse, error)
-	GetMenusByUserID(userID string) ([]MenusResponse, error)
+	GetAll(ctx context.Context) ([]MenusResponse, error)
+	GetByID(ctx context.Context, id string) (MenusResponse, error)
+	Create(ctx context.Context, entity MenusRequest) (string, error)
+	Update(ctx context.Context, id string, entity MenusRequest) error
+	Delete(ctx context.Context, id string) error
+	Paginate(ctx context.Context, page int, size int) ([]MenusResponse, error)
+	GetMenusByUserID(ctx context.Context, userID string) ([]MenusResponse, error)
 }
 
 type menusService struct {
-	repo                   MenusRepository
+	repo        MenusRepository
+	publisher   socket.Publisher
+	attachments shareds.AttachmentsCleaner
+	txManager   database.TxManager
 }
 
+// NewMenusService creates the menus service, publishing the changes of the menus
+// on the entity:menus topic
 func NewMenusService(
 	repo MenusRepository,
+	publisher socket.Publisher,
+	attachments shareds.AttachmentsCleaner,
+	txManager database.TxManager,
 ) *menusService {
 	return &menusService{
-		repo:                   repo,
+		repo:        repo,
+		publisher:   publisher,
+		attachments: attachments,
+		txManager:   txManager,
 	}
 }
 
-func (s *menusService) GetAll() ([]MenusResponse, error) {
-	return s.repo.GetAll()
+func (s *menusService) GetAll(ctx context.Context) ([]MenusResponse, error) {
+	return s.repo.GetAll(ctx)
 }
 
-func (s *menusService) GetByID(id string) (MenusResponse, error) {
-	return s.repo.GetByID(id)
+func (s *menusService) GetByID(ctx context.Context, id string) (MenusResponse, error) {
+	return s.repo.GetByID(ctx, id)
 }
 
-func (s *menusService) Create(entity MenusRequest) (string, error) {
-	return s.repo.Create(entity)
+func (s *menusService) Create(ctx context.Context, entity MenusRequest) (string, error) {
+	id, err := s.repo.Create(ctx, entity)
+	if err != nil {
+		return "", err
+	}
+
+	s.publish(ctx, socket.ActionCreated, id)
+	return id, nil
 }
 
-func (s *menusService) Update(id string, entity MenusRequest) error {
-	return s.repo.Update(id, entity)
+func (s *menusService) Update(ctx context.Context, id string, entity MenusRequest) error {
+	if err := s.repo.Update(ctx, id, entity); err != nil {
+		return err
+	}
+
+	s.publish(ctx, socket.ActionUpdated, id)
+	return nil
+}
+
+// Delete deletes a menu along with its attachments
+func (s *menusService) Delete(ctx context.Context, id string) error {
+	return s.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		if err := s.repo.WithTx(uow.Tx()).Delete(ctx, id); err != nil {
+			return err
+		}
+		if err := s.attachments.DeleteByEntity(ctx, uow, "menus", id); err != nil {
+			return err
+		}
+
+		uow.AfterCommit(func() { s.publish(ctx, socket.ActionDeleted, id) })
+		return nil
+	})
 }
 
-func (s *menusService) Delete(id string) error {
-	return s.repo.Delete(id)
+// publish tells the clients following the menus that one changed
+func (s *menusService) publish(ctx context.Context, action string, id string) {
+	s.publisher.Publish(ctx, socket.EntityTopic("menus"), socket.ChangeEvent{Action: action, ID: id})
 }
 
-func (s *menusService) Paginate(page int, size int) ([]MenusResponse, error) {
-	return s.repo.Paginate(page, size)
+func (s *menusService) Paginate(ctx context.Context, page int, size int) ([]MenusResponse, error) {
+	return s.repo.Paginate(ctx, page, size)
 }
 
-func (s *menusService) GetMenusByUserID(userID string) ([]MenusResponse, error) {
-	menus, err := s.repo.GetAll()
+func (s *menusService) GetMenusByUserID(ctx context.Context, userID string) ([]MenusResponse, error) {
+	menus, err := s.repo.GetAll(ctx)
 	if err != nil {
 		return []MenusResponse{}, err
 	}
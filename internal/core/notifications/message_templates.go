@@ -0,0 +1,69 @@
+package notifications
+
+import (
+	"bernardtm/backend/internal/core/email"
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+// ErrTemplateNotFound is returned when a message template does not exist
+var ErrTemplateNotFound = errors.New("message template not found")
+
+// RenderedMessage is a template rendered for the channels other than email
+type RenderedMessage struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// MessageRenderer renders the short messages sent by SMS, WhatsApp and push. They
+// are named and localized like the email templates, defining a "title" and a "body"
+type MessageRenderer interface {
+	Render(name string, locale string, data any) (RenderedMessage, error)
+}
+
+type messageRenderer struct {
+	emailTemplates email.TemplateRenderer
+	templates      map[string]*template.Template // by locale/name
+}
+
+// NewMessageRenderer parses the embedded message templates. The locales are
+// resolved like the ones of the emails
+func NewMessageRenderer(emailTemplates email.TemplateRenderer) *messageRenderer {
+	renderer := &messageRenderer{emailTemplates: emailTemplates, templates: map[string]*template.Template{}}
+
+	files, _ := fs.Glob(embeddedTemplates, "templates/*/*.tmpl")
+	for _, file := range files {
+		key := strings.TrimSuffix(strings.TrimPrefix(file, "templates/"), ".tmpl")
+		renderer.templates[key] = template.Must(template.ParseFS(embeddedTemplates, file))
+	}
+
+	return renderer
+}
+
+// Render renders a message template in the supported locale closest to the given one
+func (r *messageRenderer) Render(name string, locale string, data any) (RenderedMessage, error) {
+	locale = r.emailTemplates.ResolveLocale(locale)
+
+	tmpl, ok := r.templates[locale+"/"+name]
+	if !ok {
+		return RenderedMessage{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+
+	var title, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&title, "title", data); err != nil {
+		return RenderedMessage{}, fmt.Errorf("failed to render message %s: %w", name, err)
+	}
+	if err := tmpl.ExecuteTemplate(&body, "body", data); err != nil {
+		return RenderedMessage{}, fmt.Errorf("failed to render message %s: %w", name, err)
+	}
+
+	return RenderedMessage{Title: strings.TrimSpace(title.String()), Body: strings.TrimSpace(body.String())}, nil
+}
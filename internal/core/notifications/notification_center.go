@@ -0,0 +1,151 @@
+package notifications
+
+import (
+	"bernardtm/backend/internal/infra/socket"
+	"context"
+	"log"
+	"time"
+)
+
+// NotificationCenter keeps the in-app notifications of the users. They are sent
+// over the websocket when the user is connected, to this instance or to another
+// one acknowledging them, and replayed on their next connection otherwise. A
+// notification created while the user connects may be sent twice, the frontend
+// tells them apart by their notificationUUID
+type NotificationCenter interface {
+	Send(ctx context.Context, userUUID string, message socket.NotificationType)
+	Replay(ctx context.Context, userUUID string)
+	Acknowledge(ctx context.Context, userUUID string, id string)
+	GetAll(ctx context.Context, userUUID string, unreadOnly bool, page, size int) ([]UserNotification, error)
+	CountUnread(ctx context.Context, userUUID string) (UnreadCountResponse, error)
+	MarkRead(ctx context.Context, userUUID string, id string) error
+	MarkAllRead(ctx context.Context, userUUID string) error
+	Delete(ctx context.Context, userUUID string, id string) error
+}
+
+type notificationCenter struct {
+	notificationsRepo UserNotificationsRepository
+	hub               socket.Deliverer
+}
+
+func NewNotificationCenter(notificationsRepo UserNotificationsRepository, hub socket.Deliverer) *notificationCenter {
+	return &notificationCenter{notificationsRepo: notificationsRepo, hub: hub}
+}
+
+// Send stores a notification of a user and delivers it when they are connected.
+// A notification that failed to be stored is still delivered, but not replayed
+func (s *notificationCenter) Send(ctx context.Context, userUUID string, message socket.NotificationType) {
+	notification, err := s.notificationsRepo.Create(ctx, UserNotification{
+		UserUUID:      userUUID,
+		ReferenceUUID: message.UUID,
+		Title:         message.Title,
+		Description:   message.Description,
+		PriorityValue: priorityValue(message.PriorityLevel),
+		PriorityColor: priorityColor(message.PriorityLevel),
+		Type:          message.OptionType,
+	})
+	if err != nil {
+		log.Printf("failed to store notification of user %s: %v", userUUID, err)
+		s.hub.Deliver(ctx, userUUID, message)
+		return
+	}
+
+	if s.hub.Deliver(ctx, userUUID, toMessage(notification)) {
+		s.markDelivered(ctx, []string{notification.NotificationUUID})
+	}
+}
+
+// Replay delivers the notifications a user missed while disconnected, oldest first
+func (s *notificationCenter) Replay(ctx context.Context, userUUID string) {
+	missed, err := s.notificationsRepo.GetUndelivered(ctx, userUUID)
+	if err != nil {
+		log.Printf("failed to replay notifications of user %s: %v", userUUID, err)
+		return
+	}
+
+	delivered := []string{}
+	for _, notification := range missed {
+		// the user disconnected, the rest waits for the next connection
+		if !s.hub.Deliver(ctx, userUUID, toMessage(notification)) {
+			break
+		}
+		delivered = append(delivered, notification.NotificationUUID)
+	}
+
+	if len(delivered) > 0 {
+		s.markDelivered(ctx, delivered)
+	}
+}
+
+// Acknowledge marks a notification as delivered by another instance, which wrote
+// it to a connection of its user
+func (s *notificationCenter) Acknowledge(ctx context.Context, userUUID string, id string) {
+	s.markDelivered(ctx, []string{id})
+}
+
+func (s *notificationCenter) markDelivered(ctx context.Context, ids []string) {
+	if err := s.notificationsRepo.MarkDelivered(ctx, ids); err != nil {
+		log.Printf("failed to mark notifications %v as delivered: %v", ids, err)
+	}
+}
+
+// GetAll returns a page of the notifications of a user, or of the unread ones,
+// most recent first
+func (s *notificationCenter) GetAll(ctx context.Context, userUUID string, unreadOnly bool, page, size int) ([]UserNotification, error) {
+	return s.notificationsRepo.GetByUser(ctx, userUUID, unreadOnly, page, size)
+}
+
+func (s *notificationCenter) CountUnread(ctx context.Context, userUUID string) (UnreadCountResponse, error) {
+	count, err := s.notificationsRepo.CountUnread(ctx, userUUID)
+	if err != nil {
+		return UnreadCountResponse{}, err
+	}
+	return UnreadCountResponse{Count: count}, nil
+}
+
+func (s *notificationCenter) MarkRead(ctx context.Context, userUUID string, id string) error {
+	return s.notificationsRepo.MarkRead(ctx, userUUID, id)
+}
+
+func (s *notificationCenter) MarkAllRead(ctx context.Context, userUUID string) error {
+	return s.notificationsRepo.MarkAllRead(ctx, userUUID)
+}
+
+func (s *notificationCenter) Delete(ctx context.Context, userUUID string, id string) error {
+	return s.notificationsRepo.Delete(ctx, userUUID, id)
+}
+
+// toMessage returns the websocket message of a stored notification
+func toMessage(notification UserNotification) socket.NotificationType {
+	message := socket.NotificationType{
+		NotificationUUID: notification.NotificationUUID,
+		ReceivedUUID:     notification.UserUUID,
+		UUID:             notification.ReferenceUUID,
+		Title:            notification.Title,
+		Description:      notification.Description,
+		CreationDate:     notification.CreationDate.Format(time.RFC3339),
+		OptionType:       notification.Type,
+	}
+
+	if notification.PriorityValue != nil {
+		message.PriorityLevel = &socket.PriorityLevel{Value: *notification.PriorityValue}
+		if notification.PriorityColor != nil {
+			message.PriorityLevel.Color = *notification.PriorityColor
+		}
+	}
+	return message
+}
+
+func priorityValue(level *socket.PriorityLevel) *string {
+	if level == nil {
+		return nil
+	}
+	return &level.Value
+}
+
+func priorityColor(level *socket.PriorityLevel) *string {
+	if level == nil || level.Color == "" {
+		return nil
+	}
+	return &level.Color
+}
@@ -0,0 +1,147 @@
+package notifications
+
+import (
+	"bernardtm/backend/internal/core/shareds"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationCenterController interface {
+	GetAll(ctx *gin.Context)
+	CountUnread(ctx *gin.Context)
+	MarkRead(ctx *gin.Context)
+	MarkAllRead(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+type notificationCenterController struct {
+	center NotificationCenter
+}
+
+func NewNotificationCenterController(center NotificationCenter) *notificationCenterController {
+	return &notificationCenterController{center: center}
+}
+
+// GetAll lists the notifications of the user
+// @Summary List the notifications of the user
+// @Description Lists the notifications of the notification center of the user, most recent first
+// @Tags Notifications
+// @Produce json
+// @Security BearerAuth
+// @Param unread query bool false "Only the unread notifications"
+// @Param page query int false "Page Number"
+// @Param size query int false "Page Size"
+// @Success 200 {array} UserNotification
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /notifications [get]
+func (nc *notificationCenterController) GetAll(c *gin.Context) {
+	unreadOnly, err := strconv.ParseBool(c.DefaultQuery("unread", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid unread parameter"})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid page parameter"})
+		return
+	}
+
+	size, err := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if err != nil || size <= 0 || size > 100 {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid size parameter"})
+		return
+	}
+
+	notifications, err := nc.center.GetAll(c.Request.Context(), c.GetString("ID"), unreadOnly, page, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, notifications)
+}
+
+// CountUnread counts the unread notifications of the user
+// @Summary Count the unread notifications
+// @Tags Notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} UnreadCountResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /notifications/unread-count [get]
+func (nc *notificationCenterController) CountUnread(c *gin.Context) {
+	count, err := nc.center.CountUnread(c.Request.Context(), c.GetString("ID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error counting notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, count)
+}
+
+// MarkRead marks a notification as read
+// @Summary Mark a notification as read
+// @Tags Notifications
+// @Security BearerAuth
+// @Param id path string true "Notification ID"
+// @Success 204
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /notifications/{id}/read [put]
+func (nc *notificationCenterController) MarkRead(c *gin.Context) {
+	err := nc.center.MarkRead(c.Request.Context(), c.GetString("ID"), c.Param("id"))
+	if errors.Is(err, ErrNotificationNotFound) {
+		c.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Notification not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error updating notification"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// MarkAllRead marks every notification of the user as read
+// @Summary Mark every notification as read
+// @Tags Notifications
+// @Security BearerAuth
+// @Success 204
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /notifications/read [put]
+func (nc *notificationCenterController) MarkAllRead(c *gin.Context) {
+	if err := nc.center.MarkAllRead(c.Request.Context(), c.GetString("ID")); err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error updating notifications"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// Delete removes a notification
+// @Summary Delete a notification
+// @Tags Notifications
+// @Security BearerAuth
+// @Param id path string true "Notification ID"
+// @Success 204
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /notifications/{id} [delete]
+func (nc *notificationCenterController) Delete(c *gin.Context) {
+	err := nc.center.Delete(c.Request.Context(), c.GetString("ID"), c.Param("id"))
+	if errors.Is(err, ErrNotificationNotFound) {
+		c.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Notification not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error deleting notification"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
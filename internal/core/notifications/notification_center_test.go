@@ -0,0 +1,241 @@
+package notifications
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/infra/socket"
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingHub stands in for the websocket hub, the user being connected until
+// limit messages were delivered
+type recordingHub struct {
+	limit     int
+	delivered []socket.NotificationType
+}
+
+func (h *recordingHub) Deliver(ctx context.Context, userUUID string, message socket.NotificationType) bool {
+	if len(h.delivered) >= h.limit {
+		return false
+	}
+	h.delivered = append(h.delivered, message)
+	return true
+}
+
+// fakeUserNotificationsRepository keeps the notifications in memory, oldest first
+type fakeUserNotificationsRepository struct {
+	UserNotificationsRepository
+	notifications []UserNotification
+}
+
+func (r *fakeUserNotificationsRepository) Create(ctx context.Context, notification UserNotification) (UserNotification, error) {
+	if _, err := database.TenantID(ctx); err != nil {
+		return UserNotification{}, err
+	}
+
+	notification.NotificationUUID = fmt.Sprintf("notification-%d", len(r.notifications)+1)
+	notification.CreationDate = time.Now()
+	r.notifications = append(r.notifications, notification)
+	return notification, nil
+}
+
+func (r *fakeUserNotificationsRepository) GetUndelivered(ctx context.Context, userUUID string) ([]UserNotification, error) {
+	undelivered := []UserNotification{}
+	for _, notification := range r.notifications {
+		if notification.UserUUID == userUUID && notification.DeliveredDate == nil {
+			undelivered = append(undelivered, notification)
+		}
+	}
+	return undelivered, nil
+}
+
+func (r *fakeUserNotificationsRepository) MarkDelivered(ctx context.Context, ids []string) error {
+	now := time.Now()
+	for i, notification := range r.notifications {
+		if slices.Contains(ids, notification.NotificationUUID) && notification.DeliveredDate == nil {
+			r.notifications[i].DeliveredDate = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeUserNotificationsRepository) MarkRead(ctx context.Context, userUUID string, id string) error {
+	for i, notification := range r.notifications {
+		if notification.NotificationUUID == id && notification.UserUUID == userUUID {
+			if notification.ReadDate == nil {
+				now := time.Now()
+				r.notifications[i].ReadDate = &now
+			}
+			return nil
+		}
+	}
+	return ErrNotificationNotFound
+}
+
+func (r *fakeUserNotificationsRepository) Delete(ctx context.Context, userUUID string, id string) error {
+	for i, notification := range r.notifications {
+		if notification.NotificationUUID == id && notification.UserUUID == userUUID {
+			r.notifications = slices.Delete(r.notifications, i, i+1)
+			return nil
+		}
+	}
+	return ErrNotificationNotFound
+}
+
+// delivered returns the IDs of the notifications marked as delivered
+func (r *fakeUserNotificationsRepository) delivered() []string {
+	ids := []string{}
+	for _, notification := range r.notifications {
+		if notification.DeliveredDate != nil {
+			ids = append(ids, notification.NotificationUUID)
+		}
+	}
+	return ids
+}
+
+func newTestNotificationCenter(hub *recordingHub, notifications ...UserNotification) (*notificationCenter, *fakeUserNotificationsRepository) {
+	repo := &fakeUserNotificationsRepository{notifications: notifications}
+	return NewNotificationCenter(repo, hub), repo
+}
+
+func TestNotificationCenter_SendDeliversToConnectedUser(t *testing.T) {
+	hub := &recordingHub{limit: 1}
+	center, repo := newTestNotificationCenter(hub)
+	ctx := database.WithTenant(context.Background(), testTenant)
+	fileUUID := "file-1"
+
+	center.Send(ctx, "user-1", socket.NotificationType{
+		ReceivedUUID:  "user-1",
+		UUID:          &fileUUID,
+		Title:         "File processed",
+		Description:   "report.pdf is ready",
+		PriorityLevel: &socket.PriorityLevel{Value: "high", Color: "#ff0000"},
+		OptionType:    "file_processing",
+	})
+
+	if assert.Len(t, hub.delivered, 1) {
+		assert.Equal(t, "notification-1", hub.delivered[0].NotificationUUID)
+		assert.Equal(t, "file-1", *hub.delivered[0].UUID)
+		assert.Equal(t, &socket.PriorityLevel{Value: "high", Color: "#ff0000"}, hub.delivered[0].PriorityLevel)
+	}
+	if assert.Len(t, repo.notifications, 1) {
+		assert.Equal(t, "user-1", repo.notifications[0].UserUUID)
+		assert.Equal(t, "file_processing", repo.notifications[0].Type)
+	}
+	assert.Equal(t, []string{"notification-1"}, repo.delivered())
+}
+
+func TestNotificationCenter_SendKeepsNotificationOfDisconnectedUser(t *testing.T) {
+	hub := &recordingHub{}
+	center, repo := newTestNotificationCenter(hub)
+	ctx := database.WithTenant(context.Background(), testTenant)
+
+	center.Send(ctx, "user-1", socket.NotificationType{Title: "Welcome", Description: "Hello", OptionType: "info"})
+
+	assert.Empty(t, hub.delivered)
+	if assert.Len(t, repo.notifications, 1) {
+		assert.Nil(t, repo.notifications[0].PriorityValue)
+	}
+	assert.Empty(t, repo.delivered(), "the notification is left undelivered")
+}
+
+func TestNotificationCenter_SendDeliversUnstoredNotification(t *testing.T) {
+	hub := &recordingHub{limit: 1}
+	center, repo := newTestNotificationCenter(hub)
+
+	// without a tenant the notification cannot be stored
+	center.Send(context.Background(), "user-1", socket.NotificationType{Title: "Welcome", OptionType: "info"})
+
+	if assert.Len(t, hub.delivered, 1) {
+		assert.Equal(t, "Welcome", hub.delivered[0].Title)
+	}
+	assert.Empty(t, repo.notifications)
+}
+
+func TestNotificationCenter_ReplayMarksDeliveredNotifications(t *testing.T) {
+	// the user disconnects after the first notification
+	hub := &recordingHub{limit: 1}
+	center, repo := newTestNotificationCenter(hub,
+		UserNotification{NotificationUUID: "notification-1", UserUUID: "user-1", Title: "First", Type: "info"},
+		UserNotification{NotificationUUID: "notification-2", UserUUID: "user-1", Title: "Second", Type: "info"},
+		UserNotification{NotificationUUID: "notification-3", UserUUID: "user-2", Title: "Other", Type: "info"},
+	)
+	ctx := database.WithTenant(context.Background(), testTenant)
+
+	center.Replay(ctx, "user-1")
+
+	if assert.Len(t, hub.delivered, 1) {
+		assert.Equal(t, "First", hub.delivered[0].Title)
+		assert.Nil(t, hub.delivered[0].PriorityLevel)
+	}
+	assert.Equal(t, []string{"notification-1"}, repo.delivered())
+
+	// the rest is replayed on the next connection
+	hub.limit = 2
+	center.Replay(ctx, "user-1")
+
+	if assert.Len(t, hub.delivered, 2) {
+		assert.Equal(t, "Second", hub.delivered[1].Title)
+	}
+	assert.Equal(t, []string{"notification-1", "notification-2"}, repo.delivered())
+}
+
+func TestNotificationCenter_Acknowledge(t *testing.T) {
+	hub := &recordingHub{}
+	center, repo := newTestNotificationCenter(hub,
+		UserNotification{NotificationUUID: "notification-1", UserUUID: "user-1", Title: "First", Type: "info"},
+	)
+	ctx := database.WithTenant(context.Background(), testTenant)
+
+	// another instance delivered the notification
+	center.Acknowledge(ctx, "user-1", "notification-1")
+	center.Replay(ctx, "user-1")
+
+	assert.Equal(t, []string{"notification-1"}, repo.delivered())
+	assert.Empty(t, hub.delivered, "an acknowledged notification is not replayed")
+}
+
+func TestNotificationCenter_ChangesOnlyOwnNotifications(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   string
+		change func(ctx context.Context, center *notificationCenter, user string) error
+		err    error
+		kept   int
+		read   bool
+	}{
+		{name: "marks own notification as read", user: "user-1", change: markRead, kept: 1, read: true},
+		{name: "refuses to mark another user's notification as read", user: "user-2", change: markRead, err: ErrNotificationNotFound, kept: 1},
+		{name: "deletes own notification", user: "user-1", change: deleteNotification, kept: 0},
+		{name: "refuses to delete another user's notification", user: "user-2", change: deleteNotification, err: ErrNotificationNotFound, kept: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			center, repo := newTestNotificationCenter(&recordingHub{},
+				UserNotification{NotificationUUID: "notification-1", UserUUID: "user-1", Title: "First", Type: "info"},
+			)
+			ctx := database.WithTenant(context.Background(), testTenant)
+
+			err := tt.change(ctx, center, tt.user)
+
+			assert.ErrorIs(t, err, tt.err)
+			if assert.Len(t, repo.notifications, tt.kept) && tt.kept > 0 {
+				assert.Equal(t, tt.read, repo.notifications[0].ReadDate != nil)
+			}
+		})
+	}
+}
+
+func markRead(ctx context.Context, center *notificationCenter, user string) error {
+	return center.MarkRead(ctx, user, "notification-1")
+}
+
+func deleteNotification(ctx context.Context, center *notificationCenter, user string) error {
+	return center.Delete(ctx, user, "notification-1")
+}
@@ -0,0 +1,149 @@
+package notifications
+
+import (
+	"bernardtm/backend/internal/core/email"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/providers/notifications"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+)
+
+// ErrUnreachable is returned when a channel has no address of the recipient, e.g.
+// SMS for a user without a phone number
+var ErrUnreachable = errors.New("recipient unreachable on channel")
+
+// NotificationChannel sends notifications over one medium. Email sends in the
+// transaction of uow when not nil, the other channels send right away
+type NotificationChannel interface {
+	Name() string
+	Send(ctx context.Context, uow *database.UnitOfWork, recipient Recipient, notification Notification) error
+}
+
+type emailChannel struct {
+	emailService *email.EmailService
+}
+
+// NewEmailChannel creates the email channel, queueing the emails in the outbox
+func NewEmailChannel(emailService *email.EmailService) *emailChannel {
+	return &emailChannel{emailService: emailService}
+}
+
+func (c *emailChannel) Name() string {
+	return ChannelEmail
+}
+
+func (c *emailChannel) Send(ctx context.Context, uow *database.UnitOfWork, recipient Recipient, notification Notification) error {
+	if recipient.Email == "" {
+		return ErrUnreachable
+	}
+
+	return c.emailService.QueueTemplate(ctx, uow, recipient.Email, notification.Template, recipient.Locale, notification.Data, "")
+}
+
+type phoneChannel struct {
+	name      string
+	provider  notifications.NotificationProvider
+	templates MessageRenderer
+}
+
+// NewPhoneChannel creates a channel sending text messages to the phone number of
+// the recipients, such as SMS or WhatsApp
+func NewPhoneChannel(name string, provider notifications.NotificationProvider, templates MessageRenderer) *phoneChannel {
+	return &phoneChannel{name: name, provider: provider, templates: templates}
+}
+
+func (c *phoneChannel) Name() string {
+	return c.name
+}
+
+func (c *phoneChannel) Send(ctx context.Context, uow *database.UnitOfWork, recipient Recipient, notification Notification) error {
+	if recipient.Phone == "" {
+		return ErrUnreachable
+	}
+
+	rendered, err := c.templates.Render(notification.Template, recipient.Locale, notification.Data)
+	if err != nil {
+		return err
+	}
+
+	return c.provider.Send(ctx, notifications.Message{
+		To:     recipient.Phone,
+		Title:  rendered.Title,
+		Body:   rendered.Body,
+		Locale: recipient.Locale,
+	})
+}
+
+type pushChannel struct {
+	provider          notifications.NotificationProvider
+	subscriptionsRepo PushSubscriptionsRepository
+	templates         MessageRenderer
+}
+
+// NewPushChannel creates the channel sending web push notifications to every
+// browser the recipient subscribed
+func NewPushChannel(provider notifications.NotificationProvider, subscriptionsRepo PushSubscriptionsRepository, templates MessageRenderer) *pushChannel {
+	return &pushChannel{provider: provider, subscriptionsRepo: subscriptionsRepo, templates: templates}
+}
+
+func (c *pushChannel) Name() string {
+	return ChannelPush
+}
+
+// Send notifies every subscription of the recipient, succeeding when any of them
+// was notified. The subscriptions the push services report as gone are deleted
+func (c *pushChannel) Send(ctx context.Context, uow *database.UnitOfWork, recipient Recipient, notification Notification) error {
+	subscriptions, err := c.subscriptionsRepo.GetByUser(ctx, recipient.UserUUID)
+	if err != nil {
+		return err
+	}
+	if len(subscriptions) == 0 {
+		return ErrUnreachable
+	}
+
+	rendered, err := c.templates.Render(notification.Template, recipient.Locale, notification.Data)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	sent := false
+	for _, subscription := range subscriptions {
+		to, err := json.Marshal(notifications.PushSubscription{
+			Endpoint: subscription.Endpoint,
+			Keys: notifications.PushKeys{
+				P256dh: subscription.P256dh,
+				Auth:   subscription.Auth,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		err = c.provider.Send(ctx, notifications.Message{
+			To:     string(to),
+			Title:  rendered.Title,
+			Body:   rendered.Body,
+			Link:   notification.Link,
+			Locale: recipient.Locale,
+		})
+		if err == nil {
+			sent = true
+			continue
+		}
+
+		if errors.Is(err, notifications.ErrSubscriptionGone) {
+			if err := c.subscriptionsRepo.Delete(ctx, recipient.UserUUID, subscription.Endpoint); err != nil {
+				log.Printf("failed to delete push subscription %s: %v", subscription.SubscriptionUUID, err)
+			}
+		}
+		errs = append(errs, err)
+	}
+
+	if sent {
+		return nil
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,97 @@
+package notifications
+
+import (
+	"bernardtm/backend/internal/core/shareds"
+	"bernardtm/backend/pkg/providers/notifications"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationsController interface {
+	GetChannels(ctx *gin.Context)
+	Subscribe(ctx *gin.Context)
+	Unsubscribe(ctx *gin.Context)
+}
+
+type notificationsController struct {
+	service NotificationService
+}
+
+func NewNotificationsController(service NotificationService) *notificationsController {
+	return &notificationsController{service: service}
+}
+
+// GetChannels lists the notification channels
+// @Summary List the notification channels
+// @Description Lists the channels users may prefer to be notified on, set as the notification_channel of the user. The VAPID public key is the applicationServerKey of PushManager.subscribe when push is enabled
+// @Tags Notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} ChannelsResponse
+// @Router /notifications/channels [get]
+func (nc *notificationsController) GetChannels(c *gin.Context) {
+	c.JSON(http.StatusOK, nc.service.GetChannels())
+}
+
+// Subscribe registers a browser for the push notifications
+// @Summary Subscribe to the push notifications
+// @Description Stores the subscription returned by PushManager.subscribe, so the user is notified on this browser when push is their channel
+// @Tags Notifications
+// @Accept json
+// @Security BearerAuth
+// @Param input body notifications.PushSubscription true "Push subscription"
+// @Success 204
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /notifications/push-subscriptions [post]
+func (nc *notificationsController) Subscribe(c *gin.Context) {
+	var input notifications.PushSubscription
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid input data"})
+		return
+	}
+
+	err := nc.service.Subscribe(c.Request.Context(), c.GetString("ID"), input)
+	if errors.Is(err, notifications.ErrInvalidEndpoint) {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Endpoint must be a public https URL"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error saving push subscription"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// Unsubscribe removes a browser from the push notifications
+// @Summary Unsubscribe from the push notifications
+// @Tags Notifications
+// @Security BearerAuth
+// @Param endpoint query string true "Endpoint of the subscription"
+// @Success 204
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /notifications/push-subscriptions [delete]
+func (nc *notificationsController) Unsubscribe(c *gin.Context) {
+	endpoint := c.Query("endpoint")
+	if endpoint == "" {
+		c.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Endpoint is required"})
+		return
+	}
+
+	err := nc.service.Unsubscribe(c.Request.Context(), c.GetString("ID"), endpoint)
+	if errors.Is(err, ErrSubscriptionNotFound) {
+		c.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Push subscription not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error deleting push subscription"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
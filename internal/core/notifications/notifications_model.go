@@ -0,0 +1,64 @@
+package notifications
+
+import "time"
+
+// Channels the users can be notified on
+const (
+	ChannelEmail    = "email"
+	ChannelSMS      = "sms"
+	ChannelWhatsApp = "whatsapp"
+	ChannelPush     = "push"
+)
+
+// Recipient is a user to notify, with the addresses of every channel
+type Recipient struct {
+	UserUUID string // User notified, whose push subscriptions are used
+	Email    string // Email address, every user can be notified by email
+	Phone    string // Phone number in E.164 format, for SMS and WhatsApp
+	Locale   string // Preferred locale, the default one when empty
+	Channel  string // Preferred channel, email when empty
+}
+
+// Notification is the content sent to a recipient, rendered by each channel from
+// the template of the same name in the locale of the recipient
+type Notification struct {
+	Template string // Template name, e.g. email.TemplateTwoFactorCode
+	Data     any    // Data of the template
+	Link     string // URL opened from a push notification (optional)
+}
+
+// PushSubscription is a browser a user subscribed to the web push notifications
+type PushSubscription struct {
+	SubscriptionUUID string    `json:"subscription_uuid" db:"subscription_uuid"` // UUID da inscrição (chave primaria)
+	UserUUID         string    `json:"user_uuid" db:"user_uuid"`                 // Usuário inscrito
+	Endpoint         string    `json:"endpoint" db:"endpoint"`                   // URL do serviço de push do navegador
+	P256dh           string    `json:"p256dh" db:"p256dh"`                       // Chave publica do navegador
+	Auth             string    `json:"auth" db:"auth"`                           // Segredo de autenticação do navegador
+	CreationDate     time.Time `json:"creation_date" db:"creation_date"`         // Data de criação
+}
+
+// ChannelsResponse lists the channels users may prefer
+type ChannelsResponse struct {
+	Channels       []string `json:"channels"`
+	VAPIDPublicKey string   `json:"vapid_public_key,omitempty"` // Application server key of PushManager.subscribe
+}
+
+// UserNotification is a notification of the notification center of a user
+type UserNotification struct {
+	NotificationUUID string     `json:"notification_uuid" db:"notification_uuid"` // UUID da notificação (chave primaria)
+	UserUUID         string     `json:"user_uuid" db:"user_uuid"`                 // Usuário notificado
+	ReferenceUUID    *string    `json:"reference_uuid" db:"reference_uuid"`       // Entidade da notificação, ex. o arquivo processado
+	Title            string     `json:"title" db:"title"`                         // Título
+	Description      string     `json:"description" db:"description"`             // Descrição
+	PriorityValue    *string    `json:"priority_value" db:"priority_value"`       // Nível de prioridade
+	PriorityColor    *string    `json:"priority_color" db:"priority_color"`       // Cor do nível de prioridade
+	Type             string     `json:"type" db:"type"`                           // Tipo, ex. file_processing
+	DeliveredDate    *time.Time `json:"delivered_date" db:"delivered_date"`       // Data de entrega pelo websocket
+	ReadDate         *time.Time `json:"read_date" db:"read_date"`                 // Data de leitura, nula quando não lida
+	CreationDate     time.Time  `json:"creation_date" db:"creation_date"`         // Data de criação
+}
+
+// UnreadCountResponse is the number of unread notifications of a user
+type UnreadCountResponse struct {
+	Count int `json:"count"`
+}
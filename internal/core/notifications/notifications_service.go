@@ -0,0 +1,111 @@
+package notifications
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/providers/notifications"
+	"context"
+	"errors"
+	"log"
+	"slices"
+)
+
+// NotificationService notifies the users on the channel they prefer, falling back
+// to email when that channel is not enabled, cannot reach them or fails
+type NotificationService interface {
+	Notify(ctx context.Context, uow *database.UnitOfWork, recipient Recipient, notification Notification) error
+	GetChannels() ChannelsResponse
+	Subscribe(ctx context.Context, userUUID string, subscription notifications.PushSubscription) error
+	Unsubscribe(ctx context.Context, userUUID string, endpoint string) error
+}
+
+type notificationService struct {
+	channels          map[string]NotificationChannel
+	subscriptionsRepo PushSubscriptionsRepository
+	vapidPublicKey    string
+}
+
+// NewNotificationService creates a NotificationService sending over the given
+// channels, which must include email
+func NewNotificationService(channels []NotificationChannel, subscriptionsRepo PushSubscriptionsRepository, config *configs.AppConfig) *notificationService {
+	byName := make(map[string]NotificationChannel, len(channels))
+	for _, channel := range channels {
+		byName[channel.Name()] = channel
+	}
+
+	return &notificationService{
+		channels:          byName,
+		subscriptionsRepo: subscriptionsRepo,
+		vapidPublicKey:    config.VAPIDPublicKey,
+	}
+}
+
+// Notify sends a notification to a recipient. Emails are queued in the transaction
+// of uow when not nil, and the other channels send once it is committed, so a
+// rolled back operation notifies nobody
+func (s *notificationService) Notify(ctx context.Context, uow *database.UnitOfWork, recipient Recipient, notification Notification) error {
+	channel, ok := s.channels[recipient.Channel]
+	if !ok || channel.Name() == ChannelEmail {
+		return s.channels[ChannelEmail].Send(ctx, uow, recipient, notification)
+	}
+
+	if uow == nil {
+		return s.sendOrEmail(ctx, channel, recipient, notification)
+	}
+
+	uow.AfterCommit(func() {
+		// the request may be done by the time the provider answers
+		if err := s.sendOrEmail(context.WithoutCancel(ctx), channel, recipient, notification); err != nil {
+			log.Printf("failed to notify user %s: %v", recipient.UserUUID, err)
+		}
+	})
+	return nil
+}
+
+// sendOrEmail sends a notification over a channel, or by email when it fails
+func (s *notificationService) sendOrEmail(ctx context.Context, channel NotificationChannel, recipient Recipient, notification Notification) error {
+	err := channel.Send(ctx, nil, recipient, notification)
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, ErrUnreachable) {
+		log.Printf("failed to notify user %s by %s, falling back to email: %v", recipient.UserUUID, channel.Name(), err)
+	}
+	return s.channels[ChannelEmail].Send(ctx, nil, recipient, notification)
+}
+
+// GetChannels returns the enabled channels, with the key the browsers subscribe to
+// the push notifications with when push is enabled
+func (s *notificationService) GetChannels() ChannelsResponse {
+	response := ChannelsResponse{Channels: []string{}}
+	for _, name := range []string{ChannelEmail, ChannelSMS, ChannelWhatsApp, ChannelPush} {
+		if _, ok := s.channels[name]; ok {
+			response.Channels = append(response.Channels, name)
+		}
+	}
+
+	if slices.Contains(response.Channels, ChannelPush) {
+		response.VAPIDPublicKey = s.vapidPublicKey
+	}
+	return response
+}
+
+// Subscribe stores the push subscription of a browser of a user. The endpoint must
+// be a public https URL, failing with notifications.ErrInvalidEndpoint otherwise
+func (s *notificationService) Subscribe(ctx context.Context, userUUID string, subscription notifications.PushSubscription) error {
+	if err := notifications.ValidateEndpoint(subscription.Endpoint); err != nil {
+		return err
+	}
+
+	return s.subscriptionsRepo.Save(ctx, PushSubscription{
+		UserUUID: userUUID,
+		Endpoint: subscription.Endpoint,
+		P256dh:   subscription.Keys.P256dh,
+		Auth:     subscription.Keys.Auth,
+	})
+}
+
+func (s *notificationService) Unsubscribe(ctx context.Context, userUUID string, endpoint string) error {
+	return s.subscriptionsRepo.Delete(ctx, userUUID, endpoint)
+}
@@ -0,0 +1,174 @@
+package notifications
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/core/email"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/providers/notifications"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTenant = "3f1c7a52-8a3e-4f1b-9c55-0d2b9c4e7a10"
+
+var testCode = Notification{
+	Template: email.TemplateTwoFactorCode,
+	Data:     email.TwoFactorCodeData{Code: "123456", ExpiresInMinutes: 15},
+}
+
+// recordingChannel stands in for the email channel, recording who it notified
+type recordingChannel struct {
+	sent []Recipient
+}
+
+func (c *recordingChannel) Name() string {
+	return ChannelEmail
+}
+
+func (c *recordingChannel) Send(ctx context.Context, uow *database.UnitOfWork, recipient Recipient, notification Notification) error {
+	c.sent = append(c.sent, recipient)
+	return nil
+}
+
+type failingProvider struct{}
+
+func (failingProvider) Send(ctx context.Context, message notifications.Message) error {
+	return errors.New("provider unavailable")
+}
+
+func newTestRenderer() MessageRenderer {
+	return NewMessageRenderer(email.NewTemplateRenderer(&configs.AppConfig{}))
+}
+
+func newTestTxManager(t *testing.T) (database.TxManager, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return database.NewTxManager(db, database.Tenancy{Mode: database.TenancyRow}), mock
+}
+
+func TestNotificationService_SendsOnPreferredChannelOnCommit(t *testing.T) {
+	emailChannel := &recordingChannel{}
+	sms := notifications.NewFakeNotificationProvider(ChannelSMS)
+	service := NewNotificationService([]NotificationChannel{emailChannel, NewPhoneChannel(ChannelSMS, sms, newTestRenderer())}, nil, &configs.AppConfig{})
+	txManager, mock := newTestTxManager(t)
+	ctx := database.WithTenant(context.Background(), testTenant)
+	recipient := Recipient{UserUUID: "user-1", Email: "user@example.com", Phone: "+5511999999999", Locale: "en", Channel: ChannelSMS}
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	err := txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		err := service.Notify(ctx, uow, recipient, testCode)
+		assert.Empty(t, sms.Messages(), "the code is not sent before the commit")
+		return err
+	})
+
+	assert.NoError(t, err)
+	if messages := sms.Messages(); assert.Len(t, messages, 1) {
+		assert.Equal(t, "+5511999999999", messages[0].To)
+		assert.Contains(t, messages[0].Body, "123456")
+	}
+	assert.Empty(t, emailChannel.sent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationService_RolledBackSendsNothing(t *testing.T) {
+	emailChannel := &recordingChannel{}
+	sms := notifications.NewFakeNotificationProvider(ChannelSMS)
+	service := NewNotificationService([]NotificationChannel{emailChannel, NewPhoneChannel(ChannelSMS, sms, newTestRenderer())}, nil, &configs.AppConfig{})
+	txManager, mock := newTestTxManager(t)
+	ctx := database.WithTenant(context.Background(), testTenant)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	err := txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		if err := service.Notify(ctx, uow, Recipient{Phone: "+5511999999999", Channel: ChannelSMS}, testCode); err != nil {
+			return err
+		}
+		return errors.New("operation failed")
+	})
+
+	assert.Error(t, err)
+	assert.Empty(t, sms.Messages())
+	assert.Empty(t, emailChannel.sent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationService_FallsBackToEmail(t *testing.T) {
+	tests := map[string]struct {
+		channels  []NotificationChannel
+		recipient Recipient
+	}{
+		"channel not enabled": {
+			recipient: Recipient{Email: "user@example.com", Phone: "+5511999999999", Channel: ChannelWhatsApp},
+		},
+		"no phone number": {
+			channels:  []NotificationChannel{NewPhoneChannel(ChannelSMS, notifications.NewFakeNotificationProvider(ChannelSMS), newTestRenderer())},
+			recipient: Recipient{Email: "user@example.com", Channel: ChannelSMS},
+		},
+		"provider failure": {
+			channels:  []NotificationChannel{NewPhoneChannel(ChannelSMS, failingProvider{}, newTestRenderer())},
+			recipient: Recipient{Email: "user@example.com", Phone: "+5511999999999", Channel: ChannelSMS},
+		},
+		"no preference": {
+			channels:  []NotificationChannel{NewPhoneChannel(ChannelSMS, failingProvider{}, newTestRenderer())},
+			recipient: Recipient{Email: "user@example.com", Phone: "+5511999999999"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			emailChannel := &recordingChannel{}
+			service := NewNotificationService(append([]NotificationChannel{emailChannel}, test.channels...), nil, &configs.AppConfig{})
+
+			err := service.Notify(context.Background(), nil, test.recipient, testCode)
+
+			assert.NoError(t, err)
+			assert.Equal(t, []Recipient{test.recipient}, emailChannel.sent)
+		})
+	}
+}
+
+func TestNotificationService_GetChannels(t *testing.T) {
+	push := NewPushChannel(notifications.NewFakeNotificationProvider(ChannelPush), nil, newTestRenderer())
+	service := NewNotificationService([]NotificationChannel{push, &recordingChannel{}}, nil, &configs.AppConfig{VAPIDPublicKey: "public-key"})
+
+	channels := service.GetChannels()
+
+	assert.Equal(t, []string{ChannelEmail, ChannelPush}, channels.Channels)
+	assert.Equal(t, "public-key", channels.VAPIDPublicKey)
+}
+
+func TestMessageRenderer_RendersEveryTemplateInEveryLocale(t *testing.T) {
+	renderer := newTestRenderer()
+	data := map[string]any{
+		email.TemplateTwoFactorCode:     email.TwoFactorCodeData{Code: "123456", ExpiresInMinutes: 15},
+		email.TemplatePasswordResetLink: email.PasswordResetLinkData{Link: "https://app.example.com/recovery-password?token=abc", ExpiresInMinutes: 15},
+		email.TemplatePasswordChanged:   email.PasswordChangedData{Name: "Alice"},
+	}
+
+	for _, locale := range []string{"pt-BR", "en", "es"} {
+		for name, data := range data {
+			rendered, err := renderer.Render(name, locale, data)
+
+			assert.NoError(t, err, "%s in %s", name, locale)
+			assert.NotEmpty(t, rendered.Title, "%s in %s", name, locale)
+			assert.NotEmpty(t, rendered.Body, "%s in %s", name, locale)
+		}
+	}
+}
+
+func TestMessageRenderer_NotFound(t *testing.T) {
+	_, err := newTestRenderer().Render("missing", "en", nil)
+
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+}
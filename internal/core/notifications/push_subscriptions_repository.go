@@ -0,0 +1,120 @@
+package notifications
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrSubscriptionNotFound is returned when the user has no subscription with the
+// given endpoint
+var ErrSubscriptionNotFound = errors.New("push subscription not found")
+
+type PushSubscriptionsRepository interface {
+	Save(ctx context.Context, subscription PushSubscription) error
+	GetByUser(ctx context.Context, userUUID string) ([]PushSubscription, error)
+	Delete(ctx context.Context, userUUID string, endpoint string) error
+}
+
+type pushSubscriptionsRepository struct {
+	db      database.DBTX
+	timeout time.Duration
+}
+
+func NewPushSubscriptionsRepository(db database.DBTX, timeout time.Duration) *pushSubscriptionsRepository {
+	return &pushSubscriptionsRepository{db: db, timeout: timeout}
+}
+
+// Save stores a subscription. A browser subscribing again keeps its endpoint, so
+// the existing subscription is moved to the user with the new keys
+func (r *pushSubscriptionsRepository) Save(ctx context.Context, subscription PushSubscription) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO default_schema.push_subscriptions (user_uuid, endpoint, p256dh, auth, tenant_uuid)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (tenant_uuid, endpoint) DO UPDATE
+		SET user_uuid = EXCLUDED.user_uuid,
+		    p256dh = EXCLUDED.p256dh,
+		    auth = EXCLUDED.auth`,
+		subscription.UserUUID, subscription.Endpoint, subscription.P256dh, subscription.Auth, tenantID)
+
+	if err != nil {
+		log.Print(err)
+		return errors.New("failed to save push subscription")
+	}
+
+	return nil
+}
+
+func (r *pushSubscriptionsRepository) GetByUser(ctx context.Context, userUUID string) ([]PushSubscription, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT subscription_uuid, user_uuid, endpoint, p256dh, auth, creation_date
+		FROM default_schema.push_subscriptions
+		WHERE user_uuid = $1 AND tenant_uuid = $2
+		ORDER BY creation_date`, userUUID, tenantID)
+
+	if err != nil {
+		return nil, errors.New("failed to retrive push subscriptions")
+	}
+
+	defer rows.Close()
+
+	models := []PushSubscription{}
+	for rows.Next() {
+		var model PushSubscription
+		if err := rows.Scan(&model.SubscriptionUUID, &model.UserUUID, &model.Endpoint, &model.P256dh, &model.Auth, &model.CreationDate); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		models = append(models, model)
+	}
+
+	return models, nil
+}
+
+func (r *pushSubscriptionsRepository) Delete(ctx context.Context, userUUID string, endpoint string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM default_schema.push_subscriptions
+		WHERE user_uuid = $1 AND endpoint = $2 AND tenant_uuid = $3`, userUUID, endpoint, tenantID)
+
+	if err != nil {
+		return errors.New("failed to delete push subscription")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.New("failed to delete push subscription")
+	}
+	if affected == 0 {
+		return ErrSubscriptionNotFound
+	}
+
+	return nil
+}
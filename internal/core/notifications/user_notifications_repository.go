@@ -0,0 +1,262 @@
+package notifications
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrNotificationNotFound is returned when the user has no notification with the
+// given ID
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// userNotificationColumns is the column list scanned by scanUserNotification
+const userNotificationColumns = `notification_uuid, user_uuid, reference_uuid, title, description, priority_value, priority_color, type, delivered_date, read_date, creation_date`
+
+type UserNotificationsRepository interface {
+	Create(ctx context.Context, notification UserNotification) (UserNotification, error)
+	GetByUser(ctx context.Context, userUUID string, unreadOnly bool, page, size int) ([]UserNotification, error)
+	GetUndelivered(ctx context.Context, userUUID string) ([]UserNotification, error)
+	CountUnread(ctx context.Context, userUUID string) (int, error)
+	MarkDelivered(ctx context.Context, ids []string) error
+	MarkRead(ctx context.Context, userUUID string, id string) error
+	MarkAllRead(ctx context.Context, userUUID string) error
+	Delete(ctx context.Context, userUUID string, id string) error
+}
+
+type userNotificationsRepository struct {
+	db      database.DBTX
+	timeout time.Duration
+}
+
+func NewUserNotificationsRepository(db database.DBTX, timeout time.Duration) *userNotificationsRepository {
+	return &userNotificationsRepository{db: db, timeout: timeout}
+}
+
+func scanUserNotification(row interface{ Scan(dest ...any) error }) (UserNotification, error) {
+	var model UserNotification
+	err := row.Scan(&model.NotificationUUID, &model.UserUUID, &model.ReferenceUUID, &model.Title, &model.Description, &model.PriorityValue, &model.PriorityColor, &model.Type, &model.DeliveredDate, &model.ReadDate, &model.CreationDate)
+	return model, err
+}
+
+func (r *userNotificationsRepository) Create(ctx context.Context, notification UserNotification) (UserNotification, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return UserNotification{}, err
+	}
+
+	model, err := scanUserNotification(r.db.QueryRowContext(ctx, `
+		INSERT INTO default_schema.user_notifications (user_uuid, reference_uuid, title, description, priority_value, priority_color, type, tenant_uuid)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+userNotificationColumns,
+		notification.UserUUID, notification.ReferenceUUID, notification.Title, notification.Description, notification.PriorityValue, notification.PriorityColor, notification.Type, tenantID))
+
+	if err != nil {
+		log.Print(err)
+		return UserNotification{}, errors.New("failed to create notification")
+	}
+
+	return model, nil
+}
+
+// GetByUser returns a page of the notifications of a user, or of the unread ones,
+// most recent first
+func (r *userNotificationsRepository) GetByUser(ctx context.Context, userUUID string, unreadOnly bool, page, size int) ([]UserNotification, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+userNotificationColumns+`
+		FROM default_schema.user_notifications
+		WHERE user_uuid = $1 AND tenant_uuid = $2
+		  AND (NOT $3 OR read_date IS NULL)
+		ORDER BY creation_date DESC
+		LIMIT $4 OFFSET $5`, userUUID, tenantID, unreadOnly, size, (page-1)*size)
+
+	if err != nil {
+		return nil, errors.New("failed to retrive notifications")
+	}
+
+	return scanUserNotifications(rows)
+}
+
+// GetUndelivered returns the notifications a user missed while disconnected,
+// oldest first
+func (r *userNotificationsRepository) GetUndelivered(ctx context.Context, userUUID string) ([]UserNotification, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+userNotificationColumns+`
+		FROM default_schema.user_notifications
+		WHERE user_uuid = $1 AND tenant_uuid = $2
+		  AND delivered_date IS NULL
+		ORDER BY creation_date`, userUUID, tenantID)
+
+	if err != nil {
+		return nil, errors.New("failed to retrive notifications")
+	}
+
+	return scanUserNotifications(rows)
+}
+
+func scanUserNotifications(rows *sql.Rows) ([]UserNotification, error) {
+	defer rows.Close()
+
+	models := []UserNotification{}
+	for rows.Next() {
+		model, err := scanUserNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		models = append(models, model)
+	}
+
+	return models, nil
+}
+
+func (r *userNotificationsRepository) CountUnread(ctx context.Context, userUUID string) (int, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM default_schema.user_notifications
+		WHERE user_uuid = $1 AND tenant_uuid = $2 AND read_date IS NULL`, userUUID, tenantID).Scan(&count)
+
+	if err != nil {
+		return 0, errors.New("failed to count unread notifications")
+	}
+
+	return count, nil
+}
+
+// MarkDelivered records the delivery of notifications over the websocket
+func (r *userNotificationsRepository) MarkDelivered(ctx context.Context, ids []string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		UPDATE default_schema.user_notifications
+		SET delivered_date = CURRENT_TIMESTAMP
+		WHERE notification_uuid = ANY($1) AND tenant_uuid = $2
+		  AND delivered_date IS NULL`, pq.Array(ids), tenantID)
+
+	if err != nil {
+		return errors.New("failed to mark notifications as delivered")
+	}
+
+	return nil
+}
+
+// MarkRead marks a notification of a user as read. Marking it again keeps the
+// date it was first read
+func (r *userNotificationsRepository) MarkRead(ctx context.Context, userUUID string, id string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE default_schema.user_notifications
+		SET read_date = COALESCE(read_date, CURRENT_TIMESTAMP)
+		WHERE notification_uuid = $1 AND user_uuid = $2 AND tenant_uuid = $3`, id, userUUID, tenantID)
+
+	if err != nil {
+		return errors.New("failed to mark notification as read")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.New("failed to mark notification as read")
+	}
+	if affected == 0 {
+		return ErrNotificationNotFound
+	}
+
+	return nil
+}
+
+func (r *userNotificationsRepository) MarkAllRead(ctx context.Context, userUUID string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		UPDATE default_schema.user_notifications
+		SET read_date = CURRENT_TIMESTAMP
+		WHERE user_uuid = $1 AND tenant_uuid = $2 AND read_date IS NULL`, userUUID, tenantID)
+
+	if err != nil {
+		return errors.New("failed to mark notifications as read")
+	}
+
+	return nil
+}
+
+func (r *userNotificationsRepository) Delete(ctx context.Context, userUUID string, id string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM default_schema.user_notifications
+		WHERE notification_uuid = $1 AND user_uuid = $2 AND tenant_uuid = $3`, id, userUUID, tenantID)
+
+	if err != nil {
+		return errors.New("failed to delete notification")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.New("failed to delete notification")
+	}
+	if affected == 0 {
+		return ErrNotificationNotFound
+	}
+
+	return nil
+}
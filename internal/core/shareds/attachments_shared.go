@@ -0,0 +1,13 @@
+package shareds
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"context"
+)
+
+// AttachmentsCleaner removes the files attached to a deleted record, the files
+// themselves being kept. Run it in the unit of work deleting the record, so the
+// record and its attachments are deleted together
+type AttachmentsCleaner interface {
+	DeleteByEntity(ctx context.Context, uow *database.UnitOfWork, entityType string, entityID string) error
+}
@@ -34,7 +34,7 @@ func NewStatusController(service StatusService) *statusController {
 // @Failure 500 {object} shareds.ErrorResponse
 // @Router /status [get]
 func (c *statusController) GetAll(ctx *gin.Context) {
-	statuses, err := c.service.GetAll()
+	statuses, err := c.service.GetAll(ctx.Request.Context())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching statuses"})
 		return
@@ -55,7 +55,7 @@ func (c *statusController) GetAll(ctx *gin.Context) {
 func (c *statusController) GetByID(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	status, err := c.service.GetByID(id)
+	status, err := c.service.GetByID(ctx.Request.Context(), id)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Status not found"})
 		return
@@ -81,7 +81,7 @@ func (c *statusController) Create(ctx *gin.Context) {
 		return
 	}
 
-	createdID, err := c.service.Create(input)
+	createdID, err := c.service.Create(ctx.Request.Context(), input)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error creating status"})
 		return
@@ -111,7 +111,7 @@ func (c *statusController) Update(ctx *gin.Context) {
 		return
 	}
 
-	err := c.service.Update(id, input)
+	err := c.service.Update(ctx.Request.Context(), id, input)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error updating status"})
 		return
@@ -132,7 +132,7 @@ func (c *statusController) Update(ctx *gin.Context) {
 func (c *statusController) Delete(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	err := c.service.Delete(id)
+	err := c.service.Delete(ctx.Request.Context(), id)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Status not found"})
 		return
@@ -166,7 +166,7 @@ func (c *statusController) Paginate(ctx *gin.Context) {
 		limit = 10
 	}
 
-	statuses, err := c.service.Paginate(page, limit)
+	statuses, err := c.service.Paginate(ctx.Request.Context(), page, limit)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching paginated statuses"})
 		return
@@ -1,34 +1,52 @@
 package status
 
 import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/utils"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 )
 
+// ErrStatusNotFound is returned when no status matches the given ID or name
+var ErrStatusNotFound = errors.New("status not found")
+
 // StatusRepository defines the interface for status CRUD operations
 type StatusRepository interface {
-	GetAll() ([]StatusResponse, error)
-	GetByID(id string) (StatusResponse, error)
-	GetByName(name string) (StatusResponse, error)
-	Create(entity StatusRequest) (string, error)
-	Update(id string, entity StatusRequest) error
-	Delete(id string) error
-	Paginate(page int, size int) ([]StatusResponse, error)
+	GetAll(ctx context.Context) ([]StatusResponse, error)
+	GetByID(ctx context.Context, id string) (StatusResponse, error)
+	GetByName(ctx context.Context, name string) (StatusResponse, error)
+	Create(ctx context.Context, entity StatusRequest) (string, error)
+	Update(ctx context.Context, id string, entity StatusRequest) error
+	Delete(ctx context.Context, id string) error
+	Paginate(ctx context.Context, page int, size int) ([]StatusResponse, error)
+	WithTx(tx database.DBTX) StatusRepository
 }
 
 type statusRepository struct {
-	db *sql.DB
+	db      database.DBTX
+	timeout time.Duration
 }
 
 // NewStatusRepository creates a new instance of StatusRepository
-func NewStatusRepository(db *sql.DB) *statusRepository {
-	return &statusRepository{db: db}
+func NewStatusRepository(db database.DBTX, timeout time.Duration) *statusRepository {
+	return &statusRepository{db: db, timeout: timeout}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *statusRepository) WithTx(tx database.DBTX) StatusRepository {
+	return &statusRepository{db: tx, timeout: r.timeout}
 }
 
 // GetAll retrieves all status records
-func (r *statusRepository) GetAll() ([]StatusResponse, error) {
+func (r *statusRepository) GetAll(ctx context.Context) ([]StatusResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var statuses []StatusResponse
-	rows, err := r.db.Query(`
+	rows, err := r.db.QueryContext(ctx, `
 		SELECT
 			status_uuid, name, creation_date, modification_date
 		FROM
@@ -51,7 +69,10 @@ func (r *statusRepository) GetAll() ([]StatusResponse, error) {
 }
 
 // GetByID retrieves a status by its ID
-func (r *statusRepository) GetByID(id string) (StatusResponse, error) {
+func (r *statusRepository) GetByID(ctx context.Context, id string) (StatusResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var status StatusResponse
 	query := `
 		SELECT
@@ -60,17 +81,20 @@ func (r *statusRepository) GetByID(id string) (StatusResponse, error) {
 			default_schema.status
 		WHERE status_uuid = $1
 	`
-	err := r.db.QueryRow(query, id).Scan(&status.StatusUUID, &status.Name, &status.CreationDate, &status.ModificationDate)
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&status.StatusUUID, &status.Name, &status.CreationDate, &status.ModificationDate)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return StatusResponse{}, fmt.Errorf("status not found: %w", err)
+			return StatusResponse{}, fmt.Errorf("%w: %w", ErrStatusNotFound, err)
 		}
 		return StatusResponse{}, fmt.Errorf("failed to retrieve status by ID: %w", err)
 	}
 	return status, nil
 }
 
-func (r *statusRepository) GetByName(name string) (StatusResponse, error) {
+func (r *statusRepository) GetByName(ctx context.Context, name string) (StatusResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var status StatusResponse
 	query := `
 		SELECT
@@ -79,10 +103,10 @@ func (r *statusRepository) GetByName(name string) (StatusResponse, error) {
 			default_schema.status
 		WHERE name = $1
 	`
-	err := r.db.QueryRow(query, name).Scan(&status.StatusUUID, &status.Name, &status.CreationDate, &status.ModificationDate)
+	err := r.db.QueryRowContext(ctx, query, name).Scan(&status.StatusUUID, &status.Name, &status.CreationDate, &status.ModificationDate)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return StatusResponse{}, fmt.Errorf("status not found: %w", err)
+			return StatusResponse{}, fmt.Errorf("%w: %w", ErrStatusNotFound, err)
 		}
 		return StatusResponse{}, fmt.Errorf("failed to retrieve status by ID: %w", err)
 	}
@@ -90,7 +114,10 @@ func (r *statusRepository) GetByName(name string) (StatusResponse, error) {
 }
 
 // Create inserts a new status and returns its UUID
-func (r *statusRepository) Create(entity StatusRequest) (string, error) {
+func (r *statusRepository) Create(ctx context.Context, entity StatusRequest) (string, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var id string
 	query := `
 		INSERT INTO default_schema.status (
@@ -99,7 +126,7 @@ func (r *statusRepository) Create(entity StatusRequest) (string, error) {
 		VALUES ($1)
 		RETURNING status_uuid
 	`
-	err := r.db.QueryRow(query, entity.Name).Scan(&id)
+	err := r.db.QueryRowContext(ctx, query, entity.Name).Scan(&id)
 	if err != nil {
 		return "", fmt.Errorf("failed to create status: %w", err)
 	}
@@ -107,8 +134,11 @@ func (r *statusRepository) Create(entity StatusRequest) (string, error) {
 }
 
 // Update modifies an existing status by its ID
-func (r *statusRepository) Update(id string, entity StatusRequest) error {
-	_, err := r.db.Exec(`
+func (r *statusRepository) Update(ctx context.Context, id string, entity StatusRequest) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `
 		UPDATE default_schema.status
 		SET
 			name = $2,
@@ -122,8 +152,11 @@ func (r *statusRepository) Update(id string, entity StatusRequest) error {
 }
 
 // Delete removes a status by its ID
-func (r *statusRepository) Delete(id string) error {
-	_, err := r.db.Exec(`
+func (r *statusRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `
 		DELETE FROM default_schema.status
 		WHERE status_uuid = $1
 	`, id)
@@ -133,7 +166,10 @@ func (r *statusRepository) Delete(id string) error {
 	return nil
 }
 
-func (r *statusRepository) Paginate(page int, size int) ([]StatusResponse, error) {
+func (r *statusRepository) Paginate(ctx context.Context, page int, size int) ([]StatusResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var entities []StatusResponse
 	offset := (page - 1) * size
 	query := `
@@ -141,7 +177,7 @@ func (r *statusRepository) Paginate(page int, size int) ([]StatusResponse, error
 		FROM default_schema.status
 		LIMIT $1 OFFSET $2
 	`
-	rows, err := r.db.Query(query, size, offset)
+	rows, err := r.db.QueryContext(ctx, query, size, offset)
 	if err != nil {
 		return nil, err
 	}
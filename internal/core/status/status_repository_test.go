@@ -0,0 +1,49 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusRepository_GetByNameCancelled(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewStatusRepository(db, 5*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = repo.GetByName(ctx, "Pending")
+
+	assert.True(t, errors.Is(err, context.Canceled), "expected context.Canceled, got: %v", err)
+}
+
+func TestStatusRepository_GetByNameQueryTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM default_schema.status").
+		WithArgs("Pending").
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"status_uuid", "name", "creation_date", "modification_date"}))
+
+	repo := NewStatusRepository(db, 20*time.Millisecond)
+
+	start := time.Now()
+	_, err = repo.GetByName(context.Background(), "Pending")
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "query should be abandoned at the configured deadline")
+}
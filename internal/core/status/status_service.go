@@ -1,43 +1,57 @@
 package status
 
+import (
+	"bernardtm/backend/internal/core/shareds"
+	"bernardtm/backend/internal/infra/database"
+	"context"
+)
+
 type StatusService interface {
-	GetAll() ([]StatusResponse, error)
-	GetByID(id string) (StatusResponse, error)
-	Create(entity StatusRequest) (string, error)
-	Update(id string, entity StatusRequest) error
-	Delete(id string) error
-	Paginate(page int, size int) ([]StatusResponse, error)
+	GetAll(ctx context.Context) ([]StatusResponse, error)
+	GetByID(ctx context.Context, id string) (StatusResponse, error)
+	Create(ctx context.Context, entity StatusRequest) (string, error)
+	Update(ctx context.Context, id string, entity StatusRequest) error
+	Delete(ctx context.Context, id string) error
+	Paginate(ctx context.Context, page int, size int) ([]StatusResponse, error)
 }
 
 type statusService struct {
-	repo StatusRepository
+	repo        StatusRepository
+	attachments shareds.AttachmentsCleaner
+	txManager   database.TxManager
 }
 
-func NewStatusService(repo StatusRepository) *statusService {
-	return &statusService{repo: repo}
+func NewStatusService(repo StatusRepository, attachments shareds.AttachmentsCleaner, txManager database.TxManager) *statusService {
+	return &statusService{repo: repo, attachments: attachments, txManager: txManager}
 }
 
-func (s *statusService) GetAll() ([]StatusResponse, error) {
-	return s.repo.GetAll()
+func (s *statusService) GetAll(ctx context.Context) ([]StatusResponse, error) {
+	return s.repo.GetAll(ctx)
 }
 
-func (s *statusService) GetByID(id string) (StatusResponse, error) {
-	return s.repo.GetByID(id)
+func (s *statusService) GetByID(ctx context.Context, id string) (StatusResponse, error) {
+	return s.repo.GetByID(ctx, id)
 }
 
-func (s *statusService) Create(entity StatusRequest) (string, error) {
+func (s *statusService) Create(ctx context.Context, entity StatusRequest) (string, error) {
 
-	return s.repo.Create(entity)
+	return s.repo.Create(ctx, entity)
 }
 
-func (s *statusService) Update(id string, entity StatusRequest) error {
-	return s.repo.Update(id, entity)
+func (s *statusService) Update(ctx context.Context, id string, entity StatusRequest) error {
+	return s.repo.Update(ctx, id, entity)
 }
 
-func (s *statusService) Delete(id string) error {
-	return s.repo.Delete(id)
+// Delete deletes a status along with its attachments
+func (s *statusService) Delete(ctx context.Context, id string) error {
+	return s.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		if err := s.repo.WithTx(uow.Tx()).Delete(ctx, id); err != nil {
+			return err
+		}
+		return s.attachments.DeleteByEntity(ctx, uow, "status", id)
+	})
 }
 
-func (s *statusService) Paginate(page int, size int) ([]StatusResponse, error) {
-	return s.repo.Paginate(page, size)
+func (s *statusService) Paginate(ctx context.Context, page int, size int) ([]StatusResponse, error) {
+	return s.repo.Paginate(ctx, page, size)
 }
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"bernardtm/backend/internal/core/shareds"
+	"bernardtm/backend/pkg/providers/storages"
+	"errors"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LocalStorageController interface {
+	Authenticate() gin.HandlerFunc
+	Serve(ctx *gin.Context)
+	Receive(ctx *gin.Context)
+}
+
+// localStorageController serves the objects of the local storage provider, taking
+// the place of the S3 endpoints when the API runs offline
+type localStorageController struct {
+	provider storages.LocalStorageProvider
+	public   []string
+}
+
+// NewLocalStorageController creates the controller of the local storage. The objects
+// under the public prefixes, whose links are stored as is (e.g. avatars and
+// thumbnails), are downloaded without signature like from a public S3 bucket
+func NewLocalStorageController(provider storages.LocalStorageProvider, public []string) *localStorageController {
+	return &localStorageController{provider: provider, public: public}
+}
+
+func objectKey(ctx *gin.Context) string {
+	return strings.TrimPrefix(ctx.Param("key"), "/")
+}
+
+// isPublic reports whether the object may be downloaded without signature
+func (c *localStorageController) isPublic(method string, key string) bool {
+	if method != http.MethodGet && method != http.MethodHead {
+		return false
+	}
+	// the key is cleaned like the provider does, so ../ cannot leave the prefix
+	clean := strings.TrimPrefix(path.Clean("/"+key), "/")
+	for _, prefix := range c.public {
+		if prefix != "" && strings.HasPrefix(clean, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate only accepts requests carrying a valid pre-signed URL signature,
+// except the downloads of public objects. The keys are not bound to a user or
+// tenant, so a token is not enough: the signature is issued once the file
+// services checked the access to the object
+func (c *localStorageController) Authenticate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if c.isPublic(ctx.Request.Method, objectKey(ctx)) {
+			ctx.Next()
+			return
+		}
+		if err := c.provider.VerifySignature(ctx.Request.Method, objectKey(ctx), ctx.Request.URL.Query()); err != nil {
+			ctx.JSON(http.StatusForbidden, shareds.ErrorResponse{Message: "Invalid or expired signature"})
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// Serve streams a stored object
+// @Summary Download a local storage object
+// @Tags Storage
+// @Produce octet-stream
+// @Param key path string true "Key of the object"
+// @Param expires query int true "Expiration of the signature"
+// @Param signature query string true "Signature of the pre-signed URL"
+// @Success 200 {file} file
+// @Failure 403 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Router /storage/local/{key} [get]
+func (c *localStorageController) Serve(ctx *gin.Context) {
+	object, err := c.provider.Download(ctx.Request.Context(), objectKey(ctx))
+	if errors.Is(err, storages.ErrObjectNotFound) {
+		ctx.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "File not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error downloading file"})
+		return
+	}
+	defer object.Body.Close()
+
+	contentType := object.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	headers := map[string]string{}
+	if fileName := ctx.Query("filename"); fileName != "" {
+		headers["Content-Disposition"] = mime.FormatMediaType("attachment", map[string]string{"filename": fileName})
+	}
+
+	ctx.DataFromReader(http.StatusOK, object.Size, contentType, object.Body, headers)
+}
+
+// Receive stores the body of a pre-signed upload
+// @Summary Upload a local storage object
+// @Tags Storage
+// @Accept octet-stream
+// @Param key path string true "Key of the object"
+// @Param expires query int true "Expiration of the signature"
+// @Param signature query string true "Signature of the pre-signed URL"
+// @Success 200
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 403 {object} shareds.ErrorResponse
+// @Router /storage/local/{key} [put]
+func (c *localStorageController) Receive(ctx *gin.Context) {
+	if err := c.provider.Write(ctx.Request.Context(), objectKey(ctx), ctx.Request.Body); err != nil {
+		ctx.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Error uploading file"})
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/pkg/providers/storages"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const testLocalURL = "http://localhost:8080/api/v1/storage/local"
+
+func newTestLocalStorage(t *testing.T) (storages.LocalStorageProvider, *gin.Engine) {
+	gin.SetMode(gin.TestMode)
+	provider := storages.NewLocalStorageProvider(&configs.AppConfig{
+		LocalStoragePath:   t.TempDir(),
+		LocalStorageURL:    testLocalURL,
+		LocalStorageSecret: "secret",
+	})
+	controller := NewLocalStorageController(provider, []string{"avatars/"})
+
+	router := gin.New()
+	local := router.Group("/api/v1/storage/local")
+	local.Use(controller.Authenticate())
+	local.GET("/*key", controller.Serve)
+	local.PUT("/*key", controller.Receive)
+	return provider, router
+}
+
+func serve(router *gin.Engine, method string, url string, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(method, strings.TrimPrefix(url, "http://localhost:8080"), strings.NewReader(body))
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestLocalStorageController_RejectsUnsignedRequests(t *testing.T) {
+	provider, router := newTestLocalStorage(t)
+	assert.NoError(t, provider.Write(context.Background(), "pdf/2024/1/2/abc", strings.NewReader("%PDF-1.7")))
+
+	assert.Equal(t, http.StatusForbidden, serve(router, http.MethodGet, testLocalURL+"/pdf/2024/1/2/abc", "").Code)
+	assert.Equal(t, http.StatusForbidden, serve(router, http.MethodPut, testLocalURL+"/pdf/2024/1/2/abc", "overwritten").Code)
+
+	object, err := provider.Download(context.Background(), "pdf/2024/1/2/abc")
+	if err != nil {
+		t.Fatalf("failed to download: %v", err)
+	}
+	defer object.Body.Close()
+	assert.Equal(t, int64(8), object.Size, "the object is not overwritten")
+}
+
+func TestLocalStorageController_AcceptsSignedRequests(t *testing.T) {
+	provider, router := newTestLocalStorage(t)
+	ctx := context.Background()
+
+	upload, err := provider.PresignUpload(ctx, "pdf/2024/1/2/abc", "application/pdf", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to presign upload: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodPut, upload.URL, "%PDF-1.7").Code)
+
+	download, err := provider.PresignDownload(ctx, "pdf/2024/1/2/abc", "report.pdf", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to presign download: %v", err)
+	}
+	recorder := serve(router, http.MethodGet, download.URL, "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "%PDF-1.7", recorder.Body.String())
+
+	// a download signature does not allow an upload
+	assert.Equal(t, http.StatusForbidden, serve(router, http.MethodPut, download.URL, "overwritten").Code)
+}
+
+func TestLocalStorageController_ServesPublicObjectsWithoutSignature(t *testing.T) {
+	provider, router := newTestLocalStorage(t)
+	ctx := context.Background()
+	assert.NoError(t, provider.Write(ctx, "avatars/tenant/user/v1/64.webp", strings.NewReader("avatar")))
+	assert.NoError(t, provider.Write(ctx, "pdf/2024/1/2/abc", strings.NewReader("%PDF-1.7")))
+	assert.NoError(t, provider.Write(ctx, "thumbnails/pdf/2024/1/2/abc.webp", strings.NewReader("thumbnail")))
+
+	recorder := serve(router, http.MethodGet, provider.ObjectURL("avatars/tenant/user/v1/64.webp"), "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "avatar", recorder.Body.String())
+
+	// thumbnails show the content of private files, so they need a signature
+	assert.Equal(t, http.StatusForbidden, serve(router, http.MethodGet, provider.ObjectURL("thumbnails/pdf/2024/1/2/abc.webp"), "").Code)
+	thumbnail, err := provider.PresignDownload(ctx, "thumbnails/pdf/2024/1/2/abc.webp", "abc.webp", time.Minute)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, thumbnail.URL, "").Code)
+	}
+
+	// the public prefixes are read only and cannot be escaped
+	assert.Equal(t, http.StatusForbidden, serve(router, http.MethodPut, provider.ObjectURL("avatars/tenant/user/v1/64.webp"), "overwritten").Code)
+	assert.Equal(t, http.StatusForbidden, serve(router, http.MethodGet, testLocalURL+"/avatars/..%2Fpdf/2024/1/2/abc", "").Code)
+}
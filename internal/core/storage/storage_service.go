@@ -2,10 +2,20 @@ package storage
 
 import (
 	"bernardtm/backend/pkg/providers/storages"
+	"context"
+	"io"
+	"time"
 )
 
 type StorageService interface {
-	Upload(email storages.UploadDto) (interface{}, error)
+	Put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error
+	Download(ctx context.Context, key string) (*storages.Object, error)
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (storages.ObjectInfo, error)
+	List(ctx context.Context, prefix string) ([]storages.ObjectInfo, error)
+	ObjectURL(key string) string
+	PresignUpload(ctx context.Context, key string, contentType string, expires time.Duration) (storages.PresignedURL, error)
+	PresignDownload(ctx context.Context, key string, fileName string, expires time.Duration) (storages.PresignedURL, error)
 }
 
 // StorageService provides methods to interact with a storage provider
@@ -18,7 +28,42 @@ func NewStorageService(provider storages.StorageProvider) *storageService {
 	return &storageService{provider: provider}
 }
 
-// Upload uploads a file using the configured provider
-func (s *storageService) Upload(dto storages.UploadDto) (interface{}, error) {
-	return s.provider.Upload(dto)
+// Put stores a file under the given key using the configured provider
+func (s *storageService) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error {
+	return s.provider.Put(ctx, key, body, contentType)
+}
+
+// Download opens a file using the configured provider. The caller must close its body
+func (s *storageService) Download(ctx context.Context, key string) (*storages.Object, error) {
+	return s.provider.Download(ctx, key)
+}
+
+// Delete removes a file using the configured provider
+func (s *storageService) Delete(ctx context.Context, key string) error {
+	return s.provider.Delete(ctx, key)
+}
+
+// Stat returns the metadata of a file using the configured provider
+func (s *storageService) Stat(ctx context.Context, key string) (storages.ObjectInfo, error) {
+	return s.provider.Stat(ctx, key)
+}
+
+// List returns the files under a prefix using the configured provider
+func (s *storageService) List(ctx context.Context, prefix string) ([]storages.ObjectInfo, error) {
+	return s.provider.List(ctx, prefix)
+}
+
+// ObjectURL returns the URL of a file using the configured provider
+func (s *storageService) ObjectURL(key string) string {
+	return s.provider.ObjectURL(key)
+}
+
+// PresignUpload returns a time limited URL to upload a file directly to the configured provider
+func (s *storageService) PresignUpload(ctx context.Context, key string, contentType string, expires time.Duration) (storages.PresignedURL, error) {
+	return s.provider.PresignUpload(ctx, key, contentType, expires)
+}
+
+// PresignDownload returns a time limited URL to download a file directly from the configured provider
+func (s *storageService) PresignDownload(ctx context.Context, key string, fileName string, expires time.Duration) (storages.PresignedURL, error) {
+	return s.provider.PresignDownload(ctx, key, fileName, expires)
 }
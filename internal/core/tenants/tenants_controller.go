@@ -0,0 +1,114 @@
+package tenants
+
+import (
+	"bernardtm/backend/internal/core/shareds"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TenantsController interface {
+	GetAll(ctx *gin.Context)
+	GetByID(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+type tenantsController struct {
+	service TenantsService
+}
+
+func NewTenantsController(service TenantsService) *tenantsController {
+	return &tenantsController{service: service}
+}
+
+// GetAll Get all Tenants
+// @Summary Get all Tenants
+// @Tags Tenants
+// @Produce json
+// @Security AdminKey
+// @Success 200 {array} TenantResponse
+// @Failure 401 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /tenants [get]
+func (c *tenantsController) GetAll(ctx *gin.Context) {
+	tenants, err := c.service.GetAll(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching tenants"})
+		return
+	}
+	ctx.JSON(http.StatusOK, tenants)
+}
+
+// GetByID gets a tenant by ID
+// @Summary Get Tenant by ID
+// @Tags Tenants
+// @Produce json
+// @Security AdminKey
+// @Param id path string true "ID of the Tenant"
+// @Success 200 {object} TenantResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Router /tenants/{id} [get]
+func (c *tenantsController) GetByID(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	tenant, err := c.service.GetByID(ctx.Request.Context(), id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Tenant not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, tenant)
+}
+
+// Create provisions a new tenant
+// @Summary Provision a new Tenant
+// @Tags Tenants
+// @Accept json
+// @Produce json
+// @Security AdminKey
+// @Param input body TenantRequest true "Tenant Data"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /tenants [post]
+func (c *tenantsController) Create(ctx *gin.Context) {
+	var input TenantRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Invalid input data"})
+		return
+	}
+
+	createdID, err := c.service.Provision(ctx.Request.Context(), input)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error provisioning tenant"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"id": createdID, "message": "Tenant provisioned successfully"})
+}
+
+// Delete deprovisions a tenant and removes all of its data
+// @Summary Deprovision Tenant by ID
+// @Tags Tenants
+// @Security AdminKey
+// @Param id path string true "ID of the Tenant"
+// @Success 204
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /tenants/{id} [delete]
+func (c *tenantsController) Delete(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	err := c.service.Deprovision(ctx.Request.Context(), id)
+	if errors.Is(err, ErrTenantNotFound) {
+		ctx.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "Tenant not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error deprovisioning tenant"})
+		return
+	}
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
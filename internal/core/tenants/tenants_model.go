@@ -0,0 +1,14 @@
+package tenants
+
+import (
+	"time"
+)
+
+type Tenant struct {
+	TenantUUID       string     `json:"tenant_uuid" db:"tenant_uuid"`                       // UUID do tenant (chave primária)
+	Name             string     `json:"name" db:"name"`                                     // Nome do tenant
+	Slug             string     `json:"slug" db:"slug"`                                     // Identificador legível (único)
+	CreationDate     *time.Time `json:"creation_date,omitempty" db:"creation_date"`         // Data de criação (padrão: data atual)
+	ModificationDate *time.Time `json:"modification_date,omitempty" db:"modification_date"` // Data de modificação (opcional)
+	StatusUUID       string     `json:"status_uuid" db:"status_uuid"`                       // UUID do status (chave estrangeira)
+}
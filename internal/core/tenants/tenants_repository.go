@@ -0,0 +1,127 @@
+package tenants
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/internal/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTenantNotFound is returned when no tenant matches the given ID
+var ErrTenantNotFound = errors.New("tenant not found")
+
+// TenantsRepository defines the interface for tenant operations. Tenants are
+// global records, so this repository is never scoped to a tenant
+type TenantsRepository interface {
+	GetAll(ctx context.Context) ([]TenantResponse, error)
+	GetByID(ctx context.Context, id string) (TenantResponse, error)
+	Create(ctx context.Context, entity TenantRequest, statusUUID string) (string, error)
+	Delete(ctx context.Context, id string) error
+	WithTx(tx database.DBTX) TenantsRepository
+}
+
+type tenantsRepository struct {
+	db      database.DBTX
+	timeout time.Duration
+}
+
+// NewTenantsRepository creates a new instance of TenantsRepository
+func NewTenantsRepository(db database.DBTX, timeout time.Duration) *tenantsRepository {
+	return &tenantsRepository{db: db, timeout: timeout}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *tenantsRepository) WithTx(tx database.DBTX) TenantsRepository {
+	return &tenantsRepository{db: tx, timeout: r.timeout}
+}
+
+// GetAll retrieves all tenants
+func (r *tenantsRepository) GetAll(ctx context.Context) ([]TenantResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var entities []TenantResponse
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT
+			tenant_uuid, name, slug, creation_date, modification_date, status_uuid
+		FROM
+			default_schema.tenants
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve all tenants: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entity TenantResponse
+		if err := rows.Scan(&entity.TenantUUID, &entity.Name, &entity.Slug, &entity.CreationDate, &entity.ModificationDate, &entity.StatusUUID); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		entities = append(entities, entity)
+	}
+
+	return entities, nil
+}
+
+// GetByID retrieves a tenant by its ID
+func (r *tenantsRepository) GetByID(ctx context.Context, id string) (TenantResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var entity TenantResponse
+	err := r.db.QueryRowContext(ctx, `
+		SELECT
+			tenant_uuid, name, slug, creation_date, modification_date, status_uuid
+		FROM
+			default_schema.tenants
+		WHERE tenant_uuid = $1
+	`, id).Scan(&entity.TenantUUID, &entity.Name, &entity.Slug, &entity.CreationDate, &entity.ModificationDate, &entity.StatusUUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return TenantResponse{}, ErrTenantNotFound
+		}
+		return TenantResponse{}, fmt.Errorf("failed to get tenant by ID: %w", err)
+	}
+
+	return entity, nil
+}
+
+// Create inserts a new tenant and returns its UUID
+func (r *tenantsRepository) Create(ctx context.Context, entity TenantRequest, statusUUID string) (string, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var id string
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO default_schema.tenants (name, slug, status_uuid)
+		VALUES ($1, $2, $3)
+		RETURNING tenant_uuid
+	`, entity.Name, entity.Slug, statusUUID).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("failed to create tenant: %w", err)
+	}
+	return id, nil
+}
+
+// Delete removes a tenant by its ID. Its rows in default_schema are removed by ON DELETE CASCADE
+func (r *tenantsRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM default_schema.tenants WHERE tenant_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete tenant: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete tenant: %w", err)
+	}
+	if affected == 0 {
+		return ErrTenantNotFound
+	}
+	return nil
+}
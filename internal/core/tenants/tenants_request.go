@@ -0,0 +1,6 @@
+package tenants
+
+type TenantRequest struct {
+	Name string `json:"name" db:"name" binding:"required" example:"Acme"`
+	Slug string `json:"slug" db:"slug" binding:"required" example:"acme"`
+}
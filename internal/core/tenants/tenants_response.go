@@ -0,0 +1,5 @@
+package tenants
+
+type TenantResponse struct {
+	Tenant
+}
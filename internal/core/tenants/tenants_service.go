@@ -0,0 +1,73 @@
+package tenants
+
+import (
+	"bernardtm/backend/internal/core/status"
+	"bernardtm/backend/internal/infra/database"
+	"context"
+)
+
+type TenantsService interface {
+	GetAll(ctx context.Context) ([]TenantResponse, error)
+	GetByID(ctx context.Context, id string) (TenantResponse, error)
+	Provision(ctx context.Context, entity TenantRequest) (string, error)
+	Deprovision(ctx context.Context, id string) error
+}
+
+type tenantsService struct {
+	repo       TenantsRepository
+	statusRepo status.StatusRepository
+	tenancy    database.Tenancy
+	txManager  database.TxManager
+}
+
+func NewTenantsService(repo TenantsRepository, statusRepo status.StatusRepository, tenancy database.Tenancy, txManager database.TxManager) *tenantsService {
+	return &tenantsService{
+		repo:       repo,
+		statusRepo: statusRepo,
+		tenancy:    tenancy,
+		txManager:  txManager,
+	}
+}
+
+func (s *tenantsService) GetAll(ctx context.Context) ([]TenantResponse, error) {
+	return s.repo.GetAll(ctx)
+}
+
+func (s *tenantsService) GetByID(ctx context.Context, id string) (TenantResponse, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// Provision creates the tenant record and its storage in a single transaction
+func (s *tenantsService) Provision(ctx context.Context, entity TenantRequest) (string, error) {
+	var id string
+
+	err := s.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		actived, err := s.statusRepo.WithTx(uow.Tx()).GetByName(ctx, "Actived")
+		if err != nil {
+			return err
+		}
+
+		id, err = s.repo.WithTx(uow.Tx()).Create(ctx, entity, actived.StatusUUID)
+		if err != nil {
+			return err
+		}
+
+		return s.tenancy.Provision(ctx, uow.Tx(), id)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Deprovision removes the tenant together with every record it owns
+func (s *tenantsService) Deprovision(ctx context.Context, id string) error {
+	return s.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		if err := s.repo.WithTx(uow.Tx()).Delete(ctx, id); err != nil {
+			return err
+		}
+
+		return s.tenancy.Deprovision(ctx, uow.Tx(), id)
+	})
+}
@@ -3,3 +3,5 @@ package users
 import "errors"
 
 var ErrUserAlreadyExists = errors.New("user already exists")
+
+var ErrUserNotFound = errors.New("user not found")
@@ -0,0 +1,146 @@
+package users
+
+import (
+	"bernardtm/backend/internal/core/files"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/imaging"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+)
+
+// avatarFormats are the formats every avatar size is generated in. The profile
+// image link points to a JPEG variant, which every client can display
+var avatarFormats = []imaging.Format{imaging.WebP, imaging.JPEG}
+
+// UpdateAvatar validates an image, generates the square variants of the avatar
+// from it and points the profile image link of the user to them. The uploaded
+// file is never stored: the variants are encoded from the decoded pixels, so no
+// EXIF metadata such as the location of a photo reaches the storage
+func (s *usersService) UpdateAvatar(ctx context.Context, id string, fileName string, content io.ReadSeeker) (AvatarResponse, error) {
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return AvatarResponse{}, err
+	}
+
+	inspection, err := s.avatarPolicy.InspectFile(fileName, content)
+	if err != nil {
+		return AvatarResponse{}, err
+	}
+
+	img, err := imaging.Decode(content)
+	if err != nil {
+		return AvatarResponse{}, &files.UploadPolicyError{
+			Status:  http.StatusUnprocessableEntity,
+			Reason:  "invalid_image",
+			Message: "Image could not be decoded",
+			Details: map[string]interface{}{"mime_type": inspection.MIMEType},
+		}
+	}
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return AvatarResponse{}, err
+	}
+	prefix := path.Join("avatars", tenantID, id)
+	version := newAvatarVersion()
+
+	var response AvatarResponse
+	var keys []string
+	for _, size := range s.avatarSizes {
+		thumbnail := imaging.Thumbnail(img, size)
+
+		for _, format := range avatarFormats {
+			var buffer bytes.Buffer
+			if err := imaging.Encode(&buffer, thumbnail, format); err != nil {
+				s.deleteAvatarObjects(ctx, keys)
+				return AvatarResponse{}, err
+			}
+
+			key := path.Join(prefix, version, strconv.Itoa(size)+"."+format.Extension())
+			if err := s.storageService.Put(ctx, key, bytes.NewReader(buffer.Bytes()), format.ContentType()); err != nil {
+				s.deleteAvatarObjects(ctx, keys)
+				return AvatarResponse{}, fmt.Errorf("failed to store avatar: %w", err)
+			}
+			keys = append(keys, key)
+
+			link := s.storageService.ObjectURL(key)
+			response.Variants = append(response.Variants, AvatarVariant{Size: size, Format: string(format), Link: link})
+			if format == imaging.JPEG {
+				// the sizes are sorted, so the largest JPEG wins
+				response.ProfileImageLink = link
+			}
+		}
+	}
+
+	if err := s.repo.UpdateProfileImage(ctx, id, response.ProfileImageLink); err != nil {
+		s.deleteAvatarObjects(ctx, keys)
+		return AvatarResponse{}, err
+	}
+
+	if user.ProfileImageLink != nil {
+		s.deletePreviousAvatar(ctx, prefix, version, *user.ProfileImageLink)
+	}
+
+	return response, nil
+}
+
+// deletePreviousAvatar removes the variants of the version the previous profile
+// image link pointed to. Versions left behind by concurrent uploads are kept, as
+// one of them may be the version the user ends up with
+func (s *usersService) deletePreviousAvatar(ctx context.Context, prefix string, current string, previousLink string) {
+	ctx = context.WithoutCancel(ctx)
+
+	objects, err := s.storageService.List(ctx, prefix+"/")
+	if err != nil {
+		log.Printf("failed to list avatars under %s: %v", prefix, err)
+		return
+	}
+
+	previous := ""
+	for _, object := range objects {
+		if s.storageService.ObjectURL(object.Key) == previousLink {
+			previous = path.Dir(object.Key)
+			break
+		}
+	}
+	if previous == "" || path.Base(previous) == current {
+		return
+	}
+
+	var keys []string
+	for _, object := range objects {
+		if path.Dir(object.Key) == previous {
+			keys = append(keys, object.Key)
+		}
+	}
+	s.deleteAvatarObjects(ctx, keys)
+}
+
+// deleteAvatarObjects removes stored variants, logging the failures
+func (s *usersService) deleteAvatarObjects(ctx context.Context, keys []string) {
+	ctx = context.WithoutCancel(ctx)
+
+	for _, key := range keys {
+		if err := s.storageService.Delete(ctx, key); err != nil {
+			log.Printf("failed to delete avatar %s: %v", key, err)
+		}
+	}
+}
+
+// newAvatarVersion returns a random name for a set of variants, so a new avatar
+// never shares its URLs with cached copies of the previous one
+func newAvatarVersion() string {
+	version := make([]byte, 8)
+	if _, err := rand.Read(version); err != nil {
+		panic(fmt.Sprintf("failed to generate avatar version: %v", err))
+	}
+	return hex.EncodeToString(version)
+}
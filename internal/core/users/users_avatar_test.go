@@ -0,0 +1,142 @@
+package users
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/core/files"
+	"bernardtm/backend/internal/core/storage"
+	"bernardtm/backend/internal/infra/database"
+	"bernardtm/backend/pkg/providers/storages"
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeUserRepository keeps the users in memory
+type fakeUserRepository struct {
+	UserRepository
+	users map[string]UserResponse
+}
+
+func newFakeUserRepository(users ...UserResponse) *fakeUserRepository {
+	repo := &fakeUserRepository{users: map[string]UserResponse{}}
+	for _, user := range users {
+		repo.users[user.Id] = user
+	}
+	return repo
+}
+
+func (r *fakeUserRepository) GetByID(ctx context.Context, id string) (UserResponse, error) {
+	if _, err := database.TenantID(ctx); err != nil {
+		return UserResponse{}, err
+	}
+	user, ok := r.users[id]
+	if !ok {
+		return UserResponse{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (r *fakeUserRepository) UpdateProfileImage(ctx context.Context, id string, link string) error {
+	user, ok := r.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.ProfileImageLink = &link
+	r.users[id] = user
+	return nil
+}
+
+func (r *fakeUserRepository) Delete(ctx context.Context, id string) error {
+	if _, ok := r.users[id]; !ok {
+		return ErrUserNotFound
+	}
+	delete(r.users, id)
+	return nil
+}
+
+func (r *fakeUserRepository) WithTx(tx database.DBTX) UserRepository {
+	return r
+}
+
+func newTestAvatarService(users ...UserResponse) (*usersService, *fakeUserRepository, storages.StorageProvider) {
+	repo := newFakeUserRepository(users...)
+	provider := storages.NewMemoryStorageProvider()
+	service := NewUsersService(
+		repo,
+		nil,
+		storage.NewStorageService(provider),
+		nil,
+		nil,
+		&configs.AppConfig{
+			AvatarUploadPolicy: configs.UploadPolicyConfig{MIMETypes: []string{"image/png"}, MaxImageWidth: 256, MaxImageHeight: 256},
+			AvatarSizes:        []int{64, 32},
+		},
+	)
+	return service, repo, provider
+}
+
+func avatarImage(t *testing.T, width, height int) *bytes.Reader {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+	return bytes.NewReader(buffer.Bytes())
+}
+
+func TestUsersService_UpdateAvatarReplacesVariants(t *testing.T) {
+	prefix := "avatars/" + tenantA + "/" + userOfA + "/"
+	previous := "memory://" + prefix + "old/64.jpg"
+	service, repo, provider := newTestAvatarService(UserResponse{Id: userOfA, Username: "alice", ProfileImageLink: &previous})
+	ctx := database.WithTenant(context.Background(), tenantA)
+	provider.Put(ctx, prefix+"old/64.jpg", strings.NewReader("old"), "image/jpeg")
+	provider.Put(ctx, prefix+"old/64.webp", strings.NewReader("old"), "image/webp")
+
+	avatar, err := service.UpdateAvatar(ctx, userOfA, "me.png", avatarImage(t, 120, 80))
+
+	assert.NoError(t, err)
+	if assert.Len(t, avatar.Variants, 4) {
+		assert.Equal(t, AvatarVariant{Size: 32, Format: "webp", Link: avatar.Variants[0].Link}, avatar.Variants[0])
+		assert.True(t, strings.HasSuffix(avatar.ProfileImageLink, "/64.jpg"))
+	}
+	if link := repo.users[userOfA].ProfileImageLink; assert.NotNil(t, link) {
+		assert.Equal(t, avatar.ProfileImageLink, *link)
+	}
+
+	objects, _ := provider.List(ctx, prefix)
+	assert.Len(t, objects, 4)
+	for _, object := range objects {
+		assert.NotContains(t, object.Key, "/old/")
+	}
+}
+
+func TestUsersService_UpdateAvatarRefusesInvalidImages(t *testing.T) {
+	service, repo, provider := newTestAvatarService(UserResponse{Id: userOfA, Username: "alice"})
+	ctx := database.WithTenant(context.Background(), tenantA)
+
+	_, err := service.UpdateAvatar(ctx, userOfA, "me.png", avatarImage(t, 512, 80))
+
+	var policyErr *files.UploadPolicyError
+	if assert.ErrorAs(t, err, &policyErr) {
+		assert.Equal(t, http.StatusUnprocessableEntity, policyErr.Status)
+	}
+	assert.Nil(t, repo.users[userOfA].ProfileImageLink)
+	objects, _ := provider.List(ctx, "avatars/")
+	assert.Empty(t, objects)
+}
+
+func TestUsersService_UpdateAvatarOfUnknownUser(t *testing.T) {
+	service, _, provider := newTestAvatarService()
+	ctx := database.WithTenant(context.Background(), tenantA)
+
+	_, err := service.UpdateAvatar(ctx, userOfA, "me.png", avatarImage(t, 120, 80))
+
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	objects, _ := provider.List(ctx, "avatars/")
+	assert.Empty(t, objects)
+}
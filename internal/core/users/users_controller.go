@@ -1,7 +1,9 @@
 package users
 
 import (
+	"bernardtm/backend/internal/core/files"
 	"bernardtm/backend/internal/core/shareds"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -15,6 +17,7 @@ type UsersController interface {
 	Update(ctx *gin.Context)
 	Delete(ctx *gin.Context)
 	Paginate(ctx *gin.Context)
+	UpdateAvatar(ctx *gin.Context)
 }
 type usersController struct {
 	service UsersService
@@ -33,7 +36,7 @@ func NewUsersController(service UsersService) *usersController {
 // @Failure 500 {object} shareds.ErrorResponse
 // @Router /users [get]
 func (c *usersController) GetAll(ctx *gin.Context) {
-	users, err := c.service.GetAll()
+	users, err := c.service.GetAll(ctx.Request.Context())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching users"})
 		return
@@ -54,7 +57,7 @@ func (c *usersController) GetAll(ctx *gin.Context) {
 func (c *usersController) GetByID(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	user, err := c.service.GetByID(id)
+	user, err := c.service.GetByID(ctx.Request.Context(), id)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "User not found"})
 		return
@@ -80,7 +83,7 @@ func (c *usersController) Create(ctx *gin.Context) {
 		return
 	}
 
-	createdID, err := c.service.Create(input)
+	createdID, err := c.service.Create(ctx.Request.Context(), input)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error creating user"})
 		return
@@ -111,7 +114,7 @@ func (c *usersController) Update(ctx *gin.Context) {
 		return
 	}
 
-	err := c.service.Update(id, input)
+	err := c.service.Update(ctx.Request.Context(), id, input)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error updating user"})
 		return
@@ -132,7 +135,7 @@ func (c *usersController) Update(ctx *gin.Context) {
 func (c *usersController) Delete(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	err := c.service.Delete(id)
+	err := c.service.Delete(ctx.Request.Context(), id)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "User not found"})
 		return
@@ -168,7 +171,7 @@ func (c *usersController) Paginate(ctx *gin.Context) {
 		return
 	}
 
-	users, err := c.service.Paginate(page, limit)
+	users, err := c.service.Paginate(ctx.Request.Context(), page, limit)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error fetching paginated users"})
 		return
@@ -176,3 +179,61 @@ func (c *usersController) Paginate(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, users)
 }
+
+// UpdateAvatar replaces the avatar of a usuário. Users only replace their own
+// avatar, the admins replace the avatar of any user through the admin route
+// @Summary Upload the avatar of a User
+// @Description Validates the image, strips its metadata and stores square WebP and JPEG variants of it. Users can only replace their own avatar, the admins use the admin key route
+// @Tags Users
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Security AdminKey
+// @Param X-Tenant-ID header string false "Tenant of the User, on the admin key route"
+// @Param id path string true "ID of the User"
+// @Param avatar formData file true "Avatar image"
+// @Success 200 {object} AvatarResponse
+// @Failure 400 {object} shareds.ErrorResponse
+// @Failure 403 {object} shareds.ErrorResponse
+// @Failure 404 {object} shareds.ErrorResponse
+// @Failure 413 {object} shareds.ApiError
+// @Failure 415 {object} shareds.ApiError
+// @Failure 422 {object} shareds.ApiError
+// @Failure 500 {object} shareds.ErrorResponse
+// @Router /users/{id}/avatar [put]
+// @Router /admin/users/{id}/avatar [put]
+func (c *usersController) UpdateAvatar(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if ctx.GetString("ID") != id && !ctx.GetBool("admin") {
+		ctx.JSON(http.StatusForbidden, shareds.ErrorResponse{Message: "Users can only change their own avatar"})
+		return
+	}
+
+	file, err := ctx.FormFile("avatar")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, shareds.ErrorResponse{Message: "Avatar is required"})
+		return
+	}
+
+	content, err := file.Open()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Failed to open avatar"})
+		return
+	}
+	defer content.Close()
+
+	avatar, err := c.service.UpdateAvatar(ctx.Request.Context(), id, file.Filename, content)
+	if errors.Is(err, ErrUserNotFound) {
+		ctx.JSON(http.StatusNotFound, shareds.ErrorResponse{Message: "User not found"})
+		return
+	}
+	if files.WriteUploadPolicyError(ctx, err) {
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, shareds.ErrorResponse{Message: "Error updating avatar"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, avatar)
+}
@@ -0,0 +1,85 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockUsersService is a mock implementation of the UsersService interface
+type MockUsersService struct {
+	UsersService
+	UpdateAvatarFunc func(id string) (AvatarResponse, error)
+}
+
+func (m *MockUsersService) UpdateAvatar(ctx context.Context, id string, fileName string, content io.ReadSeeker) (AvatarResponse, error) {
+	return m.UpdateAvatarFunc(id)
+}
+
+// avatarRequest returns a multipart request uploading an avatar of the user id
+func avatarRequest(t *testing.T, id string) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("avatar", "me.png")
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	part.Write([]byte("png"))
+	writer.Close()
+
+	request := httptest.NewRequest(http.MethodPut, "/users/"+id+"/avatar", &body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	return request
+}
+
+func TestUsersController_UpdateAvatar(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  string
+		admin   bool
+		status  int
+		updated bool
+	}{
+		{name: "own avatar", caller: userOfA, status: http.StatusOK, updated: true},
+		{name: "avatar of another user", caller: "another-user", status: http.StatusForbidden},
+		{name: "admin", admin: true, status: http.StatusOK, updated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := false
+			service := &MockUsersService{
+				UpdateAvatarFunc: func(id string) (AvatarResponse, error) {
+					assert.Equal(t, userOfA, id)
+					updated = true
+					return AvatarResponse{ProfileImageLink: "memory://avatars/64.jpg"}, nil
+				},
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.PUT("/users/:id/avatar", func(c *gin.Context) {
+				// stands in for the JWT and admin key middlewares
+				if tt.caller != "" {
+					c.Set("ID", tt.caller)
+				}
+				if tt.admin {
+					c.Set("admin", true)
+				}
+			}, NewUsersController(service).UpdateAvatar)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, avatarRequest(t, userOfA))
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.updated, updated)
+		})
+	}
+}
@@ -36,6 +36,9 @@ type User struct {
 	CreationDate     *time.Time `json:"creation_date,omitempty" db:"creation_date"`         // Data de criação (padrão: data atual)
 	ModificationDate *time.Time `json:"modification_date,omitempty" db:"modification_date"` // Data de modificação (opcional)
 	StatusUUID       string     `json:"status_uuid" db:"status_uuid"`                       // UUID do status (chave estrangeira)
+	Locale           *string    `json:"locale,omitempty" db:"locale"`                       // Idioma preferido do usuário (opcional, ex: pt-BR)
+	// Canal preferido para notificações (opcional: email, sms, whatsapp ou push)
+	NotificationChannel *string `json:"notification_channel,omitempty" db:"notification_channel"`
 }
 
 type UserProfileUser struct {
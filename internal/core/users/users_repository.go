@@ -1,38 +1,57 @@
 package users
 
 import (
+	"bernardtm/backend/internal/infra/database"
 	"bernardtm/backend/internal/utils"
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
+	"time"
 )
 
 // UserRepository define a interface para operações sobre usuários
 type UserRepository interface {
-	GetAll() ([]UserResponse, error)
-	GetByID(id string) (UserResponse, error)
-	Create(entity UserRequest) (string, error)
-	Update(id string, entity UserRequest) error
-	Delete(id string) error
-	Paginate(page, size int) ([]UserResponse, error)
-	GetByEmail(email string) (UserResponse, error)
+	GetAll(ctx context.Context) ([]UserResponse, error)
+	GetByID(ctx context.Context, id string) (UserResponse, error)
+	Create(ctx context.Context, entity UserRequest) (string, error)
+	Update(ctx context.Context, id string, entity UserRequest) error
+	Delete(ctx context.Context, id string) error
+	Paginate(ctx context.Context, page, size int) ([]UserResponse, error)
+	GetByEmail(ctx context.Context, email string) (UserResponse, error)
+	UpdateProfileImage(ctx context.Context, id string, link string) error
+	WithTx(tx database.DBTX) UserRepository
 }
 
 type userRepository struct {
-	db *sql.DB
+	db      database.DBTX
+	timeout time.Duration
 }
 
 // NewUserRepository cria uma nova instância de UserRepository
-func NewUserRepository(db *sql.DB) *userRepository {
-	return &userRepository{db: db}
+func NewUserRepository(db database.DBTX, timeout time.Duration) *userRepository {
+	return &userRepository{db: db, timeout: timeout}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *userRepository) WithTx(tx database.DBTX) UserRepository {
+	return &userRepository{db: tx, timeout: r.timeout}
 }
 
 // GetAll returns todos os usuários
-func (r *userRepository) GetAll() ([]UserResponse, error) {
+func (r *userRepository) GetAll(ctx context.Context) ([]UserResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var entities []UserResponse
-	rows, err := r.db.Query(`
+	rows, err := r.db.QueryContext(ctx, `
 		SELECT user_uuid, username, email, tax_number, creation_date, modification_date, status_uuid
-		FROM default_schema.users`)
+		FROM default_schema.users
+		WHERE tenant_uuid = $1`, tenantID)
 	if err != nil {
 		return nil, err
 	}
@@ -70,15 +89,23 @@ func (r *userRepository) GetAll() ([]UserResponse, error) {
 }
 
 // GetByID returns um usuário baseado no ID
-func (r *userRepository) GetByID(id string) (UserResponse, error) {
+func (r *userRepository) GetByID(ctx context.Context, id string) (UserResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return UserResponse{}, err
+	}
+
 	var entity UserResponse
-	err := r.db.QueryRow(`
-		SELECT user_uuid, username, email, tax_number, creation_date, modification_date, status_uuid, position, phone, profile_image_link
-		FROM default_schema.users WHERE user_uuid = $1`, id).
-		Scan(&entity.Id, &entity.Username, &entity.Email, &entity.TaxNumber, &entity.CreationDate, &entity.ModificationDate, &entity.StatusUUID, &entity.Position, &entity.Phone, &entity.ProfileImageLink)
+	err = r.db.QueryRowContext(ctx, `
+		SELECT user_uuid, username, email, tax_number, creation_date, modification_date, status_uuid, position, phone, profile_image_link, locale, notification_channel
+		FROM default_schema.users WHERE user_uuid = $1 AND tenant_uuid = $2`, id, tenantID).
+		Scan(&entity.Id, &entity.Username, &entity.Email, &entity.TaxNumber, &entity.CreationDate, &entity.ModificationDate, &entity.StatusUUID, &entity.Position, &entity.Phone, &entity.ProfileImageLink, &entity.Locale, &entity.NotificationChannel)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return entity, errors.New("user not found")
+			return entity, ErrUserNotFound
 		}
 		convertedCreationDate, err := utils.ParseDateISO8601(&entity.CreationDate)
 
@@ -107,9 +134,17 @@ func (r *userRepository) GetByID(id string) (UserResponse, error) {
 }
 
 // Create creates a new usuário
-func (r *userRepository) Create(entity UserRequest) (string, error) {
+func (r *userRepository) Create(ctx context.Context, entity UserRequest) (string, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return "", err
+	}
+
 	var id string
-	err := r.db.QueryRow(`
+	err = r.db.QueryRowContext(ctx, `
 		INSERT INTO default_schema.users (
 			username,
 			email,
@@ -117,8 +152,11 @@ func (r *userRepository) Create(entity UserRequest) (string, error) {
 			tax_number,
 			status_uuid,
 			position,
-			phone
-		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+			phone,
+			locale,
+			notification_channel,
+			tenant_uuid
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING user_uuid`,
 		entity.Username,
 		entity.Email,
@@ -127,6 +165,9 @@ func (r *userRepository) Create(entity UserRequest) (string, error) {
 		entity.StatusUUID,
 		entity.Position,
 		entity.Phone,
+		entity.Locale,
+		entity.NotificationChannel,
+		tenantID,
 	).Scan(&id)
 	if err != nil {
 		return "", err
@@ -135,22 +176,35 @@ func (r *userRepository) Create(entity UserRequest) (string, error) {
 }
 
 // Update altera os dados de um usuário
-func (r *userRepository) Update(id string, entity UserRequest) error {
-	_, err := r.db.Exec(`
+func (r *userRepository) Update(ctx context.Context, id string, entity UserRequest) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
 		UPDATE default_schema.users
 		SET username = $2,
 			email = $3,
 			password = $4,
 			tax_number = $5,
 			status_uuid = $6,
+			locale = COALESCE($7, locale),
+			notification_channel = COALESCE($8, notification_channel),
 			modification_date = CURRENT_DATE
-		WHERE user_uuid = $1`,
+		WHERE user_uuid = $1 AND tenant_uuid = $9`,
 		id,
 		entity.Username,
 		entity.Email,
 		entity.Password,
 		entity.TaxNumber,
 		entity.StatusUUID,
+		entity.Locale,
+		entity.NotificationChannel,
+		tenantID,
 	)
 	if err != nil {
 		return err
@@ -158,11 +212,44 @@ func (r *userRepository) Update(id string, entity UserRequest) error {
 	return nil
 }
 
+// UpdateProfileImage replaces the profile image link of a user
+func (r *userRepository) UpdateProfileImage(ctx context.Context, id string, link string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE default_schema.users
+		SET profile_image_link = $2,
+			modification_date = CURRENT_DATE
+		WHERE user_uuid = $1 AND tenant_uuid = $3`, id, link, tenantID)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
 // Delete deleta um usuário by ID
-func (r *userRepository) Delete(id string) error {
-	_, err := r.db.Exec(`
+func (r *userRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
 		DELETE FROM default_schema.users
-		WHERE user_uuid = $1`, id)
+		WHERE user_uuid = $1 AND tenant_uuid = $2`, id, tenantID)
 	if err != nil {
 		return err
 	}
@@ -170,13 +257,22 @@ func (r *userRepository) Delete(id string) error {
 }
 
 // Paginate returns uma lista de usuários paginada
-func (r *userRepository) Paginate(page, size int) ([]UserResponse, error) {
+func (r *userRepository) Paginate(ctx context.Context, page, size int) ([]UserResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var entities []UserResponse
 	offset := (page - 1) * size
-	rows, err := r.db.Query(`
+	rows, err := r.db.QueryContext(ctx, `
 		SELECT user_uuid, username, email, tax_number, creation_date, modification_date, status_uuid
 		FROM default_schema.users
-		LIMIT $1 OFFSET $2`, size, offset)
+		WHERE tenant_uuid = $3
+		LIMIT $1 OFFSET $2`, size, offset, tenantID)
 	if err != nil {
 		return nil, err
 	}
@@ -215,15 +311,23 @@ func (r *userRepository) Paginate(page, size int) ([]UserResponse, error) {
 }
 
 // GetByEmail returns an user by email
-func (r *userRepository) GetByEmail(email string) (UserResponse, error) {
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (UserResponse, error) {
+	ctx, cancel := utils.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tenantID, err := database.TenantID(ctx)
+	if err != nil {
+		return UserResponse{}, err
+	}
+
 	var entity UserResponse
-	err := r.db.QueryRow(`
-		SELECT user_uuid, username, email, password, tax_number, creation_date, modification_date, status_uuid
-		FROM default_schema.users WHERE email = $1`, email).
-		Scan(&entity.Id, &entity.Username, &entity.Email, &entity.Password, &entity.TaxNumber, &entity.CreationDate, &entity.ModificationDate, &entity.StatusUUID)
+	err = r.db.QueryRowContext(ctx, `
+		SELECT user_uuid, username, email, password, tax_number, creation_date, modification_date, status_uuid, phone, locale, notification_channel
+		FROM default_schema.users WHERE email = $1 AND tenant_uuid = $2`, email, tenantID).
+		Scan(&entity.Id, &entity.Username, &entity.Email, &entity.Password, &entity.TaxNumber, &entity.CreationDate, &entity.ModificationDate, &entity.StatusUUID, &entity.Phone, &entity.Locale, &entity.NotificationChannel)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return entity, errors.New("user not found")
+			return entity, ErrUserNotFound
 		}
 
 		convertedCreationDate, err := utils.ParseDateISO8601(&entity.CreationDate)
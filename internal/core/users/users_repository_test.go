@@ -0,0 +1,86 @@
+package users
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	tenantA = "0192d1a4-0000-7000-8000-00000000000a"
+	tenantB = "0192d1a4-0000-7000-8000-00000000000b"
+	userOfA = "0192d1a4-1111-7000-8000-000000000001"
+)
+
+var userColumns = []string{"user_uuid", "username", "email", "tax_number", "creation_date", "modification_date", "status_uuid", "position", "phone", "profile_image_link", "locale", "notification_channel"}
+
+func TestUserRepository_RequiresTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db, 5*time.Second)
+
+	_, err = repo.GetByID(context.Background(), userOfA)
+	assert.True(t, errors.Is(err, database.ErrTenantRequired), "expected ErrTenantRequired, got: %v", err)
+	_, err = repo.GetAll(context.Background())
+	assert.True(t, errors.Is(err, database.ErrTenantRequired), "expected ErrTenantRequired, got: %v", err)
+
+	// no statement may reach the database without a tenant
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_CrossTenantReadIsImpossible(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	// the row belongs to tenant A, so the database only returns it for tenant A
+	mock.ExpectQuery("SELECT (.+) FROM default_schema.users WHERE user_uuid = \\$1 AND tenant_uuid = \\$2").
+		WithArgs(userOfA, tenantA).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userOfA, "alice", "alice@a.com", nil, "2024-10-10", nil, "status", nil, nil, nil, nil, nil))
+	mock.ExpectQuery("SELECT (.+) FROM default_schema.users WHERE user_uuid = \\$1 AND tenant_uuid = \\$2").
+		WithArgs(userOfA, tenantB).
+		WillReturnRows(sqlmock.NewRows(userColumns))
+
+	repo := NewUserRepository(db, 5*time.Second)
+
+	user, err := repo.GetByID(database.WithTenant(context.Background(), tenantA), userOfA)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+
+	_, err = repo.GetByID(database.WithTenant(context.Background(), tenantB), userOfA)
+	assert.EqualError(t, err, "user not found")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_SchemaModeUsesTenantSchema(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "tenant_0192d1a4_0000_7000_8000_00000000000b".users WHERE`)).
+		WithArgs(userOfA, tenantB).
+		WillReturnRows(sqlmock.NewRows(userColumns))
+
+	tenancy := database.Tenancy{Mode: database.TenancySchema}
+	repo := NewUserRepository(tenancy.Wrap(db), 5*time.Second)
+
+	_, err = repo.GetByID(database.WithTenant(context.Background(), tenantB), userOfA)
+	assert.EqualError(t, err, "user not found")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -13,6 +13,9 @@ type UserRequest struct {
 	StatusUUID   string  `json:"status_uuid" db:"status_uuid" example:""`
 	Position     *string `json:"position" db:"position" example:""`
 	Phone        *string `json:"phone" db:"phone" example:""`
+	Locale       *string `json:"locale" db:"locale" binding:"omitempty,oneof=pt-BR en es" example:"pt-BR"`
+	// NotificationChannel is the channel the user prefers to be notified on
+	NotificationChannel *string `json:"notification_channel" db:"notification_channel" binding:"omitempty,oneof=email sms whatsapp push" example:"email"`
 }
 
 type UserVisualizations struct {
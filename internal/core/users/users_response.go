@@ -14,6 +14,21 @@ type UserResponse struct {
 	StatusUUID       string  `json:"status_uuid"`
 	Phone            *string `json:"phone"`
 	ProfileImageLink *string `json:"profile_image_link"`
+	Locale           *string `json:"locale"`
+	// NotificationChannel is the channel the user prefers to be notified on
+	NotificationChannel *string `json:"notification_channel"`
+}
+
+// AvatarResponse lists the variants generated from an uploaded avatar
+type AvatarResponse struct {
+	ProfileImageLink string          `json:"profile_image_link"`
+	Variants         []AvatarVariant `json:"variants"`
+}
+
+type AvatarVariant struct {
+	Size   int    `json:"size"`
+	Format string `json:"format"`
+	Link   string `json:"link"`
 }
 
 type UserTableFrontEndResponse struct {
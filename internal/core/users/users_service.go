@@ -1,46 +1,65 @@
 package users
 
 import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/core/files"
+	"bernardtm/backend/internal/core/shareds"
 	"bernardtm/backend/internal/core/status"
 	"bernardtm/backend/internal/core/storage"
+	"bernardtm/backend/internal/infra/database"
+	"context"
+	"io"
+	"slices"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UsersService interface {
-	GetAll() ([]UserResponse, error)
-	GetByID(id string) (UserResponse, error)
-	Create(entity UserRequest) (string, error)
-	Update(id string, entity UserRequest) error
-	Delete(id string) error
-	Paginate(page int, size int) ([]UserResponse, error)
+	GetAll(ctx context.Context) ([]UserResponse, error)
+	GetByID(ctx context.Context, id string) (UserResponse, error)
+	Create(ctx context.Context, entity UserRequest) (string, error)
+	Update(ctx context.Context, id string, entity UserRequest) error
+	Delete(ctx context.Context, id string) error
+	Paginate(ctx context.Context, page int, size int) ([]UserResponse, error)
+	UpdateAvatar(ctx context.Context, id string, fileName string, content io.ReadSeeker) (AvatarResponse, error)
 }
 
 type usersService struct {
 	repo             UserRepository
 	statusRepository status.StatusRepository
 	storageService   storage.StorageService
+	avatarPolicy     files.UploadPolicy
+	avatarSizes      []int
+	attachments      shareds.AttachmentsCleaner
+	txManager        database.TxManager
 }
 
 func NewUsersService(repo UserRepository,
 	statusRepository status.StatusRepository,
-	storageService storage.StorageService) *usersService {
+	storageService storage.StorageService,
+	attachments shareds.AttachmentsCleaner,
+	txManager database.TxManager,
+	config *configs.AppConfig) *usersService {
 	return &usersService{
 		repo:             repo,
 		statusRepository: statusRepository,
 		storageService:   storageService,
+		avatarPolicy:     files.NewUploadPolicy("avatars", config.AvatarUploadPolicy),
+		avatarSizes:      slices.Sorted(slices.Values(config.AvatarSizes)),
+		attachments:      attachments,
+		txManager:        txManager,
 	}
 }
 
-func (s *usersService) GetAll() ([]UserResponse, error) {
-	return s.repo.GetAll()
+func (s *usersService) GetAll(ctx context.Context) ([]UserResponse, error) {
+	return s.repo.GetAll(ctx)
 }
 
-func (s *usersService) GetByID(id string) (UserResponse, error) {
-	return s.repo.GetByID(id)
+func (s *usersService) GetByID(ctx context.Context, id string) (UserResponse, error) {
+	return s.repo.GetByID(ctx, id)
 }
 
-func (s *usersService) Create(entity UserRequest) (string, error) {
+func (s *usersService) Create(ctx context.Context, entity UserRequest) (string, error) {
 	if entity.Password != "" {
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(entity.Password), bcrypt.DefaultCost)
 		if err != nil {
@@ -48,10 +67,10 @@ func (s *usersService) Create(entity UserRequest) (string, error) {
 		}
 		entity.Password = string(hashedPassword)
 	}
-	return s.repo.Create(entity)
+	return s.repo.Create(ctx, entity)
 }
 
-func (s *usersService) Update(id string, entity UserRequest) error {
+func (s *usersService) Update(ctx context.Context, id string, entity UserRequest) error {
 	if entity.Password != "" {
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(entity.Password), bcrypt.DefaultCost)
 		if err != nil {
@@ -59,13 +78,19 @@ func (s *usersService) Update(id string, entity UserRequest) error {
 		}
 		entity.Password = string(hashedPassword)
 	}
-	return s.repo.Update(id, entity)
+	return s.repo.Update(ctx, id, entity)
 }
 
-func (s *usersService) Delete(id string) error {
-	return s.repo.Delete(id)
+// Delete deletes a user along with the attachments of the user
+func (s *usersService) Delete(ctx context.Context, id string) error {
+	return s.txManager.WithinTransaction(ctx, func(uow *database.UnitOfWork) error {
+		if err := s.repo.WithTx(uow.Tx()).Delete(ctx, id); err != nil {
+			return err
+		}
+		return s.attachments.DeleteByEntity(ctx, uow, "users", id)
+	})
 }
 
-func (s *usersService) Paginate(page int, size int) ([]UserResponse, error) {
-	return s.repo.Paginate(page, size)
+func (s *usersService) Paginate(ctx context.Context, page int, size int) ([]UserResponse, error) {
+	return s.repo.Paginate(ctx, page, size)
 }
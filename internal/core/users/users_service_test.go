@@ -0,0 +1,62 @@
+package users
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/infra/database"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingCleaner records the records whose attachments were deleted, failing
+// with err when set
+type recordingCleaner struct {
+	err     error
+	cleaned []string
+	inUow   bool
+}
+
+func (c *recordingCleaner) DeleteByEntity(ctx context.Context, uow *database.UnitOfWork, entityType string, entityID string) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.cleaned = append(c.cleaned, entityType+":"+entityID)
+	c.inUow = uow != nil
+	return nil
+}
+
+var errCleanup = errors.New("failed to delete attachments")
+
+func TestUsersService_Delete(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		cleanerErr error
+		err        error
+		cleaned    []string
+	}{
+		{name: "deletes the user and its attachments", id: "user-1", cleaned: []string{"users:user-1"}},
+		{name: "keeps the attachments of an unknown user", id: "user-2", err: ErrUserNotFound},
+		{name: "fails when the attachments cannot be deleted", id: "user-1", cleanerErr: errCleanup, err: errCleanup},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeUserRepository(UserResponse{Id: "user-1"})
+			cleaner := &recordingCleaner{err: tt.cleanerErr}
+			service := NewUsersService(repo, nil, nil, cleaner, database.NewFakeTxManager(), &configs.AppConfig{})
+			ctx := database.WithTenant(context.Background(), tenantA)
+
+			err := service.Delete(ctx, tt.id)
+
+			assert.ErrorIs(t, err, tt.err)
+			if tt.err == nil {
+				assert.NotContains(t, repo.users, tt.id)
+				assert.True(t, cleaner.inUow, "the attachments are deleted in the transaction of the user")
+			}
+			assert.Equal(t, tt.cleaned, cleaner.cleaned)
+		})
+	}
+}
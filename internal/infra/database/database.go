@@ -0,0 +1,14 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DBTX is the common subset of *sql.DB and *sql.Tx used by repositories, so the
+// same repository can run standalone or as part of a unit of work
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
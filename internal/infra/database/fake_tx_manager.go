@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"errors"
+)
+
+type fakeTxManager struct{}
+
+// NewFakeTxManager creates a TxManager for tests of services backed by in-memory
+// repositories. Its units of work hold no transaction, so nothing is rolled back,
+// but the compensations and the actions waiting for the commit run as usual
+func NewFakeTxManager() *fakeTxManager {
+	return &fakeTxManager{}
+}
+
+func (m *fakeTxManager) WithinTransaction(ctx context.Context, fn func(uow *UnitOfWork) error) error {
+	uow := &UnitOfWork{}
+
+	if err := fn(uow); err != nil {
+		return errors.Join(err, uow.compensate())
+	}
+
+	for _, fn := range uow.afterCommit {
+		fn()
+	}
+	return nil
+}
@@ -0,0 +1,409 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// migrationsLockID is the advisory lock key shared by every instance, so only one
+// of them applies migrations while the others wait
+const migrationsLockID int64 = 4829174356
+
+// migrationFileRegex matches the golang-migrate naming scheme, e.g. 000001_init.up.sql
+var migrationFileRegex = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// ErrDirtyDatabase is returned when a previous migration failed halfway and the
+// version must be fixed with Force before migrating again
+var ErrDirtyDatabase = errors.New("database is dirty, fix it and run force")
+
+type migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes the state of a single migration
+type MigrationStatus struct {
+	Version int64  `json:"version"`
+	Name    string `json:"name"`
+	Applied bool   `json:"applied"`
+}
+
+// MigrationsReport describes the current version and every known migration
+type MigrationsReport struct {
+	Version    int64             `json:"version"`
+	Dirty      bool              `json:"dirty"`
+	Migrations []MigrationStatus `json:"migrations"`
+}
+
+// Migrator applies embedded SQL migrations. Versions are tracked in the same
+// schema_migrations table used by the migrate CLI, so both can be used on the
+// same database.
+//
+// In schema mode the tenant migrations, found in the tenant folder of the source,
+// are then applied to the schema of every tenant, whose version is tracked in its
+// own schema_migrations table. They bring the tenant tables of the schemas
+// provisioned before a migration up to date, their default_schema tenant tables
+// being rewritten to the schema of each tenant
+type Migrator struct {
+	db               *sql.DB
+	tenancy          Tenancy
+	migrations       []migration
+	tenantMigrations []migration
+}
+
+// NewMigrator loads every migration found in the root of source, and the tenant
+// migrations found in its tenant folder
+func NewMigrator(db *sql.DB, source fs.FS, tenancy Tenancy) (*Migrator, error) {
+	migrations, err := loadMigrations(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var tenantMigrations []migration
+	if tenantSource, err := fs.Sub(source, "tenant"); err == nil {
+		if tenantMigrations, err = loadMigrations(tenantSource); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return &Migrator{db: db, tenancy: tenancy, migrations: migrations, tenantMigrations: tenantMigrations}, nil
+}
+
+// loadMigrations reads and sorts the migrations of source by version
+func loadMigrations(source fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(source, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		matches := migrationFileRegex.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(source, path.Clean(entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %q: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		}
+		if matches[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up applies every pending migration, then the pending tenant migrations of every
+// tenant in schema mode
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		version, dirty, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return ErrDirtyDatabase
+		}
+
+		for _, mig := range m.migrations {
+			if mig.Version <= version {
+				continue
+			}
+			log.Printf("Applying migration %d_%s", mig.Version, mig.Name)
+			if err := m.apply(ctx, conn, mig.Up, mig.Version); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+		}
+
+		if m.tenancy.Mode != TenancySchema {
+			return nil
+		}
+		return m.upTenants(ctx, conn)
+	})
+}
+
+// upTenants provisions the tenants without schema, such as the default tenant
+// seeded by the migrations, and applies the pending tenant migrations of the others
+func (m *Migrator) upTenants(ctx context.Context, conn *sql.Conn) error {
+	rows, err := conn.QueryContext(ctx, `SELECT tenant_uuid FROM default_schema.tenants ORDER BY tenant_uuid`)
+	if err != nil {
+		return fmt.Errorf("failed to list tenants: %w", err)
+	}
+	var tenantIDs []string
+	for rows.Next() {
+		var tenantID string
+		if err := rows.Scan(&tenantID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to list tenants: %w", err)
+		}
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list tenants: %w", err)
+	}
+
+	for _, tenantID := range tenantIDs {
+		if err := m.upTenant(ctx, conn, tenantID); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenantID, err)
+		}
+	}
+	return nil
+}
+
+// upTenant brings the schema of a tenant up to date
+func (m *Migrator) upTenant(ctx context.Context, conn *sql.Conn, tenantID string) error {
+	var exists bool
+	err := conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM information_schema.schemata WHERE schema_name = $1)`, TenantSchema(tenantID)).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to look up tenant schema: %w", err)
+	}
+	if !exists {
+		log.Printf("Provisioning schema of tenant %s", tenantID)
+		return m.tenancy.Provision(ctx, conn, tenantID)
+	}
+
+	// schemas provisioned before their version was recorded start from scratch, the
+	// tenant migrations adding what they miss only
+	schema := pq.QuoteIdentifier(TenantSchema(tenantID))
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+schema+".schema_migrations (version BIGINT NOT NULL PRIMARY KEY)"); err != nil {
+		return fmt.Errorf("failed to create tenant migrations table: %w", err)
+	}
+	var version int64
+	if err := conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), -1) FROM "+schema+".schema_migrations").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read tenant migrations version: %w", err)
+	}
+
+	for _, mig := range m.tenantMigrations {
+		if mig.Version <= version {
+			continue
+		}
+		log.Printf("Applying tenant migration %d_%s to tenant %s", mig.Version, mig.Name, tenantID)
+		if err := m.applyTenant(ctx, conn, schema, tenantQuery(tenantID, mig.Up), mig.Version); err != nil {
+			return fmt.Errorf("tenant migration %d_%s failed: %w", mig.Version, mig.Name, err)
+		}
+	}
+	return nil
+}
+
+// applyTenant runs a tenant migration body and records the version of the tenant
+// schema in one transaction
+func (m *Migrator) applyTenant(ctx context.Context, conn *sql.Conn, schema string, body string, version int64) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, body); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM "+schema+".schema_migrations"); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO "+schema+".schema_migrations (version) VALUES ($1)", version); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Down rolls back the given number of applied migrations, or all of them when steps <= 0.
+// The tenant schemas are not rolled back: they keep their tables and version, so
+// the next Up does not migrate them again
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		version, dirty, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return ErrDirtyDatabase
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if mig.Version > version {
+				continue
+			}
+
+			previous := int64(-1)
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+			log.Printf("Reverting migration %d_%s", mig.Version, mig.Name)
+			if err := m.apply(ctx, conn, mig.Down, previous); err != nil {
+				return fmt.Errorf("rollback of %d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+			version = previous
+
+			if steps > 0 {
+				steps--
+				if steps == 0 {
+					break
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Status reports the current version and which migrations are applied
+func (m *Migrator) Status(ctx context.Context) (MigrationsReport, error) {
+	var report MigrationsReport
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		version, dirty, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		report.Version = version
+		report.Dirty = dirty
+		for _, mig := range m.migrations {
+			report.Migrations = append(report.Migrations, MigrationStatus{
+				Version: mig.Version,
+				Name:    mig.Name,
+				Applied: mig.Version <= version,
+			})
+		}
+		return nil
+	})
+	return report, err
+}
+
+// Force sets the current version without running any migration and clears the
+// dirty flag. A version of -1 means no migration is applied
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		return m.setVersion(ctx, conn, version, false)
+	})
+}
+
+// withLock runs fn on a dedicated connection holding the migrations advisory lock
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationsLockID); err != nil {
+		return fmt.Errorf("failed to acquire migrations lock: %w", err)
+	}
+	defer func() {
+		// the lock is released with the session anyway, so a failure here is only logged
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationsLockID); err != nil {
+			log.Printf("failed to release migrations lock: %v", err)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT NOT NULL PRIMARY KEY,
+			dirty BOOLEAN NOT NULL
+		)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// version returns the current version, or -1 when no migration was applied
+func (m *Migrator) version(ctx context.Context, conn *sql.Conn) (int64, bool, error) {
+	var version int64
+	var dirty bool
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return -1, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read migrations version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// apply runs a migration body and records the resulting version in one transaction.
+// If the transaction cannot be rolled back cleanly the version is flagged dirty
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, body string, version int64) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if body != "" {
+		if _, err := tx.ExecContext(ctx, body); err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				_ = m.setVersion(context.WithoutCancel(ctx), conn, version, true)
+			}
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if version >= 0 {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, version); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// setVersion overwrites the recorded version
+func (m *Migrator) setVersion(ctx context.Context, conn *sql.Conn, version int64, dirty bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if version >= 0 {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`, version, dirty); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"bernardtm/backend/migrations"
+	"context"
+	"io/fs"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadMigrations_SortsAndPairsFiles(t *testing.T) {
+	source := fstest.MapFS{
+		"000002_add_files.up.sql":   {Data: []byte("CREATE TABLE files ();")},
+		"000002_add_files.down.sql": {Data: []byte("DROP TABLE files;")},
+		"000001_init.up.sql":        {Data: []byte("CREATE SCHEMA s;")},
+		"000001_init.down.sql":      {Data: []byte("DROP SCHEMA s;")},
+		"README.md":                 {Data: []byte("ignored")},
+	}
+
+	loaded, err := loadMigrations(source)
+
+	assert.NoError(t, err)
+	assert.Len(t, loaded, 2)
+	assert.Equal(t, int64(1), loaded[0].Version)
+	assert.Equal(t, "add_files", loaded[1].Name)
+	assert.Equal(t, "DROP TABLE files;", loaded[1].Down)
+}
+
+func TestLoadMigrations_EmbeddedPostgres(t *testing.T) {
+	source, err := fs.Sub(migrations.Postgres, "postgres")
+	if err != nil {
+		t.Fatalf("failed to open embedded migrations: %v", err)
+	}
+
+	loaded, err := loadMigrations(source)
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, loaded)
+	versions := map[int64]bool{}
+	for _, m := range loaded {
+		assert.NotEmpty(t, m.Down, "migration %d_%s has no down file", m.Version, m.Name)
+		versions[m.Version] = true
+	}
+
+	tenantSource, err := fs.Sub(source, "tenant")
+	if err != nil {
+		t.Fatalf("failed to open embedded tenant migrations: %v", err)
+	}
+	tenantLoaded, err := loadMigrations(tenantSource)
+	assert.NoError(t, err)
+	for _, m := range tenantLoaded {
+		assert.True(t, versions[m.Version], "tenant migration %d_%s has no migration", m.Version, m.Name)
+	}
+}
+
+func TestMigrator_UpAppliesPendingUnderLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	source := fstest.MapFS{
+		"000001_init.up.sql":      {Data: []byte("CREATE SCHEMA s;")},
+		"000002_add_files.up.sql": {Data: []byte("CREATE TABLE s.files ();")},
+	}
+	migrator, err := NewMigrator(db, source, Tenancy{Mode: TenancyRow})
+	if err != nil {
+		t.Fatalf("failed to create migrator: %v", err)
+	}
+
+	mock.ExpectExec("SELECT pg_advisory_lock").WithArgs(migrationsLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, dirty FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, false))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE s.files").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock").WithArgs(migrationsLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = migrator.Up(context.Background())
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_UpRefusesDirtyDatabase(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := NewMigrator(db, fstest.MapFS{"000001_init.up.sql": {Data: []byte("CREATE SCHEMA s;")}}, Tenancy{Mode: TenancyRow})
+	if err != nil {
+		t.Fatalf("failed to create migrator: %v", err)
+	}
+
+	mock.ExpectExec("SELECT pg_advisory_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, dirty FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, true))
+	mock.ExpectExec("SELECT pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = migrator.Up(context.Background())
+
+	assert.ErrorIs(t, err, ErrDirtyDatabase)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_UpMigratesTenantSchemas(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	source := fstest.MapFS{
+		"000001_init.up.sql":              {Data: []byte("CREATE TABLE default_schema.files ();")},
+		"000002_add_locale.up.sql":        {Data: []byte("ALTER TABLE default_schema.users ADD COLUMN locale TEXT;")},
+		"tenant/000002_add_locale.up.sql": {Data: []byte("ALTER TABLE default_schema.users ADD COLUMN IF NOT EXISTS locale TEXT;")},
+		"tenant/000001_ignored.up.sql":    {Data: []byte("SELECT 1;")},
+	}
+	migrator, err := NewMigrator(db, source, Tenancy{Mode: TenancySchema})
+	if err != nil {
+		t.Fatalf("failed to create migrator: %v", err)
+	}
+
+	const newTenant = "00000000-0000-0000-0000-000000000001"
+	schema := `"tenant_0192d1a4_0000_7000_8000_00000000000a"`
+	mock.ExpectExec("SELECT pg_advisory_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, dirty FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(2, false))
+	mock.ExpectQuery("SELECT tenant_uuid FROM default_schema.tenants").
+		WillReturnRows(sqlmock.NewRows([]string{"tenant_uuid"}).AddRow(newTenant).AddRow(testTenant))
+
+	// the tenant seeded by the migrations has no schema yet
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("tenant_00000000_0000_0000_0000_000000000001").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE SCHEMA IF NOT EXISTS "tenant_00000000_0000_0000_0000_000000000001"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	for range tenantTables {
+		mock.ExpectExec(`CREATE TABLE IF NOT EXISTS (.+) \(LIKE`).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "tenant_00000000_0000_0000_0000_000000000001".schema_migrations`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "tenant_00000000_0000_0000_0000_000000000001".schema_migrations (version) SELECT version FROM schema_migrations`)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// the other one was provisioned at version 1
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("tenant_0192d1a4_0000_7000_8000_00000000000a").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS " + schema + ".schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(version), -1) FROM " + schema + ".schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE " + schema + ".users ADD COLUMN IF NOT EXISTS locale TEXT;")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM " + schema + ".schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO " + schema + ".schema_migrations")).WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = migrator.Up(context.Background())
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replicaPingTimeout bounds every health check of a replica
+const replicaPingTimeout = 2 * time.Second
+
+// readLockClauses turn a SELECT into a statement that must run on the primary
+var readLockClauses = []string{"FOR UPDATE", "FOR NO KEY UPDATE", "FOR SHARE", "FOR KEY SHARE"}
+
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// DBRouter sends read-only statements to the healthy replicas in round-robin and
+// everything else to the primary. Transactions are begun on Primary, so they
+// never reach a replica. Without replicas every statement goes to the primary
+type DBRouter struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+	interval time.Duration
+	stop     context.CancelFunc
+	done     sync.WaitGroup
+}
+
+// NewDBRouter creates a router over the primary and its replicas. Replicas start
+// unhealthy until the first health check of Start marks them as available
+func NewDBRouter(primary *sql.DB, replicas []*sql.DB, healthInterval time.Duration) *DBRouter {
+	r := &DBRouter{primary: primary, interval: healthInterval}
+	for _, db := range replicas {
+		r.replicas = append(r.replicas, &replica{db: db})
+	}
+	return r
+}
+
+// Primary returns the primary database, used for transactions and migrations
+func (r *DBRouter) Primary() *sql.DB {
+	return r.primary
+}
+
+// Start checks the replicas once and keeps checking them in the background until Close
+func (r *DBRouter) Start(ctx context.Context) {
+	if len(r.replicas) == 0 {
+		return
+	}
+	r.checkReplicas(ctx)
+	if r.interval <= 0 {
+		return
+	}
+
+	ctx, r.stop = context.WithCancel(ctx)
+	r.done.Add(1)
+	go func() {
+		defer r.done.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.checkReplicas(ctx)
+			}
+		}
+	}()
+}
+
+// Close stops the health checks and closes the replicas. The primary is owned by the caller
+func (r *DBRouter) Close() error {
+	if r.stop != nil {
+		r.stop()
+		r.done.Wait()
+	}
+	var errs []error
+	for _, rep := range r.replicas {
+		errs = append(errs, rep.db.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (r *DBRouter) checkReplicas(ctx context.Context) {
+	for i, rep := range r.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, replicaPingTimeout)
+		err := rep.db.PingContext(pingCtx)
+		cancel()
+
+		healthy := err == nil
+		if rep.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Printf("Postgres replica %d is healthy", i)
+			} else {
+				log.Printf("Postgres replica %d is unhealthy: %v", i, err)
+			}
+		}
+	}
+}
+
+// reader picks the next healthy replica, falling back to the primary
+func (r *DBRouter) reader(ctx context.Context) *sql.DB {
+	if len(r.replicas) == 0 || pinnedToPrimary(ctx) {
+		return r.primary
+	}
+	healthy := make([]*sql.DB, 0, len(r.replicas))
+	for _, rep := range r.replicas {
+		if rep.healthy.Load() {
+			healthy = append(healthy, rep.db)
+		}
+	}
+	if len(healthy) > 0 {
+		return healthy[(r.next.Add(1)-1)%uint64(len(healthy))]
+	}
+	return r.primary
+}
+
+// route returns the database for the statement, pinning the request to the
+// primary when the statement writes
+func (r *DBRouter) route(ctx context.Context, query string) *sql.DB {
+	if isReadQuery(query) {
+		return r.reader(ctx)
+	}
+	MarkWrite(ctx)
+	return r.primary
+}
+
+func (r *DBRouter) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	MarkWrite(ctx)
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+func (r *DBRouter) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.route(ctx, query).QueryContext(ctx, query, args...)
+}
+
+func (r *DBRouter) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return r.route(ctx, query).QueryRowContext(ctx, query, args...)
+}
+
+// isReadQuery reports whether the statement is a plain SELECT. Anything else,
+// e.g. INSERT ... RETURNING or SELECT ... FOR UPDATE, goes to the primary
+func isReadQuery(query string) bool {
+	statement := strings.ToUpper(strings.TrimSpace(query))
+	if !strings.HasPrefix(statement, "SELECT") {
+		return false
+	}
+	for _, clause := range readLockClauses {
+		if strings.Contains(statement, clause) {
+			return false
+		}
+	}
+	return true
+}
+
+type readYourWritesKey struct{}
+
+// WithReadYourWrites returns a copy of ctx whose reads move to the primary as soon
+// as it writes, so a request always sees its own changes despite replication lag
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readYourWritesKey{}, new(atomic.Bool))
+}
+
+// MarkWrite pins a read-your-writes context to the primary. It is a no-op for other contexts
+func MarkWrite(ctx context.Context) {
+	if pinned, ok := ctx.Value(readYourWritesKey{}).(*atomic.Bool); ok {
+		pinned.Store(true)
+	}
+}
+
+func pinnedToPrimary(ctx context.Context) bool {
+	pinned, ok := ctx.Value(readYourWritesKey{}).(*atomic.Bool)
+	return ok && pinned.Load()
+}
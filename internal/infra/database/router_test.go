@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, mock
+}
+
+func expectRead(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT name FROM default_schema.status").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Actived"))
+}
+
+func read(t *testing.T, db DBTX, ctx context.Context) {
+	t.Helper()
+	var name string
+	assert.NoError(t, db.QueryRowContext(ctx, "SELECT name FROM default_schema.status").Scan(&name))
+}
+
+func TestDBRouter_ReadsRoundRobinOverHealthyReplicas(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replicaA, mockA := newMockDB(t)
+	replicaB, mockB := newMockDB(t)
+	replicaC, mockC := newMockDB(t)
+
+	mockA.ExpectPing()
+	mockB.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mockC.ExpectPing()
+	expectRead(mockA)
+	expectRead(mockC)
+	expectRead(mockA)
+	expectRead(mockC)
+
+	router := NewDBRouter(primary, []*sql.DB{replicaA, replicaB, replicaC}, 0)
+	router.Start(context.Background())
+
+	for i := 0; i < 4; i++ {
+		read(t, router, context.Background())
+	}
+
+	for _, mock := range []sqlmock.Sqlmock{primaryMock, mockA, mockB, mockC} {
+		assert.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestDBRouter_WritesGoToPrimary(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	replicaMock.ExpectPing()
+	primaryMock.ExpectQuery("INSERT INTO default_schema.menus").
+		WillReturnRows(sqlmock.NewRows([]string{"menu_uuid"}).AddRow("id"))
+	primaryMock.ExpectQuery("SELECT status_uuid FROM default_schema.status FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"status_uuid"}).AddRow("id"))
+	primaryMock.ExpectExec("DELETE FROM default_schema.menus").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	router := NewDBRouter(primary, []*sql.DB{replica}, 0)
+	router.Start(context.Background())
+
+	var id string
+	assert.NoError(t, router.QueryRowContext(context.Background(), "INSERT INTO default_schema.menus (name) VALUES ($1) RETURNING menu_uuid", "a").Scan(&id))
+	assert.NoError(t, router.QueryRowContext(context.Background(), "SELECT status_uuid FROM default_schema.status FOR UPDATE").Scan(&id))
+	_, err := router.ExecContext(context.Background(), "DELETE FROM default_schema.menus")
+	assert.NoError(t, err)
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestDBRouter_ReadYourWritesPinsToPrimary(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	replicaMock.ExpectPing()
+	expectRead(replicaMock)
+	primaryMock.ExpectExec("UPDATE default_schema.menus").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRead(primaryMock)
+	// another request is not affected by the pin
+	expectRead(replicaMock)
+
+	router := NewDBRouter(primary, []*sql.DB{replica}, 0)
+	router.Start(context.Background())
+
+	ctx := WithReadYourWrites(context.Background())
+	read(t, router, ctx)
+	_, err := router.ExecContext(ctx, "UPDATE default_schema.menus SET name = $1", "a")
+	assert.NoError(t, err)
+	read(t, router, ctx)
+	read(t, router, WithReadYourWrites(context.Background()))
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestDBRouter_FallsBackToPrimaryWithoutHealthyReplicas(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	replicaMock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	expectRead(primaryMock)
+
+	router := NewDBRouter(primary, []*sql.DB{replica}, 0)
+	router.Start(context.Background())
+
+	read(t, router, context.Background())
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
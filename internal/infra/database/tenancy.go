@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// TenancyMode selects how tenant data is isolated
+type TenancyMode string
+
+const (
+	// TenancyRow keeps every tenant in default_schema, isolated by the tenant_uuid column
+	TenancyRow TenancyMode = "row"
+	// TenancySchema gives every tenant its own copy of the tenant tables in a dedicated schema
+	TenancySchema TenancyMode = "schema"
+)
+
+// ErrTenantRequired is returned when a tenant scoped operation runs without a tenant in its context
+var ErrTenantRequired = errors.New("tenant is required")
+
+// tenantTables are the tables holding tenant data. Every other table (e.g. status) is shared
+var tenantTables = []string{"users", "menus", "user_menus", "files", "two_factor_codes", "uploads", "file_shares", "file_access_history", "attachments", "file_versions", "push_subscriptions", "user_notifications"}
+
+var tenantTablesRegex = regexp.MustCompile(`default_schema\.(` + strings.Join(tenantTables, "|") + `)\b`)
+
+var tenantIDRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+type tenantKey struct{}
+
+type unscopedKey struct{}
+
+// WithTenant returns a copy of ctx carrying the tenant ID
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant ID carried by ctx
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(string)
+	return tenantID, ok && tenantID != ""
+}
+
+// TenantID returns the tenant ID carried by ctx, or ErrTenantRequired
+func TenantID(ctx context.Context) (string, error) {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return "", ErrTenantRequired
+	}
+	return tenantID, nil
+}
+
+// IsValidTenantID reports whether the tenant ID is a UUID
+func IsValidTenantID(tenantID string) bool {
+	return tenantIDRegex.MatchString(tenantID)
+}
+
+// TenantSchema returns the schema holding the tables of a tenant in schema mode
+func TenantSchema(tenantID string) string {
+	return "tenant_" + strings.ReplaceAll(strings.ToLower(tenantID), "-", "_")
+}
+
+// Tenancy applies the configured isolation mode to database handles
+type Tenancy struct {
+	Mode TenancyMode
+}
+
+// Wrap scopes db to the tenant of each call's context. In row mode the
+// repositories filter by tenant_uuid themselves, so db is returned as is
+func (t Tenancy) Wrap(db DBTX) DBTX {
+	if t.Mode != TenancySchema {
+		return db
+	}
+	return &schemaTenantDB{db: db}
+}
+
+// Provision prepares the storage of a new tenant
+func (t Tenancy) Provision(ctx context.Context, db DBTX, tenantID string) error {
+	if t.Mode != TenancySchema {
+		return nil
+	}
+	if !IsValidTenantID(tenantID) {
+		return fmt.Errorf("invalid tenant ID %q", tenantID)
+	}
+
+	// the statements name their schemas explicitly, so db must not rewrite them
+	ctx = context.WithValue(ctx, unscopedKey{}, true)
+	schema := pq.QuoteIdentifier(TenantSchema(tenantID))
+	if _, err := db.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+schema); err != nil {
+		return fmt.Errorf("failed to create tenant schema: %w", err)
+	}
+	// LIKE copies columns, defaults, constraints and indexes but not foreign keys
+	for _, table := range tenantTables {
+		query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (LIKE default_schema.%s INCLUDING ALL)", schema, table, table)
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to create tenant table %s: %w", table, err)
+		}
+	}
+
+	// the tables are copied from default_schema as migrated so far, so the tenant
+	// migrations up to its version are already applied to them
+	if _, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+schema+".schema_migrations (version BIGINT NOT NULL PRIMARY KEY)"); err != nil {
+		return fmt.Errorf("failed to create tenant migrations table: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO "+schema+".schema_migrations (version) SELECT version FROM schema_migrations ON CONFLICT DO NOTHING"); err != nil {
+		return fmt.Errorf("failed to record tenant migrations version: %w", err)
+	}
+	return nil
+}
+
+// Deprovision removes the storage of a tenant. In row mode its rows are removed
+// by the ON DELETE CASCADE of the tenant_uuid foreign keys
+func (t Tenancy) Deprovision(ctx context.Context, db DBTX, tenantID string) error {
+	if t.Mode != TenancySchema {
+		return nil
+	}
+	if !IsValidTenantID(tenantID) {
+		return fmt.Errorf("invalid tenant ID %q", tenantID)
+	}
+
+	schema := pq.QuoteIdentifier(TenantSchema(tenantID))
+	if _, err := db.ExecContext(ctx, "DROP SCHEMA IF EXISTS "+schema+" CASCADE"); err != nil {
+		return fmt.Errorf("failed to drop tenant schema: %w", err)
+	}
+	return nil
+}
+
+// schemaTenantDB points the tenant tables of every statement to the schema of
+// the tenant found in the context. Statements on tenant tables without a tenant
+// fail with ErrTenantRequired rather than reach default_schema
+type schemaTenantDB struct {
+	db DBTX
+}
+
+func (s *schemaTenantDB) rewrite(ctx context.Context, query string) (string, error) {
+	if unscoped, _ := ctx.Value(unscopedKey{}).(bool); unscoped || !tenantTablesRegex.MatchString(query) {
+		return query, nil
+	}
+
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok || !IsValidTenantID(tenantID) {
+		return "", ErrTenantRequired
+	}
+	return tenantQuery(tenantID, query), nil
+}
+
+// tenantQuery points the tenant tables of query to the schema of a tenant
+func tenantQuery(tenantID string, query string) string {
+	return tenantTablesRegex.ReplaceAllString(query, pq.QuoteIdentifier(TenantSchema(tenantID))+".$1")
+}
+
+func (s *schemaTenantDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query, err := s.rewrite(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.ExecContext(ctx, query, args...)
+}
+
+func (s *schemaTenantDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query, err := s.rewrite(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.QueryContext(ctx, query, args...)
+}
+
+func (s *schemaTenantDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	query, err := s.rewrite(ctx, query)
+	if err != nil {
+		return tenantRequiredDB.QueryRowContext(ctx, query)
+	}
+	return s.db.QueryRowContext(ctx, query, args...)
+}
+
+// tenantRequiredDB fails every statement with ErrTenantRequired, as database/sql
+// cannot build a *sql.Row holding an error otherwise
+var tenantRequiredDB = sql.OpenDB(refusingConnector{err: ErrTenantRequired})
+
+type refusingConnector struct {
+	err error
+}
+
+func (c refusingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return nil, c.err
+}
+
+func (c refusingConnector) Driver() driver.Driver {
+	return c
+}
+
+func (c refusingConnector) Open(name string) (driver.Conn, error) {
+	return nil, c.err
+}
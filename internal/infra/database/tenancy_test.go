@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTenant = "0192D1A4-0000-7000-8000-00000000000A"
+
+func TestTenancy_SchemaModeRewritesOnlyTenantTables(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(`UPDATE "tenant_0192d1a4_0000_7000_8000_00000000000a".users SET status_uuid = (SELECT status_uuid FROM default_schema.status)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM default_schema.tenants`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	tenantDB := Tenancy{Mode: TenancySchema}.Wrap(db)
+
+	_, err = tenantDB.ExecContext(WithTenant(context.Background(), testTenant), `UPDATE default_schema.users SET status_uuid = (SELECT status_uuid FROM default_schema.status)`)
+	assert.NoError(t, err)
+	// the shared tables need no tenant
+	_, err = tenantDB.ExecContext(context.Background(), `DELETE FROM default_schema.tenants`)
+	assert.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenancy_SchemaModeRequiresTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	tenantDB := Tenancy{Mode: TenancySchema}.Wrap(db)
+
+	for _, ctx := range []context.Context{context.Background(), WithTenant(context.Background(), "not-a-uuid")} {
+		_, err = tenantDB.ExecContext(ctx, `DELETE FROM default_schema.user_menus`)
+		assert.ErrorIs(t, err, ErrTenantRequired)
+		_, err = tenantDB.QueryContext(ctx, `SELECT code FROM default_schema.two_factor_codes`)
+		assert.ErrorIs(t, err, ErrTenantRequired)
+		var code string
+		err = tenantDB.QueryRowContext(ctx, `SELECT code FROM default_schema.two_factor_codes WHERE two_factor_code_uuid = $1`, "code-1").Scan(&code)
+		assert.ErrorIs(t, err, ErrTenantRequired)
+	}
+
+	// nothing reaches default_schema
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenancy_RowModeDoesNotWrap(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	assert.Same(t, db, Tenancy{Mode: TenancyRow}.Wrap(db))
+}
+
+func TestTenancy_Provision(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	schema := `"tenant_0192d1a4_0000_7000_8000_00000000000a"`
+	mock.ExpectExec(regexp.QuoteMeta("CREATE SCHEMA IF NOT EXISTS " + schema)).WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, table := range tenantTables {
+		mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS " + schema + "." + table + " (LIKE default_schema." + table + " INCLUDING ALL)")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS " + schema + ".schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO " + schema + ".schema_migrations (version) SELECT version FROM schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	tenancy := Tenancy{Mode: TenancySchema}
+	// the source tables must stay in default_schema even when the caller carries a tenant
+	ctx := WithTenant(context.Background(), testTenant)
+	assert.NoError(t, tenancy.Provision(ctx, tenancy.Wrap(db), testTenant))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTenancy_ProvisionRejectsInvalidID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	err = Tenancy{Mode: TenancySchema}.Provision(context.Background(), db, `x"; DROP SCHEMA default_schema; --`)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
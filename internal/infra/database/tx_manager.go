@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// TxManager runs a set of repository calls atomically
+type TxManager interface {
+	WithinTransaction(ctx context.Context, fn func(uow *UnitOfWork) error) error
+}
+
+// UnitOfWork holds the transaction shared by the repositories of a single
+// operation, plus the compensating actions for side effects outside the database
+// and the actions waiting for its commit
+type UnitOfWork struct {
+	tx            DBTX
+	compensations []func() error
+	afterCommit   []func()
+}
+
+// Tx returns the transaction to be handed to repositories through WithTx
+func (u *UnitOfWork) Tx() DBTX {
+	return u.tx
+}
+
+// AddCompensation registers an action that undoes an external side effect
+// (e.g. an S3 upload) when the unit of work is rolled back
+func (u *UnitOfWork) AddCompensation(fn func() error) {
+	u.compensations = append(u.compensations, fn)
+}
+
+// AfterCommit registers an action to run once the unit of work is committed, such
+// as waking a worker for the rows it inserted. It does not run on rollback
+func (u *UnitOfWork) AfterCommit(fn func()) {
+	u.afterCommit = append(u.afterCommit, fn)
+}
+
+// compensate runs the registered compensations in reverse order
+func (u *UnitOfWork) compensate() error {
+	var errs []error
+	for i := len(u.compensations) - 1; i >= 0; i-- {
+		if err := u.compensations[i](); err != nil {
+			log.Printf("compensating action failed: %v", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type txManager struct {
+	db      *sql.DB
+	tenancy Tenancy
+}
+
+// NewTxManager creates a new TxManager backed by the given database, whose
+// transactions are scoped to the tenant of their context
+func NewTxManager(db *sql.DB, tenancy Tenancy) *txManager {
+	return &txManager{db: db, tenancy: tenancy}
+}
+
+// WithinTransaction begins a transaction bound to ctx, runs fn and commits it. If fn returns an
+// error, panics or the commit fails, the transaction is rolled back and every
+// registered compensation is executed
+func (m *txManager) WithinTransaction(ctx context.Context, fn func(uow *UnitOfWork) error) (err error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// reads following a transaction must see its writes
+	MarkWrite(ctx)
+	uow := &UnitOfWork{tx: m.tenancy.Wrap(tx)}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			_ = uow.compensate()
+			panic(p)
+		}
+	}()
+
+	if err = fn(uow); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Printf("failed to rollback transaction: %v", rbErr)
+		}
+		return errors.Join(err, uow.compensate())
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Join(fmt.Errorf("failed to commit transaction: %w", err), uow.compensate())
+	}
+
+	for _, fn := range uow.afterCommit {
+		fn()
+	}
+	return nil
+}
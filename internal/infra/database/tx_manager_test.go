@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithinTransaction_Commit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO default_schema.files").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	compensated := false
+	err = NewTxManager(db, Tenancy{Mode: TenancyRow}).WithinTransaction(context.Background(), func(uow *UnitOfWork) error {
+		uow.AddCompensation(func() error {
+			compensated = true
+			return nil
+		})
+		_, err := uow.Tx().ExecContext(context.Background(), "INSERT INTO default_schema.files (file_name) VALUES ($1)", "a.pdf")
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.False(t, compensated, "compensations must not run on commit")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithinTransaction_RollbackRunsCompensationsInReverse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	var order []string
+	failure := errors.New("failed to create file")
+	err = NewTxManager(db, Tenancy{Mode: TenancyRow}).WithinTransaction(context.Background(), func(uow *UnitOfWork) error {
+		uow.AddCompensation(func() error {
+			order = append(order, "upload")
+			return nil
+		})
+		uow.AddCompensation(func() error {
+			order = append(order, "thumbnail")
+			return nil
+		})
+		return failure
+	})
+
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, []string{"thumbnail", "upload"}, order)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithinTransaction_CommitFailureCompensates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit().WillReturnError(errors.New("connection lost"))
+
+	compensated := false
+	err = NewTxManager(db, Tenancy{Mode: TenancyRow}).WithinTransaction(context.Background(), func(uow *UnitOfWork) error {
+		uow.AddCompensation(func() error {
+			compensated = true
+			return nil
+		})
+		return nil
+	})
+
+	assert.Error(t, err)
+	assert.True(t, compensated)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithinTransaction_AfterCommitRunsOnlyOnCommit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	var ran []string
+	manager := NewTxManager(db, Tenancy{Mode: TenancyRow})
+	err = manager.WithinTransaction(context.Background(), func(uow *UnitOfWork) error {
+		uow.AfterCommit(func() { ran = append(ran, "committed") })
+		return nil
+	})
+	assert.NoError(t, err)
+
+	err = manager.WithinTransaction(context.Background(), func(uow *UnitOfWork) error {
+		uow.AfterCommit(func() { ran = append(ran, "rolled back") })
+		return errors.New("failed")
+	})
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{"committed"}, ran)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,20 +2,26 @@ package di
 
 import (
 	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/core/attachments"
 	"bernardtm/backend/internal/core/auth"
 	"bernardtm/backend/internal/core/auth/token"
 	"bernardtm/backend/internal/core/email"
 	"bernardtm/backend/internal/core/files"
 	"bernardtm/backend/internal/core/menus"
+	"bernardtm/backend/internal/core/notifications"
 	"bernardtm/backend/internal/core/shareds"
 	"bernardtm/backend/internal/core/status"
 	"bernardtm/backend/internal/core/storage"
+	"bernardtm/backend/internal/core/tenants"
 	"bernardtm/backend/internal/core/users"
+	"bernardtm/backend/internal/infra/database"
 	"bernardtm/backend/internal/infra/socket"
 	"bernardtm/backend/pkg/providers/emails"
+	notificationproviders "bernardtm/backend/pkg/providers/notifications"
+	"bernardtm/backend/pkg/providers/scanners"
 	"bernardtm/backend/pkg/providers/storages"
-	"database/sql"
-	"os"
+	"bernardtm/backend/pkg/redis_client"
+	"log"
 
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -28,63 +34,218 @@ type Container struct {
 	TokenService          token.TokenService
 	HealthcheckController shareds.HealthcheckController
 	FilesController       files.FilesController
+	TusController         files.TusController
+	FileProcessor         files.FileProcessor
+	StorageLifecycle      files.StorageLifecycle
+	EmailDispatcher       email.EmailDispatcher
 	SocketHandler         socket.SocketController
+	SocketHub             *socket.WebSocketHub
+	// LocalStorageController is only set when the local storage provider is selected
+	LocalStorageController storage.LocalStorageController
+	TenantsController      tenants.TenantsController
+	AttachmentsController  attachments.AttachmentsController
+	LifecycleController    files.StorageLifecycleController
+	TemplatesController    email.EmailTemplatesController
+	OutboxController       email.EmailOutboxController
+	TrackingController     email.EmailTrackingController
+	NotifyController       notifications.NotificationsController
+	CenterController       notifications.NotificationCenterController
 }
 
-func NewContainer(db *sql.DB, mongoClient *mongo.Client, appConfig *configs.AppConfig) *Container {
+func NewContainer(db *database.DBRouter, mongoClient *mongo.Client, appConfig *configs.AppConfig) *Container {
 
 	// Providers
-	var emailProvider emails.EmailProvider
+	var emailProviders []emails.NamedEmailProvider
 
-	if os.Getenv("ENVIRONMENT") == "dev" {
-		emailProvider = emails.NewMailpitSMTPEmailProvider(appConfig)
-	} else {
-		emailProvider = emails.NewMailgunProvider(appConfig)
+	for _, name := range appConfig.EmailProviders {
+		var provider emails.EmailProvider
+
+		switch name {
+		case "mailpit":
+			provider = emails.NewMailpitSMTPEmailProvider(appConfig)
+		case "mailpit-api":
+			provider = emails.NewMailpitAPIProvider(appConfig)
+		case "mailgun-api":
+			provider = emails.NewMailgunAPIProvider(appConfig)
+		case "smtp":
+			provider = emails.NewSMTPEmailProvider(appConfig)
+		case "ses":
+			provider = emails.NewSESEmailProvider(appConfig)
+		default:
+			provider = emails.NewMailgunProvider(appConfig)
+		}
+
+		emailProviders = append(emailProviders, emails.NamedEmailProvider{Name: name, Provider: provider})
+	}
+	emailProvider := emails.NewFailoverEmailProvider(emailProviders, appConfig)
+
+	var storageProvider storages.StorageProvider
+	var localStorageController storage.LocalStorageController
+
+	switch appConfig.StorageProvider {
+	case "local":
+		localProvider := storages.NewLocalStorageProvider(appConfig)
+		storageProvider = localProvider
+		localStorageController = storage.NewLocalStorageController(localProvider, appConfig.LocalStoragePublic)
+	case "memory":
+		storageProvider = storages.NewMemoryStorageProvider()
+	default:
+		storageProvider = storages.NewS3StorageProvider(appConfig)
+	}
+
+	var smsProvider, whatsAppProvider, pushProvider notificationproviders.NotificationProvider
+
+	switch appConfig.SMSProvider {
+	case "fake":
+		smsProvider = notificationproviders.NewFakeNotificationProvider(notifications.ChannelSMS)
+	case "twilio":
+		smsProvider = notificationproviders.NewTwilioSMSProvider(appConfig)
+	}
+
+	switch appConfig.WhatsAppProvider {
+	case "fake":
+		whatsAppProvider = notificationproviders.NewFakeNotificationProvider(notifications.ChannelWhatsApp)
+	case "cloud":
+		whatsAppProvider = notificationproviders.NewWhatsAppProvider(appConfig)
+	}
+
+	switch appConfig.PushProvider {
+	case "fake":
+		pushProvider = notificationproviders.NewFakeNotificationProvider(notifications.ChannelPush)
+	case "webpush":
+		pushProvider = notificationproviders.NewWebPushProvider(appConfig)
+	}
+
+	var scannerProvider scanners.ScannerProvider
+
+	switch appConfig.ScannerProvider {
+	case "fake":
+		scannerProvider = scanners.NewFakeScannerProvider()
+	default:
+		scannerProvider = scanners.NewClamAVScannerProvider(appConfig)
 	}
-	storageProvider := storages.NewS3StorageProvider(appConfig)
 
 	// redisClient, err := redis_client.ConnectRedis(appConfig.REDIS_ADDRESS)
 	// if err != nil {
 	// 	log.Printf("Failed to connect to Redis: %v", err)
 	// }
-	// queueProvider := queues.NewRedisQueueProvider(redisClient)
+	// queueProvider := queues.NewRedisQueueProvider(redisClient, appConfig.QueueTimeout)
+
+	var socketBroker socket.Broker
+
+	if appConfig.WSBroker == "redis" {
+		redisClient, err := redis_client.ConnectRedis(appConfig.REDIS_ADDRESS)
+		if err != nil {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		socketBroker = socket.NewRedisBroker(redisClient, appConfig.WSRedisChannel)
+	}
+
+	// Database
+	tenancy := database.Tenancy{Mode: database.TenancyMode(appConfig.TenancyMode)}
+	tenantDB := tenancy.Wrap(db)
+	txManager := database.NewTxManager(db.Primary(), tenancy)
 
 	// Repositories
-	statusRepo := status.NewStatusRepository(db)
-	twoFactorRepo := auth.NewTwoFactorCodesRepository(db)
-	userRepo := users.NewUserRepository(db)
-	filesRepo := files.NewFilesRepository(db)
-	menusRepo := menus.NewMenusRepository(db)
+	statusRepo := status.NewStatusRepository(db, appConfig.DBQueryTimeout)
+	tenantsRepo := tenants.NewTenantsRepository(db, appConfig.DBQueryTimeout)
+	twoFactorRepo := auth.NewTwoFactorCodesRepository(tenantDB, appConfig.DBQueryTimeout)
+	userRepo := users.NewUserRepository(tenantDB, appConfig.DBQueryTimeout)
+	filesRepo := files.NewFilesRepository(tenantDB, appConfig.DBQueryTimeout)
+	uploadsRepo := files.NewResumableUploadsRepository(tenantDB, appConfig.DBQueryTimeout)
+	fileJobsRepo := files.NewFileJobsRepository(db, appConfig.DBQueryTimeout)
+	emailOutboxRepo := email.NewEmailOutboxRepository(db, appConfig.DBQueryTimeout)
+	emailTrackingRepo := email.NewEmailTrackingRepository(db, appConfig.DBQueryTimeout)
+	fileVersionsRepo := files.NewFileVersionsRepository(tenantDB, appConfig.DBQueryTimeout)
+	fileSharesRepo := files.NewFileSharesRepository(tenantDB, appConfig.DBQueryTimeout)
+	fileHistoryRepo := files.NewFileAccessHistoryRepository(tenantDB, appConfig.DBQueryTimeout)
+	menusRepo := menus.NewMenusRepository(tenantDB, appConfig.DBQueryTimeout)
+	attachmentsRepo := attachments.NewAttachmentsRepository(tenantDB, appConfig.DBQueryTimeout)
+	pushSubscriptionsRepo := notifications.NewPushSubscriptionsRepository(tenantDB, appConfig.DBQueryTimeout)
+	userNotificationsRepo := notifications.NewUserNotificationsRepository(tenantDB, appConfig.DBQueryTimeout)
 
 	// Services
-	emailService := email.NewEmailService(emailProvider)
+	emailTemplates := email.NewTemplateRenderer(appConfig)
+	emailTracker := email.NewEmailTracker(emailTrackingRepo, txManager, appConfig)
+	emailDispatcher := email.NewEmailDispatcher(emailOutboxRepo, emailProvider, emailTracker, appConfig)
+	emailService := email.NewEmailService(emailProvider, emailTemplates, emailDispatcher, emailTracker, appConfig.EmailSender)
+	messageTemplates := notifications.NewMessageRenderer(emailTemplates)
+	notificationChannels := []notifications.NotificationChannel{notifications.NewEmailChannel(emailService)}
+	if smsProvider != nil {
+		notificationChannels = append(notificationChannels, notifications.NewPhoneChannel(notifications.ChannelSMS, smsProvider, messageTemplates))
+	}
+	if whatsAppProvider != nil {
+		notificationChannels = append(notificationChannels, notifications.NewPhoneChannel(notifications.ChannelWhatsApp, whatsAppProvider, messageTemplates))
+	}
+	if pushProvider != nil {
+		notificationChannels = append(notificationChannels, notifications.NewPushChannel(pushProvider, pushSubscriptionsRepo, messageTemplates))
+	}
+	notificationService := notifications.NewNotificationService(notificationChannels, pushSubscriptionsRepo, appConfig)
+	socketHub := socket.NewWebSocketHub(socketBroker, appConfig)
+	notificationCenter := notifications.NewNotificationCenter(userNotificationsRepo, socketHub)
 	tokenService := token.NewTokenService(appConfig)
 	twoFactorService := auth.NewTwoFactorCodesService(twoFactorRepo, statusRepo)
-	authService := auth.NewAuthService(userRepo, appConfig, *emailService, twoFactorService, tokenService)
-	statusService := status.NewStatusService(statusRepo)
+	authService := auth.NewAuthService(userRepo, appConfig, notificationService, twoFactorService, tokenService, txManager)
+	attachmentsCleaner := attachments.NewAttachmentsCleaner(attachmentsRepo)
+	statusService := status.NewStatusService(statusRepo, attachmentsCleaner, txManager)
 	storageService := storage.NewStorageService(storageProvider)
-	filesService := files.NewFilesService(filesRepo, statusRepo, storageService)
-	menusService := menus.NewMenusService(menusRepo)
-	userService := users.NewUsersService(userRepo, statusRepo, storageService)
+	fileProcessor := files.NewFileProcessor(fileJobsRepo, filesRepo, fileVersionsRepo, statusRepo, storageService, scannerProvider, notificationCenter, socketHub, txManager, appConfig)
+	filesService := files.NewFilesService(filesRepo, fileVersionsRepo, fileSharesRepo, fileHistoryRepo, statusRepo, storageService, txManager, fileProcessor, socketHub, appConfig)
+	storageLifecycle := files.NewStorageLifecycle(tenantsRepo, filesRepo, fileVersionsRepo, uploadsRepo, storageService, txManager, appConfig)
+	uploadsService := files.NewResumableUploadsService(uploadsRepo, filesRepo, statusRepo, storageService, txManager, fileProcessor, appConfig)
+	menusService := menus.NewMenusService(menusRepo, socketHub, attachmentsCleaner, txManager)
+	userService := users.NewUsersService(userRepo, statusRepo, storageService, attachmentsCleaner, txManager, appConfig)
+	tenantsService := tenants.NewTenantsService(tenantsRepo, statusRepo, tenancy, txManager)
+	attachmentsService := attachments.NewAttachmentsService(attachmentsRepo, filesService, map[string]attachments.RecordLookup{
+		"menus":  attachments.LookupRecord(menusService.GetByID, menus.ErrMenuNotFound),
+		"users":  attachments.LookupRecord(userService.GetByID, users.ErrUserNotFound),
+		"status": attachments.LookupRecord(statusService.GetByID, status.ErrStatusNotFound),
+	})
 
 	// Controllers
 	authController := auth.NewAuthController(authService)
 	statusController := status.NewStatusController(statusService)
 	healthcheckController := shareds.NewHealthcheckController()
 	filesController := files.NewFilesController(filesService)
+	storageLifecycleController := files.NewStorageLifecycleController(storageLifecycle)
+	emailTemplatesController := email.NewEmailTemplatesController(emailTemplates)
+	emailOutboxController := email.NewEmailOutboxController(emailDispatcher)
+	emailTrackingController := email.NewEmailTrackingController(emailTracker)
+	tusController := files.NewTusController(uploadsService, appConfig.TusChunkTimeout)
 	menusController := menus.NewMenusController(menusService)
 	userController := users.NewUsersController(userService)
+	tenantsController := tenants.NewTenantsController(tenantsService)
+	attachmentsController := attachments.NewAttachmentsController(attachmentsService)
+	notificationsController := notifications.NewNotificationsController(notificationService)
+	notificationCenterController := notifications.NewNotificationCenterController(notificationCenter)
 
-	socketHandler := socket.NewSocketController()
+	socketHub.Acknowledge(notificationCenter.Acknowledge)
+	socketHub.Authorize("entity", socket.AllowEntities("menus"))
+	socketHub.Authorize("file", files.TopicAuthorizer(filesService))
+	socketHandler := socket.NewSocketController(socketHub, notificationCenter)
 
 	return &Container{
-		AuthController:        authController,
-		StatusController:      statusController,
-		TokenService:          tokenService,
-		HealthcheckController: healthcheckController,
-		SocketHandler:         socketHandler,
-		FilesController:       filesController,
-		MenusController:       menusController,
-		UserController:        userController,
+		AuthController:         authController,
+		StatusController:       statusController,
+		TokenService:           tokenService,
+		HealthcheckController:  healthcheckController,
+		SocketHandler:          socketHandler,
+		SocketHub:              socketHub,
+		FilesController:        filesController,
+		TusController:          tusController,
+		FileProcessor:          fileProcessor,
+		StorageLifecycle:       storageLifecycle,
+		EmailDispatcher:        emailDispatcher,
+		MenusController:        menusController,
+		UserController:         userController,
+		TenantsController:      tenantsController,
+		LocalStorageController: localStorageController,
+		AttachmentsController:  attachmentsController,
+		LifecycleController:    storageLifecycleController,
+		TemplatesController:    emailTemplatesController,
+		OutboxController:       emailOutboxController,
+		TrackingController:     emailTrackingController,
+		NotifyController:       notificationsController,
+		CenterController:       notificationCenterController,
 	}
 }
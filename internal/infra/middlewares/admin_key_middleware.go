@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminKeyMiddleware protects the admin routes with the key sent in the X-Admin-Key header.
+// Every request is denied when no key is configured. The accepted requests are flagged as
+// admin, for the handlers shared with the users
+func AdminKeyMiddleware(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := c.GetHeader("X-Admin-Key")
+		if key == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(key)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin key"})
+			c.Abort()
+			return
+		}
+
+		c.Set("admin", true)
+		c.Next()
+	}
+}
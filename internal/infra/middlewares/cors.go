@@ -13,12 +13,14 @@ func CORS(origin string) gin.HandlerFunc {
 		c.Header("Access-Control-Allow-Headers", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, HEAD, PATCH")
 		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Access-Control-Expose-Headers", "Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Upload-Offset, Upload-Length, X-File-ID")
 		c.Header("Access-Control-Max-Age", "1")
 		c.Header("Content-Type", "application/json")
 		c.Header("Cache-Control", "no-cache, no-store, max-age=0, must-revalidate")
 
-		// Handle preflight requests
-		if c.Request.Method == http.MethodOptions {
+		// Handle preflight requests. Other OPTIONS requests, e.g. the tus
+		// capabilities discovery, reach their routes
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
 			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
@@ -59,6 +59,7 @@ func (j *JWTMiddleware) AuthMiddleware(audience string) gin.HandlerFunc {
 			// if token is valid, stores user info in context
 			c.Set("ID", claims.ID)
 			c.Set("playerUUID", claims.PlayerUUID)
+			c.Set("tenantID", claims.TenantID)
 		} else {
 			// Validate JWT token
 			claims, err := j.tokenService.ValidateToken(tokenString)
@@ -76,6 +77,7 @@ func (j *JWTMiddleware) AuthMiddleware(audience string) gin.HandlerFunc {
 
 			// if token is valid, stores user info in context
 			c.Set("ID", claims.ID)
+			c.Set("tenantID", claims.TenantID)
 		}
 
 		// Continue execution
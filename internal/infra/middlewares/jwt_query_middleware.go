@@ -45,6 +45,7 @@ func (j *JWTQueryMiddleware) AuthQueryMiddleware(audience string) gin.HandlerFun
 
 			c.Set("ID", claims.ID)
 			c.Set("playerUUID", claims.PlayerUUID)
+			c.Set("tenantID", claims.TenantID)
 		} else {
 
 			claims, err := j.tokenService.ValidateToken(queryToken)
@@ -62,6 +63,7 @@ func (j *JWTQueryMiddleware) AuthQueryMiddleware(audience string) gin.HandlerFun
 			}
 
 			c.Set("ID", claims.ID)
+			c.Set("tenantID", claims.TenantID)
 		}
 
 		c.Next()
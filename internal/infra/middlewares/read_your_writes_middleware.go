@@ -0,0 +1,16 @@
+package middlewares
+
+import (
+	"bernardtm/backend/internal/infra/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReadYourWritesMiddleware pins the reads of a request to the primary database once
+// the request writes, so it never reads stale data from a lagging replica
+func ReadYourWritesMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(database.WithReadYourWrites(c.Request.Context()))
+		c.Next()
+	}
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TenantHeader is the header used to select the tenant of unauthenticated requests
+const TenantHeader = "X-Tenant-ID"
+
+// TenantMiddleware resolves the tenant of the request and stores it in the request context.
+// Authenticated requests use the tenant of their token, which the X-Tenant-ID header may
+// not contradict, and are rejected when their token has none. Unauthenticated requests
+// use the header, or defaultTenant when it is not present. Must run after the JWT
+// middleware on protected routes
+func TenantMiddleware(defaultTenant string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader(TenantHeader)
+		tenantID := c.GetString("tenantID")
+		_, authenticated := c.Get("ID")
+
+		switch {
+		case tenantID != "":
+			if header != "" && header != tenantID {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Tenant does not match the token"})
+				c.Abort()
+				return
+			}
+		case authenticated:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has no tenant"})
+			c.Abort()
+			return
+		case header != "":
+			tenantID = header
+		default:
+			tenantID = defaultTenant
+		}
+
+		if tenantID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "X-Tenant-ID header is required"})
+			c.Abort()
+			return
+		}
+		if !database.IsValidTenantID(tenantID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+			c.Abort()
+			return
+		}
+
+		c.Set("tenantID", tenantID)
+		c.Request = c.Request.WithContext(database.WithTenant(c.Request.Context(), tenantID))
+
+		c.Next()
+	}
+}
@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"bernardtm/backend/internal/infra/database"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	tokenTenant   = "0192d1a4-0000-7000-8000-00000000000a"
+	otherTenant   = "0192d1a4-0000-7000-8000-00000000000b"
+	defaultTenant = "00000000-0000-0000-0000-000000000001"
+)
+
+func newTenantRouter(authenticated bool, claimsTenant string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		// stands in for the JWT middleware
+		if authenticated {
+			c.Set("ID", "user-1")
+			c.Set("tenantID", claimsTenant)
+		}
+	}, TenantMiddleware(defaultTenant), func(c *gin.Context) {
+		tenantID, _ := database.TenantFromContext(c.Request.Context())
+		c.String(http.StatusOK, tenantID)
+	})
+	return router
+}
+
+func TestTenantMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		authenticated bool
+		claimsTenant  string
+		header        string
+		wantStatus    int
+		wantTenant    string
+	}{
+		{"token tenant", true, tokenTenant, "", http.StatusOK, tokenTenant},
+		{"header matching token", true, tokenTenant, tokenTenant, http.StatusOK, tokenTenant},
+		{"header cannot override token", true, tokenTenant, otherTenant, http.StatusForbidden, ""},
+		{"token without tenant", true, "", "", http.StatusUnauthorized, ""},
+		{"header cannot select tenant of token without one", true, "", otherTenant, http.StatusUnauthorized, ""},
+		{"header without token", false, "", otherTenant, http.StatusOK, otherTenant},
+		{"default tenant", false, "", "", http.StatusOK, defaultTenant},
+		{"invalid header", false, "", "tenant-b", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(TenantHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			newTenantRouter(tt.authenticated, tt.claimsTenant).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, tt.wantTenant, rec.Body.String())
+			}
+		})
+	}
+}
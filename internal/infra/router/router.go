@@ -2,6 +2,7 @@ package server
 
 import (
 	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/core/attachments"
 	"bernardtm/backend/internal/core/shareds"
 	"bernardtm/backend/internal/infra/di"
 	"bernardtm/backend/internal/infra/middlewares"
@@ -17,52 +18,110 @@ func SetupRouter(container *di.Container, config *configs.AppConfig) *gin.Engine
 	router := gin.Default()
 
 	applyMiddlewares(router, config)
-	configurePublicRoutes(router, container)
-	configureProtectedRoutes(router, container)
+	configurePublicRoutes(router, container, config)
+	configureProtectedRoutes(router, container, config)
+	configureAdminRoutes(router, container, config)
 
 	return router
 }
 
 // applyMiddlewares applies common middlewares to the router
 func applyMiddlewares(router *gin.Engine, config *configs.AppConfig) {
-	router.Use(gzip.Gzip(gzip.BestSpeed))
+	// tus responses carry no body and their chunks must reach the handler unbuffered
+	router.Use(gzip.Gzip(gzip.BestSpeed, gzip.WithExcludedPaths([]string{"/api/v1/files/tus"})))
 	router.Use(gin.Recovery())
 	router.Use(middlewares.CORS(config.CorsOrigin))
 	router.Use(middlewares.SecurityHeadersMiddleware())
+	if config.ReadYourWrites {
+		router.Use(middlewares.ReadYourWritesMiddleware())
+	}
 }
 
 // configurePublicRoutes sets up public routes
-func configurePublicRoutes(router *gin.Engine, c *di.Container) {
+func configurePublicRoutes(router *gin.Engine, c *di.Container, config *configs.AppConfig) {
 	// healthcheck
 	router.GET("", c.HealthcheckController.Status)
 
+	// tus capabilities discovery
+	router.OPTIONS("/api/v1/files/tus", c.TusController.Options)
+
 	api := router.Group("/api/v1")
+	api.Use(middlewares.TenantMiddleware(config.DefaultTenantUUID))
 
 	// auth
 	api.POST("/auth/login", c.AuthController.Login)
 	api.POST("/auth/login/request-password-reset", c.AuthController.RequestPasswordReset)
 
+	// provider webhooks report events of every tenant, so they are not bound to the tenant middleware
+	router.POST("/api/v1/emails/webhooks/mailgun", c.TrackingController.MailgunWebhook)
+
+	// share links carry their tenant, so they are not bound to the tenant middleware
+	router.GET("/api/v1/shared/:token", c.FilesController.DownloadShared)
 }
 
 // configureProtectedRoutes sets up protected routes
-func configureProtectedRoutes(router *gin.Engine, c *di.Container) {
+func configureProtectedRoutes(router *gin.Engine, c *di.Container, config *configs.AppConfig) {
 	jwtMiddleware := middlewares.NewJWTMiddleware(c.TokenService)
 	jwtQueryMiddleware := middlewares.NewJWTQueryMiddleware(c.TokenService)
+	tenantMiddleware := middlewares.TenantMiddleware(config.DefaultTenantUUID)
 
 	api := router.Group("/api/v1")
 
 	// auth
-	api.POST("/auth/login/verify", jwtMiddleware.AuthMiddleware("2step_verification"), c.AuthController.Login2Step)
-	api.POST("/auth/login/reset-password", jwtMiddleware.AuthMiddleware("password_reset_verification"), c.AuthController.ResetPassword)
+	api.POST("/auth/login/verify", jwtMiddleware.AuthMiddleware("2step_verification"), tenantMiddleware, c.AuthController.Login2Step)
+	api.POST("/auth/login/reset-password", jwtMiddleware.AuthMiddleware("password_reset_verification"), tenantMiddleware, c.AuthController.ResetPassword)
 
-	api.GET("/ws", jwtQueryMiddleware.AuthQueryMiddleware("api"), func(ctx *gin.Context) {
+	api.GET("/ws", jwtQueryMiddleware.AuthQueryMiddleware("api"), tenantMiddleware, func(ctx *gin.Context) {
 		c.SocketHandler.WebSocketHandler(ctx)
 	})
 
-	api.Use(jwtMiddleware.AuthMiddleware("api"))
+	// local storage, served by the API when files are kept on disk, only through pre-signed URLs
+	if c.LocalStorageController != nil {
+		local := router.Group("/api/v1/storage/local")
+		local.Use(c.LocalStorageController.Authenticate())
+		local.GET("/*key", c.LocalStorageController.Serve)
+		local.PUT("/*key", c.LocalStorageController.Receive)
+	}
+
+	api.Use(jwtMiddleware.AuthMiddleware("api"), tenantMiddleware)
 
 	// files
+	api.GET("/files", c.FilesController.GetAll)
+	api.GET("/files/paginate", c.FilesController.Paginate)
+	api.GET("/files/:id", c.FilesController.GetByID)
+	api.GET("/files/:id/download", c.FilesController.Download)
+	api.GET("/files/:id/url", c.FilesController.GetDownloadURL)
+	api.GET("/files/:id/text", c.FilesController.GetText)
 	api.POST("/files", c.FilesController.Create)
+	api.POST("/files/upload-url", c.FilesController.CreateUploadURL)
+	api.POST("/files/:id/complete", c.FilesController.CompleteUpload)
+	api.DELETE("/files/:id", c.FilesController.Delete)
+	api.GET("/files/:id/shares", c.FilesController.GetShares)
+	api.POST("/files/:id/shares", c.FilesController.Share)
+	api.DELETE("/files/:id/shares/:shareId", c.FilesController.RevokeShare)
+	api.POST("/files/:id/share-links", c.FilesController.CreateShareLink)
+	api.GET("/files/:id/history", c.FilesController.GetAccessHistory)
+	api.PUT("/files/:id/content", c.FilesController.UpdateContent)
+	api.GET("/files/:id/versions", c.FilesController.GetVersions)
+	api.GET("/files/:id/versions/:versionId/download", c.FilesController.DownloadVersion)
+
+	// resumable uploads (tus)
+	api.POST("/files/tus", c.TusController.Create)
+	api.HEAD("/files/tus/:id", c.TusController.Head)
+	api.PATCH("/files/tus/:id", c.TusController.Patch)
+
+	// users
+	api.PUT("/users/:id/avatar", c.UserController.UpdateAvatar)
+
+	// notifications
+	api.GET("/notifications/channels", c.NotifyController.GetChannels)
+	api.POST("/notifications/push-subscriptions", c.NotifyController.Subscribe)
+	api.DELETE("/notifications/push-subscriptions", c.NotifyController.Unsubscribe)
+	api.GET("/notifications", c.CenterController.GetAll)
+	api.GET("/notifications/unread-count", c.CenterController.CountUnread)
+	api.PUT("/notifications/read", c.CenterController.MarkAllRead)
+	api.PUT("/notifications/:id/read", c.CenterController.MarkRead)
+	api.DELETE("/notifications/:id", c.CenterController.Delete)
 
 	// menus
 	api.GET("/menus/user", c.MenusController.GetMenusByUserID)
@@ -76,11 +135,48 @@ func configureProtectedRoutes(router *gin.Engine, c *di.Container) {
 	}
 
 	for path, controller := range entities {
-		setupCrudRoutes(api, path, controller)
+		setupCrudRoutes(api, path, controller, c.AttachmentsController)
 	}
 }
 
-func setupCrudRoutes(group *gin.RouterGroup, path string, controller shareds.CrudController) {
+// configureAdminRoutes sets up the routes protected by the admin key
+func configureAdminRoutes(router *gin.Engine, c *di.Container, config *configs.AppConfig) {
+	admin := router.Group("/api/v1")
+	admin.Use(middlewares.AdminKeyMiddleware(config.AdminAPIKey))
+
+	// tenants
+	admin.GET("/tenants", c.TenantsController.GetAll)
+	admin.GET("/tenants/:id", c.TenantsController.GetByID)
+	admin.POST("/tenants", c.TenantsController.Create)
+	admin.DELETE("/tenants/:id", c.TenantsController.Delete)
+
+	// storage
+	admin.POST("/storage/reconcile", c.LifecycleController.Reconcile)
+
+	// files without owner, looked up in the tenant of the X-Tenant-ID header
+	admin.PUT("/files/:id/owner", middlewares.TenantMiddleware(""), c.FilesController.AssignOwner)
+
+	// avatars of any user, looked up in the tenant of the X-Tenant-ID header
+	admin.PUT("/admin/users/:id/avatar", middlewares.TenantMiddleware(""), c.UserController.UpdateAvatar)
+
+	// email templates
+	admin.GET("/emails/templates", c.TemplatesController.GetAll)
+	admin.GET("/emails/templates/:name/preview", c.TemplatesController.Preview)
+
+	// email outbox
+	admin.GET("/emails/outbox", c.OutboxController.GetAll)
+	admin.GET("/emails/outbox/:id", c.OutboxController.GetByID)
+	admin.POST("/emails/outbox/:id/retry", c.OutboxController.Retry)
+	admin.GET("/emails/outbox/:id/events", c.TrackingController.GetEvents)
+
+	// email suppressions
+	admin.GET("/emails/suppressions", c.TrackingController.GetSuppressions)
+	admin.DELETE("/emails/suppressions/:email", c.TrackingController.DeleteSuppression)
+}
+
+// setupCrudRoutes sets up the CRUD routes of an entity and the routes of the files
+// attached to its records, the entity type of the attachments being the path
+func setupCrudRoutes(group *gin.RouterGroup, path string, controller shareds.CrudController, attachmentsController attachments.AttachmentsController) {
 	routes := group.Group(path)
 	{
 		routes.GET("", controller.GetAll)
@@ -89,5 +185,9 @@ func setupCrudRoutes(group *gin.RouterGroup, path string, controller shareds.Cru
 		routes.POST("", controller.Create)
 		routes.PUT("/:id", controller.Update)
 		routes.DELETE("/:id", controller.Delete)
+
+		routes.GET("/:id/attachments", attachmentsController.GetAll(path))
+		routes.POST("/:id/attachments", attachmentsController.Create(path))
+		routes.DELETE("/:id/attachments/:attachmentId", attachmentsController.Delete(path))
 	}
 }
@@ -2,10 +2,10 @@ package socket
 
 import (
 	"bernardtm/backend/internal/core/shareds"
-	"encoding/json"
+	"bernardtm/backend/internal/infra/database"
+	"context"
 	"log"
 	"net/http"
-	"sync"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -17,6 +17,9 @@ type PriorityLevel struct {
 }
 
 type NotificationType struct {
+	// NotificationUUID identifies the notification once stored in the notification center
+	NotificationUUID string `json:"notificationUUID,omitempty"`
+
 	ReceivedUUID  string         `json:"receivedUUID"`
 	UUID          *string        `json:"uuid"`
 	Title         string         `json:"title"`
@@ -26,13 +29,9 @@ type NotificationType struct {
 	OptionType    string         `json:"type"`
 }
 
-type WebSocketHub struct {
-	clients map[string]*websocket.Conn
-	mu      sync.Mutex
-}
-
-var hub = WebSocketHub{
-	clients: make(map[string]*websocket.Conn),
+// Replayer sends a user the notifications they missed while disconnected
+type Replayer interface {
+	Replay(ctx context.Context, userUUID string)
 }
 
 type SocketController interface {
@@ -40,10 +39,15 @@ type SocketController interface {
 }
 
 type socketController struct {
+	hub      *WebSocketHub
+	replayer Replayer
 }
 
-func NewSocketController() *socketController {
-	return &socketController{}
+// NewSocketController creates the websocket handler, registering the connections
+// in hub. The missed notifications are replayed by replayer on every connection,
+// when not nil
+func NewSocketController(hub *WebSocketHub, replayer Replayer) *socketController {
+	return &socketController{hub: hub, replayer: replayer}
 }
 
 var upgrader = websocket.Upgrader{
@@ -60,57 +64,32 @@ func (f *socketController) WebSocketHandler(c *gin.Context) {
 		return
 	}
 
-	userUUID, _ := c.Get("ID")
-
-	hub.mu.Lock()
-	hub.clients[userUUID.(string)] = conn
-	hub.mu.Unlock()
-
-	log.Printf("user %s connected", userUUID.(string))
+	userUUID := c.GetString("ID")
+	tenantID, _ := database.TenantFromContext(c.Request.Context())
+	client := f.hub.register(userUUID, tenantID, conn)
+	defer f.hub.unregister(client)
 
-	defer func() {
-		hub.mu.Lock()
+	log.Printf("user %s connected", userUUID)
 
-		delete(hub.clients, userUUID.(string))
-
-		hub.mu.Unlock()
-
-		conn.Close()
-	}()
-
-	for {
-		_, _, err := conn.ReadMessage()
-		if err != nil {
-			break
-		}
+	if f.replayer != nil {
+		f.replayer.Replay(c.Request.Context(), userUUID)
 	}
 
+	f.hub.read(c.Request.Context(), client)
 }
 
-func SendNotification(userUUID string, message NotificationType) {
-	hub.mu.Lock()
-	conn, ok := hub.clients[userUUID]
-	hub.mu.Unlock()
-
-	if !ok {
-		log.Printf("Usuário %s não está conectado", userUUID)
-		return
-	}
-
-	jsonMessage, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Erro ao serializar mensagem para usuário %s: %v", userUUID, err)
-		return
-	}
+// Notifier sends notifications to the users connected to the websocket
+type Notifier interface {
+	Send(ctx context.Context, userUUID string, message NotificationType)
+}
 
-	if ok {
-		err := conn.WriteMessage(websocket.TextMessage, jsonMessage)
-		if err != nil {
-			log.Printf("Failed to send message to user %s: %v", userUUID, err)
-			conn.Close()
-			hub.mu.Lock()
-			delete(hub.clients, userUUID)
-			hub.mu.Unlock()
-		}
-	}
+// Deliverer writes notifications to the users connected to the websocket,
+// reporting whether they were written, as WebSocketHub does
+type Deliverer interface {
+	Deliver(ctx context.Context, userUUID string, message NotificationType) bool
 }
+
+// Acknowledger records that a stored notification was written to a connection of
+// its user by an instance other than the one delivering it. ctx carries the tenant
+// of the notification
+type Acknowledger func(ctx context.Context, userUUID string, notificationUUID string)
@@ -0,0 +1,56 @@
+package socket
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Broker carries the websocket messages between the instances of the API, so a
+// user is reached whatever instance they are connected to
+type Broker interface {
+	Publish(ctx context.Context, payload []byte) error
+	// Subscribe calls handle with every message published, including the ones of
+	// this instance, until ctx is done or the subscription breaks
+	Subscribe(ctx context.Context, handle func(payload []byte)) error
+}
+
+type redisBroker struct {
+	client  *redis.Client
+	channel string
+}
+
+// NewRedisBroker creates a Broker over a Redis pub/sub channel. Messages published
+// while an instance is not subscribed are lost to it, the notification center
+// replaying them on the next connection of the user
+func NewRedisBroker(client *redis.Client, channel string) *redisBroker {
+	return &redisBroker{client: client, channel: channel}
+}
+
+func (b *redisBroker) Publish(ctx context.Context, payload []byte) error {
+	return b.client.Publish(ctx, b.channel, payload).Err()
+}
+
+func (b *redisBroker) Subscribe(ctx context.Context, handle func(payload []byte)) error {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	defer pubsub.Close()
+
+	// wait for the confirmation, so a broken connection is reported right away
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case message, ok := <-messages:
+			if !ok {
+				return errors.New("redis subscription closed")
+			}
+			handle([]byte(message.Payload))
+		}
+	}
+}
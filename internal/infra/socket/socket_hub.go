@@ -0,0 +1,477 @@
+package socket
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/infra/database"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxMessageSize bounds the messages read from the clients
+	maxMessageSize = 4096
+	// publishTimeout bounds the publication of a message to the other instances
+	publishTimeout = 5 * time.Second
+)
+
+// WebSocketHub keeps the connections of the users, a user having one per browser
+// tab, and the topics they follow. Messages are written by a goroutine per
+// connection from a buffered queue, a connection supporting a single concurrent
+// writer, and are fanned out to the other instances through the broker when set
+type WebSocketHub struct {
+	clients      map[string]map[*client]struct{} // by user
+	topics       map[string]map[*client]struct{} // by tenant scoped topic
+	authorizers  map[string]TopicAuthorizer      // by topic kind
+	acknowledger Acknowledger
+	mu           sync.RWMutex
+	broker       Broker
+	instanceID   string
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	writeTimeout time.Duration
+	sendQueue    int
+	stop         context.CancelFunc
+	done         sync.WaitGroup
+}
+
+// client is a connection of a user
+type client struct {
+	userUUID string
+	tenantID string
+	topics   map[string]struct{} // tenant scoped, guarded by the hub
+	conn     *websocket.Conn
+	send     chan []byte
+	closed   chan struct{}
+	once     sync.Once
+}
+
+// envelope is a message published to the other instances, for a user or for the
+// followers of a topic. The notifications of the notification center carry their
+// UUID and tenant, so the instance writing them acknowledges them
+type envelope struct {
+	Origin           string          `json:"origin"`
+	UserUUID         string          `json:"user_uuid,omitempty"`
+	TenantID         string          `json:"tenant_id,omitempty"`
+	NotificationUUID string          `json:"notification_uuid,omitempty"`
+	Topic            string          `json:"topic,omitempty"`
+	Payload          json.RawMessage `json:"payload"`
+}
+
+// NewWebSocketHub creates a hub delivering through broker to the other instances,
+// or to the connections of this instance only when broker is nil
+func NewWebSocketHub(broker Broker, config *configs.AppConfig) *WebSocketHub {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	return &WebSocketHub{
+		clients:      make(map[string]map[*client]struct{}),
+		topics:       make(map[string]map[*client]struct{}),
+		authorizers:  make(map[string]TopicAuthorizer),
+		broker:       broker,
+		instanceID:   hex.EncodeToString(id),
+		pingInterval: cmp.Or(config.WSPingInterval, 30*time.Second),
+		pongTimeout:  cmp.Or(config.WSPongTimeout, time.Minute),
+		writeTimeout: cmp.Or(config.WSWriteTimeout, 10*time.Second),
+		sendQueue:    cmp.Or(config.WSSendQueue, 64),
+	}
+}
+
+// Authorize registers the authorizer of the topics of a kind, e.g. file for the
+// file:<uuid> topics. Topics of a kind without authorizer cannot be followed
+func (h *WebSocketHub) Authorize(kind string, authorizer TopicAuthorizer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.authorizers[kind] = authorizer
+}
+
+// Acknowledge registers the acknowledger of the stored notifications delivered by
+// this instance for another one
+func (h *WebSocketHub) Acknowledge(acknowledger Acknowledger) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.acknowledger = acknowledger
+}
+
+// Start receives the messages published by the other instances until Close
+func (h *WebSocketHub) Start(ctx context.Context) {
+	if h.broker == nil {
+		return
+	}
+
+	ctx, h.stop = context.WithCancel(ctx)
+	h.done.Add(1)
+	go func() {
+		defer h.done.Done()
+		h.receive(ctx)
+	}()
+}
+
+// Close stops receiving the messages of the other instances
+func (h *WebSocketHub) Close() {
+	if h.stop != nil {
+		h.stop()
+		h.done.Wait()
+	}
+}
+
+// receive delivers the messages of the other instances to the local connections,
+// subscribing again after a second when the subscription breaks
+func (h *WebSocketHub) receive(ctx context.Context) {
+	for {
+		err := h.broker.Subscribe(ctx, func(payload []byte) {
+			var message envelope
+			if err := json.Unmarshal(payload, &message); err != nil {
+				log.Printf("invalid websocket message from broker: %v", err)
+				return
+			}
+			if message.Origin == h.instanceID {
+				return
+			}
+			if message.Topic != "" {
+				h.publishLocal(message.Topic, message.Payload)
+			} else if h.deliverLocal(message.UserUUID, message.Payload) {
+				h.acknowledge(message)
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("websocket broker subscription lost: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Deliver writes a notification to every connection of a user, reporting whether
+// any connection of this instance took it. It is also published to the other
+// instances, which report their deliveries of stored notifications to the
+// acknowledger instead
+func (h *WebSocketHub) Deliver(ctx context.Context, userUUID string, message NotificationType) bool {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Erro ao serializar mensagem para usuário %s: %v", userUUID, err)
+		return false
+	}
+
+	tenantID, _ := database.TenantFromContext(ctx)
+
+	delivered := h.deliverLocal(userUUID, payload)
+	h.publish(envelope{UserUUID: userUUID, TenantID: tenantID, NotificationUUID: message.NotificationUUID, Payload: payload})
+	return delivered
+}
+
+// Publish sends a payload to the clients following a topic of the tenant of ctx,
+// on every instance
+func (h *WebSocketHub) Publish(ctx context.Context, topic string, payload any) {
+	message, err := json.Marshal(ServerMessage{Type: MessageEvent, Topic: topic, Payload: payload})
+	if err != nil {
+		log.Printf("failed to encode websocket event of topic %s: %v", topic, err)
+		return
+	}
+
+	tenantID, _ := database.TenantFromContext(ctx)
+	scoped := scopedTopic(tenantID, topic)
+
+	h.publishLocal(scoped, message)
+	h.publish(envelope{Topic: scoped, Payload: message})
+}
+
+// SendNotification delivers a notification, logging when the user is not
+// connected to this instance
+func (h *WebSocketHub) SendNotification(ctx context.Context, userUUID string, message NotificationType) {
+	if !h.Deliver(ctx, userUUID, message) {
+		log.Printf("Usuário %s não está conectado", userUUID)
+	}
+}
+
+// publish sends a message to the other instances
+func (h *WebSocketHub) publish(message envelope) {
+	if h.broker == nil {
+		return
+	}
+
+	message.Origin = h.instanceID
+	payload, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("failed to encode websocket message: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	if err := h.broker.Publish(ctx, payload); err != nil {
+		log.Printf("failed to publish websocket message: %v", err)
+	}
+}
+
+// acknowledge reports the delivery of a stored notification of another instance,
+// in the tenant of the notification
+func (h *WebSocketHub) acknowledge(message envelope) {
+	h.mu.RLock()
+	acknowledger := h.acknowledger
+	h.mu.RUnlock()
+
+	if acknowledger == nil || message.NotificationUUID == "" {
+		return
+	}
+
+	ctx := context.Background()
+	if message.TenantID != "" {
+		ctx = database.WithTenant(ctx, message.TenantID)
+	}
+	acknowledger(ctx, message.UserUUID, message.NotificationUUID)
+}
+
+// deliverLocal queues a message on every connection of a user to this instance,
+// reporting whether any took it
+func (h *WebSocketHub) deliverLocal(userUUID string, payload []byte) bool {
+	return h.enqueueAll(h.clients, userUUID, payload)
+}
+
+// publishLocal queues a message on every connection of this instance following a
+// tenant scoped topic
+func (h *WebSocketHub) publishLocal(topic string, payload []byte) {
+	h.enqueueAll(h.topics, topic, payload)
+}
+
+// enqueueAll queues a message on the connections of an index under key, reporting
+// whether any took it
+func (h *WebSocketHub) enqueueAll(index map[string]map[*client]struct{}, key string, payload []byte) bool {
+	h.mu.RLock()
+	clients := make([]*client, 0, len(index[key]))
+	for c := range index[key] {
+		clients = append(clients, c)
+	}
+	h.mu.RUnlock()
+
+	delivered := false
+	for _, c := range clients {
+		if h.enqueue(c, payload) {
+			delivered = true
+		}
+	}
+	return delivered
+}
+
+// enqueue queues a message on a connection without waiting. A connection whose
+// queue is full is too slow to keep up and is closed at once, its client
+// reconnecting to catch up. Slow writes are left to the writer, whose deadline is
+// the write timeout
+func (h *WebSocketHub) enqueue(c *client, payload []byte) bool {
+	select {
+	case <-c.closed:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- payload:
+		return true
+	default:
+		log.Printf("closing slow websocket connection of user %s", c.userUUID)
+		c.close()
+		return false
+	}
+}
+
+// register adds a connection of a user of a tenant and starts its writer
+func (h *WebSocketHub) register(userUUID string, tenantID string, conn *websocket.Conn) *client {
+	c := &client{
+		userUUID: userUUID,
+		tenantID: tenantID,
+		topics:   make(map[string]struct{}),
+		conn:     conn,
+		send:     make(chan []byte, h.sendQueue),
+		closed:   make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	if h.clients[userUUID] == nil {
+		h.clients[userUUID] = make(map[*client]struct{})
+	}
+	h.clients[userUUID][c] = struct{}{}
+	h.mu.Unlock()
+
+	go h.write(c)
+	return c
+}
+
+// unregister removes a connection with its topics and closes it
+func (h *WebSocketHub) unregister(c *client) {
+	h.mu.Lock()
+	remove(h.clients, c.userUUID, c)
+	for topic := range c.topics {
+		remove(h.topics, topic, c)
+	}
+	h.mu.Unlock()
+
+	c.close()
+}
+
+// remove removes a connection from an index under key
+func remove(index map[string]map[*client]struct{}, key string, c *client) {
+	delete(index[key], c)
+	if len(index[key]) == 0 {
+		delete(index, key)
+	}
+}
+
+// read handles the messages of a connection until it fails or no pong arrives in
+// time
+func (h *WebSocketHub) read(ctx context.Context, c *client) {
+	c.conn.SetReadLimit(maxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(h.pongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(h.pongTimeout))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var message ClientMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			h.reply(c, ServerMessage{Type: MessageError, Message: "invalid message"})
+			continue
+		}
+		h.handle(ctx, c, message)
+	}
+}
+
+// handle answers a message of a client with an ack, or with an error
+func (h *WebSocketHub) handle(ctx context.Context, c *client, message ClientMessage) {
+	var err error
+	switch message.Type {
+	case MessageSubscribe:
+		err = h.subscribe(ctx, c, message.Topic)
+	case MessageUnsubscribe:
+		h.unsubscribe(c, message.Topic)
+	default:
+		err = errors.New("unknown message type")
+	}
+
+	if err != nil {
+		h.reply(c, ServerMessage{Type: MessageError, ID: message.ID, Topic: message.Topic, Message: err.Error()})
+		return
+	}
+	h.reply(c, ServerMessage{Type: MessageAck, ID: message.ID, Topic: message.Topic})
+}
+
+// subscribe makes a connection follow a topic its user is authorized to
+func (h *WebSocketHub) subscribe(ctx context.Context, c *client, topic string) error {
+	kind, id, ok := splitTopic(topic)
+	if !ok {
+		return errors.New("invalid topic")
+	}
+
+	h.mu.RLock()
+	authorizer, ok := h.authorizers[kind]
+	h.mu.RUnlock()
+	if !ok {
+		return errUnknownTopic
+	}
+
+	if err := authorizer(ctx, c.userUUID, id); err != nil {
+		if errors.Is(err, errUnknownTopic) {
+			return err
+		}
+		if !errors.Is(err, ErrTopicForbidden) {
+			log.Printf("failed to authorize topic %s for user %s: %v", topic, c.userUUID, err)
+		}
+		return ErrTopicForbidden
+	}
+
+	scoped := scopedTopic(c.tenantID, topic)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := c.topics[scoped]; !ok && len(c.topics) >= maxTopics {
+		return errors.New("too many topics")
+	}
+	c.topics[scoped] = struct{}{}
+	if h.topics[scoped] == nil {
+		h.topics[scoped] = make(map[*client]struct{})
+	}
+	h.topics[scoped][c] = struct{}{}
+	return nil
+}
+
+// unsubscribe stops a connection from following a topic, followed or not
+func (h *WebSocketHub) unsubscribe(c *client, topic string) {
+	scoped := scopedTopic(c.tenantID, topic)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(c.topics, scoped)
+	remove(h.topics, scoped, c)
+}
+
+// reply queues a message of the protocol on a connection
+func (h *WebSocketHub) reply(c *client, message ServerMessage) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("failed to encode websocket message: %v", err)
+		return
+	}
+	h.enqueue(c, payload)
+}
+
+// scopedTopic returns the key of a topic of a tenant, the topics of the tenants
+// being apart
+func scopedTopic(tenantID string, topic string) string {
+	return tenantID + "/" + topic
+}
+
+// write is the single writer of a connection, sending the queued messages and
+// the pings keeping it alive until it is closed
+func (h *WebSocketHub) write(c *client) {
+	ticker := time.NewTicker(h.pingInterval)
+	defer ticker.Stop()
+	defer c.close()
+
+	for {
+		select {
+		case <-c.closed:
+			return
+		case payload := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(h.writeTimeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				log.Printf("Failed to send message to user %s: %v", c.userUUID, err)
+				return
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(h.writeTimeout))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// close closes the connection, which ends its reader and writer
+func (c *client) close() {
+	c.once.Do(func() {
+		close(c.closed)
+		c.conn.Close()
+	})
+}
@@ -0,0 +1,402 @@
+package socket
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/internal/infra/database"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryBroker is a Broker shared by hubs of the same process, standing in for
+// Redis between instances
+type memoryBroker struct {
+	mu          sync.Mutex
+	subscribers []chan []byte
+}
+
+func (b *memoryBroker) Publish(ctx context.Context, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, subscriber := range b.subscribers {
+		subscriber <- payload
+	}
+	return nil
+}
+
+func (b *memoryBroker) Subscribe(ctx context.Context, handle func(payload []byte)) error {
+	messages := make(chan []byte, 16)
+	b.mu.Lock()
+	b.subscribers = append(b.subscribers, messages)
+	b.mu.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case payload := <-messages:
+			handle(payload)
+		}
+	}
+}
+
+func (b *memoryBroker) subscribed() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// connectedReplayer reports the connections, replayed once registered in the hub
+type connectedReplayer chan string
+
+func (r connectedReplayer) Replay(ctx context.Context, userUUID string) {
+	r <- userUUID
+}
+
+// newTestServer serves the websocket of a hub, the user and tenant being given by
+// the user and tenant query parameters
+func newTestServer(t *testing.T, hub *WebSocketHub) (string, connectedReplayer) {
+	gin.SetMode(gin.TestMode)
+	connected := make(connectedReplayer, 4)
+	controller := NewSocketController(hub, connected)
+
+	router := gin.New()
+	router.GET("/ws", func(c *gin.Context) {
+		c.Set("ID", c.Query("user"))
+		if tenant := c.Query("tenant"); tenant != "" {
+			c.Request = c.Request.WithContext(database.WithTenant(c.Request.Context(), tenant))
+		}
+		controller.WebSocketHandler(c)
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/ws", connected
+}
+
+func dial(t *testing.T, url string, connected connectedReplayer, userUUID string) *websocket.Conn {
+	return dialTenant(t, url, connected, userUUID, "")
+}
+
+func dialTenant(t *testing.T, url string, connected connectedReplayer, userUUID string, tenant string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?user="+userUUID+"&tenant="+tenant, nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("connection not registered")
+	}
+	return conn
+}
+
+func readNotification(t *testing.T, conn *websocket.Conn) NotificationType {
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	var message NotificationType
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatalf("failed to read notification: %v", err)
+	}
+	return message
+}
+
+func TestWebSocketHub_DeliversToEveryConnectionOfUser(t *testing.T) {
+	hub := NewWebSocketHub(nil, &configs.AppConfig{})
+	url, connected := newTestServer(t, hub)
+	first := dial(t, url, connected, "user-1")
+	second := dial(t, url, connected, "user-1")
+	other := dial(t, url, connected, "user-2")
+
+	delivered := hub.Deliver(context.Background(), "user-1", NotificationType{Title: "File processed"})
+
+	assert.True(t, delivered)
+	assert.Equal(t, "File processed", readNotification(t, first).Title)
+	assert.Equal(t, "File processed", readNotification(t, second).Title, "a second tab does not replace the first")
+
+	_ = other.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err := other.ReadMessage()
+	assert.Error(t, err, "other users are not notified")
+}
+
+func TestWebSocketHub_KeepsOtherConnectionsOnDisconnect(t *testing.T) {
+	hub := NewWebSocketHub(nil, &configs.AppConfig{})
+	url, connected := newTestServer(t, hub)
+	first := dial(t, url, connected, "user-1")
+	second := dial(t, url, connected, "user-1")
+
+	first.Close()
+	assert.Eventually(t, func() bool {
+		hub.mu.RLock()
+		defer hub.mu.RUnlock()
+		return len(hub.clients["user-1"]) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	assert.True(t, hub.Deliver(context.Background(), "user-1", NotificationType{Title: "Still here"}))
+	assert.Equal(t, "Still here", readNotification(t, second).Title)
+}
+
+func TestWebSocketHub_DeliversThroughBroker(t *testing.T) {
+	broker := &memoryBroker{}
+	local := NewWebSocketHub(broker, &configs.AppConfig{})
+	remote := NewWebSocketHub(broker, &configs.AppConfig{})
+	for _, hub := range []*WebSocketHub{local, remote} {
+		hub.Start(context.Background())
+		t.Cleanup(hub.Close)
+	}
+	assert.Eventually(t, func() bool { return broker.subscribed() == 2 }, time.Second, 10*time.Millisecond)
+
+	localURL, localConnected := newTestServer(t, local)
+	remoteURL, remoteConnected := newTestServer(t, remote)
+	onLocal := dial(t, localURL, localConnected, "user-1")
+	onRemote := dial(t, remoteURL, remoteConnected, "user-1")
+
+	delivered := local.Deliver(context.Background(), "user-1", NotificationType{Title: "File processed"})
+
+	assert.True(t, delivered)
+	assert.Equal(t, "File processed", readNotification(t, onLocal).Title)
+	assert.Equal(t, "File processed", readNotification(t, onRemote).Title)
+
+	_ = onLocal.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err := onLocal.ReadMessage()
+	assert.Error(t, err, "the instance does not deliver its own message twice")
+}
+
+func TestWebSocketHub_AcknowledgesNotificationsOfOtherInstances(t *testing.T) {
+	broker := &memoryBroker{}
+	local := NewWebSocketHub(broker, &configs.AppConfig{})
+	remote := NewWebSocketHub(broker, &configs.AppConfig{})
+	acknowledged := make(chan string, 2)
+	for _, hub := range []*WebSocketHub{local, remote} {
+		hub.Acknowledge(func(ctx context.Context, userUUID string, notificationUUID string) {
+			tenantID, _ := database.TenantFromContext(ctx)
+			acknowledged <- tenantID + "/" + userUUID + "/" + notificationUUID
+		})
+		hub.Start(context.Background())
+		t.Cleanup(hub.Close)
+	}
+	assert.Eventually(t, func() bool { return broker.subscribed() == 2 }, time.Second, 10*time.Millisecond)
+
+	// the user is only connected to the remote instance
+	remoteURL, remoteConnected := newTestServer(t, remote)
+	onRemote := dial(t, remoteURL, remoteConnected, "user-1")
+	ctx := database.WithTenant(context.Background(), "tenant-1")
+
+	delivered := local.Deliver(ctx, "user-1", NotificationType{NotificationUUID: "notification-1", Title: "File processed"})
+
+	assert.False(t, delivered)
+	assert.Equal(t, "notification-1", readNotification(t, onRemote).NotificationUUID)
+	select {
+	case ack := <-acknowledged:
+		assert.Equal(t, "tenant-1/user-1/notification-1", ack)
+	case <-time.After(time.Second):
+		t.Fatal("the notification was not acknowledged by the remote instance")
+	}
+
+	// messages that were not stored have nothing to acknowledge
+	local.Deliver(ctx, "user-1", NotificationType{Title: "Not stored"})
+	assert.Equal(t, "Not stored", readNotification(t, onRemote).Title)
+	select {
+	case ack := <-acknowledged:
+		t.Fatalf("unexpected acknowledgement %s", ack)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWebSocketHub_PingsConnections(t *testing.T) {
+	hub := NewWebSocketHub(nil, &configs.AppConfig{WSPingInterval: 20 * time.Millisecond})
+	url, connected := newTestServer(t, hub)
+	conn := dial(t, url, connected, "user-1")
+
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-pinged:
+	case <-time.After(time.Second):
+		t.Fatal("connection not pinged")
+	}
+}
+
+func TestWebSocketHub_ClosesConnectionWithoutPong(t *testing.T) {
+	hub := NewWebSocketHub(nil, &configs.AppConfig{WSPingInterval: time.Hour, WSPongTimeout: 50 * time.Millisecond})
+	url, connected := newTestServer(t, hub)
+	dial(t, url, connected, "user-1")
+
+	assert.Eventually(t, func() bool {
+		payload, _ := json.Marshal(NotificationType{})
+		return !hub.deliverLocal("user-1", payload)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWebSocketHub_ClosesSlowConnectionWithoutWaiting(t *testing.T) {
+	hub := NewWebSocketHub(nil, &configs.AppConfig{WSWriteTimeout: time.Hour, WSSendQueue: 1})
+	url, connected := newTestServer(t, hub)
+	dial(t, url, connected, "user-1") // never read, so the writes end up blocked
+
+	payload := bytes.Repeat([]byte("x"), 1<<20)
+	start := time.Now()
+	delivered := true
+	for i := 0; i < 256 && delivered; i++ {
+		delivered = hub.deliverLocal("user-1", payload)
+	}
+
+	assert.False(t, delivered, "the connection falling behind is closed")
+	assert.Less(t, time.Since(start), 5*time.Second, "the delivery does not wait for the slow connection")
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) ServerMessage {
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	var message ServerMessage
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+	return message
+}
+
+func assertNoMessage(t *testing.T, conn *websocket.Conn, msgAndArgs ...any) {
+	_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err := conn.ReadMessage()
+	assert.Error(t, err, msgAndArgs...)
+}
+
+// subscribe follows a topic, failing the test unless acknowledged
+func subscribe(t *testing.T, conn *websocket.Conn, topic string) {
+	if err := conn.WriteJSON(ClientMessage{Type: MessageSubscribe, ID: "sub", Topic: topic}); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	assert.Equal(t, ServerMessage{Type: MessageAck, ID: "sub", Topic: topic}, readMessage(t, conn))
+}
+
+func ownFiles(ctx context.Context, userUUID string, id string) error {
+	if id != "file-"+userUUID {
+		return ErrTopicForbidden
+	}
+	return nil
+}
+
+func TestWebSocketHub_PublishesToSubscribers(t *testing.T) {
+	hub := NewWebSocketHub(nil, &configs.AppConfig{})
+	hub.Authorize("entity", AllowEntities("menus"))
+	url, connected := newTestServer(t, hub)
+	subscriber := dialTenant(t, url, connected, "user-1", "tenant-1")
+	other := dialTenant(t, url, connected, "user-2", "tenant-1")
+	subscribe(t, subscriber, EntityTopic("menus"))
+
+	ctx := database.WithTenant(context.Background(), "tenant-1")
+	hub.Publish(ctx, EntityTopic("menus"), ChangeEvent{Action: ActionCreated, ID: "menu-1"})
+
+	message := readMessage(t, subscriber)
+	assert.Equal(t, MessageEvent, message.Type)
+	assert.Equal(t, "entity:menus", message.Topic)
+	assert.Equal(t, map[string]any{"action": "created", "id": "menu-1"}, message.Payload)
+	assertNoMessage(t, other, "connections not subscribed receive no event")
+}
+
+func TestWebSocketHub_RejectsUnauthorizedTopics(t *testing.T) {
+	hub := NewWebSocketHub(nil, &configs.AppConfig{})
+	hub.Authorize("entity", AllowEntities("menus"))
+	hub.Authorize("file", ownFiles)
+	url, connected := newTestServer(t, hub)
+	conn := dial(t, url, connected, "user-1")
+
+	tests := []struct {
+		topic   string
+		message string
+	}{
+		{"file:file-user-2", "topic forbidden"},
+		{"entity:users", "unknown topic"},
+		{"tenant:tenant-1", "unknown topic"},
+		{"menus", "invalid topic"},
+	}
+	for _, test := range tests {
+		if err := conn.WriteJSON(ClientMessage{Type: MessageSubscribe, ID: test.topic, Topic: test.topic}); err != nil {
+			t.Fatalf("failed to subscribe: %v", err)
+		}
+		assert.Equal(t, ServerMessage{Type: MessageError, ID: test.topic, Topic: test.topic, Message: test.message}, readMessage(t, conn))
+	}
+
+	subscribe(t, conn, FileTopic("file-user-1"))
+	hub.Publish(context.Background(), FileTopic("file-user-2"), ChangeEvent{Action: ActionDeleted, ID: "file-user-2"})
+	assertNoMessage(t, conn, "events of forbidden topics are not received")
+}
+
+func TestWebSocketHub_UnsubscribeStopsEvents(t *testing.T) {
+	hub := NewWebSocketHub(nil, &configs.AppConfig{})
+	hub.Authorize("entity", AllowEntities("menus"))
+	url, connected := newTestServer(t, hub)
+	conn := dial(t, url, connected, "user-1")
+	subscribe(t, conn, EntityTopic("menus"))
+
+	if err := conn.WriteJSON(ClientMessage{Type: MessageUnsubscribe, ID: "unsub", Topic: EntityTopic("menus")}); err != nil {
+		t.Fatalf("failed to unsubscribe: %v", err)
+	}
+	assert.Equal(t, ServerMessage{Type: MessageAck, ID: "unsub", Topic: "entity:menus"}, readMessage(t, conn))
+
+	hub.Publish(context.Background(), EntityTopic("menus"), ChangeEvent{Action: ActionDeleted, ID: "menu-1"})
+	assertNoMessage(t, conn)
+	assert.Empty(t, hub.topics)
+}
+
+func TestWebSocketHub_ScopesTopicsByTenant(t *testing.T) {
+	hub := NewWebSocketHub(nil, &configs.AppConfig{})
+	hub.Authorize("entity", AllowEntities("menus"))
+	url, connected := newTestServer(t, hub)
+	first := dialTenant(t, url, connected, "user-1", "tenant-1")
+	second := dialTenant(t, url, connected, "user-2", "tenant-2")
+	subscribe(t, first, EntityTopic("menus"))
+	subscribe(t, second, EntityTopic("menus"))
+
+	hub.Publish(database.WithTenant(context.Background(), "tenant-2"), EntityTopic("menus"), ChangeEvent{Action: ActionUpdated, ID: "menu-2"})
+
+	assert.Equal(t, MessageEvent, readMessage(t, second).Type)
+	assertNoMessage(t, first, "other tenants receive no event")
+}
+
+func TestWebSocketHub_PublishesThroughBroker(t *testing.T) {
+	broker := &memoryBroker{}
+	local := NewWebSocketHub(broker, &configs.AppConfig{})
+	remote := NewWebSocketHub(broker, &configs.AppConfig{})
+	for _, hub := range []*WebSocketHub{local, remote} {
+		hub.Authorize("entity", AllowEntities("menus"))
+		hub.Start(context.Background())
+		t.Cleanup(hub.Close)
+	}
+	assert.Eventually(t, func() bool { return broker.subscribed() == 2 }, time.Second, 10*time.Millisecond)
+
+	remoteURL, remoteConnected := newTestServer(t, remote)
+	conn := dialTenant(t, remoteURL, remoteConnected, "user-1", "tenant-1")
+	subscribe(t, conn, EntityTopic("menus"))
+
+	local.Publish(database.WithTenant(context.Background(), "tenant-1"), EntityTopic("menus"), ChangeEvent{Action: ActionCreated, ID: "menu-1"})
+
+	message := readMessage(t, conn)
+	assert.Equal(t, MessageEvent, message.Type)
+	assert.Equal(t, "entity:menus", message.Topic)
+}
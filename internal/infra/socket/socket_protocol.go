@@ -0,0 +1,109 @@
+package socket
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// Types of the messages of the websocket protocol. The notifications keep their
+// own format, their type being the kind of notification
+const (
+	// MessageSubscribe and MessageUnsubscribe are sent by the clients to follow a
+	// topic, e.g. {"type":"subscribe","id":"1","topic":"file:<uuid>"}
+	MessageSubscribe   = "subscribe"
+	MessageUnsubscribe = "unsubscribe"
+	// MessageAck and MessageError answer them, with the ID of the message answered
+	MessageAck   = "ack"
+	MessageError = "error"
+	// MessageEvent carries a payload published on a topic the client follows
+	MessageEvent = "event"
+)
+
+// Actions of the change events published when an entity changes
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionDeleted = "deleted"
+)
+
+const (
+	// maxTopics bounds the topics a connection follows
+	maxTopics = 100
+	// maxTopicLength bounds the length of a topic
+	maxTopicLength = 200
+)
+
+var (
+	// ErrTopicForbidden is returned by the authorizers when the user may not follow
+	// a topic. Topics of entities the user cannot see are forbidden too, so their
+	// existence does not leak
+	ErrTopicForbidden = errors.New("topic forbidden")
+	// errUnknownTopic is returned for the topics of a kind without authorizer
+	errUnknownTopic = errors.New("unknown topic")
+)
+
+// ClientMessage is a message sent by a client
+type ClientMessage struct {
+	Type  string `json:"type"`
+	ID    string `json:"id,omitempty"` // Echoed by the answer, so the client can match them
+	Topic string `json:"topic"`
+}
+
+// ServerMessage is a message of the protocol sent to a client
+type ServerMessage struct {
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	Topic   string `json:"topic,omitempty"`
+	Message string `json:"message,omitempty"` // Reason of an error
+	Payload any    `json:"payload,omitempty"` // Payload of an event
+}
+
+// ChangeEvent is the payload published on the topic of an entity when it changes
+type ChangeEvent struct {
+	Action string `json:"action"`
+	ID     string `json:"id"`
+	Data   any    `json:"data,omitempty"`
+}
+
+// Publisher publishes live updates to the clients following a topic of the tenant
+// of ctx, as WebSocketHub does
+type Publisher interface {
+	Publish(ctx context.Context, topic string, payload any)
+}
+
+// TopicAuthorizer decides whether a user may follow the topics of a kind, given
+// the ID following the kind, e.g. the UUID of file:<uuid>. The context carries
+// the tenant of the user
+type TopicAuthorizer func(ctx context.Context, userUUID string, id string) error
+
+// AllowEntities authorizes every user to follow the given entities, for the
+// entity:<name> topics of the data shared by the users of a tenant
+func AllowEntities(names ...string) TopicAuthorizer {
+	return func(ctx context.Context, userUUID string, id string) error {
+		if !slices.Contains(names, id) {
+			return errUnknownTopic
+		}
+		return nil
+	}
+}
+
+// splitTopic returns the kind and ID of a topic such as file:<uuid>
+func splitTopic(topic string) (string, string, bool) {
+	if len(topic) > maxTopicLength {
+		return "", "", false
+	}
+	kind, id, ok := strings.Cut(topic, ":")
+	return kind, id, ok && kind != "" && id != ""
+}
+
+// EntityTopic returns the topic of an entity, e.g. entity:menus
+func EntityTopic(name string) string {
+	return "entity:" + name
+}
+
+// FileTopic returns the topic of a file
+func FileTopic(fileUUID string) string {
+	return "file:" + fileUUID
+}
@@ -0,0 +1,12 @@
+package utils
+
+import (
+	"bernardtm/backend/pkg/ctxutil"
+	"context"
+	"time"
+)
+
+// WithTimeout bounds ctx by the given per-operation timeout, see ctxutil.WithTimeout
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return ctxutil.WithTimeout(ctx, timeout)
+}
@@ -6,20 +6,31 @@
 // @in header
 // @name Authorization
 // @description Provide the JWT token in the format: Bearer <token>
+// @securityDefinitions.apikey AdminKey
+// @in header
+// @name X-Admin-Key
+// @description Provide the admin key configured in ADMIN_API_KEY
 package main
 
 import (
 	"bernardtm/backend/configs"
 	docs "bernardtm/backend/docs"
+	"bernardtm/backend/internal/infra/database"
 	"bernardtm/backend/internal/infra/di"
 	"bernardtm/backend/internal/infra/router"
+	"bernardtm/backend/migrations"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"go.mongodb.org/mongo-driver/mongo"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -30,6 +41,13 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(os.Args[2:]); err != nil {
+			log.Fatal("Error running migrations:", err)
+		}
+		return
+	}
+
 	if err := startServer(); err != nil {
 		log.Fatal("Error starting server:", err)
 	}
@@ -48,7 +66,37 @@ func startServer() error {
 	}
 	defer db.Close()
 
-	container := di.NewContainer(db, mongoClient, config)
+	if config.MigrateOnStartup {
+		migrator, err := newMigrator(db, config)
+		if err != nil {
+			return err
+		}
+		if err := migrator.Up(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	replicas, err := configs.ConnectReplicas(config)
+	if err != nil {
+		return err
+	}
+	dbRouter := database.NewDBRouter(db, replicas, config.ReplicaHealthInterval)
+	dbRouter.Start(context.Background())
+	defer dbRouter.Close()
+
+	container := di.NewContainer(dbRouter, mongoClient, config)
+	container.FileProcessor.Start(context.Background())
+	defer container.FileProcessor.Close()
+
+	container.StorageLifecycle.Start(context.Background())
+	defer container.StorageLifecycle.Close()
+
+	container.EmailDispatcher.Start(context.Background())
+	defer container.EmailDispatcher.Close()
+
+	container.SocketHub.Start(context.Background())
+	defer container.SocketHub.Close()
+
 	mainRouter := server.SetupRouter(container, config)
 	srv := createHTTPServer(config, mainRouter)
 	ws := createWsServer(config, mainRouter)
@@ -87,6 +135,78 @@ func initializeDependencies(config *configs.AppConfig) (*sql.DB, *mongo.Client,
 
 }
 
+// newMigrator creates a migrator for the embedded PostgreSQL migrations
+func newMigrator(db *sql.DB, config *configs.AppConfig) (*database.Migrator, error) {
+	source, err := fs.Sub(migrations.Postgres, "postgres")
+	if err != nil {
+		return nil, err
+	}
+	return database.NewMigrator(db, source, database.Tenancy{Mode: database.TenancyMode(config.TenancyMode)})
+}
+
+// runMigrateCommand handles "migrate up", "migrate down <steps|all>", "migrate status" and "migrate force <version>".
+// Rolling back requires an explicit count, so a missing argument cannot revert every migration
+func runMigrateCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up | down <steps|all> | status | force <version>")
+	}
+
+	config, err := loadConfiguration()
+	if err != nil {
+		return err
+	}
+	db, err := configs.ConnectDB(config)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := newMigrator(db, config)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		if len(args) < 2 {
+			return errors.New("usage: migrate down <steps|all>")
+		}
+		if args[1] == "all" {
+			return migrator.Down(ctx, 0)
+		}
+		steps, err := strconv.Atoi(args[1])
+		if err != nil || steps <= 0 {
+			return fmt.Errorf("invalid steps %q, expected a positive number or all", args[1])
+		}
+		return migrator.Down(ctx, steps)
+	case "status":
+		report, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		output, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(output))
+		return nil
+	case "force":
+		if len(args) < 2 {
+			return errors.New("usage: migrate force <version>")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q: %w", args[1], err)
+		}
+		return migrator.Force(ctx, version)
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
 // setupManagementServer sets up the management server, which serves the Swagger UI
 func setupManagementServer(config *configs.AppConfig) {
 	docs.SwaggerInfo.Host = config.SwaggerHostConfig
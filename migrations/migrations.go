@@ -0,0 +1,15 @@
+// Package migrations embeds the SQL migrations so the binary can apply them
+// without the external migrate CLI
+package migrations
+
+import "embed"
+
+// Postgres holds the PostgreSQL migrations, named <version>_<title>.<up|down>.sql.
+// The tenant folder holds the part of the migrations changing the tenant tables,
+// applied to the schema of every tenant in schema mode. A migration changing a
+// tenant table needs a tenant migration of the same version. The tenant migrations
+// up to 000017 also run on the schemas provisioned before their versions were
+// recorded, hence their IF NOT EXISTS
+//
+//go:embed postgres/*.sql postgres/tenant/*.sql
+var Postgres embed.FS
@@ -0,0 +1,7 @@
+-- Remove the seeded records from Status Table
+DELETE FROM default_schema.status WHERE name IN (
+    'Actived', 'Inactived', 'Canceled', 'Pending', 'Updated', 'Created', 'Completed',
+    'Error', 'Custom', 'Sent', 'Received', 'Changed', 'Expired', 'Renewed', 'Failed',
+    'Alert', 'Unauthorized', 'Assigned', 'Uploaded', 'Downloaded', 'Shared', 'Accepted',
+    'Rejected', 'Processed', 'Requested'
+);
@@ -0,0 +1,5 @@
+-- Drop the tables in reverse order to avoid dependency issues
+DROP TABLE IF EXISTS default_schema.files CASCADE;
+DROP TABLE IF EXISTS default_schema.two_factor_codes CASCADE;
+DROP TABLE IF EXISTS default_schema.user_menus CASCADE;
+DROP TABLE IF EXISTS default_schema.users CASCADE;
@@ -0,0 +1,54 @@
+-- Users Table
+CREATE TABLE default_schema.users (
+    user_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    username VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    "password" VARCHAR(255) NOT NULL,
+    tax_number VARCHAR(20),
+    position VARCHAR(255),
+    phone VARCHAR(30),
+    profile_image_link TEXT,
+    creation_date DATE DEFAULT CURRENT_DATE,
+    modification_date DATE NULL,
+    status_uuid UUID NOT NULL REFERENCES default_schema.status(status_uuid)
+);
+
+
+-- User Menus Table
+CREATE TABLE default_schema.user_menus (
+    user_menu_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    user_uuid UUID NOT NULL REFERENCES default_schema.users(user_uuid) ON DELETE CASCADE,
+    menu_uuid UUID NOT NULL REFERENCES default_schema.menus(menu_uuid) ON DELETE CASCADE,
+    creation_date DATE DEFAULT CURRENT_DATE,
+    modification_date DATE NULL,
+    UNIQUE (user_uuid, menu_uuid)
+);
+
+
+-- Two Factor Codes Table
+CREATE TABLE default_schema.two_factor_codes (
+    two_factor_code_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    user_uuid UUID NOT NULL REFERENCES default_schema.users(user_uuid) ON DELETE CASCADE,
+    code VARCHAR(20) NOT NULL,
+    expiration_date TIMESTAMP NOT NULL,
+    creation_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modification_date TIMESTAMP NULL,
+    status_uuid UUID NOT NULL REFERENCES default_schema.status(status_uuid)
+);
+
+CREATE INDEX idx_two_factor_codes_user_uuid ON default_schema.two_factor_codes (user_uuid);
+
+
+-- Files Table
+CREATE TABLE default_schema.files (
+    file_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    file_name VARCHAR(255) NOT NULL,
+    file_link TEXT NOT NULL,
+    file_type VARCHAR(100) NOT NULL,
+    file_folder VARCHAR(255) NOT NULL,
+    status_uuid UUID NOT NULL REFERENCES default_schema.status(status_uuid),
+    creation_date DATE DEFAULT CURRENT_DATE,
+    modification_date DATE NULL
+);
+
+CREATE INDEX idx_files_file_link ON default_schema.files (file_link);
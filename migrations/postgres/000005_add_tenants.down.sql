@@ -0,0 +1,13 @@
+-- Drop the tenant columns
+DROP INDEX IF EXISTS default_schema.idx_files_tenant_uuid;
+ALTER TABLE default_schema.files DROP COLUMN IF EXISTS tenant_uuid;
+
+DROP INDEX IF EXISTS default_schema.idx_menus_tenant_uuid;
+ALTER TABLE default_schema.menus DROP COLUMN IF EXISTS tenant_uuid;
+
+ALTER TABLE default_schema.users DROP CONSTRAINT IF EXISTS users_tenant_uuid_email_key;
+ALTER TABLE default_schema.users DROP COLUMN IF EXISTS tenant_uuid;
+ALTER TABLE default_schema.users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+-- Drop the tenants table
+DROP TABLE IF EXISTS default_schema.tenants;
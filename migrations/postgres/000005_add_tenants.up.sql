@@ -0,0 +1,36 @@
+-- Tenants Table
+CREATE TABLE default_schema.tenants (
+    tenant_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(100) NOT NULL UNIQUE,
+    creation_date DATE DEFAULT CURRENT_DATE,
+    modification_date DATE NULL,
+    status_uuid UUID NOT NULL REFERENCES default_schema.status(status_uuid)
+);
+
+
+-- Default tenant owning every record created before multi-tenancy
+INSERT INTO default_schema.tenants (tenant_uuid, name, slug, status_uuid)
+SELECT '00000000-0000-0000-0000-000000000001', 'Default', 'default', status_uuid
+FROM default_schema.status WHERE name = 'Actived';
+
+
+-- Tenant column of the tenant scoped tables
+ALTER TABLE default_schema.users
+    ADD COLUMN tenant_uuid UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001'
+    REFERENCES default_schema.tenants(tenant_uuid) ON DELETE CASCADE;
+ALTER TABLE default_schema.users ALTER COLUMN tenant_uuid DROP DEFAULT;
+ALTER TABLE default_schema.users DROP CONSTRAINT users_email_key;
+ALTER TABLE default_schema.users ADD CONSTRAINT users_tenant_uuid_email_key UNIQUE (tenant_uuid, email);
+
+ALTER TABLE default_schema.menus
+    ADD COLUMN tenant_uuid UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001'
+    REFERENCES default_schema.tenants(tenant_uuid) ON DELETE CASCADE;
+ALTER TABLE default_schema.menus ALTER COLUMN tenant_uuid DROP DEFAULT;
+CREATE INDEX idx_menus_tenant_uuid ON default_schema.menus (tenant_uuid);
+
+ALTER TABLE default_schema.files
+    ADD COLUMN tenant_uuid UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001'
+    REFERENCES default_schema.tenants(tenant_uuid) ON DELETE CASCADE;
+ALTER TABLE default_schema.files ALTER COLUMN tenant_uuid DROP DEFAULT;
+CREATE INDEX idx_files_tenant_uuid ON default_schema.files (tenant_uuid);
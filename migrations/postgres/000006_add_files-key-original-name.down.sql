@@ -0,0 +1,2 @@
+ALTER TABLE default_schema.files DROP COLUMN IF EXISTS original_file_name;
+ALTER TABLE default_schema.files DROP COLUMN IF EXISTS file_key;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS default_schema.idx_files_tenant_uuid_file_key;
+
+ALTER TABLE default_schema.files DROP COLUMN IF EXISTS file_size;
+ALTER TABLE default_schema.files DROP COLUMN IF EXISTS checksum;
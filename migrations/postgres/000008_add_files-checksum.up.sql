@@ -0,0 +1,6 @@
+-- Content checksum and size of the stored files. Files with the same key share
+-- one stored blob, which is removed with the last file referencing it
+ALTER TABLE default_schema.files ADD COLUMN checksum CHAR(64);
+ALTER TABLE default_schema.files ADD COLUMN file_size BIGINT;
+
+CREATE INDEX idx_files_tenant_uuid_file_key ON default_schema.files (tenant_uuid, file_key);
@@ -0,0 +1,4 @@
+ALTER TABLE default_schema.files DROP COLUMN IF EXISTS thumbnail_link;
+ALTER TABLE default_schema.files DROP COLUMN IF EXISTS extracted_text;
+
+DROP TABLE IF EXISTS default_schema.file_jobs;
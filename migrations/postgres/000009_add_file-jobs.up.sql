@@ -0,0 +1,21 @@
+-- Processing jobs of the uploaded files. The table is shared by every tenant,
+-- including in schema mode, so the workers claim the jobs of all tenants at once
+CREATE TABLE default_schema.file_jobs (
+    job_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    tenant_uuid UUID NOT NULL REFERENCES default_schema.tenants(tenant_uuid) ON DELETE CASCADE,
+    file_uuid UUID NOT NULL,
+    user_uuid UUID NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    run_after TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP NULL,
+    failed_date TIMESTAMP NULL,
+    creation_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modification_date TIMESTAMP NULL
+);
+
+CREATE INDEX idx_file_jobs_run_after ON default_schema.file_jobs (run_after) WHERE failed_date IS NULL;
+
+-- Results of the processing
+ALTER TABLE default_schema.files ADD COLUMN extracted_text TEXT;
+ALTER TABLE default_schema.files ADD COLUMN thumbnail_link TEXT;
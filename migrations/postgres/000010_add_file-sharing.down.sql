@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS default_schema.file_access_history;
+DROP TABLE IF EXISTS default_schema.file_shares;
+
+DROP INDEX IF EXISTS default_schema.idx_files_owner_uuid;
+ALTER TABLE default_schema.files DROP COLUMN IF EXISTS owner_uuid;
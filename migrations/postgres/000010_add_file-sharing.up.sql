@@ -0,0 +1,38 @@
+-- Owner of the files, the user who uploaded them. Files uploaded before owners were
+-- recorded have none and stay hidden from the users until an admin assigns them one
+ALTER TABLE default_schema.files
+    ADD COLUMN owner_uuid UUID NULL REFERENCES default_schema.users(user_uuid) ON DELETE CASCADE;
+CREATE INDEX idx_files_owner_uuid ON default_schema.files (owner_uuid);
+
+
+-- File Shares Table. A share grants read access either to one user or to anyone
+-- holding the token of a share link
+CREATE TABLE default_schema.file_shares (
+    share_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    tenant_uuid UUID NOT NULL REFERENCES default_schema.tenants(tenant_uuid) ON DELETE CASCADE,
+    file_uuid UUID NOT NULL REFERENCES default_schema.files(file_uuid) ON DELETE CASCADE,
+    user_uuid UUID NULL REFERENCES default_schema.users(user_uuid) ON DELETE CASCADE,
+    token_hash CHAR(64) NULL UNIQUE,
+    created_by UUID NULL REFERENCES default_schema.users(user_uuid) ON DELETE SET NULL,
+    expiration_date TIMESTAMP NULL,
+    creation_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modification_date TIMESTAMP NULL,
+    UNIQUE (file_uuid, user_uuid),
+    CHECK ((user_uuid IS NULL) <> (token_hash IS NULL))
+);
+
+CREATE INDEX idx_file_shares_user_uuid ON default_schema.file_shares (user_uuid);
+
+
+-- File Access History Table
+CREATE TABLE default_schema.file_access_history (
+    access_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    tenant_uuid UUID NOT NULL REFERENCES default_schema.tenants(tenant_uuid) ON DELETE CASCADE,
+    file_uuid UUID NOT NULL REFERENCES default_schema.files(file_uuid) ON DELETE CASCADE,
+    user_uuid UUID NULL REFERENCES default_schema.users(user_uuid) ON DELETE SET NULL,
+    share_uuid UUID NULL REFERENCES default_schema.file_shares(share_uuid) ON DELETE SET NULL,
+    status_uuid UUID NOT NULL REFERENCES default_schema.status(status_uuid),
+    creation_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_file_access_history_file_uuid ON default_schema.file_access_history (file_uuid, creation_date);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS default_schema.idx_files_file_folder_creation_date;
+DROP TABLE IF EXISTS default_schema.file_versions;
@@ -0,0 +1 @@
+ALTER TABLE default_schema.users DROP COLUMN IF EXISTS locale;
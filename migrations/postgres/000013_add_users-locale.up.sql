@@ -0,0 +1,3 @@
+-- Preferred locale of the user, such as pt-BR, en or es. Emails are rendered in the
+-- closest supported locale, falling back to the default one when it is not set
+ALTER TABLE default_schema.users ADD COLUMN locale VARCHAR(10) NULL;
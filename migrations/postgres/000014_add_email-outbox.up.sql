@@ -0,0 +1,21 @@
+-- Outbox of the emails. Callers enqueue the emails in their own transaction and the
+-- dispatcher sends them in background, retrying with an exponential delay. Like the
+-- file jobs, the table is shared by every tenant, including in schema mode
+CREATE TABLE default_schema.email_outbox (
+    message_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    tenant_uuid UUID NOT NULL REFERENCES default_schema.tenants(tenant_uuid) ON DELETE CASCADE,
+    idempotency_key VARCHAR(255) NULL,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    run_after TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP NULL,
+    sent_date TIMESTAMP NULL,
+    failed_date TIMESTAMP NULL,
+    creation_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modification_date TIMESTAMP NULL,
+    UNIQUE (tenant_uuid, idempotency_key)
+);
+
+CREATE INDEX idx_email_outbox_run_after ON default_schema.email_outbox (run_after) WHERE sent_date IS NULL AND failed_date IS NULL;
+CREATE INDEX idx_email_outbox_sent_date ON default_schema.email_outbox (sent_date) WHERE sent_date IS NOT NULL;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS default_schema.email_suppressions;
+DROP TABLE IF EXISTS default_schema.email_events;
+
+ALTER TABLE default_schema.email_outbox
+    DROP COLUMN IF EXISTS delivery_date,
+    DROP COLUMN IF EXISTS delivery_status;
@@ -0,0 +1,32 @@
+-- Delivery status reported by the providers for the messages of the outbox
+ALTER TABLE default_schema.email_outbox
+    ADD COLUMN delivery_status VARCHAR(20) NULL,
+    ADD COLUMN delivery_date TIMESTAMP NULL;
+
+-- Delivery events received from the provider webhooks. The message is known when it
+-- was sent through the outbox, whose messages are purged after their retention,
+-- hence no foreign key
+CREATE TABLE default_schema.email_events (
+    event_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    provider VARCHAR(20) NOT NULL,
+    provider_event_id VARCHAR(255) NOT NULL,
+    message_id VARCHAR(255) NULL,
+    message_uuid UUID NULL,
+    recipient VARCHAR(255) NOT NULL,
+    event VARCHAR(20) NOT NULL,
+    reason TEXT NULL,
+    occurred_date TIMESTAMP NOT NULL,
+    creation_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, provider_event_id)
+);
+
+CREATE INDEX idx_email_events_message_uuid ON default_schema.email_events (message_uuid) WHERE message_uuid IS NOT NULL;
+
+-- Addresses no email is sent to, e.g. after a hard bounce. Shared by every tenant
+-- since a mailbox bounces for all of them
+CREATE TABLE default_schema.email_suppressions (
+    email VARCHAR(255) NOT NULL PRIMARY KEY,
+    reason VARCHAR(20) NOT NULL,
+    detail TEXT NULL,
+    creation_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
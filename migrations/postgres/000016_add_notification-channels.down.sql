@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS default_schema.push_subscriptions;
+
+ALTER TABLE default_schema.users DROP COLUMN IF EXISTS notification_channel;
@@ -0,0 +1,18 @@
+-- Channel the user prefers to be notified on: email, sms, whatsapp or push. Email
+-- is used when it is not set, or when the preferred channel cannot reach the user
+ALTER TABLE default_schema.users ADD COLUMN notification_channel VARCHAR(20) NULL;
+
+-- Push Subscriptions Table. Browsers the users subscribed to the web push
+-- notifications, a user having one per browser
+CREATE TABLE default_schema.push_subscriptions (
+    subscription_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    tenant_uuid UUID NOT NULL REFERENCES default_schema.tenants(tenant_uuid) ON DELETE CASCADE,
+    user_uuid UUID NOT NULL REFERENCES default_schema.users(user_uuid) ON DELETE CASCADE,
+    endpoint TEXT NOT NULL,
+    p256dh VARCHAR(255) NOT NULL,
+    auth VARCHAR(255) NOT NULL,
+    creation_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant_uuid, endpoint)
+);
+
+CREATE INDEX idx_push_subscriptions_user_uuid ON default_schema.push_subscriptions (user_uuid);
@@ -0,0 +1,20 @@
+-- User Notifications Table. Notification center of the users, the notifications
+-- being sent over the websocket when the user is connected and replayed otherwise
+-- on their next connection
+CREATE TABLE default_schema.user_notifications (
+    notification_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    tenant_uuid UUID NOT NULL REFERENCES default_schema.tenants(tenant_uuid) ON DELETE CASCADE,
+    user_uuid UUID NOT NULL REFERENCES default_schema.users(user_uuid) ON DELETE CASCADE,
+    reference_uuid UUID NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    priority_value VARCHAR(50) NULL,
+    priority_color VARCHAR(20) NULL,
+    type VARCHAR(50) NOT NULL,
+    delivered_date TIMESTAMP NULL,
+    read_date TIMESTAMP NULL,
+    creation_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_notifications_user_uuid ON default_schema.user_notifications (user_uuid, creation_date DESC);
+CREATE INDEX idx_user_notifications_undelivered ON default_schema.user_notifications (user_uuid) WHERE delivered_date IS NULL;
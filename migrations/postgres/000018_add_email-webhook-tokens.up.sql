@@ -0,0 +1,13 @@
+-- Tokens of the signed provider webhooks already received. The signatures only
+-- cover a timestamp and a token, so a token is accepted once to keep a captured
+-- signature from being replayed with another event. Tokens signed before the
+-- maximum age of the webhooks are purged, their signatures being rejected anyway
+CREATE TABLE default_schema.email_webhook_tokens (
+    provider VARCHAR(20) NOT NULL,
+    token VARCHAR(255) NOT NULL,
+    signed_date TIMESTAMP NOT NULL,
+    creation_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, token)
+);
+
+CREATE INDEX idx_email_webhook_tokens_signed_date ON default_schema.email_webhook_tokens (signed_date);
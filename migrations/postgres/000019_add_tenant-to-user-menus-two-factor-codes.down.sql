@@ -0,0 +1,2 @@
+ALTER TABLE default_schema.user_menus DROP COLUMN IF EXISTS tenant_uuid;
+ALTER TABLE default_schema.two_factor_codes DROP COLUMN IF EXISTS tenant_uuid;
@@ -0,0 +1,19 @@
+-- Tenant column of the two-factor codes and of the menus of the users, taken from
+-- the tenant of their users
+ALTER TABLE default_schema.two_factor_codes
+    ADD COLUMN tenant_uuid UUID NULL REFERENCES default_schema.tenants(tenant_uuid) ON DELETE CASCADE;
+UPDATE default_schema.two_factor_codes c
+SET tenant_uuid = u.tenant_uuid
+FROM default_schema.users u
+WHERE u.user_uuid = c.user_uuid;
+ALTER TABLE default_schema.two_factor_codes ALTER COLUMN tenant_uuid SET NOT NULL;
+CREATE INDEX idx_two_factor_codes_tenant_uuid ON default_schema.two_factor_codes (tenant_uuid);
+
+ALTER TABLE default_schema.user_menus
+    ADD COLUMN tenant_uuid UUID NULL REFERENCES default_schema.tenants(tenant_uuid) ON DELETE CASCADE;
+UPDATE default_schema.user_menus um
+SET tenant_uuid = u.tenant_uuid
+FROM default_schema.users u
+WHERE u.user_uuid = um.user_uuid;
+ALTER TABLE default_schema.user_menus ALTER COLUMN tenant_uuid SET NOT NULL;
+CREATE INDEX idx_user_menus_tenant_uuid ON default_schema.user_menus (tenant_uuid);
@@ -0,0 +1,10 @@
+-- Storage key and original name of the uploaded files
+ALTER TABLE default_schema.files ADD COLUMN IF NOT EXISTS file_key TEXT;
+ALTER TABLE default_schema.files ADD COLUMN IF NOT EXISTS original_file_name VARCHAR(255);
+
+UPDATE default_schema.files
+SET file_key = COALESCE(substring(file_link from '^https?://[^/]+/(.*)$'), file_link),
+    original_file_name = file_name
+WHERE file_key IS NULL;
+
+ALTER TABLE default_schema.files ALTER COLUMN file_key SET NOT NULL;
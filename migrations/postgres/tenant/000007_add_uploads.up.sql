@@ -0,0 +1,19 @@
+-- Resumable Uploads Table
+CREATE TABLE IF NOT EXISTS default_schema.uploads (
+    upload_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    tenant_uuid UUID NOT NULL REFERENCES default_schema.tenants(tenant_uuid) ON DELETE CASCADE,
+    user_uuid UUID NULL REFERENCES default_schema.users(user_uuid) ON DELETE SET NULL,
+    file_name VARCHAR(255) NOT NULL,
+    file_type VARCHAR(100) NULL,
+    upload_length BIGINT NOT NULL,
+    upload_offset BIGINT NOT NULL DEFAULT 0,
+    file_uuid UUID NULL REFERENCES default_schema.files(file_uuid) ON DELETE SET NULL,
+    status_uuid UUID NOT NULL REFERENCES default_schema.status(status_uuid),
+    expiration_date TIMESTAMP NOT NULL,
+    creation_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modification_date TIMESTAMP NULL,
+    CHECK (upload_offset >= 0 AND upload_offset <= upload_length)
+);
+
+CREATE INDEX IF NOT EXISTS idx_uploads_tenant_uuid ON default_schema.uploads (tenant_uuid);
+CREATE INDEX IF NOT EXISTS idx_uploads_expiration_date ON default_schema.uploads (expiration_date);
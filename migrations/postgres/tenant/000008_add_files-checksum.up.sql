@@ -0,0 +1,5 @@
+-- Content checksum and size of the stored files
+ALTER TABLE default_schema.files ADD COLUMN IF NOT EXISTS checksum CHAR(64);
+ALTER TABLE default_schema.files ADD COLUMN IF NOT EXISTS file_size BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_files_tenant_uuid_file_key ON default_schema.files (tenant_uuid, file_key);
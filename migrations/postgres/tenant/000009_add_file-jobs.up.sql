@@ -0,0 +1,3 @@
+-- Results of the processing. The jobs themselves are shared by every tenant
+ALTER TABLE default_schema.files ADD COLUMN IF NOT EXISTS extracted_text TEXT;
+ALTER TABLE default_schema.files ADD COLUMN IF NOT EXISTS thumbnail_link TEXT;
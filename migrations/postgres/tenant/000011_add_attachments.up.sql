@@ -0,0 +1,15 @@
+-- Attachments Table
+CREATE TABLE IF NOT EXISTS default_schema.attachments (
+    attachment_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    tenant_uuid UUID NOT NULL REFERENCES default_schema.tenants(tenant_uuid) ON DELETE CASCADE,
+    entity_type VARCHAR(100) NOT NULL,
+    entity_id VARCHAR(255) NOT NULL,
+    file_uuid UUID NOT NULL REFERENCES default_schema.files(file_uuid) ON DELETE CASCADE,
+    "role" VARCHAR(50) NOT NULL DEFAULT 'attachment',
+    created_by UUID NULL REFERENCES default_schema.users(user_uuid) ON DELETE SET NULL,
+    creation_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    modification_date TIMESTAMP NULL,
+    UNIQUE (tenant_uuid, entity_type, entity_id, file_uuid, "role")
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_file_uuid ON default_schema.attachments (file_uuid);
@@ -0,0 +1,18 @@
+-- File Versions Table
+CREATE TABLE IF NOT EXISTS default_schema.file_versions (
+    version_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    tenant_uuid UUID NOT NULL REFERENCES default_schema.tenants(tenant_uuid) ON DELETE CASCADE,
+    file_uuid UUID NOT NULL REFERENCES default_schema.files(file_uuid) ON DELETE CASCADE,
+    version_number INT NOT NULL,
+    original_file_name VARCHAR(255) NOT NULL,
+    file_key TEXT NOT NULL,
+    file_type VARCHAR(100) NOT NULL,
+    checksum CHAR(64),
+    file_size BIGINT,
+    created_by UUID NULL REFERENCES default_schema.users(user_uuid) ON DELETE SET NULL,
+    creation_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (file_uuid, version_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_versions_tenant_uuid_file_key ON default_schema.file_versions (tenant_uuid, file_key);
+CREATE INDEX IF NOT EXISTS idx_files_file_folder_creation_date ON default_schema.files (file_folder, creation_date);
@@ -0,0 +1,2 @@
+-- Preferred locale of the user
+ALTER TABLE default_schema.users ADD COLUMN IF NOT EXISTS locale VARCHAR(10) NULL;
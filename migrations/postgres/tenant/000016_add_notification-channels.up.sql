@@ -0,0 +1,16 @@
+-- Channel the user prefers to be notified on
+ALTER TABLE default_schema.users ADD COLUMN IF NOT EXISTS notification_channel VARCHAR(20) NULL;
+
+-- Push Subscriptions Table
+CREATE TABLE IF NOT EXISTS default_schema.push_subscriptions (
+    subscription_uuid UUID NOT NULL DEFAULT default_schema.uuid_generate_v7() PRIMARY KEY,
+    tenant_uuid UUID NOT NULL REFERENCES default_schema.tenants(tenant_uuid) ON DELETE CASCADE,
+    user_uuid UUID NOT NULL REFERENCES default_schema.users(user_uuid) ON DELETE CASCADE,
+    endpoint TEXT NOT NULL,
+    p256dh VARCHAR(255) NOT NULL,
+    auth VARCHAR(255) NOT NULL,
+    creation_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant_uuid, endpoint)
+);
+
+CREATE INDEX IF NOT EXISTS idx_push_subscriptions_user_uuid ON default_schema.push_subscriptions (user_uuid);
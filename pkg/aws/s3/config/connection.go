@@ -4,17 +4,31 @@ import (
 	"context"
 	"errors"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
-func ConnectionS3(s3Region string, s3AccessKeyID string, s3SecretAccessKey string) (*s3.Client, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(s3Region),
+// S3Options configures the connection to S3 or to an S3-compatible storage
+type S3Options struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Endpoint replaces the AWS endpoint, e.g. http://localhost:9000 for MinIO
+	Endpoint string
+	// UsePathStyle addresses buckets as endpoint/bucket/key instead of bucket.endpoint/key
+	UsePathStyle bool
+}
+
+// ConnectionS3 creates an S3 client. The client is safe for concurrent use and
+// keeps its connections alive, so it is meant to be created once and shared
+func ConnectionS3(ctx context.Context, options S3Options) (*s3.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(options.Region),
 		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			s3AccessKeyID,
-			s3SecretAccessKey,
+			options.AccessKeyID,
+			options.SecretAccessKey,
 			"", // Optional, leave as "" for long-term credentials
 		)))
 
@@ -22,5 +36,10 @@ func ConnectionS3(s3Region string, s3AccessKeyID string, s3SecretAccessKey strin
 		return nil, errors.New("error loading AWS config")
 	}
 
-	return s3.NewFromConfig(cfg), nil
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if options.Endpoint != "" {
+			o.BaseEndpoint = aws.String(options.Endpoint)
+		}
+		o.UsePathStyle = options.UsePathStyle
+	}), nil
 }
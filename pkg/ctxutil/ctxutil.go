@@ -0,0 +1,17 @@
+// Package ctxutil holds the context helpers shared by the providers and the
+// internal packages
+package ctxutil
+
+import (
+	"context"
+	"time"
+)
+
+// WithTimeout bounds ctx by the given per-operation timeout. A zero or negative
+// timeout leaves the deadline of ctx untouched
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
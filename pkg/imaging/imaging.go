@@ -0,0 +1,209 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // GIF decoding
+	"image/jpeg"
+	_ "image/png" // PNG decoding
+	"io"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // WebP decoding
+)
+
+// Format is an output format of the encoded images
+type Format string
+
+const (
+	JPEG Format = "jpeg"
+	WebP Format = "webp"
+)
+
+// Extension returns the file extension of the format
+func (f Format) Extension() string {
+	if f == JPEG {
+		return "jpg"
+	}
+	return string(f)
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	return "image/" + string(f)
+}
+
+// jpegQuality is the quality of the encoded JPEG images
+const jpegQuality = 85
+
+// Image is a decoded image together with the orientation its EXIF metadata asks
+// it to be displayed with. Nothing else of the metadata survives decoding, so
+// every image encoded from it is free of EXIF data
+type Image struct {
+	image.Image
+	Format      string
+	Orientation int
+}
+
+// Decode reads a JPEG, PNG, GIF or WebP image
+func Decode(r io.Reader) (Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+	return Image{Image: img, Format: format, Orientation: orientation}, nil
+}
+
+// Thumbnail returns a size x size image: the centered square of the image scaled
+// with Catmull-Rom and turned upright according to its orientation
+func Thumbnail(img Image, size int) image.Image {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img.Image, crop, draw.Src, nil)
+
+	// the centered square is the same whatever the orientation, so the cheap
+	// transform runs on the small image
+	return orient(dst, img.Orientation)
+}
+
+// Encode writes img in the given format. JPEG has no transparency, so transparent
+// pixels are laid over a white background
+func Encode(w io.Writer, img image.Image, format Format) error {
+	switch format {
+	case JPEG:
+		opaque := image.NewRGBA(img.Bounds())
+		draw.Draw(opaque, opaque.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(opaque, opaque.Bounds(), img, img.Bounds().Min, draw.Over)
+		return jpeg.Encode(w, opaque, &jpeg.Options{Quality: jpegQuality})
+	case WebP:
+		return nativewebp.Encode(w, img, nil)
+	}
+	return fmt.Errorf("unsupported image format %q", format)
+}
+
+// orient applies one of the eight EXIF orientations to a square image
+func orient(img *image.NRGBA, orientation int) *image.NRGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	size := img.Bounds().Dx()
+	last := size - 1
+	dst := image.NewNRGBA(img.Bounds())
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = last-x, y
+			case 3: // rotated 180
+				dx, dy = last-x, last-y
+			case 4: // mirrored vertically
+				dx, dy = x, last-y
+			case 5: // mirrored along the main diagonal
+				dx, dy = y, x
+			case 6: // rotated 90 clockwise
+				dx, dy = last-y, x
+			case 7: // mirrored along the anti-diagonal
+				dx, dy = last-y, last-x
+			case 8: // rotated 90 counterclockwise
+				dx, dy = y, last-x
+			}
+			dst.SetNRGBA(dx, dy, img.NRGBAAt(x, y))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation reads the orientation tag of the EXIF segment of a JPEG image,
+// returning 1 (upright) when there is none
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return 1
+	}
+
+	for offset := 2; offset+4 <= len(data); {
+		if data[offset] != 0xff {
+			return 1
+		}
+		marker := data[offset+1]
+		length := int(data[offset+2])<<8 | int(data[offset+3])
+		if marker == 0xda || length < 2 || offset+2+length > len(data) {
+			// the image data starts without any EXIF segment
+			return 1
+		}
+
+		segment := data[offset+4 : offset+2+length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			orientation, err := exifOrientation(segment[6:])
+			if err != nil {
+				return 1
+			}
+			return orientation
+		}
+		offset += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation reads tag 0x0112 from the first IFD of a TIFF structure
+func exifOrientation(tiff []byte) (int, error) {
+	if len(tiff) < 8 {
+		return 0, errors.New("short EXIF header")
+	}
+
+	var u16 func([]byte) uint16
+	var u32 func([]byte) uint32
+	switch string(tiff[0:2]) {
+	case "II":
+		u16 = func(b []byte) uint16 { return uint16(b[0]) | uint16(b[1])<<8 }
+		u32 = func(b []byte) uint32 { return uint32(u16(b)) | uint32(u16(b[2:]))<<16 }
+	case "MM":
+		u16 = func(b []byte) uint16 { return uint16(b[1]) | uint16(b[0])<<8 }
+		u32 = func(b []byte) uint32 { return uint32(u16(b[2:])) | uint32(u16(b))<<16 }
+	default:
+		return 0, errors.New("invalid EXIF byte order")
+	}
+
+	ifd := int(u32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 0, errors.New("invalid EXIF offset")
+	}
+
+	entries := int(u16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if u16(tiff[entry:]) == 0x0112 {
+			orientation := int(u16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 0, errors.New("invalid orientation")
+			}
+			return orientation, nil
+		}
+	}
+	return 1, nil
+}
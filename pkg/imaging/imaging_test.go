@@ -0,0 +1,80 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// markedJPEG encodes a 64x64 white image whose top left quadrant is red and
+// inserts an EXIF segment with the given orientation after the SOI marker
+func markedJPEG(t *testing.T, orientation byte) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.White)
+			if x < 32 && y < 32 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			}
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatalf("failed to encode jpeg: %v", err)
+	}
+
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00")
+	exif = append(exif, orientation, 0, 0, 0, 0, 0, 0)
+	segment := append([]byte{0xff, 0xe1, byte((len(exif) + 2) >> 8), byte(len(exif) + 2)}, exif...)
+
+	data := buffer.Bytes()
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func isRed(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r > 0xc000 && g < 0x4000 && b < 0x4000
+}
+
+func TestDecode_ReadsOrientation(t *testing.T) {
+	img, err := Decode(bytes.NewReader(markedJPEG(t, 6)))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "jpeg", img.Format)
+	assert.Equal(t, 6, img.Orientation)
+}
+
+func TestThumbnail_TurnsImageUpright(t *testing.T) {
+	img, err := Decode(bytes.NewReader(markedJPEG(t, 6)))
+	assert.NoError(t, err)
+
+	thumbnail := Thumbnail(img, 16)
+
+	// rotated 90 degrees clockwise, the red quadrant moves to the top right
+	assert.Equal(t, image.Rect(0, 0, 16, 16), thumbnail.Bounds())
+	assert.False(t, isRed(thumbnail.At(4, 4)))
+	assert.True(t, isRed(thumbnail.At(12, 4)))
+}
+
+func TestEncode_StripsMetadata(t *testing.T) {
+	img, err := Decode(bytes.NewReader(markedJPEG(t, 3)))
+	assert.NoError(t, err)
+
+	for _, format := range []Format{JPEG, WebP} {
+		var buffer bytes.Buffer
+		assert.NoError(t, Encode(&buffer, Thumbnail(img, 32), format))
+		assert.NotContains(t, buffer.String(), "Exif")
+
+		decoded, err := Decode(bytes.NewReader(buffer.Bytes()))
+		if assert.NoError(t, err) {
+			assert.Equal(t, string(format), decoded.Format)
+			assert.Equal(t, 1, decoded.Orientation)
+			assert.Equal(t, image.Rect(0, 0, 32, 32), decoded.Bounds())
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package emails
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/smtp"
+)
+
+// contextTransport binds every outgoing request to ctx, for clients such as
+// mailgun-go that do not accept a context themselves
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+// newContextClient returns an HTTP client whose requests are cancelled with ctx
+func newContextClient(ctx context.Context) *http.Client {
+	return &http.Client{Transport: contextTransport{ctx: ctx, base: http.DefaultTransport}}
+}
+
+// smtpSecurity selects how an SMTP connection is secured
+type smtpSecurity string
+
+const (
+	// smtpOpportunistic upgrades the connection with STARTTLS when the server offers it
+	smtpOpportunistic smtpSecurity = ""
+	// smtpNone never encrypts the connection, e.g. for a local relay with no certificate
+	smtpNone smtpSecurity = "none"
+	// smtpSTARTTLS requires the connection to be upgraded with STARTTLS, usually on port 587
+	smtpSTARTTLS smtpSecurity = "starttls"
+	// smtpImplicitTLS encrypts the connection from the start, usually on port 465
+	smtpImplicitTLS smtpSecurity = "tls"
+)
+
+// sendMailContext is smtp.SendMail honouring the deadline and cancellation of ctx,
+// with the connection secured as security requires
+func sendMailContext(ctx context.Context, address string, security smtpSecurity, auth smtp.Auth, from string, to []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// unblock any pending read or write as soon as ctx is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if security == smtpImplicitTLS {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return err
+		}
+		conn = tlsConn
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if security == smtpOpportunistic || security == smtpSTARTTLS {
+		ok, _ := client.Extension("STARTTLS")
+		if !ok && security == smtpSTARTTLS {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		if ok {
+			if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+				return err
+			}
+		}
+	}
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -1,5 +1,7 @@
 package emails
 
+import "context"
+
 // EmailDto represents the structure of an email to be sent
 type EmailDto struct {
 	Sender      string            `json:"sender"`                // Sender email address
@@ -9,6 +11,7 @@ type EmailDto struct {
 	Subject     string            `json:"subject"`               // Email subject
 	Body        string            `json:"body"`                  // Email body content
 	IsHTML      bool              `json:"is_html"`               // Indicates if the body is in HTML format
+	HTML        string            `json:"html,omitempty"`        // HTML alternative of a text body, sent as multipart/alternative (optional)
 	Attachments []Attachment      `json:"attachments,omitempty"` // Optional attachments
 	Headers     map[string]string `json:"headers,omitempty"`     // Custom headers (optional)
 }
@@ -22,5 +25,5 @@ type Attachment struct {
 
 // EmailProvider defines the interface for an email provider
 type EmailProvider interface {
-	Send(email EmailDto) error
+	Send(ctx context.Context, email EmailDto) error
 }
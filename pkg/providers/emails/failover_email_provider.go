@@ -0,0 +1,137 @@
+package emails
+
+import (
+	"bernardtm/backend/configs"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrNoEmailProvider is returned when the circuit of every provider is open
+var ErrNoEmailProvider = errors.New("no email provider available")
+
+// NamedEmailProvider is a provider of a failover chain, named for the logs
+type NamedEmailProvider struct {
+	Name     string
+	Provider EmailProvider
+}
+
+// circuitBreaker stops trying a provider after consecutive failures. Once the
+// cooldown elapses a single send is let through, closing the circuit again when
+// it succeeds and reopening it when it fails
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// failoverProvider sends an email through the first provider that accepts it,
+// in priority order, skipping the providers whose circuit is open
+type failoverProvider struct {
+	providers []NamedEmailProvider
+	breakers  []*circuitBreaker
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+}
+
+func NewFailoverEmailProvider(providers []NamedEmailProvider, config *configs.AppConfig) *failoverProvider {
+	breakers := make([]*circuitBreaker, len(providers))
+	for i := range breakers {
+		breakers[i] = &circuitBreaker{}
+	}
+
+	return &failoverProvider{
+		providers: providers,
+		breakers:  breakers,
+		threshold: max(config.BreakerThreshold, 1),
+		cooldown:  config.BreakerCooldown,
+		now:       time.Now,
+	}
+}
+
+// Send implements the EmailProvider interface, failing only when every provider
+// failed or is unavailable
+func (f *failoverProvider) Send(ctx context.Context, email EmailDto) error {
+	var errs []error
+
+	for i, provider := range f.providers {
+		breaker := f.breakers[i]
+		if !f.allow(breaker) {
+			continue
+		}
+
+		err := provider.Provider.Send(ctx, email)
+		if err == nil {
+			f.succeed(breaker)
+			return nil
+		}
+		if ctx.Err() != nil {
+			// the caller gave up, which says nothing about the provider
+			f.release(breaker)
+			return ctx.Err()
+		}
+
+		if f.fail(breaker) {
+			log.Printf("email provider %s unavailable for %s: %v", provider.Name, f.cooldown, err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name, err))
+	}
+
+	if len(errs) == 0 {
+		return ErrNoEmailProvider
+	}
+	return errors.Join(errs...)
+}
+
+// allow reports whether a send may go through the provider of a breaker
+func (f *failoverProvider) allow(breaker *circuitBreaker) bool {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	if breaker.failures < f.threshold {
+		return true
+	}
+	if breaker.probing || f.now().Before(breaker.openUntil) {
+		return false
+	}
+
+	breaker.probing = true
+	return true
+}
+
+func (f *failoverProvider) succeed(breaker *circuitBreaker) {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	breaker.failures = 0
+	breaker.probing = false
+}
+
+// fail records a failed send, reporting whether it opened the circuit
+func (f *failoverProvider) fail(breaker *circuitBreaker) bool {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	breaker.failures++
+	breaker.probing = false
+	if breaker.failures < f.threshold {
+		return false
+	}
+
+	breaker.openUntil = f.now().Add(f.cooldown)
+	return true
+}
+
+// release lets another send probe a half-open circuit when the send let through
+// was interrupted
+func (f *failoverProvider) release(breaker *circuitBreaker) {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	breaker.probing = false
+}
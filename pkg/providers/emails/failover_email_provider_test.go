@@ -0,0 +1,106 @@
+package emails
+
+import (
+	"bernardtm/backend/configs"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProvider fails with err, counting the sends
+type fakeProvider struct {
+	err   error
+	sends int
+}
+
+func (p *fakeProvider) Send(ctx context.Context, email EmailDto) error {
+	p.sends++
+	return p.err
+}
+
+func newTestFailover(providers ...*fakeProvider) (*failoverProvider, *time.Time) {
+	named := make([]NamedEmailProvider, len(providers))
+	for i, provider := range providers {
+		named[i] = NamedEmailProvider{Name: string(rune('a' + i)), Provider: provider}
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	failover := NewFailoverEmailProvider(named, &configs.AppConfig{BreakerThreshold: 2, BreakerCooldown: time.Minute})
+	failover.now = func() time.Time { return now }
+	return failover, &now
+}
+
+func TestFailoverProvider_FallsBackInOrder(t *testing.T) {
+	primary := &fakeProvider{err: errors.New("connection refused")}
+	secondary := &fakeProvider{}
+	failover, _ := newTestFailover(primary, secondary)
+
+	err := failover.Send(context.Background(), EmailDto{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, primary.sends)
+	assert.Equal(t, 1, secondary.sends)
+}
+
+func TestFailoverProvider_JoinsErrors(t *testing.T) {
+	failover, _ := newTestFailover(&fakeProvider{err: errors.New("connection refused")}, &fakeProvider{err: errors.New("quota exceeded")})
+
+	err := failover.Send(context.Background(), EmailDto{})
+
+	assert.ErrorContains(t, err, "a: connection refused")
+	assert.ErrorContains(t, err, "b: quota exceeded")
+}
+
+func TestFailoverProvider_OpensCircuit(t *testing.T) {
+	primary := &fakeProvider{err: errors.New("connection refused")}
+	secondary := &fakeProvider{}
+	failover, now := newTestFailover(primary, secondary)
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, failover.Send(context.Background(), EmailDto{}))
+	}
+	assert.Equal(t, 2, primary.sends, "the primary is skipped once its circuit opens")
+	assert.Equal(t, 3, secondary.sends)
+
+	// a single send probes the primary after the cooldown
+	*now = now.Add(time.Minute)
+	primary.err = nil
+	assert.NoError(t, failover.Send(context.Background(), EmailDto{}))
+	assert.NoError(t, failover.Send(context.Background(), EmailDto{}))
+
+	assert.Equal(t, 4, primary.sends, "the circuit closes once the probe succeeds")
+	assert.Equal(t, 3, secondary.sends)
+}
+
+func TestFailoverProvider_ReopensCircuitWhenProbeFails(t *testing.T) {
+	primary := &fakeProvider{err: errors.New("connection refused")}
+	failover, now := newTestFailover(primary)
+
+	failover.Send(context.Background(), EmailDto{})
+	failover.Send(context.Background(), EmailDto{})
+	*now = now.Add(time.Minute)
+	failover.Send(context.Background(), EmailDto{})
+
+	err := failover.Send(context.Background(), EmailDto{})
+
+	assert.ErrorIs(t, err, ErrNoEmailProvider)
+	assert.Equal(t, 3, primary.sends)
+}
+
+func TestFailoverProvider_CanceledSendIsNotAFailure(t *testing.T) {
+	primary := &fakeProvider{err: context.Canceled}
+	secondary := &fakeProvider{}
+	failover, _ := newTestFailover(primary, secondary)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, failover.Send(ctx, EmailDto{}), context.Canceled)
+	}
+
+	assert.Equal(t, 3, primary.sends, "the circuit stays closed")
+	assert.Equal(t, 0, secondary.sends)
+}
@@ -1,7 +1,12 @@
 package emails
 
 import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/pkg/ctxutil"
+	"context"
 	"encoding/base64"
+	"time"
+
 	"github.com/mailgun/mailgun-go"
 )
 
@@ -9,9 +14,22 @@ type MailgunAPIProvider struct {
 	APIBaseURL string
 	APIKey     string
 	Domain     string
+	Timeout    time.Duration
+}
+
+func NewMailgunAPIProvider(config *configs.AppConfig) *MailgunAPIProvider {
+	return &MailgunAPIProvider{
+		APIBaseURL: config.MailgunAPIBase,
+		APIKey:     config.MAILGUN_API_KEY,
+		Domain:     config.MAILGUN_DOMAIN,
+		Timeout:    config.EmailTimeout,
+	}
 }
 
-func (m *MailgunAPIProvider) Send(email EmailDto) error {
+func (m *MailgunAPIProvider) Send(ctx context.Context, email EmailDto) error {
+	ctx, cancel := ctxutil.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	to := email.To
 	cc := email.CC
 	bcc := email.BCC
@@ -38,9 +56,17 @@ func (m *MailgunAPIProvider) Send(email EmailDto) error {
 	}
 
 	mg := mailgun.NewMailgun(m.Domain, m.APIKey)
+	mg.SetClient(newContextClient(ctx))
+	if m.APIBaseURL != "" {
+		// e.g. https://api.eu.mailgun.net/v3 for domains in the EU region
+		mg.SetAPIBase(m.APIBaseURL)
+	}
 
 	message := mg.NewMessage(
 		email.Sender, email.Subject, email.Body, to...)
+	if email.HTML != "" {
+		message.SetHtml(email.HTML)
+	}
 
 	resp, _, err := mg.Send(message)
 
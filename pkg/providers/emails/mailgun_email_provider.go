@@ -2,25 +2,35 @@ package emails
 
 import (
 	"bernardtm/backend/configs"
+	"bernardtm/backend/pkg/ctxutil"
+	"context"
 	"encoding/base64"
 	"fmt"
+	"time"
+
 	"github.com/mailgun/mailgun-go"
 )
 
 type mailgunSMTPProvider struct {
-	Domain string
-	APIKey string
+	Domain  string
+	APIKey  string
+	Timeout time.Duration
 }
 
 func NewMailgunProvider(config *configs.AppConfig) *mailgunSMTPProvider {
 	return &mailgunSMTPProvider{
-		Domain: config.MAILGUN_DOMAIN,
-		APIKey: config.MAILGUN_API_KEY,
+		Domain:  config.MAILGUN_DOMAIN,
+		APIKey:  config.MAILGUN_API_KEY,
+		Timeout: config.EmailTimeout,
 	}
 }
 
-func (m *mailgunSMTPProvider) Send(email EmailDto) error {
+func (m *mailgunSMTPProvider) Send(ctx context.Context, email EmailDto) error {
+	ctx, cancel := ctxutil.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	mg := mailgun.NewMailgun(m.Domain, m.APIKey)
+	mg.SetClient(newContextClient(ctx))
 
 	message := mg.NewMessage(email.Sender, email.Subject, email.Body)
 
@@ -42,6 +52,12 @@ func (m *mailgunSMTPProvider) Send(email EmailDto) error {
 
 	if email.IsHTML {
 		message.SetHtml(email.Body)
+	} else if email.HTML != "" {
+		message.SetHtml(email.HTML)
+	}
+
+	for key, value := range email.Headers {
+		message.AddHeader(key, value)
 	}
 
 	for _, attachment := range email.Attachments {
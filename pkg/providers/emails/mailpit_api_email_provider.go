@@ -1,21 +1,35 @@
 package emails
 
 import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/pkg/ctxutil"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // MailpitAPIProvider is an implementation of the EmailProvider interface using Mailpit API
 type MailpitAPIProvider struct {
-	APIBaseURL string // Base URL for the Mailpit API
-	APIKey     string // Optional API key for authentication (if required)
+	APIBaseURL string        // Base URL for the Mailpit API
+	APIKey     string        // Optional API key for authentication (if required)
+	Timeout    time.Duration // Optional deadline for each send
+}
+
+func NewMailpitAPIProvider(config *configs.AppConfig) *MailpitAPIProvider {
+	return &MailpitAPIProvider{
+		APIBaseURL: config.MailpitAPIURL,
+		Timeout:    config.EmailTimeout,
+	}
 }
 
 // Send implements the EmailProvider interface for Mailpit API
-func (m *MailpitAPIProvider) Send(email EmailDto) error {
+func (m *MailpitAPIProvider) Send(ctx context.Context, email EmailDto) error {
+	ctx, cancel := ctxutil.WithTimeout(ctx, m.Timeout)
+	defer cancel()
 
 	// Map email addresses to AddressName format
 	to := MapAddresses(email.To)
@@ -29,6 +43,7 @@ func (m *MailpitAPIProvider) Send(email EmailDto) error {
 		BCC:     bcc,
 		Subject: email.Subject,
 		Text:    email.Body,
+		HTML:    email.HTML,
 	}
 
 	if email.IsHTML {
@@ -56,7 +71,7 @@ func (m *MailpitAPIProvider) Send(email EmailDto) error {
 
 	// Create the HTTP request
 	url := fmt.Sprintf("%s/send", m.APIBaseURL)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payloadBytes))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(payloadBytes))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -2,6 +2,7 @@ package emails
 
 import (
 	"bernardtm/backend/configs"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -56,7 +57,7 @@ func TestSMTPProviderSend(t *testing.T) {
 	}
 
 	// Send the email using SMTPProvider
-	err := mailpitSMTPProvider.Send(email)
+	err := mailpitSMTPProvider.Send(context.Background(), email)
 	if err != nil {
 		t.Fatalf("failed to send email: %v", err)
 	}
@@ -0,0 +1,137 @@
+package emails
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// mimeEntity is a part of a MIME message: its headers and its encoded content
+type mimeEntity struct {
+	header  textproto.MIMEHeader
+	content []byte
+}
+
+// buildMessage writes an email as a MIME message to be sent over SMTP. A body with
+// an HTML alternative is sent as multipart/alternative, so clients show the best
+// version they support, and attachments wrap the body in multipart/mixed. Writes
+// go to memory buffers, which cannot fail
+func buildMessage(email EmailDto) []byte {
+	body := bodyEntity(email)
+	if len(email.Attachments) > 0 {
+		parts := []mimeEntity{body}
+		for _, attachment := range email.Attachments {
+			parts = append(parts, attachmentEntity(attachment))
+		}
+		body = multipartEntity("mixed", parts)
+	}
+
+	var message bytes.Buffer
+	writeHeader(&message, "From", email.Sender)
+	writeHeader(&message, "To", strings.Join(email.To, ", "))
+	if len(email.CC) > 0 {
+		writeHeader(&message, "Cc", strings.Join(email.CC, ", "))
+	}
+	writeHeader(&message, "Subject", mime.QEncoding.Encode("utf-8", email.Subject))
+	writeHeader(&message, "MIME-Version", "1.0")
+	for _, key := range sortedKeys(email.Headers) {
+		writeHeader(&message, key, email.Headers[key])
+	}
+	for _, key := range sortedKeys(body.header) {
+		writeHeader(&message, key, body.header.Get(key))
+	}
+	message.WriteString("\r\n")
+	message.Write(body.content)
+	return message.Bytes()
+}
+
+// bodyEntity returns the text of the email, with its HTML alternative when set
+func bodyEntity(email EmailDto) mimeEntity {
+	if email.IsHTML {
+		return textEntity("text/html", email.Body)
+	}
+	if email.HTML == "" {
+		return textEntity("text/plain", email.Body)
+	}
+	// the preferred alternative goes last
+	return multipartEntity("alternative", []mimeEntity{
+		textEntity("text/plain", email.Body),
+		textEntity("text/html", email.HTML),
+	})
+}
+
+func textEntity(contentType string, text string) mimeEntity {
+	var content bytes.Buffer
+	writer := quotedprintable.NewWriter(&content)
+	writer.Write([]byte(text))
+	writer.Close()
+
+	return mimeEntity{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"charset": "utf-8"})},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		content: content.Bytes(),
+	}
+}
+
+func attachmentEntity(attachment Attachment) mimeEntity {
+	contentType := attachment.MIMEType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// base64 lines are limited to 76 characters
+	encoded := base64.StdEncoding.EncodeToString(attachment.Content)
+	var content bytes.Buffer
+	for len(encoded) > 76 {
+		content.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	content.WriteString(encoded)
+
+	return mimeEntity{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		},
+		content: content.Bytes(),
+	}
+}
+
+func multipartEntity(subtype string, parts []mimeEntity) mimeEntity {
+	var content bytes.Buffer
+	writer := multipart.NewWriter(&content)
+	for _, part := range parts {
+		partWriter, _ := writer.CreatePart(part.header)
+		partWriter.Write(part.content)
+	}
+	writer.Close()
+
+	return mimeEntity{
+		header: textproto.MIMEHeader{
+			"Content-Type": {fmt.Sprintf("multipart/%s; boundary=%s", subtype, writer.Boundary())},
+		},
+		content: content.Bytes(),
+	}
+}
+
+func writeHeader(message *bytes.Buffer, key string, value string) {
+	message.WriteString(key + ": " + value + "\r\n")
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
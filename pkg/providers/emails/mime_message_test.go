@@ -0,0 +1,115 @@
+package emails
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readParts parses a multipart body, decoding the content of each part
+func readParts(t *testing.T, contentType string, body io.Reader) ([]string, []string) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, mediaType, "multipart/")
+
+	var types, contents []string
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, part.Header.Get("Content-Type"))
+		contents = append(contents, string(content))
+	}
+	return types, contents
+}
+
+func TestBuildMessage_TextWithHTMLAlternative(t *testing.T) {
+	message := buildMessage(EmailDto{
+		Sender:  "no-reply@company.com",
+		To:      []string{"alice@example.com", "bob@example.com"},
+		Subject: "Código de Verificação",
+		Body:    "Seu código é 123456",
+		HTML:    "<p>Seu código é <strong>123456</strong></p>",
+		Headers: map[string]string{"X-Template": "two_factor_code"},
+	})
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Código de Verificação", subject)
+	assert.Equal(t, "alice@example.com, bob@example.com", parsed.Header.Get("To"))
+	assert.Equal(t, "two_factor_code", parsed.Header.Get("X-Template"))
+
+	types, contents := readParts(t, parsed.Header.Get("Content-Type"), parsed.Body)
+	assert.Equal(t, []string{"text/plain; charset=utf-8", "text/html; charset=utf-8"}, types)
+	assert.Equal(t, []string{"Seu código é 123456", "<p>Seu código é <strong>123456</strong></p>"}, contents)
+}
+
+func TestBuildMessage_Attachments(t *testing.T) {
+	message := buildMessage(EmailDto{
+		Sender:      "no-reply@company.com",
+		To:          []string{"alice@example.com"},
+		Subject:     "Report",
+		Body:        "Attached",
+		HTML:        "<p>Attached</p>",
+		Attachments: []Attachment{{Filename: "report.pdf", Content: bytes.Repeat([]byte("%PDF"), 50), MIMEType: "application/pdf"}},
+	})
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "multipart/mixed", mediaType)
+
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	body, err := reader.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	types, _ := readParts(t, body.Header.Get("Content-Type"), body)
+	assert.Equal(t, []string{"text/plain; charset=utf-8", "text/html; charset=utf-8"}, types)
+
+	attachment, err := reader.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "report.pdf", attachment.FileName())
+	assert.Equal(t, "application/pdf", attachment.Header.Get("Content-Type"))
+	assert.Equal(t, "base64", attachment.Header.Get("Content-Transfer-Encoding"))
+}
+
+func TestBuildMessage_PlainText(t *testing.T) {
+	message := buildMessage(EmailDto{Sender: "no-reply@company.com", To: []string{"alice@example.com"}, Subject: "Hi", Body: "Hello"})
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "text/plain; charset=utf-8", parsed.Header.Get("Content-Type"))
+	assert.Equal(t, "quoted-printable", parsed.Header.Get("Content-Transfer-Encoding"))
+}
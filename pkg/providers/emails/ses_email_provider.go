@@ -0,0 +1,113 @@
+package emails
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/pkg/ctxutil"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// sesSendEmailRequest is the body of the SendEmail action of the SES v2 API
+type sesSendEmailRequest struct {
+	FromEmailAddress string         `json:"FromEmailAddress"`
+	Destination      sesDestination `json:"Destination"`
+	Content          sesContent     `json:"Content"`
+}
+
+type sesDestination struct {
+	ToAddresses  []string `json:"ToAddresses,omitempty"`
+	CcAddresses  []string `json:"CcAddresses,omitempty"`
+	BccAddresses []string `json:"BccAddresses,omitempty"`
+}
+
+type sesContent struct {
+	Raw struct {
+		Data []byte `json:"Data"` // Base64-encoded MIME message
+	} `json:"Raw"`
+}
+
+// sesProvider sends emails through the Amazon SES v2 API, or any service
+// implementing it, as raw MIME messages signed with AWS Signature Version 4
+type sesProvider struct {
+	Endpoint    string          // API endpoint, e.g. https://email.us-east-1.amazonaws.com
+	Region      string          // Region the requests are signed for
+	Credentials aws.Credentials // Credentials the requests are signed with
+	Timeout     time.Duration   // Deadline for each send (zero means no deadline)
+	client      *http.Client
+	signer      *v4.Signer
+}
+
+func NewSESEmailProvider(config *configs.AppConfig) *sesProvider {
+	endpoint := config.SESEndpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://email.%s.amazonaws.com", config.SESRegion)
+	}
+
+	return &sesProvider{
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		Region:   config.SESRegion,
+		Credentials: aws.Credentials{
+			AccessKeyID:     config.SESAccessKeyID,
+			SecretAccessKey: config.SESSecretAccessKey,
+		},
+		Timeout: config.EmailTimeout,
+		client:  &http.Client{},
+		signer:  v4.NewSigner(),
+	}
+}
+
+// Send implements the EmailProvider interface for SES
+func (p *sesProvider) Send(ctx context.Context, email EmailDto) error {
+	ctx, cancel := ctxutil.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	request := sesSendEmailRequest{
+		FromEmailAddress: email.Sender,
+		Destination: sesDestination{
+			ToAddresses:  email.To,
+			CcAddresses:  email.CC,
+			BccAddresses: email.BCC,
+		},
+	}
+	request.Content.Raw.Data = buildMessage(email)
+
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to serialize email payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint+"/v2/email/outbound-emails", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	hash := sha256.Sum256(payload)
+	if err := p.signer.SignHTTP(ctx, p.Credentials, req, hex.EncodeToString(hash[:]), "ses", p.Region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to SES: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("SES returned error: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package emails
+
+import (
+	"bernardtm/backend/configs"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSESProviderSend(t *testing.T) {
+	var request sesSendEmailRequest
+	var authorization string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v2/email/outbound-emails", r.URL.Path)
+		authorization = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`{"MessageId":"0100018c"}`))
+	}))
+	defer server.Close()
+
+	provider := NewSESEmailProvider(&configs.AppConfig{
+		SESRegion:          "eu-west-1",
+		SESEndpoint:        server.URL + "/",
+		SESAccessKeyID:     "AKIDEXAMPLE",
+		SESSecretAccessKey: "secret",
+	})
+
+	err := provider.Send(context.Background(), EmailDto{
+		Sender:  "sender@example.com",
+		To:      []string{"recipient@example.com"},
+		BCC:     []string{"audit@example.com"},
+		Subject: "Test Email Subject",
+		Body:    "This is a test email body.",
+	})
+
+	if err != nil {
+		t.Fatalf("failed to send email: %v", err)
+	}
+	assert.True(t, strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+	assert.Contains(t, authorization, "/eu-west-1/ses/aws4_request")
+	assert.Equal(t, "sender@example.com", request.FromEmailAddress)
+	assert.Equal(t, []string{"audit@example.com"}, request.Destination.BccAddresses)
+	assert.Contains(t, string(request.Content.Raw.Data), "Subject: Test Email Subject")
+	assert.NotContains(t, string(request.Content.Raw.Data), "audit@example.com", "BCC recipients are not in the headers")
+}
+
+func TestSESProviderSend_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message":"Email address is not verified."}`))
+	}))
+	defer server.Close()
+
+	provider := NewSESEmailProvider(&configs.AppConfig{SESEndpoint: server.URL})
+
+	err := provider.Send(context.Background(), EmailDto{Sender: "sender@example.com", To: []string{"recipient@example.com"}})
+
+	assert.ErrorContains(t, err, "Email address is not verified.")
+}
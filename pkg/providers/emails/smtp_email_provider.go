@@ -0,0 +1,54 @@
+package emails
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/pkg/ctxutil"
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// smtpProvider sends emails through any SMTP server, authenticating when a
+// username is set
+type smtpProvider struct {
+	Host     string        // SMTP server host
+	Port     int           // SMTP server port (e.g., 587 with STARTTLS, 465 with implicit TLS)
+	Username string        // SMTP username, no authentication when empty
+	Password string        // SMTP password
+	Security smtpSecurity  // How the connection is secured: starttls, tls or none
+	Timeout  time.Duration // Deadline for each send (zero means no deadline)
+}
+
+func NewSMTPEmailProvider(config *configs.AppConfig) *smtpProvider {
+	return &smtpProvider{
+		Host:     config.SMTPHost,
+		Port:     config.SMTPPort,
+		Username: config.SMTPUsername,
+		Password: config.SMTPPassword,
+		Security: smtpSecurity(config.SMTPSecurity),
+		Timeout:  config.EmailTimeout,
+	}
+}
+
+// Send implements the EmailProvider interface for SMTP
+func (s *smtpProvider) Send(ctx context.Context, email EmailDto) error {
+	ctx, cancel := ctxutil.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		// PLAIN only goes over encrypted connections, or to localhost
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	address := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	err := sendMailContext(ctx, address, s.Security, auth, email.Sender, append(email.To, append(email.CC, email.BCC...)...), buildMessage(email))
+	if err != nil {
+		return fmt.Errorf("failed to send email via SMTP: %w", err)
+	}
+
+	return nil
+}
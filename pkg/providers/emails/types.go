@@ -12,6 +12,7 @@ type EmailMailpitPayload struct {
 	BCC         []AddressName        `json:"BCC,omitempty"`
 	Subject     string               `json:"Subject"`
 	Text        string               `json:"Text"`
+	HTML        string               `json:"HTML,omitempty"`
 	ContentType string               `json:"Content_type"`
 	Attachments []MailpitAttachtment `json:"Attachments"`
 }
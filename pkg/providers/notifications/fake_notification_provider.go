@@ -0,0 +1,41 @@
+package notifications
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+)
+
+type fakeNotificationProvider struct {
+	channel  string
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewFakeNotificationProvider creates a provider for development and tests, which
+// logs the messages of a channel instead of sending them and keeps them in memory
+func NewFakeNotificationProvider(channel string) *fakeNotificationProvider {
+	return &fakeNotificationProvider{channel: channel}
+}
+
+func (p *fakeNotificationProvider) Send(ctx context.Context, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	log.Printf("%s to %s: %s", p.channel, message.To, message.Body)
+	p.messages = append(p.messages, message)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first
+func (p *fakeNotificationProvider) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return slices.Clone(p.messages)
+}
@@ -0,0 +1,25 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrSubscriptionGone is returned when a push subscription expired or was revoked
+// by the user, and must not be used again
+var ErrSubscriptionGone = errors.New("push subscription is gone")
+
+// Message is a short notification sent over a channel other than email
+type Message struct {
+	To     string `json:"to"`             // Phone number in E.164 format, or the JSON push subscription
+	Title  string `json:"title"`          // Title, shown by the push notifications only
+	Body   string `json:"body"`           // Text of the message
+	Link   string `json:"link,omitempty"` // URL opened from a push notification (optional)
+	Locale string `json:"locale"`         // Locale the message is written in, e.g. pt-BR
+}
+
+// NotificationProvider defines the interface for a provider of a notification
+// channel, such as SMS, WhatsApp or web push
+type NotificationProvider interface {
+	Send(ctx context.Context, message Message) error
+}
@@ -0,0 +1,68 @@
+package notifications
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/pkg/ctxutil"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// twilioSMSProvider sends SMS messages through the Twilio Messaging API
+type twilioSMSProvider struct {
+	APIBaseURL string        // Base URL of the API, e.g. https://api.twilio.com
+	AccountSID string        // Account the messages are sent by
+	AuthToken  string        // Auth token of the account
+	From       string        // Sender phone number or messaging service SID
+	Timeout    time.Duration // Deadline for each send (zero means no deadline)
+	client     *http.Client
+}
+
+func NewTwilioSMSProvider(config *configs.AppConfig) *twilioSMSProvider {
+	return &twilioSMSProvider{
+		APIBaseURL: "https://api.twilio.com",
+		AccountSID: config.TwilioAccountSID,
+		AuthToken:  config.TwilioAuthToken,
+		From:       config.TwilioFrom,
+		Timeout:    config.NotifyTimeout,
+		client:     &http.Client{},
+	}
+}
+
+// Send implements the NotificationProvider interface for Twilio
+func (p *twilioSMSProvider) Send(ctx context.Context, message Message) error {
+	ctx, cancel := ctxutil.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	form := url.Values{"To": {message.To}, "Body": {message.Body}}
+	if strings.HasPrefix(p.From, "MG") {
+		form.Set("MessagingServiceSid", p.From)
+	} else {
+		form.Set("From", p.From)
+	}
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", p.APIBaseURL, url.PathEscape(p.AccountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(p.AccountSID, p.AuthToken)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to Twilio: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("twilio returned error: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
@@ -0,0 +1,339 @@
+package notifications
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/pkg/ctxutil"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"golang.org/x/crypto/hkdf"
+)
+
+// recordSize is the record size announced in the encrypted push messages, which
+// always fit in a single record
+const recordSize = 4096
+
+// ErrInvalidEndpoint is returned for the push endpoints that are not public https URLs
+var ErrInvalidEndpoint = errors.New("push endpoint must be a public https URL")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), not routed on the internet
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// PushSubscription is the subscription of a browser to the push notifications, as
+// returned by PushManager.subscribe in the browser
+type PushSubscription struct {
+	Endpoint string   `json:"endpoint" binding:"required,url"` // Public https URL of the push service, see ValidateEndpoint
+	Keys     PushKeys `json:"keys"`
+}
+
+// PushKeys are the keys a push message is encrypted with for a subscription
+type PushKeys struct {
+	P256dh string `json:"p256dh" binding:"required"` // P-256 public key of the browser, base64url encoded
+	Auth   string `json:"auth" binding:"required"`   // Authentication secret of the browser, base64url encoded
+}
+
+// pushPayload is the notification shown by the service worker of the frontend
+type pushPayload struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Link  string `json:"link,omitempty"`
+}
+
+// webPushProvider sends Web Push notifications (RFC 8030) encrypted for the
+// subscription (RFC 8291) and authenticated with VAPID (RFC 8292). The message
+// recipient is the JSON subscription
+type webPushProvider struct {
+	PublicKey string        // VAPID public key, base64url encoded, shared with the frontend
+	Subject   string        // Contact of the application server, e.g. mailto:admin@company.com
+	TTL       time.Duration // How long the push services keep an undelivered message
+	Timeout   time.Duration // Deadline for each send (zero means no deadline)
+	key       *ecdsa.PrivateKey
+	keyErr    error
+	client    *http.Client
+	allowed   func(addr netip.Addr) bool
+}
+
+func NewWebPushProvider(config *configs.AppConfig) *webPushProvider {
+	key, err := parseVAPIDKey(config.VAPIDPrivateKey)
+
+	return &webPushProvider{
+		PublicKey: config.VAPIDPublicKey,
+		Subject:   config.VAPIDSubject,
+		TTL:       24 * time.Hour,
+		Timeout:   config.NotifyTimeout,
+		key:       key,
+		keyErr:    err,
+		client:    newPushClient(isPublicAddr),
+		allowed:   isPublicAddr,
+	}
+}
+
+// newPushClient returns a client only connecting to the allowed addresses. The
+// host names of the endpoints are checked once resolved, so a name pointing to the
+// internal network is refused too, and redirects are not followed
+func newPushClient(allowed func(addr netip.Addr) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !allowed(addrPort.Addr()) {
+				return ErrInvalidEndpoint
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// ValidateEndpoint checks that a push endpoint is an https URL whose host is not a
+// loopback, private or link-local address, so a subscription cannot make the API
+// send requests to the internal network
+func ValidateEndpoint(endpoint string) error {
+	return validateEndpoint(endpoint, isPublicAddr)
+}
+
+func validateEndpoint(endpoint string, allowed func(addr netip.Addr) bool) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return ErrInvalidEndpoint
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrInvalidEndpoint
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !allowed(addr) {
+		return ErrInvalidEndpoint
+	}
+	return nil
+}
+
+// isPublicAddr reports whether an address is routed on the internet, excluding the
+// loopback, private, link-local, multicast and shared addresses
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// Send implements the NotificationProvider interface for Web Push. It returns
+// ErrSubscriptionGone when the subscription must be deleted and ErrInvalidEndpoint
+// when its endpoint is not a public https URL
+func (p *webPushProvider) Send(ctx context.Context, message Message) error {
+	if p.keyErr != nil {
+		return p.keyErr
+	}
+
+	ctx, cancel := ctxutil.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	var subscription PushSubscription
+	if err := json.Unmarshal([]byte(message.To), &subscription); err != nil {
+		return fmt.Errorf("invalid push subscription: %w", err)
+	}
+	if err := validateEndpoint(subscription.Endpoint, p.allowed); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(pushPayload{Title: message.Title, Body: message.Body, Link: message.Link})
+	if err != nil {
+		return fmt.Errorf("failed to serialize push payload: %w", err)
+	}
+
+	body, err := encryptPushPayload(subscription, payload)
+	if err != nil {
+		return err
+	}
+
+	authorization, err := p.vapidAuthorization(subscription.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(p.TTL.Seconds())))
+	req.Header.Set("Urgency", "high")
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to push service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return ErrSubscriptionGone
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("push service returned error: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
+
+// vapidAuthorization returns the Authorization header identifying the application
+// server to the push service of an endpoint
+func (p *webPushProvider) vapidAuthorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid push endpoint: %w", err)
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": p.Subject,
+	}).SignedString(p.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign VAPID token: %w", err)
+	}
+
+	return fmt.Sprintf("vapid t=%s, k=%s", token, p.PublicKey), nil
+}
+
+// parseVAPIDKey decodes a base64url encoded P-256 private key, as generated by the
+// usual web-push tools
+func parseVAPIDKey(encoded string) (*ecdsa.PrivateKey, error) {
+	d, err := decodeBase64URL(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+
+	key, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+
+	// the uncompressed public key is 0x04 || X || Y
+	public := key.PublicKey().Bytes()
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(public[1:33]),
+			Y:     new(big.Int).SetBytes(public[33:]),
+		},
+		D: new(big.Int).SetBytes(d),
+	}, nil
+}
+
+// encryptPushPayload encrypts a payload for a subscription with the aes128gcm
+// content encoding, in a single record
+func encryptPushPayload(subscription PushSubscription, payload []byte) ([]byte, error) {
+	userAgentKey, err := decodeBase64URL(subscription.Keys.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push subscription key: %w", err)
+	}
+	userAgentPublic, err := ecdh.P256().NewPublicKey(userAgentKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push subscription key: %w", err)
+	}
+	authSecret, err := decodeBase64URL(subscription.Keys.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push subscription secret: %w", err)
+	}
+
+	serverKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := serverKey.ECDH(userAgentPublic)
+	if err != nil {
+		return nil, err
+	}
+	serverPublic := serverKey.PublicKey().Bytes()
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	// the input keying material mixes the shared secret with the auth secret
+	keyInfo := append(append([]byte("WebPush: info\x00"), userAgentKey...), serverPublic...)
+	ikm, err := expand(hkdf.Extract(sha256.New, sharedSecret, authSecret), keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	contentKey, err := expand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := expand(prk, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload)+1+gcm.Overhead() > recordSize {
+		return nil, errors.New("push payload too large")
+	}
+
+	// header: salt || record size || key ID length || key ID (the server public key)
+	header := make([]byte, 0, 16+4+1+len(serverPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, recordSize)
+	header = append(header, byte(len(serverPublic)))
+	header = append(header, serverPublic...)
+
+	// the 0x02 delimiter marks the last record
+	return gcm.Seal(header, nonce, append(payload, 0x02), nil), nil
+}
+
+func expand(prk, info []byte, length int) ([]byte, error) {
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, info), out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// decodeBase64URL decodes base64url, padded or not, as browsers and tools differ
+func decodeBase64URL(encoded string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+}
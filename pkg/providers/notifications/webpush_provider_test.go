@@ -0,0 +1,201 @@
+package notifications
+
+import (
+	"bernardtm/backend/configs"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/hkdf"
+)
+
+// browser holds the keys of a push subscription, as kept by a browser
+type browser struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newBrowser(t *testing.T) browser {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	if _, err := rand.Read(auth); err != nil {
+		t.Fatal(err)
+	}
+	return browser{key: key, auth: auth}
+}
+
+func (b browser) subscription(endpoint string) string {
+	subscription, _ := json.Marshal(PushSubscription{
+		Endpoint: endpoint,
+		Keys: PushKeys{
+			P256dh: base64.RawURLEncoding.EncodeToString(b.key.PublicKey().Bytes()),
+			Auth:   base64.RawURLEncoding.EncodeToString(b.auth),
+		},
+	})
+	return string(subscription)
+}
+
+// decrypt decrypts an aes128gcm push message the way the browser does
+func (b browser) decrypt(t *testing.T, body []byte) []byte {
+	salt, keyID := body[:16], body[21:21+int(body[20])]
+	assert.Equal(t, uint32(recordSize), binary.BigEndian.Uint32(body[16:20]))
+
+	serverPublic, err := ecdh.P256().NewPublicKey(keyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharedSecret, err := b.key.ECDH(serverPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), b.key.PublicKey().Bytes()...), keyID...)
+	ikm := read(t, hkdf.Expand(sha256.New, hkdf.Extract(sha256.New, sharedSecret, b.auth), keyInfo), 32)
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	contentKey := read(t, hkdf.Expand(sha256.New, prk, []byte("Content-Encoding: aes128gcm\x00")), 16)
+	nonce := read(t, hkdf.Expand(sha256.New, prk, []byte("Content-Encoding: nonce\x00")), 12)
+
+	block, _ := aes.NewCipher(contentKey)
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonce, body[21+len(keyID):], nil)
+	if err != nil {
+		t.Fatalf("failed to decrypt push message: %v", err)
+	}
+
+	assert.Equal(t, byte(0x02), plaintext[len(plaintext)-1], "the single record is the last one")
+	return plaintext[:len(plaintext)-1]
+}
+
+func read(t *testing.T, r io.Reader, length int) []byte {
+	out := make([]byte, length)
+	if _, err := io.ReadFull(r, out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func newTestWebPushProvider(t *testing.T) *webPushProvider {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewWebPushProvider(&configs.AppConfig{
+		VAPIDPublicKey:  base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		VAPIDPrivateKey: base64.RawURLEncoding.EncodeToString(key.Bytes()),
+		VAPIDSubject:    "mailto:admin@company.com",
+	})
+}
+
+// trust lets the provider send to a test server, listening on the loopback
+func trust(provider *webPushProvider, server *httptest.Server) {
+	provider.allowed = func(netip.Addr) bool { return true }
+	provider.client = server.Client()
+}
+
+func TestWebPushProviderSend(t *testing.T) {
+	provider := newTestWebPushProvider(t)
+	browser := newBrowser(t)
+	var received *http.Request
+	var body []byte
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+	trust(provider, server)
+
+	err := provider.Send(context.Background(), Message{
+		To:    browser.subscription(server.URL + "/push/abc"),
+		Title: "Verification code",
+		Body:  "Your verification code is 123456",
+		Link:  "https://app.example.com",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/push/abc", received.URL.Path)
+	assert.Equal(t, "aes128gcm", received.Header.Get("Content-Encoding"))
+	assert.Equal(t, "86400", received.Header.Get("TTL"))
+	assert.JSONEq(t, `{"title":"Verification code","body":"Your verification code is 123456","link":"https://app.example.com"}`, string(browser.decrypt(t, body)))
+
+	// the push service checks the token against the key the browser subscribed with
+	token, key, ok := strings.Cut(strings.TrimPrefix(received.Header.Get("Authorization"), "vapid t="), ", k=")
+	assert.True(t, ok)
+	assert.Equal(t, provider.PublicKey, key)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return &provider.key.PublicKey, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL, claims["aud"])
+	assert.Equal(t, "mailto:admin@company.com", claims["sub"])
+}
+
+func TestWebPushProviderSubscriptionGone(t *testing.T) {
+	provider := newTestWebPushProvider(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+	trust(provider, server)
+
+	err := provider.Send(context.Background(), Message{To: newBrowser(t).subscription(server.URL), Body: "Hello"})
+
+	assert.ErrorIs(t, err, ErrSubscriptionGone)
+}
+
+func TestWebPushProviderRefusesInternalEndpoints(t *testing.T) {
+	provider := newTestWebPushProvider(t)
+
+	for _, endpoint := range []string{
+		"http://push.example.com/abc",
+		"https://localhost/abc",
+		"https://127.0.0.1/abc",
+		"https://[::1]/abc",
+		"https://10.0.0.8/abc",
+		"https://169.254.169.254/latest/meta-data",
+		"https://100.64.0.1/abc",
+		"https://[::ffff:192.168.0.1]/abc",
+	} {
+		err := provider.Send(context.Background(), Message{To: newBrowser(t).subscription(endpoint), Body: "Hello"})
+		assert.ErrorIs(t, err, ErrInvalidEndpoint, endpoint)
+	}
+	assert.NoError(t, ValidateEndpoint("https://fcm.googleapis.com/fcm/send/abc"))
+
+	// the connections are checked too, covering the host names resolving to internal addresses
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+	_, err := provider.client.Get(server.URL)
+	assert.ErrorIs(t, err, ErrInvalidEndpoint)
+}
+
+func TestWebPushProviderInvalidKey(t *testing.T) {
+	provider := NewWebPushProvider(&configs.AppConfig{VAPIDPrivateKey: "not a key"})
+
+	err := provider.Send(context.Background(), Message{To: newBrowser(t).subscription("https://push.example.com"), Body: "Hello"})
+
+	assert.ErrorContains(t, err, "invalid VAPID private key")
+}
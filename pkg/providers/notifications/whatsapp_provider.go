@@ -0,0 +1,117 @@
+package notifications
+
+import (
+	"bernardtm/backend/configs"
+	"bernardtm/backend/pkg/ctxutil"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// whatsAppPayload is the body of the messages endpoint of the WhatsApp Cloud API
+type whatsAppPayload struct {
+	MessagingProduct string            `json:"messaging_product"`
+	To               string            `json:"to"`
+	Type             string            `json:"type"`
+	Text             *whatsAppText     `json:"text,omitempty"`
+	Template         *whatsAppTemplate `json:"template,omitempty"`
+}
+
+type whatsAppText struct {
+	Body string `json:"body"`
+}
+
+type whatsAppTemplate struct {
+	Name     string `json:"name"`
+	Language struct {
+		Code string `json:"code"`
+	} `json:"language"`
+	Components []whatsAppComponent `json:"components"`
+}
+
+type whatsAppComponent struct {
+	Type       string              `json:"type"`
+	Parameters []whatsAppParameter `json:"parameters"`
+}
+
+type whatsAppParameter struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// whatsAppProvider sends messages through the WhatsApp Business Cloud API. The
+// messages opening a conversation must use an approved template, so when one is
+// configured every message is sent with it, its text being the single parameter
+// of the template body
+type whatsAppProvider struct {
+	APIBaseURL    string        // Base URL of the Graph API, e.g. https://graph.facebook.com/v21.0
+	PhoneNumberID string        // ID of the business phone number the messages are sent from
+	AccessToken   string        // System user access token
+	Template      string        // Approved template the messages are sent with, text messages when empty
+	Timeout       time.Duration // Deadline for each send (zero means no deadline)
+	client        *http.Client
+}
+
+func NewWhatsAppProvider(config *configs.AppConfig) *whatsAppProvider {
+	return &whatsAppProvider{
+		APIBaseURL:    strings.TrimSuffix(config.WhatsAppAPIBase, "/"),
+		PhoneNumberID: config.WhatsAppPhoneID,
+		AccessToken:   config.WhatsAppToken,
+		Template:      config.WhatsAppTemplate,
+		Timeout:       config.NotifyTimeout,
+		client:        &http.Client{},
+	}
+}
+
+// Send implements the NotificationProvider interface for WhatsApp
+func (p *whatsAppProvider) Send(ctx context.Context, message Message) error {
+	ctx, cancel := ctxutil.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	payload := whatsAppPayload{
+		MessagingProduct: "whatsapp",
+		To:               strings.TrimPrefix(message.To, "+"),
+		Type:             "text",
+		Text:             &whatsAppText{Body: message.Body},
+	}
+	if p.Template != "" {
+		template := &whatsAppTemplate{Name: p.Template, Components: []whatsAppComponent{{
+			Type:       "body",
+			Parameters: []whatsAppParameter{{Type: "text", Text: message.Body}},
+		}}}
+		// the template languages are named like pt_BR
+		template.Language.Code = strings.ReplaceAll(message.Locale, "-", "_")
+
+		payload.Type, payload.Text, payload.Template = "template", nil, template
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to serialize message payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/%s/messages", p.APIBaseURL, p.PhoneNumberID), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.AccessToken)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to WhatsApp: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("whatsapp returned error: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
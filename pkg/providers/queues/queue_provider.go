@@ -1,6 +1,8 @@
 package queues
 
+import "context"
+
 // QueueProvider defines the interface for a queue provider
 type QueueProvider interface {
-	Enqueue(message interface{}, queueName string) error
+	Enqueue(ctx context.Context, message interface{}, queueName string) error
 }
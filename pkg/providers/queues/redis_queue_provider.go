@@ -1,6 +1,7 @@
 package queues
 
 import (
+	"bernardtm/backend/pkg/ctxutil"
 	"context"
 	"encoding/json"
 	"log"
@@ -10,18 +11,20 @@ import (
 )
 
 type RedisQueueProvider struct {
-	Client *redis.Client
+	Client  *redis.Client
+	Timeout time.Duration
 }
 
 // Initialize a new RedisQueueProvider
-func NewRedisQueueProvider(redisClient *redis.Client) *RedisQueueProvider {
+func NewRedisQueueProvider(redisClient *redis.Client, timeout time.Duration) *RedisQueueProvider {
 	return &RedisQueueProvider{
-		Client: redisClient,
+		Client:  redisClient,
+		Timeout: timeout,
 	}
 }
 
 // Enqueue adds an message to the Redis queue
-func (r *RedisQueueProvider) Enqueue(message interface{}, queueName string) error {
+func (r *RedisQueueProvider) Enqueue(ctx context.Context, message interface{}, queueName string) error {
 	// Serialize the message to JSON
 	messageBytes, err := json.Marshal(message)
 	if err != nil {
@@ -33,7 +36,7 @@ func (r *RedisQueueProvider) Enqueue(message interface{}, queueName string) erro
 	messageMap := map[string]interface{}{"message": messageBytes}
 
 	// Add the message to the queue in Redis
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := ctxutil.WithTimeout(ctx, r.Timeout)
 	defer cancel()
 
 	// Use XAdd to add a new entry to the stream
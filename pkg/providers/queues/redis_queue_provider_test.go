@@ -1,7 +1,9 @@
 package queues
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"bernardtm/backend/pkg/redis_client"
 )
@@ -18,8 +20,8 @@ func TestEnqueue(t *testing.T) {
 	}
 
 	// Create a new RedisQueueProvider
-	queueProvider := NewRedisQueueProvider(redisClient)
+	queueProvider := NewRedisQueueProvider(redisClient, 5*time.Second)
 
 	// Enqueue a message
-	queueProvider.Enqueue(TestMessage{Task: "test-task"}, "task_stream")
+	queueProvider.Enqueue(context.Background(), TestMessage{Task: "test-task"}, "task_stream")
 }
@@ -0,0 +1,114 @@
+package scanners
+
+import (
+	"bernardtm/backend/configs"
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamavChunkSize is the size of the chunks streamed to clamd, which must stay
+// below its StreamMaxLength
+const clamavChunkSize = 64 << 10
+
+type clamavScannerProvider struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamAVScannerProvider creates a scanner talking to the clamd daemon at
+// CLAMAV_ADDRESS, either tcp://host:port or unix:///path/to/clamd.sock
+func NewClamAVScannerProvider(config *configs.AppConfig) *clamavScannerProvider {
+	network, address := "tcp", config.ClamAVAddress
+	if scheme, rest, ok := strings.Cut(address, "://"); ok {
+		network, address = scheme, rest
+	}
+
+	return &clamavScannerProvider{
+		network: network,
+		address: address,
+		timeout: config.ScannerTimeout,
+	}
+}
+
+// Scan streams the content to clamd with the INSTREAM command
+func (p *clamavScannerProvider) Scan(ctx context.Context, content io.Reader) (ScanResult, error) {
+	dialer := net.Dialer{Timeout: p.timeout}
+	conn, err := dialer.DialContext(ctx, p.network, p.address)
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(p.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return ScanResult{}, err
+	}
+
+	// clamd may stop reading and answer early, e.g. when the stream is too long,
+	// so the reply is read even when streaming fails
+	streamErr := p.stream(conn, content)
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		if streamErr != nil {
+			return ScanResult{}, streamErr
+		}
+		return ScanResult{}, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+
+	return parseClamAVReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// stream sends the INSTREAM command followed by the content as length prefixed chunks
+func (p *clamavScannerProvider) stream(conn net.Conn, content io.Reader) error {
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return fmt.Errorf("failed to send command to clamd: %w", err)
+	}
+
+	chunk := make([]byte, 4+clamavChunkSize)
+	for {
+		n, err := io.ReadFull(content, chunk[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(chunk[:4], uint32(n))
+			if _, err := conn.Write(chunk[:4+n]); err != nil {
+				return fmt.Errorf("failed to stream content to clamd: %w", err)
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read content: %w", err)
+		}
+	}
+
+	// a zero length chunk ends the stream
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return fmt.Errorf("failed to stream content to clamd: %w", err)
+	}
+	return nil
+}
+
+// parseClamAVReply reads replies such as "stream: OK" or "stream: Eicar-Signature FOUND"
+func parseClamAVReply(reply string) (ScanResult, error) {
+	result := strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case result == "OK":
+		return ScanResult{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return ScanResult{Infected: true, Signature: strings.TrimSuffix(result, " FOUND")}, nil
+	}
+	return ScanResult{}, fmt.Errorf("clamd failed to scan: %s", reply)
+}
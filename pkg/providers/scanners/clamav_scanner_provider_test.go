@@ -0,0 +1,101 @@
+package scanners
+
+import (
+	"bernardtm/backend/configs"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveClamd accepts one connection, reads an INSTREAM command and answers with
+// the reply returned by verdict for the streamed content
+func serveClamd(t *testing.T, verdict func(content []byte) string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		command, err := reader.ReadString(0)
+		if err != nil || command != "zINSTREAM\x00" {
+			conn.Write([]byte("UNKNOWN COMMAND\x00"))
+			return
+		}
+
+		var content bytes.Buffer
+		for {
+			var length uint32
+			if err := binary.Read(reader, binary.BigEndian, &length); err != nil || length == 0 {
+				break
+			}
+			if _, err := io.CopyN(&content, reader, int64(length)); err != nil {
+				return
+			}
+		}
+		conn.Write([]byte(verdict(content.Bytes()) + "\x00"))
+	}()
+
+	return "tcp://" + listener.Addr().String()
+}
+
+func TestClamAVScannerProvider_Scan(t *testing.T) {
+	content := strings.Repeat("a", clamavChunkSize+10) + eicarSignature
+
+	address := serveClamd(t, func(received []byte) string {
+		assert.Equal(t, content, string(received))
+		return "stream: Eicar-Signature FOUND"
+	})
+	provider := NewClamAVScannerProvider(&configs.AppConfig{ClamAVAddress: address, ScannerTimeout: time.Second})
+
+	result, err := provider.Scan(context.Background(), strings.NewReader(content))
+
+	assert.NoError(t, err)
+	assert.Equal(t, ScanResult{Infected: true, Signature: "Eicar-Signature"}, result)
+}
+
+func TestClamAVScannerProvider_ScanClean(t *testing.T) {
+	address := serveClamd(t, func([]byte) string { return "stream: OK" })
+	provider := NewClamAVScannerProvider(&configs.AppConfig{ClamAVAddress: address, ScannerTimeout: time.Second})
+
+	result, err := provider.Scan(context.Background(), strings.NewReader("clean"))
+
+	assert.NoError(t, err)
+	assert.False(t, result.Infected)
+}
+
+func TestClamAVScannerProvider_ScanError(t *testing.T) {
+	address := serveClamd(t, func([]byte) string { return "INSTREAM size limit exceeded. ERROR" })
+	provider := NewClamAVScannerProvider(&configs.AppConfig{ClamAVAddress: address, ScannerTimeout: time.Second})
+
+	_, err := provider.Scan(context.Background(), strings.NewReader("large"))
+
+	assert.ErrorContains(t, err, "size limit exceeded")
+}
+
+func TestFakeScannerProvider_Scan(t *testing.T) {
+	provider := NewFakeScannerProvider()
+
+	result, err := provider.Scan(context.Background(), strings.NewReader("prefix "+eicarSignature))
+	assert.NoError(t, err)
+	assert.True(t, result.Infected)
+
+	result, err = provider.Scan(context.Background(), strings.NewReader("clean"))
+	assert.NoError(t, err)
+	assert.False(t, result.Infected)
+}
@@ -0,0 +1,31 @@
+package scanners
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// eicarSignature is the antivirus test file every scanner reports as infected
+const eicarSignature = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+type fakeScannerProvider struct{}
+
+// NewFakeScannerProvider creates a scanner for development and tests, which only
+// reports the EICAR test file as infected, like a real scanner would
+func NewFakeScannerProvider() *fakeScannerProvider {
+	return &fakeScannerProvider{}
+}
+
+func (p *fakeScannerProvider) Scan(ctx context.Context, content io.Reader) (ScanResult, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return ScanResult{}, fmt.Errorf("failed to read content: %w", err)
+	}
+
+	if bytes.Contains(data, []byte(eicarSignature)) {
+		return ScanResult{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+	}
+	return ScanResult{}, nil
+}
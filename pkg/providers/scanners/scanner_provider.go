@@ -0,0 +1,17 @@
+package scanners
+
+import (
+	"context"
+	"io"
+)
+
+// ScanResult is the verdict of a scanner on some content
+type ScanResult struct {
+	Infected  bool   `json:"infected"`
+	Signature string `json:"signature,omitempty"` // Name of the detected threat
+}
+
+// ScannerProvider defines the interface for a malware scanner
+type ScannerProvider interface {
+	Scan(ctx context.Context, content io.Reader) (ScanResult, error)
+}
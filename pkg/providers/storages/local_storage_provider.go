@@ -0,0 +1,290 @@
+package storages
+
+import (
+	"bernardtm/backend/configs"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSignature is returned when a signed local storage URL is forged or expired
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+// LocalStorageProvider is a StorageProvider keeping the objects on the local disk.
+// Its objects are served by the API itself, which uses Write and VerifySignature
+type LocalStorageProvider interface {
+	StorageProvider
+	Write(ctx context.Context, key string, body io.Reader) error
+	VerifySignature(method string, key string, query url.Values) error
+}
+
+type localStorageProvider struct {
+	root    string
+	baseURL string
+	secret  []byte
+}
+
+// NewLocalStorageProvider creates a provider storing the objects under LOCAL_STORAGE_PATH
+// with the same key layout as S3. Links point to LOCAL_STORAGE_URL, the route serving them,
+// and are signed with LOCAL_STORAGE_SECRET
+func NewLocalStorageProvider(config *configs.AppConfig) *localStorageProvider {
+	return &localStorageProvider{
+		root:    config.LocalStoragePath,
+		baseURL: strings.TrimRight(config.LocalStorageURL, "/"),
+		secret:  []byte(config.LocalStorageSecret),
+	}
+}
+
+// path resolves a key to a file under the root, rejecting keys escaping it
+func (p *localStorageProvider) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if key == "" || clean == "/" || clean != "/"+key {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(p.root, filepath.FromSlash(clean)), nil
+}
+
+// Put stores body under key. The content type is derived from the key when read
+func (p *localStorageProvider) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error {
+	return p.Write(ctx, key, body)
+}
+
+// Write stores body under key. The content is written to a temporary file first,
+// so a failed or cancelled upload never leaves a partial object behind
+func (p *localStorageProvider) Write(ctx context.Context, key string, body io.Reader) error {
+	target, err := p.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("failed to create folder: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, &contextReader{ctx: ctx, reader: body})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	return nil
+}
+
+func (p *localStorageProvider) Download(ctx context.Context, key string) (*Object, error) {
+	target, err := p.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(target)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+
+	return &Object{ObjectInfo: localObjectInfo(key, info), Body: file}, nil
+}
+
+// Delete removes the object stored under the given key. Like S3, removing a
+// missing object succeeds
+func (p *localStorageProvider) Delete(ctx context.Context, key string) error {
+	target, err := p.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+func (p *localStorageProvider) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	target, err := p.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ObjectInfo{}, ErrObjectNotFound
+		}
+		return ObjectInfo{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	return localObjectInfo(key, info), nil
+}
+
+// List returns every object whose key starts with prefix
+func (p *localStorageProvider) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	err := filepath.WalkDir(p.root, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// temporary files of uploads in progress are not objects
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+
+		relative, err := filepath.Rel(p.root, current)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relative)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, localObjectInfo(key, info))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	return objects, nil
+}
+
+func (p *localStorageProvider) ObjectURL(key string) string {
+	return p.baseURL + "/" + key
+}
+
+// PresignUpload returns a signed URL of the local storage route accepting a PUT of the object
+func (p *localStorageProvider) PresignUpload(ctx context.Context, key string, contentType string, expires time.Duration) (PresignedURL, error) {
+	presigned, err := p.presign(http.MethodPut, key, nil, expires)
+	if err != nil {
+		return PresignedURL{}, err
+	}
+	if contentType != "" {
+		presigned.Headers = map[string]string{"Content-Type": contentType}
+	}
+	return presigned, nil
+}
+
+// PresignDownload returns a signed URL of the local storage route serving the object
+// as an attachment named after fileName
+func (p *localStorageProvider) PresignDownload(ctx context.Context, key string, fileName string, expires time.Duration) (PresignedURL, error) {
+	return p.presign(http.MethodGet, key, url.Values{"filename": {fileName}}, expires)
+}
+
+func (p *localStorageProvider) presign(method string, key string, query url.Values, expires time.Duration) (PresignedURL, error) {
+	if _, err := p.path(key); err != nil {
+		return PresignedURL{}, err
+	}
+	if query == nil {
+		query = url.Values{}
+	}
+
+	expiresAt := time.Now().Add(expires)
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", p.sign(method, key, query))
+
+	return PresignedURL{
+		URL:       p.ObjectURL(key) + "?" + query.Encode(),
+		Method:    method,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// VerifySignature checks a URL produced by PresignUpload or PresignDownload
+func (p *localStorageProvider) VerifySignature(method string, key string, query url.Values) error {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return ErrInvalidSignature
+	}
+
+	expected, err := hex.DecodeString(p.sign(method, key, query))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	provided, err := hex.DecodeString(query.Get("signature"))
+	if err != nil || !hmac.Equal(expected, provided) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// sign covers the method, the key and every query parameter except the signature itself
+func (p *localStorageProvider) sign(method string, key string, query url.Values) string {
+	signed := url.Values{}
+	for name, values := range query {
+		if name != "signature" {
+			signed[name] = values
+		}
+	}
+
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(method + "\n" + key + "\n" + signed.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// localObjectInfo describes a file on disk. The content type comes from the
+// first segment of the key, which is the file extension
+func localObjectInfo(key string, info fs.FileInfo) ObjectInfo {
+	folder, _, _ := strings.Cut(key, "/")
+
+	return ObjectInfo{
+		Key:          key,
+		Size:         info.Size(),
+		ContentType:  mime.TypeByExtension("." + folder),
+		LastModified: info.ModTime(),
+	}
+}
+
+// contextReader stops a copy as soon as its context is done
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r *contextReader) Read(b []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(b)
+}
@@ -0,0 +1,85 @@
+package storages
+
+import (
+	"bernardtm/backend/configs"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLocalProvider(t *testing.T) *localStorageProvider {
+	return NewLocalStorageProvider(&configs.AppConfig{
+		LocalStoragePath:   t.TempDir(),
+		LocalStorageURL:    "http://localhost:8080/api/v1/storage/local/",
+		LocalStorageSecret: "secret",
+	})
+}
+
+func TestLocalStorageProvider_Lifecycle(t *testing.T) {
+	provider := newTestLocalProvider(t)
+	ctx := context.Background()
+
+	key, _, _ := BuildKey("abc", "report.pdf", time.Now())
+	assert.NoError(t, provider.Put(ctx, key, strings.NewReader("%PDF-1.7"), "application/pdf"))
+	assert.Equal(t, "http://localhost:8080/api/v1/storage/local/"+key, provider.ObjectURL(key))
+
+	info, err := provider.Stat(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), info.Size)
+	assert.Equal(t, "application/pdf", info.ContentType)
+
+	object, err := provider.Download(ctx, key)
+	assert.NoError(t, err)
+	content, _ := io.ReadAll(object.Body)
+	object.Body.Close()
+	assert.Equal(t, "%PDF-1.7", string(content))
+
+	objects, err := provider.List(ctx, "pdf/")
+	assert.NoError(t, err)
+	assert.Len(t, objects, 1)
+
+	assert.NoError(t, provider.Delete(ctx, key))
+	_, err = provider.Stat(ctx, key)
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+func TestLocalStorageProvider_RejectsKeysOutsideRoot(t *testing.T) {
+	provider := newTestLocalProvider(t)
+
+	for _, key := range []string{"../outside", "pdf/../../outside", "/etc/passwd", ""} {
+		err := provider.Write(context.Background(), key, strings.NewReader("x"))
+		assert.Error(t, err, key)
+	}
+
+	_, err := os.Stat(provider.root + "/../outside")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalStorageProvider_Signatures(t *testing.T) {
+	provider := newTestLocalProvider(t)
+	key := "pdf/2024/10/1/abc"
+
+	presigned, err := provider.PresignDownload(context.Background(), key, "report.pdf", time.Minute)
+	assert.NoError(t, err)
+	parsed, _ := url.Parse(presigned.URL)
+
+	assert.NoError(t, provider.VerifySignature(http.MethodGet, key, parsed.Query()))
+	assert.ErrorIs(t, provider.VerifySignature(http.MethodPut, key, parsed.Query()), ErrInvalidSignature, "the method is signed")
+	assert.ErrorIs(t, provider.VerifySignature(http.MethodGet, "pdf/2024/10/1/other", parsed.Query()), ErrInvalidSignature, "the key is signed")
+
+	tampered := parsed.Query()
+	tampered.Set("filename", "other.pdf")
+	assert.ErrorIs(t, provider.VerifySignature(http.MethodGet, key, tampered), ErrInvalidSignature, "the query is signed")
+
+	expired, err := provider.PresignUpload(context.Background(), key, "", -time.Minute)
+	assert.NoError(t, err)
+	parsed, _ = url.Parse(expired.URL)
+	assert.ErrorIs(t, provider.VerifySignature(http.MethodPut, key, parsed.Query()), ErrInvalidSignature)
+}
@@ -0,0 +1,119 @@
+package storages
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryObject struct {
+	data         []byte
+	lastModified time.Time
+}
+
+// memoryStorageProvider keeps the objects in memory. It is meant for tests and
+// for running the API without any storage
+type memoryStorageProvider struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+// NewMemoryStorageProvider creates an empty in-memory provider
+func NewMemoryStorageProvider() *memoryStorageProvider {
+	return &memoryStorageProvider{objects: map[string]memoryObject{}}
+}
+
+// Put stores body under key. The content type is derived from the key when read
+func (p *memoryStorageProvider) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error {
+	return p.Write(ctx, key, body)
+}
+
+// Write stores body under key, replacing any previous object
+func (p *memoryStorageProvider) Write(ctx context.Context, key string, body io.Reader) error {
+	data, err := io.ReadAll(&contextReader{ctx: ctx, reader: body})
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.objects[key] = memoryObject{data: data, lastModified: time.Now()}
+	return nil
+}
+
+func (p *memoryStorageProvider) Download(ctx context.Context, key string) (*Object, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	object, ok := p.objects[key]
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	return &Object{ObjectInfo: memoryObjectInfo(key, object), Body: io.NopCloser(bytes.NewReader(object.data))}, nil
+}
+
+func (p *memoryStorageProvider) Delete(ctx context.Context, key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.objects, key)
+	return nil
+}
+
+func (p *memoryStorageProvider) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	object, ok := p.objects[key]
+	if !ok {
+		return ObjectInfo{}, ErrObjectNotFound
+	}
+	return memoryObjectInfo(key, object), nil
+}
+
+// List returns every object whose key starts with prefix, sorted by key
+func (p *memoryStorageProvider) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var objects []ObjectInfo
+	for key, object := range p.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, memoryObjectInfo(key, object))
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (p *memoryStorageProvider) ObjectURL(key string) string {
+	return "memory://" + key
+}
+
+// PresignUpload returns the object URL, as in-memory objects are only reachable in process
+func (p *memoryStorageProvider) PresignUpload(ctx context.Context, key string, contentType string, expires time.Duration) (PresignedURL, error) {
+	return PresignedURL{URL: p.ObjectURL(key), Method: http.MethodPut, ExpiresAt: time.Now().Add(expires)}, nil
+}
+
+// PresignDownload returns the object URL, as in-memory objects are only reachable in process
+func (p *memoryStorageProvider) PresignDownload(ctx context.Context, key string, fileName string, expires time.Duration) (PresignedURL, error) {
+	return PresignedURL{URL: p.ObjectURL(key), Method: http.MethodGet, ExpiresAt: time.Now().Add(expires)}, nil
+}
+
+func memoryObjectInfo(key string, object memoryObject) ObjectInfo {
+	folder, _, _ := strings.Cut(key, "/")
+
+	return ObjectInfo{
+		Key:          key,
+		Size:         int64(len(object.data)),
+		ContentType:  mime.TypeByExtension("." + folder),
+		LastModified: object.lastModified,
+	}
+}
@@ -3,81 +3,318 @@ package storages
 import (
 	"bernardtm/backend/configs"
 	"bernardtm/backend/pkg/aws/s3/config"
+	"bernardtm/backend/pkg/ctxutil"
 	"context"
+	"errors"
 	"fmt"
-	"path/filepath"
+	"io"
+	"mime"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
-type UploadFileOutput struct {
-	Name   string `json:"file_name"`
-	Link   string `json:"file_link"`
-	Folder string `json:"file_folder"`
-	Type   string `json:"file_type"`
+type s3StorageProvider struct {
+	S3_BUCKET_NAME string
+	S3_REGION      string
+	endpoint       string
+	usePathStyle   bool
+	publicURL      string
+	sse            types.ServerSideEncryption
+	kmsKeyID       string
+	timeout        time.Duration
+	// client is shared by every call; clientErr is returned by them when it could not be created
+	client    *s3.Client
+	clientErr error
 }
 
-type s3StorageProvider struct {
-	S3_BUCKET_NAME       string
-	S3_REGION            string
-	S3_ACCESS_KEY_ID     string
-	S3_SECRET_ACCESS_KEY string
+func NewS3StorageProvider(appConfig *configs.AppConfig) *s3StorageProvider {
+	provider := &s3StorageProvider{
+		S3_BUCKET_NAME: appConfig.S3_BUCKET_NAME,
+		S3_REGION:      appConfig.S3_REGION,
+		endpoint:       strings.TrimRight(appConfig.S3Endpoint, "/"),
+		usePathStyle:   appConfig.S3UsePathStyle,
+		publicURL:      strings.TrimRight(appConfig.S3PublicURL, "/"),
+		sse:            types.ServerSideEncryption(appConfig.S3Encryption),
+		kmsKeyID:       appConfig.S3KMSKeyID,
+		timeout:        appConfig.StorageTimeout,
+	}
+
+	client, err := config.ConnectionS3(context.Background(), config.S3Options{
+		Region:          appConfig.S3_REGION,
+		AccessKeyID:     appConfig.S3_ACCESS_KEY_ID,
+		SecretAccessKey: appConfig.S3_SECRET_ACCESS_KEY,
+		Endpoint:        provider.endpoint,
+		UsePathStyle:    appConfig.S3UsePathStyle,
+	})
+	if err != nil {
+		provider.clientErr = fmt.Errorf("failed to create S3 client: %v", err)
+	}
+	provider.client = client
+
+	return provider
+}
+
+// encrypt applies the configured server-side encryption to an upload
+func (p *s3StorageProvider) encrypt(input *s3.PutObjectInput) {
+	if p.sse == "" {
+		return
+	}
+	input.ServerSideEncryption = p.sse
+	if p.sse == types.ServerSideEncryptionAwsKms && p.kmsKeyID != "" {
+		input.SSEKMSKeyId = aws.String(p.kmsKeyID)
+	}
+}
+
+// Put stores body under the given key, replacing any previous object
+func (p *s3StorageProvider) Put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error {
+	ctx, cancel := ctxutil.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	if p.clientErr != nil {
+		return p.clientErr
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(p.S3_BUCKET_NAME),
+		Key:    aws.String(key),
+		Body:   body,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	p.encrypt(input)
+
+	if _, err := p.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	return nil
 }
 
-func NewS3StorageProvider(config *configs.AppConfig) *s3StorageProvider {
-	return &s3StorageProvider{
-		S3_BUCKET_NAME:       config.S3_BUCKET_NAME,
-		S3_REGION:            config.S3_REGION,
-		S3_ACCESS_KEY_ID:     config.S3_ACCESS_KEY_ID,
-		S3_SECRET_ACCESS_KEY: config.S3_SECRET_ACCESS_KEY,
+// ObjectURL returns the URL of the object stored under the given key, under the
+// public base URL when one is configured, such as a CDN in front of the bucket.
+// Otherwise it is only reachable without signing when the bucket is public
+func (p *s3StorageProvider) ObjectURL(key string) string {
+	switch {
+	case p.publicURL != "":
+		return p.publicURL + "/" + key
+	case p.endpoint != "" && p.usePathStyle:
+		return fmt.Sprintf("%s/%s/%s", p.endpoint, p.S3_BUCKET_NAME, key)
+	case p.endpoint != "":
+		scheme, host, ok := strings.Cut(p.endpoint, "://")
+		if !ok {
+			scheme, host = "https", p.endpoint
+		}
+		return fmt.Sprintf("%s://%s.%s/%s", scheme, p.S3_BUCKET_NAME, host, key)
+	case p.usePathStyle:
+		return fmt.Sprintf("https://s3.%s.amazonaws.com/%s/%s", p.S3_REGION, p.S3_BUCKET_NAME, key)
+	default:
+		return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", p.S3_BUCKET_NAME, key)
 	}
 }
 
-func (p *s3StorageProvider) Upload(dto UploadDto) (interface{}, error) {
-	s3Client, err := config.ConnectionS3(p.S3_REGION, p.S3_ACCESS_KEY_ID, p.S3_SECRET_ACCESS_KEY)
+// PresignUpload returns a URL allowing a client to PUT the object directly to S3
+func (p *s3StorageProvider) PresignUpload(ctx context.Context, key string, contentType string, expires time.Duration) (PresignedURL, error) {
+	ctx, cancel := ctxutil.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	if p.clientErr != nil {
+		return PresignedURL{}, p.clientErr
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(p.S3_BUCKET_NAME),
+		Key:    aws.String(key),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	// the encryption headers are signed, so newPresignedURL hands them to the client
+	p.encrypt(input)
+
+	request, err := s3.NewPresignClient(p.client).PresignPutObject(ctx, input, s3.WithPresignExpires(expires))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create S3 client: %v", err)
+		return PresignedURL{}, fmt.Errorf("failed to presign upload: %w", err)
 	}
 
-	fileType := strings.ToLower(filepath.Ext(dto.OriginalFileName))
+	presigned := newPresignedURL(request, expires)
+	if contentType != "" {
+		// S3 stores the content type sent with the PUT, so the client must send it
+		presigned.Headers["Content-Type"] = contentType
+	}
+	return presigned, nil
+}
+
+// PresignDownload returns a URL allowing a client to GET the object directly from S3.
+// The response is served as an attachment named after fileName
+func (p *s3StorageProvider) PresignDownload(ctx context.Context, key string, fileName string, expires time.Duration) (PresignedURL, error) {
+	ctx, cancel := ctxutil.WithTimeout(ctx, p.timeout)
+	defer cancel()
 
-	if fileType == "" {
-		fileType = "others"
+	if p.clientErr != nil {
+		return PresignedURL{}, p.clientErr
 	}
 
-	fileType = fileType[1:]
+	request, err := s3.NewPresignClient(p.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket:                     aws.String(p.S3_BUCKET_NAME),
+		Key:                        aws.String(key),
+		ResponseContentDisposition: aws.String(mime.FormatMediaType("attachment", map[string]string{"filename": fileName})),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return PresignedURL{}, fmt.Errorf("failed to presign download: %w", err)
+	}
 
-	folder := fileType
-	// Get the current time
-	currentTime := time.Now()
+	return newPresignedURL(request, expires), nil
+}
 
-	// Extract year, month, and day
-	year, month, day := currentTime.Date()
+func newPresignedURL(request *v4.PresignedHTTPRequest, expires time.Duration) PresignedURL {
+	headers := map[string]string{}
+	for name, values := range request.SignedHeader {
+		// the client sets Host from the URL itself
+		if strings.EqualFold(name, "Host") || len(values) == 0 {
+			continue
+		}
+		headers[name] = values[0]
+	}
 
-	key := fmt.Sprintf("%s/%d/%d/%d/%s", folder, year, month, day, dto.FileName)
-	fmt.Println(key)
+	return PresignedURL{
+		URL:       request.URL,
+		Method:    request.Method,
+		Headers:   headers,
+		ExpiresAt: time.Now().Add(expires),
+	}
+}
+
+// Delete removes the object stored under the given key
+func (p *s3StorageProvider) Delete(ctx context.Context, key string) error {
+	ctx, cancel := ctxutil.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	if p.clientErr != nil {
+		return p.clientErr
+	}
 
-	// Perform the upload
-	_, err = s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
+	_, err := p.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(p.S3_BUCKET_NAME),
 		Key:    aws.String(key),
-		Body:   dto.FileStream,
 	})
+	if err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
 
+	return nil
+}
+
+// Download opens the object stored under the given key. The storage timeout
+// covers the whole transfer and ends when the body is closed
+func (p *s3StorageProvider) Download(ctx context.Context, key string) (*Object, error) {
+	if p.clientErr != nil {
+		return nil, p.clientErr
+	}
+
+	ctx, cancel := ctxutil.WithTimeout(ctx, p.timeout)
+
+	output, err := p.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(p.S3_BUCKET_NAME),
+		Key:    aws.String(key),
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to upload file: %w", err)
+		cancel()
+		if isS3NotFound(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to download file: %w", err)
 	}
 
-	result := UploadFileOutput{
-		Name:   dto.FileName,
-		Link:   fmt.Sprintf("https://%s.s3.amazonaws.com/%s", p.S3_BUCKET_NAME, key),
-		Folder: folder,
-		Type:   fileType,
+	return &Object{
+		ObjectInfo: ObjectInfo{
+			Key:          key,
+			Size:         aws.ToInt64(output.ContentLength),
+			ContentType:  aws.ToString(output.ContentType),
+			ETag:         strings.Trim(aws.ToString(output.ETag), `"`),
+			LastModified: aws.ToTime(output.LastModified),
+		},
+		Body: &cancelOnClose{ReadCloser: output.Body, cancel: cancel},
+	}, nil
+}
+
+// Stat returns the metadata of the object stored under the given key
+func (p *s3StorageProvider) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	ctx, cancel := ctxutil.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	if p.clientErr != nil {
+		return ObjectInfo{}, p.clientErr
 	}
-	fmt.Println(result)
 
-	return result, nil
+	output, err := p.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(p.S3_BUCKET_NAME),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return ObjectInfo{}, ErrObjectNotFound
+		}
+		return ObjectInfo{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	return ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(output.ContentLength),
+		ContentType:  aws.ToString(output.ContentType),
+		ETag:         strings.Trim(aws.ToString(output.ETag), `"`),
+		LastModified: aws.ToTime(output.LastModified),
+	}, nil
+}
+
+// List returns every object whose key starts with prefix
+func (p *s3StorageProvider) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	ctx, cancel := ctxutil.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	if p.clientErr != nil {
+		return nil, p.clientErr
+	}
+
+	var objects []ObjectInfo
+	paginator := s3.NewListObjectsV2Paginator(p.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(p.S3_BUCKET_NAME),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files: %w", err)
+		}
+		for _, object := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.ToString(object.Key),
+				Size:         aws.ToInt64(object.Size),
+				ETag:         strings.Trim(aws.ToString(object.ETag), `"`),
+				LastModified: aws.ToTime(object.LastModified),
+			})
+		}
+	}
+
+	return objects, nil
+}
+
+// isS3NotFound reports whether err means the object does not exist. HeadObject
+// has no body, so it reports a plain NotFound code instead of NoSuchKey
+func isS3NotFound(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return true
+	}
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return true
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && (apiErr.ErrorCode() == "NotFound" || apiErr.ErrorCode() == "NoSuchKey")
 }
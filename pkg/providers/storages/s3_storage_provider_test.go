@@ -0,0 +1,117 @@
+package storages
+
+import (
+	"bernardtm/backend/configs"
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestS3Provider() *s3StorageProvider {
+	return NewS3StorageProvider(&configs.AppConfig{
+		S3_BUCKET_NAME:       "bucket",
+		S3_REGION:            "us-east-1",
+		S3_ACCESS_KEY_ID:     "AKIDEXAMPLE",
+		S3_SECRET_ACCESS_KEY: "secret",
+	})
+}
+
+func TestS3PresignUpload(t *testing.T) {
+	presigned, err := newTestS3Provider().PresignUpload(context.Background(), "pdf/2024/10/1/abc", "application/pdf", 10*time.Minute)
+	assert.NoError(t, err)
+
+	parsed, err := url.Parse(presigned.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodPut, presigned.Method)
+	assert.Contains(t, parsed.Path, "pdf/2024/10/1/abc")
+	assert.Equal(t, "600", parsed.Query().Get("X-Amz-Expires"))
+	assert.NotEmpty(t, parsed.Query().Get("X-Amz-Signature"))
+	assert.Equal(t, "application/pdf", presigned.Headers["Content-Type"], "the content type must be sent by the client")
+	assert.WithinDuration(t, time.Now().Add(10*time.Minute), presigned.ExpiresAt, time.Minute)
+}
+
+func TestS3PresignDownload(t *testing.T) {
+	presigned, err := newTestS3Provider().PresignDownload(context.Background(), "pdf/2024/10/1/abc", "relatório.pdf", 5*time.Minute)
+	assert.NoError(t, err)
+
+	parsed, err := url.Parse(presigned.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodGet, presigned.Method)
+	assert.Equal(t, "300", parsed.Query().Get("X-Amz-Expires"))
+	assert.Equal(t, "attachment; filename*=utf-8''relat%C3%B3rio.pdf", parsed.Query().Get("response-content-disposition"))
+}
+
+func TestS3PresignUploadWithEncryption(t *testing.T) {
+	provider := NewS3StorageProvider(&configs.AppConfig{
+		S3_BUCKET_NAME:       "bucket",
+		S3_REGION:            "us-east-1",
+		S3_ACCESS_KEY_ID:     "AKIDEXAMPLE",
+		S3_SECRET_ACCESS_KEY: "secret",
+		S3Encryption:         "aws:kms",
+		S3KMSKeyID:           "key-1",
+	})
+
+	presigned, err := provider.PresignUpload(context.Background(), "pdf/2024/10/1/abc", "application/pdf", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "aws:kms", presigned.Headers["X-Amz-Server-Side-Encryption"], "the encryption headers are signed, so the client must send them")
+	assert.Equal(t, "key-1", presigned.Headers["X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"])
+}
+
+func TestS3PresignWithPathStyleEndpoint(t *testing.T) {
+	provider := NewS3StorageProvider(&configs.AppConfig{
+		S3_BUCKET_NAME:       "bucket",
+		S3_REGION:            "us-east-1",
+		S3_ACCESS_KEY_ID:     "minio",
+		S3_SECRET_ACCESS_KEY: "minio123",
+		S3Endpoint:           "http://localhost:9000/",
+		S3UsePathStyle:       true,
+	})
+
+	presigned, err := provider.PresignDownload(context.Background(), "pdf/2024/10/1/abc", "report.pdf", time.Minute)
+	assert.NoError(t, err)
+
+	parsed, err := url.Parse(presigned.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:9000", parsed.Host)
+	assert.Equal(t, "/bucket/pdf/2024/10/1/abc", parsed.Path)
+}
+
+func TestS3ObjectURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   configs.AppConfig
+		expected string
+	}{
+		{"virtual host", configs.AppConfig{}, "https://bucket.s3.amazonaws.com/pdf/abc"},
+		{"path style", configs.AppConfig{S3UsePathStyle: true}, "https://s3.sa-east-1.amazonaws.com/bucket/pdf/abc"},
+		{"endpoint", configs.AppConfig{S3Endpoint: "https://storage.example.com"}, "https://bucket.storage.example.com/pdf/abc"},
+		{"path style endpoint", configs.AppConfig{S3Endpoint: "http://localhost:9000", S3UsePathStyle: true}, "http://localhost:9000/bucket/pdf/abc"},
+		{"public url", configs.AppConfig{S3Endpoint: "http://localhost:9000", S3PublicURL: "https://cdn.example.com/files/"}, "https://cdn.example.com/files/pdf/abc"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := test.config
+			config.S3_BUCKET_NAME = "bucket"
+			config.S3_REGION = "sa-east-1"
+			assert.Equal(t, test.expected, NewS3StorageProvider(&config).ObjectURL("pdf/abc"))
+		})
+	}
+}
+
+func TestBuildKey(t *testing.T) {
+	now := time.Date(2024, time.October, 1, 12, 0, 0, 0, time.UTC)
+
+	key, folder, fileType := BuildKey("abc", "Report.PDF", now)
+	assert.Equal(t, "pdf/2024/10/1/abc", key)
+	assert.Equal(t, "pdf", folder)
+	assert.Equal(t, "pdf", fileType)
+
+	key, _, fileType = BuildKey("abc", "README", now)
+	assert.Equal(t, "others/2024/10/1/abc", key)
+	assert.Equal(t, "others", fileType)
+}
@@ -0,0 +1,23 @@
+package storages
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BuildKey returns the key of a new object following the type/year/month/day/name
+// layout, together with its folder and file type, both taken from the extension
+// of the original file name
+func BuildKey(fileName string, originalFileName string, now time.Time) (key string, folder string, fileType string) {
+	fileType = strings.TrimPrefix(strings.ToLower(filepath.Ext(originalFileName)), ".")
+	if fileType == "" {
+		fileType = "others"
+	}
+
+	folder = fileType
+	year, month, day := now.Date()
+
+	return fmt.Sprintf("%s/%d/%d/%d/%s", folder, year, month, day, fileName), folder, fileType
+}
@@ -1,13 +1,58 @@
 package storages
 
-import "mime/multipart"
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
 
-type UploadDto struct {
-	FileName         string         `json:"file_name"`          // File name of the attachment
-	OriginalFileName string         `json:"original_file_name"` // Original file name of the attachment
-	FileStream       multipart.File // File stream of the attachment
+// ErrObjectNotFound is returned when no object is stored under the given key
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"content_type"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Object is a stored object being downloaded. Body must be closed by the caller
+type Object struct {
+	ObjectInfo
+	Body io.ReadCloser
+}
+
+// PresignedURL is a time limited URL granting direct access to an object. The
+// client must send Headers along with the request
+type PresignedURL struct {
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at"`
 }
 
 type StorageProvider interface {
-	Upload(email UploadDto) (interface{}, error)
+	Put(ctx context.Context, key string, body io.ReadSeeker, contentType string) error
+	Download(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	ObjectURL(key string) string
+	PresignUpload(ctx context.Context, key string, contentType string, expires time.Duration) (PresignedURL, error)
+	PresignDownload(ctx context.Context, key string, fileName string, expires time.Duration) (PresignedURL, error)
+}
+
+// cancelOnClose releases the context of a download once its body is closed, as the
+// body is read after the provider call returns
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
 }
@@ -0,0 +1,146 @@
+package textextract
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ErrUnsupported is returned for content types text cannot be extracted from
+var ErrUnsupported = errors.New("text extraction is not supported for this type")
+
+// MaxLength is the maximum number of bytes of text extracted from a file
+const MaxLength = 1 << 20
+
+// maxEntrySize bounds the uncompressed size of an archive entry read for text
+const maxEntrySize = 64 << 20
+
+// documentEntries lists, per MIME type of the zip based office formats, the
+// archive entries holding the text
+var documentEntries = map[string]func(name string) bool{
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": func(name string) bool {
+		return name == "word/document.xml"
+	},
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": func(name string) bool {
+		return strings.HasPrefix(name, "ppt/slides/slide") && path.Ext(name) == ".xml"
+	},
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": func(name string) bool {
+		return name == "xl/sharedStrings.xml"
+	},
+	"application/vnd.oasis.opendocument.text": func(name string) bool {
+		return name == "content.xml"
+	},
+	"application/vnd.oasis.opendocument.spreadsheet": func(name string) bool {
+		return name == "content.xml"
+	},
+}
+
+// Supported reports whether text can be extracted from the MIME type
+func Supported(mimeType string) bool {
+	_, document := documentEntries[mimeType]
+	return document || strings.HasPrefix(mimeType, "text/")
+}
+
+// Extract returns the text of plain text files and of the zip based office
+// documents (DOCX, PPTX, XLSX, ODT and ODS), truncated to MaxLength bytes
+func Extract(mimeType string, content io.ReaderAt, size int64) (string, error) {
+	if strings.HasPrefix(mimeType, "text/") {
+		return extractPlainText(io.NewSectionReader(content, 0, size))
+	}
+
+	match, ok := documentEntries[mimeType]
+	if !ok {
+		return "", ErrUnsupported
+	}
+	return extractDocument(content, size, match)
+}
+
+func extractPlainText(content io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(content, MaxLength))
+	if err != nil {
+		return "", fmt.Errorf("failed to read text: %w", err)
+	}
+	// PostgreSQL text cannot hold NUL characters
+	text := strings.ReplaceAll(strings.ToValidUTF8(string(data), ""), "\x00", "")
+	return truncate(text), nil
+}
+
+// extractDocument collects the character data of the XML entries of an office document
+func extractDocument(content io.ReaderAt, size int64, match func(name string) bool) (string, error) {
+	archive, err := zip.NewReader(content, size)
+	if err != nil {
+		return "", fmt.Errorf("failed to open document: %w", err)
+	}
+
+	var entries []*zip.File
+	for _, entry := range archive.File {
+		if match(entry.Name) {
+			entries = append(entries, entry)
+		}
+	}
+	// slides are numbered without padding, slide10 must come after slide9
+	slices.SortFunc(entries, func(a, b *zip.File) int {
+		if len(a.Name) != len(b.Name) {
+			return len(a.Name) - len(b.Name)
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	var text strings.Builder
+	for _, entry := range entries {
+		if err := extractXMLText(&text, entry); err != nil {
+			return "", err
+		}
+		if text.Len() >= MaxLength {
+			break
+		}
+	}
+	return truncate(strings.TrimSpace(text.String())), nil
+}
+
+// extractXMLText appends the character data of an XML entry, ending each
+// paragraph, row or cell with a line break
+func extractXMLText(text *strings.Builder, entry *zip.File) error {
+	reader, err := entry.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", entry.Name, err)
+	}
+	defer reader.Close()
+
+	decoder := xml.NewDecoder(io.LimitReader(reader, maxEntrySize))
+	for text.Len() < MaxLength {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", entry.Name, err)
+		}
+
+		switch token := token.(type) {
+		case xml.CharData:
+			text.Write(token)
+		case xml.EndElement:
+			switch token.Name.Local {
+			case "p", "h", "si", "table-row":
+				text.WriteByte('\n')
+			case "tab", "table-cell":
+				text.WriteByte('\t')
+			}
+		}
+	}
+	return nil
+}
+
+// truncate cuts text to MaxLength bytes without splitting a character
+func truncate(text string) string {
+	if len(text) <= MaxLength {
+		return text
+	}
+	return strings.ToValidUTF8(text[:MaxLength], "")
+}
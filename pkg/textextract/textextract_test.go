@@ -0,0 +1,59 @@
+package textextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const docxType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+func document(t *testing.T, entries map[string]string) *bytes.Reader {
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	for name, content := range entries {
+		entry, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create entry: %v", err)
+		}
+		entry.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("failed to close archive: %v", err)
+	}
+	return bytes.NewReader(buffer.Bytes())
+}
+
+func TestExtract_Document(t *testing.T) {
+	content := document(t, map[string]string{
+		"word/document.xml": `<w:document xmlns:w="w"><w:body><w:p><w:r><w:t>Quarterly</w:t></w:r><w:r><w:t xml:space="preserve"> report</w:t></w:r></w:p><w:p><w:r><w:t>Revenue grew</w:t></w:r></w:p></w:body></w:document>`,
+		"word/styles.xml":   `<w:styles xmlns:w="w"><w:p><w:t>ignored</w:t></w:p></w:styles>`,
+	})
+
+	text, err := Extract(docxType, content, content.Size())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Quarterly report\nRevenue grew", text)
+}
+
+func TestExtract_PlainText(t *testing.T) {
+	content := strings.NewReader(strings.Repeat("é", MaxLength))
+
+	text, err := Extract("text/plain", content, content.Size())
+
+	assert.NoError(t, err)
+	assert.Equal(t, MaxLength, len(text))
+}
+
+func TestExtract_Unsupported(t *testing.T) {
+	content := strings.NewReader("%PDF-1.7")
+
+	_, err := Extract("application/pdf", content, content.Size())
+
+	assert.ErrorIs(t, err, ErrUnsupported)
+	assert.False(t, Supported("application/pdf"))
+	assert.True(t, Supported(docxType))
+}